@@ -0,0 +1,177 @@
+// Package bind converts Go values to Lisp values and back using reflection, so Go
+// structs can be used from Lisp without hand-written MarshalHashMap methods and
+// factories (see marshaler).
+//
+// Structs are converted to hash maps keyed by keywords. The key of each exported field
+// is set with the lisp struct tag, or derived from the field name in kebab case
+// (ServerPort becomes :server-port):
+//
+//	type Server struct {
+//		Host    string        `lisp:"host"`
+//		Port    int           `lisp:"port,omitempty"`
+//		Timeout time.Duration `lisp:"-"` // ignored
+//	}
+//
+// The omitempty option omits the field from the hash map if it has its zero value.
+// The default option sets the value (read as Lisp) of a field that is still zero after
+// converting a hash map without its key (e.g. `lisp:"port,default=8080"`; the value
+// cannot contain commas). The required option makes a hash map without the key of the
+// field an error (a [KeyError]). Fields of embedded structs without a tag are promoted,
+// as in encoding/json.
+//
+// Other conversions:
+//   - slices and arrays are vectors ([]byte is kept as is);
+//   - maps are hash maps with string keys (keywords are accepted when converting back);
+//   - pointers are converted to the value they point to (nil to nil);
+//   - integers are int, floating point numbers are float64 (float32 and int are accepted
+//     when converting back);
+//   - values implementing encoding.TextMarshaler (e.g. time.Time) are strings;
+//   - time.Duration values are strings as "1m30s";
+//   - values implementing marshaler.HashMap are converted with MarshalHashMap;
+//   - values implementing [Marshaler] and [Unmarshaler] convert themselves to and from
+//     Lisp values;
+//   - Lisp values (e.g. HashMap or fields of type MalType) are kept as they are.
+package bind
+
+import (
+	"encoding"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/jig/lisp/marshaler"
+	. "github.com/jig/lisp/types"
+)
+
+// Mode sets how hash map keys without a matching struct field are handled when
+// converting Lisp values to Go structs
+type Mode int
+
+const (
+	Lenient Mode = iota // unknown keys are ignored
+	Strict              // unknown keys are errors
+)
+
+var (
+	textMarshalerType   = reflect.TypeFor[encoding.TextMarshaler]()
+	textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+	marshalerType       = reflect.TypeFor[Marshaler]()
+	unmarshalerType     = reflect.TypeFor[Unmarshaler]()
+	durationType        = reflect.TypeFor[time.Duration]()
+	hashMapMarshalType  = reflect.TypeFor[marshaler.HashMap]()
+	malTypes            = map[reflect.Type]bool{
+		reflect.TypeFor[List]():    true,
+		reflect.TypeFor[Vector]():  true,
+		reflect.TypeFor[HashMap](): true,
+		reflect.TypeFor[Set]():     true,
+		reflect.TypeFor[Symbol]():  true,
+		reflect.TypeFor[Func]():    true,
+		reflect.TypeFor[MalFunc](): true,
+	}
+)
+
+// Marshaler is implemented by types that convert themselves to Lisp values
+type Marshaler interface {
+	MarshalLisp() (MalType, error)
+}
+
+// Unmarshaler is implemented by types that convert themselves from Lisp values (that
+// might be nil)
+type Unmarshaler interface {
+	UnmarshalLisp(MalType) error
+}
+
+// field is an exported struct field as seen from Lisp
+type field struct {
+	key       Keyword
+	index     []int // as reflect.Value.FieldByIndex
+	omitEmpty bool
+	required  bool
+	def       *string  // default value (Lisp source)
+	options   []string // other tag options
+}
+
+// fieldName returns the name of the field of the given Lisp key
+func (f field) name() string {
+	return f.key.Name()
+}
+
+var fieldsCache sync.Map // reflect.Type → []field
+
+// fields returns the fields of a struct type in declaration order
+func fields(t reflect.Type) []field {
+	if cached, ok := fieldsCache.Load(t); ok {
+		return cached.([]field)
+	}
+	result := collectFields(t, nil)
+	fieldsCache.Store(t, result)
+	return result
+}
+
+func collectFields(t reflect.Type, index []int) []field {
+	result := []field{}
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag, hasTag := sf.Tag.Lookup("lisp")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		fieldIndex := append(append([]int{}, index...), i)
+		if sf.Anonymous && name == "" && sf.Type.Kind() == reflect.Struct {
+			result = append(result, collectFields(sf.Type, fieldIndex)...)
+			continue
+		}
+		if !sf.IsExported() {
+			continue
+		}
+		if !hasTag || name == "" {
+			name = kebab(sf.Name)
+		}
+		f := field{key: NewKeyword(name), index: fieldIndex}
+		if opts != "" {
+			for _, opt := range strings.Split(opts, ",") {
+				if opt == "omitempty" {
+					f.omitEmpty = true
+				} else if opt == "required" {
+					f.required = true
+				} else if def, ok := strings.CutPrefix(opt, "default="); ok {
+					f.def = &def
+				} else {
+					f.options = append(f.options, opt)
+				}
+			}
+		}
+		result = append(result, f)
+	}
+	return result
+}
+
+// Keys returns the hash map keys of the fields of the struct type t in declaration order
+func Keys(t reflect.Type) []Keyword {
+	keys := []Keyword{}
+	for _, f := range fields(t) {
+		keys = append(keys, f.key)
+	}
+	return keys
+}
+
+// kebab converts a Go identifier to kebab case (e.g. HTTPServerPort to http-server-port)
+func kebab(name string) string {
+	runes := []rune(name)
+	var sb strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			if i > 0 && (unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1]) ||
+				i+1 < len(runes) && unicode.IsLower(runes[i+1]) && unicode.IsUpper(runes[i-1])) {
+				sb.WriteRune('-')
+			}
+			sb.WriteRune(unicode.ToLower(r))
+		} else {
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,209 @@
+package bind_test
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jig/lisp"
+	"github.com/jig/lisp/bind"
+	"github.com/jig/lisp/env"
+	"github.com/jig/lisp/types"
+)
+
+type Base struct {
+	ID string `lisp:"id"`
+}
+
+type Server struct {
+	Host string   `lisp:"host"`
+	Port int      `lisp:"port,omitempty"`
+	Tags []string `lisp:"tags,omitempty"`
+}
+
+type Config struct {
+	Base
+	Name       string
+	MaxRetries uint8
+	Ratio      float64
+	Enabled    bool
+	Server     Server
+	Backup     *Server
+	Servers    []Server
+	Labels     map[string]string
+	Started    time.Time
+	Wait       time.Duration
+	Extra      any
+	Secret     string `lisp:"-"`
+	hidden     int
+}
+
+func read(t *testing.T, code string) types.MalType {
+	t.Helper()
+	ast, err := lisp.READ(code, types.NewCursorFile("config.lisp"), env.NewEnv())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ast
+}
+
+func TestRoundTrip(t *testing.T) {
+	started := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	cfg := Config{
+		Base:       Base{ID: "a1"},
+		Name:       "main",
+		MaxRetries: 3,
+		Ratio:      0.5,
+		Enabled:    true,
+		Server:     Server{Host: "localhost"},
+		Backup:     &Server{Host: "backup", Port: 8081, Tags: []string{"b"}},
+		Servers:    []Server{{Host: "x", Port: 1}, {Host: "y", Port: 2}},
+		Labels:     map[string]string{"env": "prod"},
+		Started:    started,
+		Wait:       90 * time.Second,
+		Extra:      types.Vector{Val: []types.MalType{1, 2}},
+		Secret:     "s",
+		hidden:     1,
+	}
+	data, err := bind.ToLisp(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hm := data.(types.HashMap)
+	for key, expected := range map[string]types.MalType{
+		"id":          "a1",
+		"name":        "main",
+		"max-retries": 3,
+		"enabled":     true,
+		"started":     "2026-01-02T03:04:05Z",
+		"wait":        "1m30s",
+	} {
+		if got := hm.Val[types.NewKeyword(key)]; got != expected {
+			t.Errorf(":%s: got %v, expected %v", key, got, expected)
+		}
+	}
+	if _, ok := hm.Val[types.NewKeyword("secret")]; ok {
+		t.Error(`field tagged "-" must be omitted`)
+	}
+	server := hm.Val[types.NewKeyword("server")].(types.HashMap)
+	if _, ok := server.Val[types.NewKeyword("port")]; ok {
+		t.Error("empty field with omitempty must be omitted")
+	}
+
+	var back Config
+	if err := bind.FromLisp(data, &back, bind.Strict); err != nil {
+		t.Fatal(err)
+	}
+	cfg.Secret, cfg.hidden = "", 0
+	if !reflect.DeepEqual(back, cfg) {
+		t.Fatalf("got %+v, expected %+v", back, cfg)
+	}
+}
+
+func TestFromLisp(t *testing.T) {
+	var cfg Config
+	err := bind.FromLisp(read(t, `{:name :main
+		:ratio 2
+		:server {"host" "localhost" :port 80}
+		:servers ({:host "x"})
+		:labels {:env "prod"}
+		:backup nil
+		:extra {:a 1}}`), &cfg, bind.Lenient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Name != "main" || cfg.Ratio != 2 || !reflect.DeepEqual(cfg.Server, Server{Host: "localhost", Port: 80}) ||
+		len(cfg.Servers) != 1 || cfg.Servers[0].Host != "x" || cfg.Labels["env"] != "prod" || cfg.Backup != nil {
+		t.Fatalf("unexpected %+v", cfg)
+	}
+	if _, ok := cfg.Extra.(types.HashMap); !ok {
+		t.Fatalf("expected a HashMap, got %T", cfg.Extra)
+	}
+}
+
+func TestUnknownKeys(t *testing.T) {
+	data := read(t, `{:host "h" :colour "blue"}`)
+	var server Server
+	if err := bind.FromLisp(data, &server, bind.Lenient); err != nil {
+		t.Fatal(err)
+	}
+	if server.Host != "h" {
+		t.Fatalf("unexpected %+v", server)
+	}
+	err := bind.FromLisp(data, &server, bind.Strict)
+	if err == nil || !strings.Contains(err.Error(), "unknown key :colour") {
+		t.Fatalf("unexpected error %v", err)
+	}
+	var keyErr *bind.KeyError
+	if !errors.As(err, &keyErr) || keyErr.Key != types.NewKeyword("colour") || keyErr.Missing {
+		t.Fatalf("unexpected error %#v", err)
+	}
+}
+
+func TestRequiredKeys(t *testing.T) {
+	type login struct {
+		User     string `lisp:"user,required"`
+		Password string `lisp:"password,required"`
+	}
+	var l login
+	if err := bind.FromLisp(read(t, `{:user "u" :password "p"}`), &l, bind.Lenient); err != nil {
+		t.Fatal(err)
+	}
+	err := bind.FromLisp(read(t, `{:user "u"}`), &l, bind.Lenient)
+	var keyErr *bind.KeyError
+	if !errors.As(err, &keyErr) || !keyErr.Missing || err.Error() != "config.lisp:1:1: missing required key :password" {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	for _, tc := range []struct {
+		code, expected string
+	}{
+		{`{:server {:port "80"}}`, `config.lisp:1:10: .server.port: expected int, got string`},
+		{"{:servers [{:host \"x\"}\n {:host 1}]}", `config.lisp:2:2: .servers[1].host: expected string, got int`},
+		{`{:max-retries 300}`, `config.lisp:1:1: .max-retries: 300 overflows uint8`},
+		{`{:labels [1]}`, `config.lisp:1:10: .labels: expected map, got vector`},
+		{`{:started "yesterday"}`, `.started: parsing time`},
+	} {
+		var cfg Config
+		err := bind.FromLisp(read(t, tc.code), &cfg, bind.Strict)
+		var bindErr *bind.Error
+		if !errors.As(err, &bindErr) {
+			t.Fatalf("%s: expected a *bind.Error, got %v", tc.code, err)
+		}
+		if !strings.Contains(err.Error(), tc.expected) {
+			t.Errorf("%s: got %q, expected %q", tc.code, err, tc.expected)
+		}
+	}
+	if _, err := bind.ToLisp(map[int]string{1: "a"}); err == nil {
+		t.Error("expected an error on non string map keys")
+	}
+	if _, err := bind.ToLisp(struct{ Size uint64 }{math.MaxUint64}); err == nil || !strings.Contains(err.Error(), ".size: 18446744073709551615 overflows int") {
+		t.Errorf("expected an overflow error, got %v", err)
+	}
+	if err := bind.FromLisp(1, Config{}, bind.Strict); err == nil {
+		t.Error("expected an error on non pointer")
+	}
+}
+
+func TestKeyNames(t *testing.T) {
+	type names struct {
+		HTTPServerPort int
+		UserID         int
+		Plain          int
+		V2Api          int
+	}
+	data, err := bind.ToLisp(names{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"http-server-port", "user-id", "plain", "v2-api"} {
+		if _, ok := data.(types.HashMap).Val[types.NewKeyword(key)]; !ok {
+			t.Errorf("missing :%s on %v", key, data)
+		}
+	}
+}
@@ -0,0 +1,323 @@
+package bind
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/jig/lisp/reader"
+	. "github.com/jig/lisp/types"
+)
+
+// FromLisp converts a Lisp value (e.g. a hash map read from a configuration file) to
+// the Go value pointed by v. Struct fields without a matching key are left unchanged.
+func FromLisp(data MalType, v any, mode Mode) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return fmt.Errorf("bind: FromLisp requires a non nil pointer (got %T)", v)
+	}
+	d := decoder{mode: mode}
+	return d.decode(data, rv.Elem(), "", nil)
+}
+
+type decoder struct {
+	mode Mode
+}
+
+// position returns the position of data if it is a collection read with a position,
+// else the position of the enclosing collection
+func position(data MalType, enclosing *Position) *Position {
+	var cursor *Position
+	switch data := data.(type) {
+	case List:
+		cursor = data.Cursor
+	case Vector:
+		cursor = data.Cursor
+	case HashMap:
+		cursor = data.Cursor
+	case Set:
+		cursor = data.Cursor
+	}
+	if cursor != nil {
+		return cursor
+	}
+	return enclosing
+}
+
+func (d *decoder) fail(path string, pos *Position, err error) error {
+	return &Error{Path: path, Position: pos, Err: err}
+}
+
+func (d *decoder) decode(data MalType, v reflect.Value, path string, pos *Position) error {
+	pos = position(data, pos)
+	t := v.Type()
+
+	if data != nil && (malTypes[t] || t.Kind() == reflect.Interface || t.Kind() == reflect.Struct) && reflect.TypeOf(data).AssignableTo(t) {
+		// Lisp values, fields of type any and Go structs stored on Lisp
+		v.Set(reflect.ValueOf(data))
+		return nil
+	}
+	if data == nil {
+		switch t.Kind() {
+		case reflect.Pointer, reflect.Interface, reflect.Slice, reflect.Map:
+			v.Set(reflect.Zero(t))
+			return nil
+		}
+		if malTypes[t] {
+			v.Set(reflect.Zero(t))
+			return nil
+		}
+	}
+	if t.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			v.Set(reflect.New(t.Elem()))
+		}
+		return d.decode(data, v.Elem(), path, pos)
+	}
+	if reflect.PointerTo(t).Implements(unmarshalerType) && v.CanAddr() {
+		if err := v.Addr().Interface().(Unmarshaler).UnmarshalLisp(data); err != nil {
+			var inner *Error
+			if errors.As(err, &inner) {
+				// the error of a nested conversion is relative to this value
+				if inner.Position != nil {
+					pos = inner.Position
+				}
+				return d.fail(path+inner.Path, pos, inner.Err)
+			}
+			return d.fail(path, pos, err)
+		}
+		return nil
+	}
+	if reflect.PointerTo(t).Implements(textUnmarshalerType) && v.CanAddr() {
+		s, ok := data.(string)
+		if !ok {
+			return d.fail(path, pos, &TypeError{Expected: reflect.TypeFor[string](), Value: data})
+		}
+		if err := v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s)); err != nil {
+			return d.fail(path, pos, err)
+		}
+		return nil
+	}
+	if t == durationType {
+		s, ok := data.(string)
+		if !ok {
+			return d.fail(path, pos, &TypeError{Expected: t, Value: data})
+		}
+		duration, err := time.ParseDuration(s)
+		if err != nil {
+			return d.fail(path, pos, err)
+		}
+		v.SetInt(int64(duration))
+		return nil
+	}
+	typeError := func() error {
+		return d.fail(path, pos, &TypeError{Expected: t, Value: data})
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		b, ok := data.(bool)
+		if !ok {
+			return typeError()
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, ok := data.(int)
+		if !ok {
+			return typeError()
+		}
+		if v.OverflowInt(int64(n)) {
+			return d.fail(path, pos, fmt.Errorf("%d overflows %s", n, t))
+		}
+		v.SetInt(int64(n))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n, ok := data.(int)
+		if !ok {
+			return typeError()
+		}
+		if n < 0 || v.OverflowUint(uint64(n)) {
+			return d.fail(path, pos, fmt.Errorf("%d overflows %s", n, t))
+		}
+		v.SetUint(uint64(n))
+	case reflect.Float32, reflect.Float64:
+		switch n := data.(type) {
+		case int:
+			v.SetFloat(float64(n))
+		case float32:
+			// the reader reads float32 numbers: use the shortest decimal representation
+			// so 0.1 is read as 0.1 and not as 0.10000000149011612
+			f, _ := strconv.ParseFloat(strconv.FormatFloat(float64(n), 'g', -1, 32), 64)
+			v.SetFloat(f)
+		case float64:
+			v.SetFloat(n)
+		default:
+			return typeError()
+		}
+	case reflect.String:
+		switch s := data.(type) {
+		case string:
+			v.SetString(s)
+		case Keyword:
+			v.SetString(s.Name())
+		default:
+			return typeError()
+		}
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			switch b := data.(type) {
+			case []byte:
+				v.SetBytes(append([]byte{}, b...))
+				return nil
+			case string:
+				v.SetBytes([]byte(b))
+				return nil
+			}
+		}
+		items, err := GetSlice(data)
+		if err != nil {
+			return typeError()
+		}
+		slice := reflect.MakeSlice(t, len(items), len(items))
+		for i, item := range items {
+			if err := d.decode(item, slice.Index(i), fmt.Sprintf("%s[%d]", path, i), pos); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+	case reflect.Array:
+		items, err := GetSlice(data)
+		if err != nil {
+			return typeError()
+		}
+		if len(items) != t.Len() {
+			return d.fail(path, pos, fmt.Errorf("expected %d items, got %d", t.Len(), len(items)))
+		}
+		for i, item := range items {
+			if err := d.decode(item, v.Index(i), fmt.Sprintf("%s[%d]", path, i), pos); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		hm, ok := data.(HashMap)
+		if !ok {
+			return typeError()
+		}
+		if t.Key().Kind() != reflect.String {
+			return d.fail(path, pos, fmt.Errorf("unsupported map key type %s (only string keys are supported)", t.Key()))
+		}
+		m := reflect.MakeMapWithSize(t, len(hm.Val))
+		for _, key := range SortedKeys(hm.Val) {
+			name := KeyName(key)
+			value := reflect.New(t.Elem()).Elem()
+			if err := d.decode(hm.Val[key], value, fmt.Sprintf("%s[%q]", path, name), pos); err != nil {
+				return err
+			}
+			m.SetMapIndex(reflect.ValueOf(name).Convert(t.Key()), value)
+		}
+		v.Set(m)
+	case reflect.Struct:
+		hm, ok := data.(HashMap)
+		if !ok {
+			return typeError()
+		}
+		return d.decodeStruct(hm, v, path, pos)
+	case reflect.Interface:
+		return typeError()
+	default:
+		return d.fail(path, pos, fmt.Errorf("unsupported type %s", t))
+	}
+	return nil
+}
+
+func (d *decoder) decodeStruct(hm HashMap, v reflect.Value, path string, pos *Position) error {
+	byName := map[string]field{}
+	for _, f := range fields(v.Type()) {
+		byName[f.name()] = f
+	}
+	found := map[string]bool{}
+	for _, key := range SortedKeys(hm.Val) {
+		name := KeyName(key)
+		f, ok := byName[name]
+		if !ok {
+			if d.mode == Strict {
+				return d.fail(path, pos, &KeyError{Key: key})
+			}
+			continue
+		}
+		found[name] = true
+		fv, err := allocFieldByIndex(v, f.index)
+		if err != nil {
+			return d.fail(path, pos, err)
+		}
+		if err := d.decode(hm.Val[key], fv, path+"."+name, pos); err != nil {
+			return err
+		}
+	}
+	for _, f := range fields(v.Type()) {
+		if f.required && !found[f.name()] {
+			return d.fail(path, pos, &KeyError{Key: f.key, Missing: true})
+		}
+	}
+	return d.defaults(v, found, path, pos)
+}
+
+// defaults sets the default values of the zero fields of a struct not found on the
+// hash map (and of the fields of its missing nested structs)
+func (d *decoder) defaults(v reflect.Value, found map[string]bool, path string, pos *Position) error {
+	for _, f := range fields(v.Type()) {
+		if found[f.name()] {
+			continue
+		}
+		fv, ok := fieldByIndex(v, f.index)
+		if !ok || !fv.CanSet() {
+			continue
+		}
+		fieldPath := path + "." + f.name()
+		if f.def == nil {
+			if fv.Kind() == reflect.Struct && !malTypes[fv.Type()] {
+				if err := d.defaults(fv, nil, fieldPath, pos); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if !fv.IsZero() {
+			continue
+		}
+		def, err := reader.Read_str(*f.def, nil, nil)
+		if err != nil {
+			return d.fail(fieldPath, pos, fmt.Errorf("invalid default %q: %w", *f.def, err))
+		}
+		if err := d.decode(def, fv, fieldPath, pos); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// allocFieldByIndex returns a (maybe promoted) field allocating nil embedded pointers
+func allocFieldByIndex(v reflect.Value, index []int) (reflect.Value, error) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				if !v.CanSet() {
+					return reflect.Value{}, errors.New("cannot set embedded pointer to unexported struct")
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, nil
+}
+
+func printKey(key MalType) string {
+	if k, ok := key.(Keyword); ok {
+		return k.String()
+	}
+	return fmt.Sprintf("%q", key)
+}
@@ -0,0 +1,142 @@
+package bind
+
+import (
+	"encoding"
+	"fmt"
+	"math"
+	"reflect"
+	"time"
+
+	"github.com/jig/lisp/marshaler"
+	. "github.com/jig/lisp/types"
+)
+
+// ToLisp converts a Go value to a Lisp value
+func ToLisp(v any) (MalType, error) {
+	if v == nil {
+		return nil, nil
+	}
+	return toLisp(reflect.ValueOf(v), "")
+}
+
+func toLisp(v reflect.Value, path string) (MalType, error) {
+	if !v.IsValid() {
+		return nil, nil
+	}
+	t := v.Type()
+	if malTypes[t] {
+		return v.Interface(), nil
+	}
+	if t.Implements(marshalerType) && !isNilPointer(v) {
+		value, err := v.Interface().(Marshaler).MarshalLisp()
+		if err != nil {
+			return nil, &Error{Path: path, Err: err}
+		}
+		return value, nil
+	}
+	if t.Implements(hashMapMarshalType) && !isNilPointer(v) {
+		return v.Interface().(marshaler.HashMap).MarshalHashMap()
+	}
+	if t.Implements(textMarshalerType) && !isNilPointer(v) {
+		text, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+		if err != nil {
+			return nil, &Error{Path: path, Err: err}
+		}
+		return string(text), nil
+	}
+	if t == durationType {
+		return time.Duration(v.Int()).String(), nil
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return v.Bool(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return int(v.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if v.Uint() > math.MaxInt {
+			return nil, &Error{Path: path, Err: fmt.Errorf("%d overflows int", v.Uint())}
+		}
+		return int(v.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), nil
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Interface, reflect.Pointer:
+		if v.IsNil() {
+			return nil, nil
+		}
+		return toLisp(v.Elem(), path)
+	case reflect.Slice:
+		if v.IsNil() {
+			return nil, nil
+		}
+		if t.Elem().Kind() == reflect.Uint8 {
+			return v.Bytes(), nil
+		}
+		fallthrough
+	case reflect.Array:
+		items := make([]MalType, v.Len())
+		for i := range items {
+			item, err := toLisp(v.Index(i), fmt.Sprintf("%s[%d]", path, i))
+			if err != nil {
+				return nil, err
+			}
+			items[i] = item
+		}
+		return Vector{Val: items}, nil
+	case reflect.Map:
+		if v.IsNil() {
+			return nil, nil
+		}
+		if t.Key().Kind() != reflect.String {
+			return nil, &Error{Path: path, Err: fmt.Errorf("unsupported map key type %s (only string keys are supported)", t.Key())}
+		}
+		hm := HashMap{Val: make(map[MalType]MalType, v.Len())}
+		iter := v.MapRange()
+		for iter.Next() {
+			key := iter.Key().String()
+			value, err := toLisp(iter.Value(), fmt.Sprintf("%s[%q]", path, key))
+			if err != nil {
+				return nil, err
+			}
+			hm.Val[key] = value
+		}
+		return hm, nil
+	case reflect.Struct:
+		hm := HashMap{Val: map[MalType]MalType{}}
+		for _, f := range fields(t) {
+			fv, ok := fieldByIndex(v, f.index)
+			if !ok || f.omitEmpty && fv.IsZero() {
+				continue
+			}
+			value, err := toLisp(fv, path+"."+f.name())
+			if err != nil {
+				return nil, err
+			}
+			hm.Val[f.key] = value
+		}
+		return hm, nil
+	default:
+		return nil, &Error{Path: path, Err: fmt.Errorf("unsupported type %s", t)}
+	}
+}
+
+func isNilPointer(v reflect.Value) bool {
+	return (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) && v.IsNil()
+}
+
+// fieldByIndex returns a (maybe promoted) field without panicking on nil embedded
+// pointers
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
@@ -0,0 +1,108 @@
+package bind
+
+import (
+	"fmt"
+	"reflect"
+
+	. "github.com/jig/lisp/types"
+)
+
+// Error is an error converting a value, located by its path from the root value (e.g.
+// .server.ports[1]) and, when converting from Lisp, by the position of the innermost
+// Lisp collection that includes it
+type Error struct {
+	Path     string
+	Position *Position
+	Err      error
+}
+
+func (e *Error) Error() string {
+	msg := e.Err.Error()
+	if e.Path != "" {
+		msg = e.Path + ": " + msg
+	}
+	if e.Position != nil {
+		msg = positionString(e.Position) + ": " + msg
+	}
+	return msg
+}
+
+// positionString returns the module, row and column of a position (e.g. config.lisp:12:5).
+// The reader sets the column after the opening token of the collection, so the column
+// of the opening token is the previous one.
+func positionString(pos *Position) string {
+	s := fmt.Sprintf("%d:%d", pos.BeginRow, max(pos.BeginCol-1, 1))
+	if pos.Module != nil {
+		s = *pos.Module + ":" + s
+	}
+	return s
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// TypeError is the error of a Lisp value that cannot be converted to a Go type
+type TypeError struct {
+	Expected reflect.Type
+	Value    MalType
+}
+
+func (e *TypeError) Error() string {
+	return fmt.Sprintf("expected %s, got %s", typeName(e.Expected), lispTypeName(e.Value))
+}
+
+// KeyError is the error of a hash map key without a struct field (in Strict mode) or of
+// a required key missing from a hash map
+type KeyError struct {
+	Key     MalType // as on the hash map (usually a keyword)
+	Missing bool
+}
+
+func (e *KeyError) Error() string {
+	if e.Missing {
+		return "missing required key " + printKey(e.Key)
+	}
+	return "unknown key " + printKey(e.Key)
+}
+
+func typeName(t reflect.Type) string {
+	if t.Kind() == reflect.Struct && t.Name() != "" {
+		return "map (" + t.String() + ")"
+	}
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array:
+		return "vector"
+	case reflect.Map:
+		return "map"
+	case reflect.Interface:
+		return "any"
+	}
+	return t.String()
+}
+
+// lispTypeName returns the name of the type of a Lisp value (as type? does)
+func lispTypeName(v MalType) string {
+	switch v := v.(type) {
+	case nil:
+		return "nil"
+	case string:
+		return "string"
+	case Keyword:
+		return "keyword"
+	case List:
+		return "list"
+	case Vector:
+		return "vector"
+	case HashMap:
+		return "map"
+	case Set:
+		return "set"
+	case Symbol:
+		return "symbol"
+	case float32, float64:
+		return "float"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
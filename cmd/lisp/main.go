@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"log"
 	"os"
 
@@ -10,6 +11,8 @@ import (
 	"github.com/jig/lisp/lib/concurrent/nsconcurrent"
 	"github.com/jig/lisp/lib/core/nscore"
 	"github.com/jig/lisp/lib/coreextented/nscoreextended"
+	"github.com/jig/lisp/lib/dispatch/nsdispatch"
+	"github.com/jig/lisp/lib/schema/nsschema"
 	"github.com/jig/lisp/lib/system/nssystem"
 	"github.com/jig/lisp/types"
 )
@@ -26,6 +29,8 @@ func main() {
 		{"command line args", nscore.LoadCmdLineArgs(command.PreParseArgs(os.Args))},
 		{"concurrent", nsconcurrent.Load},
 		{"core mal extended", nscoreextended.Load},
+		{"dispatch", nsdispatch.Load},
+		{"schema", nsschema.Load},
 		{"assert", nsassert.Load},
 		{"system", nssystem.Load},
 	} {
@@ -35,6 +40,7 @@ func main() {
 	}
 
 	if err := command.Execute(os.Args, ns); err != nil {
-		log.Fatalf("Error: %v\n", err)
+		fmt.Fprintln(os.Stderr, command.RenderError(err))
+		os.Exit(1)
 	}
 }
@@ -0,0 +1,329 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/format"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultOutput = "lispbind_gen.go"
+	callPackage   = "github.com/jig/lisp/lib/call"
+	typesPackage  = "github.com/jig/lisp/types"
+)
+
+// Generate returns the source code of the adapters of the package on dir. The output
+// file is not read, so stale adapters do not break the type check.
+func Generate(dir, output string) ([]byte, error) {
+	bp, err := build.ImportDir(dir, 0)
+	if err != nil {
+		return nil, err
+	}
+	fset := token.NewFileSet()
+	files := []*ast.File{}
+	for _, name := range bp.GoFiles {
+		if name == output {
+			continue
+		}
+		f, err := parser.ParseFile(fset, filepath.Join(dir, name), nil, 0)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+	}
+
+	info := &types.Info{
+		Types: map[ast.Expr]types.TypeAndValue{},
+		Uses:  map[*ast.Ident]types.Object{},
+	}
+	conf := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	pkg, err := conf.Check(bp.ImportPath, fset, files, info)
+	if err != nil {
+		return nil, err
+	}
+
+	g := generator{pkg: pkg, imports: map[string]string{"context": "context"}}
+	signatures := map[string]*types.Signature{} // by function type
+	for _, f := range files {
+		ast.Inspect(f, func(n ast.Node) bool {
+			if fn := registeredFunction(n, info); fn != nil {
+				if sig, ok := info.TypeOf(fn).Underlying().(*types.Signature); ok && supported(sig) {
+					signatures[g.funcType(sig)] = sig
+				}
+			}
+			return true
+		})
+	}
+	if len(signatures) == 0 {
+		return nil, errors.New("no functions registered with call.Call or call.CallOverrideFN")
+	}
+
+	keys := make([]string, 0, len(signatures))
+	for key := range signatures {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var adapters bytes.Buffer
+	for _, key := range keys {
+		g.adapter(&adapters, signatures[key])
+	}
+	return g.file(adapters.Bytes())
+}
+
+// registeredFunction returns the function argument of a call to call.Call or
+// call.CallOverrideFN
+func registeredFunction(n ast.Node, info *types.Info) ast.Expr {
+	ce, ok := n.(*ast.CallExpr)
+	if !ok {
+		return nil
+	}
+	sel, ok := ce.Fun.(*ast.SelectorExpr)
+	if !ok {
+		return nil
+	}
+	obj, ok := info.Uses[sel.Sel].(*types.Func)
+	if !ok || obj.Pkg() == nil || obj.Pkg().Path() != callPackage {
+		return nil
+	}
+	switch {
+	case obj.Name() == "Call" && len(ce.Args) >= 2:
+		return ce.Args[1]
+	case obj.Name() == "CallOverrideFN" && len(ce.Args) >= 3:
+		return ce.Args[2]
+	}
+	return nil
+}
+
+var errorType = types.Universe.Lookup("error").Type()
+
+// supported reports whether the adapter of a function type can be generated (others
+// are called with reflection as call.Call does)
+func supported(sig *types.Signature) bool {
+	if sig.TypeParams() != nil || sig.Recv() != nil {
+		return false
+	}
+	if sig.Results().Len() > 0 && sequential(sig.Results().At(0).Type()) {
+		return false
+	}
+	for i := 0; i < sig.Params().Len(); i++ {
+		t := sig.Params().At(i).Type()
+		if sig.Variadic() && i == sig.Params().Len()-1 {
+			t = t.(*types.Slice).Elem()
+		}
+		if sequential(t) {
+			// channels and iterators are tied to the context of the call
+			return false
+		}
+	}
+	if sig.Params().Len() > 0 && !sig.Variadic() && options(sig.Params().At(sig.Params().Len()-1).Type()) {
+		return false
+	}
+	switch sig.Results().Len() {
+	case 0, 1:
+		return true
+	case 2:
+		return types.Identical(sig.Results().At(1).Type(), errorType)
+	default:
+		return false
+	}
+}
+
+// options reports whether t is an options struct (embedding call.Options)
+func options(t types.Type) bool {
+	st, ok := t.Underlying().(*types.Struct)
+	if !ok {
+		return false
+	}
+	for i := 0; i < st.NumFields(); i++ {
+		if f := st.Field(i); f.Embedded() {
+			if named, ok := f.Type().(*types.Named); ok && named.Obj().Pkg() != nil &&
+				named.Obj().Pkg().Path() == callPackage && named.Obj().Name() == "Options" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// sequential reports whether values of type t become lazy sequences when returned and
+// are converted from them when passed (channels that can be received from and iterators)
+func sequential(t types.Type) bool {
+	switch t := t.Underlying().(type) {
+	case *types.Chan:
+		return t.Dir() != types.SendOnly
+	case *types.Signature:
+		if t.Params().Len() != 1 || t.Results().Len() != 0 || t.Variadic() {
+			return false
+		}
+		yield, ok := t.Params().At(0).Type().Underlying().(*types.Signature)
+		return ok && yield.Params().Len() == 1 && yield.Results().Len() == 1 &&
+			types.Identical(yield.Results().At(0).Type().Underlying(), types.Typ[types.Bool])
+	default:
+		return false
+	}
+}
+
+func isContext(t types.Type) bool {
+	named, ok := t.(*types.Named)
+	return ok && named.Obj().Pkg() != nil && named.Obj().Pkg().Path() == "context" && named.Obj().Name() == "Context"
+}
+
+type generator struct {
+	pkg     *types.Package
+	imports map[string]string // path → name
+}
+
+// qualifier names the packages of the types on the generated code, importing them
+func (g *generator) qualifier(p *types.Package) string {
+	if p == g.pkg {
+		return ""
+	}
+	if name, ok := g.imports[p.Path()]; ok {
+		return name
+	}
+	name := p.Name()
+	for i := 2; g.used(name); i++ {
+		name = p.Name() + strconv.Itoa(i)
+	}
+	g.imports[p.Path()] = name
+	return name
+}
+
+func (g *generator) used(name string) bool {
+	for _, used := range g.imports {
+		if used == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *generator) typeString(t types.Type) string {
+	return types.TypeString(t, g.qualifier)
+}
+
+// adapter writes the registration of the adapter of the functions of type sig
+func (g *generator) adapter(w *bytes.Buffer, sig *types.Signature) {
+	typesName := g.qualifier(types.NewPackage(typesPackage, "types"))
+	callName := g.qualifier(types.NewPackage(callPackage, "call"))
+
+	params := sig.Params()
+	args := make([]string, params.Len())
+	ctx := "_"
+	for i := 0; i < params.Len(); i++ {
+		t := params.At(i).Type()
+		switch {
+		case i == 0 && isContext(t):
+			ctx = "ctx"
+			args[i] = "ctx"
+		case sig.Variadic() && i == params.Len()-1:
+			ctx = "ctx"
+			elem := g.typeString(t.(*types.Slice).Elem())
+			args[i] = fmt.Sprintf("%s.Rest[%s](ctx, args, %d)...", callName, elem, g.argIndex(sig, i))
+		default:
+			ctx = "ctx"
+			args[i] = fmt.Sprintf("%s.Arg[%s](ctx, args, %d)", callName, g.typeString(t), g.argIndex(sig, i))
+		}
+	}
+	results := sig.Results()
+	call := "fn(" + strings.Join(args, ", ") + ")"
+
+	fmt.Fprintf(w, "\t%s.Static(func(fn %s) %s.ExternalCall {\n", callName, g.funcType(sig), typesName)
+	fmt.Fprintf(w, "\t\treturn func(%s context.Context, args []%s.MalType) (%s.MalType, error) {\n", ctx, typesName, typesName)
+	switch {
+	case results.Len() == 0:
+		fmt.Fprintf(w, "\t\t\t%s\n\t\t\treturn nil, nil\n", call)
+	case results.Len() == 1 && types.Identical(results.At(0).Type(), errorType):
+		fmt.Fprintf(w, "\t\t\treturn nil, %s\n", call)
+	case results.Len() == 1:
+		fmt.Fprintf(w, "\t\t\treturn %s, nil\n", call)
+	default:
+		fmt.Fprintf(w, "\t\t\treturn %s\n", call)
+	}
+	fmt.Fprintf(w, "\t\t}\n\t})\n")
+}
+
+// funcType returns the type of the functions of signature sig, without parameter names
+func (g *generator) funcType(sig *types.Signature) string {
+	params := make([]string, sig.Params().Len())
+	for i := range params {
+		t := sig.Params().At(i).Type()
+		if sig.Variadic() && i == len(params)-1 {
+			params[i] = "..." + g.typeString(t.(*types.Slice).Elem())
+		} else {
+			params[i] = g.typeString(t)
+		}
+	}
+	results := make([]string, sig.Results().Len())
+	for i := range results {
+		results[i] = g.typeString(sig.Results().At(i).Type())
+	}
+	fnType := "func(" + strings.Join(params, ", ") + ")"
+	switch len(results) {
+	case 0:
+	case 1:
+		fnType += " " + results[0]
+	default:
+		fnType += " (" + strings.Join(results, ", ") + ")"
+	}
+	return fnType
+}
+
+// argIndex returns the index of the Lisp argument of the parameter i
+func (g *generator) argIndex(sig *types.Signature, i int) int {
+	if sig.Params().Len() > 0 && isContext(sig.Params().At(0).Type()) {
+		return i - 1
+	}
+	return i
+}
+
+func isStandard(importPath string) bool {
+	first, _, _ := strings.Cut(importPath, "/")
+	return !strings.Contains(first, ".")
+}
+
+// file returns the formatted source code of the generated file
+func (g *generator) file(adapters []byte) ([]byte, error) {
+	paths := make([]string, 0, len(g.imports))
+	for p := range g.imports {
+		paths = append(paths, p)
+	}
+	// standard library first
+	sort.Slice(paths, func(i, j int) bool {
+		if isStandard(paths[i]) != isStandard(paths[j]) {
+			return isStandard(paths[i])
+		}
+		return paths[i] < paths[j]
+	})
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by lispbind; DO NOT EDIT.\n\npackage %s\n\nimport (\n", g.pkg.Name())
+	for i, p := range paths {
+		if i > 0 && isStandard(paths[i-1]) && !isStandard(p) {
+			buf.WriteString("\n")
+		}
+		if name := g.imports[p]; name != path.Base(p) {
+			fmt.Fprintf(&buf, "\t%s %q\n", name, p)
+		} else {
+			fmt.Fprintf(&buf, "\t%q\n", p)
+		}
+	}
+	fmt.Fprintf(&buf, ")\n\nfunc init() {\n%s}\n", adapters)
+	source, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("formatting generated code: %w\n%s", err, buf.Bytes())
+	}
+	return source, nil
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestGenerated checks that the generated adapters of the lib packages are up to date
+func TestGenerated(t *testing.T) {
+	for _, dir := range []string{"../../lib/core", "../../lib/concurrent", "../../lib/system", "../../lib/dispatch", "../../lib/schema"} {
+		source, err := Generate(dir, defaultOutput)
+		if err != nil {
+			t.Fatalf("%s: %s", dir, err)
+		}
+		committed, err := os.ReadFile(filepath.Join(dir, defaultOutput))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(source, committed) {
+			t.Fatalf("%s: %s is stale, run go generate", dir, defaultOutput)
+		}
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("go.mod", "module example.com/ext\n\nrequire github.com/jig/lisp v0.0.0\n\nreplace github.com/jig/lisp => "+mustAbs(t, "../..")+"\n")
+	write("ext.go", `package ext
+
+import (
+	"context"
+	"time"
+
+	"github.com/jig/lisp/lib/call"
+	"github.com/jig/lisp/types"
+)
+
+func Load(env types.EnvType) {
+	call.Call(env, wait)
+	call.Call(env, sum, 1)
+	call.CallOverrideFN(env, "three", func() (int, string, error) { return 0, "", nil })
+	call.CallOverrideFN(env, "ticks", func(n int) <-chan int { return nil })
+	call.CallOverrideFN(env, "merge", func(chs ...<-chan int) int { return 0 })
+	call.CallOverrideFN(env, "opts", func(n int, _ struct{ call.Options }) int { return n })
+	call.CallOverrideFN(env, "twice", func(ctx context.Context, d time.Duration) error { return nil })
+}
+
+func wait(ctx context.Context, d time.Duration) error { return nil }
+
+func sum(xs ...int) int { return 0 }
+`)
+	// stale output is ignored
+	write(defaultOutput, "package ext\n\nfunc init() { broken() }\n")
+
+	source, err := Generate(dir, defaultOutput)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		"\"context\"\n\t\"time\"\n\n\t\"github.com/jig/lisp/lib/call\"",
+		"call.Static(func(fn func(context.Context, time.Duration) error) types.ExternalCall {",
+		"return nil, fn(ctx, call.Arg[time.Duration](ctx, args, 0))",
+		"call.Static(func(fn func(...int) int) types.ExternalCall {",
+		"return func(ctx context.Context, args []types.MalType) (types.MalType, error) {",
+		"return fn(call.Rest[int](ctx, args, 0)...), nil",
+	} {
+		if !strings.Contains(string(source), expected) {
+			t.Fatalf("expected %q on:\n%s", expected, source)
+		}
+	}
+	if strings.Count(string(source), "call.Static(") != 2 {
+		t.Fatalf("expected 2 adapters (three results, channels and options are not supported):\n%s", source)
+	}
+}
+
+func mustAbs(t *testing.T, path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return abs
+}
@@ -0,0 +1,51 @@
+// Command lispbind generates the adapters of the Go functions that a package registers
+// with call.Call and call.CallOverrideFN, so they are called without reflection.
+//
+// Add the following line to a file of the package and run go generate:
+//
+//	//go:generate go run github.com/jig/lisp/cmd/lispbind
+//
+// The package type checked and every function (or function literal) passed to
+// call.Call or call.CallOverrideFN gets a static adapter for its type, registered with
+// call.Static on package initialisation. Arity checks, argument conversion, error
+// wrapping and _PACKAGES_ registration do not change. Functions whose types are not
+// supported (e.g. generic functions, more than two results, channel and iterator
+// parameters and results or options structs) keep using reflection.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("lispbind: ")
+	output := flag.String("o", defaultOutput, "output file name (relative to the package directory)")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: lispbind [-o file] [directory]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	dir := "."
+	switch flag.NArg() {
+	case 0:
+	case 1:
+		dir = flag.Arg(0)
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	source, err := Generate(dir, *output)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, *output), source, 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
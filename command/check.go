@@ -0,0 +1,69 @@
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/alexflint/go-arg"
+	"github.com/jig/lisp/lint"
+	"github.com/jig/lisp/types"
+)
+
+// checkArgs represents command line arguments of the check subcommand
+type checkArgs struct {
+	JSON  bool     `arg:"--json" help:"print findings as JSON (one array with all findings)"`
+	Files []string `arg:"positional,required" help:"lisp files to check" placeholder:"FILE"`
+}
+
+func (checkArgs) Description() string {
+	return "Analyze Lisp files without running them"
+}
+
+// runCheck implements lisp check FILE...
+func runCheck(cmdArgs []string, repl_env types.EnvType) error {
+	var parsedArgs checkArgs
+	parser, err := arg.NewParser(arg.Config{Program: "lisp check"}, &parsedArgs)
+	if err != nil {
+		return err
+	}
+	err = parser.Parse(cmdArgs)
+	if err == arg.ErrHelp {
+		parser.WriteHelp(os.Stdout)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	findings := []lint.Finding{}
+	for _, fileName := range parsedArgs.Files {
+		fileFindings, err := lint.CheckFile(fileName, repl_env)
+		if err != nil {
+			return err
+		}
+		findings = append(findings, fileFindings...)
+	}
+
+	if parsedArgs.JSON {
+		b, err := json.MarshalIndent(findings, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(b))
+	} else {
+		for _, finding := range findings {
+			fmt.Println(finding)
+		}
+	}
+	errors := 0
+	for _, finding := range findings {
+		if finding.Severity == lint.SeverityError {
+			errors++
+		}
+	}
+	if errors > 0 {
+		return fmt.Errorf("%d error(s) found", errors)
+	}
+	return nil
+}
@@ -10,7 +10,9 @@ import (
 
 	"github.com/alexflint/go-arg"
 	"github.com/jig/lisp"
+	"github.com/jig/lisp/lisperror"
 	"github.com/jig/lisp/repl"
+	"github.com/jig/lisp/typecheck"
 	"github.com/jig/lisp/types"
 )
 
@@ -18,7 +20,8 @@ import (
 type args struct {
 	Version bool     `arg:"-v,--version" help:"show version information"`
 	Test    string   `arg:"-t,--test" help:"run test suite from directory" placeholder:"DIR"`
-	Debug   bool     `arg:"--debug" help:"enable DEBUG-EVAL support (may impact performance)"`
+	Debug   bool     `arg:"--debug" help:"enable DEBUG-EVAL support and Go stacks on errors (may impact performance)"`
+	Assert  bool     `arg:"--assert-types" help:"assert the type hints of the functions defined by defn on their calls"`
 	Eval    string   `arg:"-e,--eval" help:"evaluate expression and exit" placeholder:"EXPR"`
 	Script  string   `arg:"positional" help:"lisp script to execute"`
 	Args    []string `arg:"positional" help:"arguments to pass to the script"`
@@ -28,6 +31,17 @@ func (args) Description() string {
 	return "Lisp interpreter"
 }
 
+// subcommands are the commands run with lisp <subcommand> [args...] instead of a script
+var subcommands = map[string]func(cmdArgs []string, repl_env types.EnvType) error{
+	"check":     runCheck,
+	"dap":       runDAP,
+	"doc":       runDoc,
+	"fmt":       runFmt,
+	"lsp":       runLSP,
+	"nrepl":     runNREPL,
+	"typecheck": runTypecheck,
+}
+
 // PreParseArgs does a preliminary parse of arguments to extract the script arguments
 // before libraries are loaded. This is needed because if LoadCmdLineArgs is used it
 // needs to know the script arguments before the main Execute runs.
@@ -56,6 +70,12 @@ func PreParseArgs(cmdArgs []string) []string {
 // args are usually the os.Args, and repl_env contains the environment filled
 // with the symbols required for the interpreter.
 func Execute(cmdArgs []string, repl_env types.EnvType) error {
+	if len(cmdArgs) > 1 {
+		if subcommand, ok := subcommands[cmdArgs[1]]; ok {
+			return subcommand(cmdArgs[2:], repl_env)
+		}
+	}
+
 	var parsedArgs args
 	parser, err := arg.NewParser(arg.Config{Program: "lisp"}, &parsedArgs)
 	if err != nil {
@@ -77,6 +97,14 @@ func Execute(cmdArgs []string, repl_env types.EnvType) error {
 	// Enable DEBUG-EVAL if flag is set
 	if parsedArgs.Debug {
 		lisp.DebugEvalEnabled = true
+		lisperror.CaptureGoStack = true
+		debugMode = true
+	}
+
+	if parsedArgs.Assert {
+		if err := typecheck.EnableAssertions(repl_env); err != nil {
+			return err
+		}
 	}
 
 	if parsedArgs.Eval != "" && (parsedArgs.Version || parsedArgs.Test != "") {
@@ -128,6 +156,7 @@ func Execute(cmdArgs []string, repl_env types.EnvType) error {
 	}
 
 	if parsedArgs.Eval != "" {
+		evalSources["-e"] = parsedArgs.Eval
 		ctx := context.Background()
 		result, err := lisp.REPL(ctx, repl_env, parsedArgs.Eval, types.NewCursorFile("-e"))
 		if err != nil {
@@ -175,3 +204,24 @@ func ExecuteFile(fileName string, ns types.EnvType) (types.MalType, error) {
 	}
 	return result, nil
 }
+
+// evalSources keeps the source code not available on the file system (e.g. -e expressions)
+var evalSources = lisperror.SourceMap{}
+
+// debugMode is set by --debug to render errors in full
+var debugMode = false
+
+// RenderError formats an error returned by [Execute] with an excerpt of the offending
+// source code. Colors are used if stderr is a terminal (and NO_COLOR is not set).
+func RenderError(err error) string {
+	return lisperror.Render(err, lisperror.RenderOptions{
+		Sources: lisperror.FileSources{Fallback: evalSources},
+		Color:   stderrIsTerminal() && os.Getenv("NO_COLOR") == "",
+		Debug:   debugMode,
+	})
+}
+
+func stderrIsTerminal() bool {
+	fi, err := os.Stderr.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
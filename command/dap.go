@@ -0,0 +1,49 @@
+package command
+
+import (
+	"os"
+
+	"github.com/alexflint/go-arg"
+	"github.com/jig/lisp/dap"
+	"github.com/jig/lisp/types"
+)
+
+// dapArgs represents command line arguments of the dap subcommand
+type dapArgs struct{}
+
+func (dapArgs) Description() string {
+	return "Run the Debug Adapter Protocol server over stdio"
+}
+
+// runDAP implements lisp dap
+func runDAP(cmdArgs []string, repl_env types.EnvType) error {
+	var parsedArgs dapArgs
+	parser, err := arg.NewParser(arg.Config{Program: "lisp dap"}, &parsedArgs)
+	if err != nil {
+		return err
+	}
+	err = parser.Parse(cmdArgs)
+	if err == arg.ErrHelp {
+		parser.WriteHelp(os.Stdout)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// stdout carries the protocol: the output of the debugged script is sent as events
+	protocol := os.Stdout
+	r, w, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	os.Stdout = w
+	defer func() {
+		os.Stdout = protocol
+		w.Close()
+	}()
+
+	server := dap.NewServer(repl_env)
+	go server.Output(r, "stdout")
+	return server.Serve(os.Stdin, protocol)
+}
@@ -0,0 +1,57 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/alexflint/go-arg"
+	"github.com/jig/lisp/docgen"
+	"github.com/jig/lisp/types"
+)
+
+// docArgs represents command line arguments of the doc subcommand
+type docArgs struct {
+	Out    string `arg:"-o,--out" help:"directory where the reference pages are written" placeholder:"DIR"`
+	Format string `arg:"-f,--format" default:"markdown" help:"format of the pages: markdown or html"`
+	Test   bool   `arg:"-t,--test" help:"run the examples of the docstrings instead of generating pages"`
+}
+
+func (docArgs) Description() string {
+	return "Generate reference pages for the symbols of the environment, or test their examples"
+}
+
+// runDoc implements lisp doc
+func runDoc(cmdArgs []string, repl_env types.EnvType) error {
+	var parsedArgs docArgs
+	parser, err := arg.NewParser(arg.Config{Program: "lisp doc"}, &parsedArgs)
+	if err != nil {
+		return err
+	}
+	err = parser.Parse(cmdArgs)
+	if err == arg.ErrHelp {
+		parser.WriteHelp(os.Stdout)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	packages := docgen.Collect(repl_env)
+	if parsedArgs.Test {
+		run, failures := docgen.RunExamples(context.Background(), repl_env, packages)
+		for _, failure := range failures {
+			fmt.Println(failure)
+		}
+		if len(failures) > 0 {
+			return fmt.Errorf("%d of %d example(s) failed", len(failures), run)
+		}
+		fmt.Printf("%d example(s) passed\n", run)
+		return nil
+	}
+	if parsedArgs.Out == "" {
+		return errors.New("--out is required to generate the pages")
+	}
+	return docgen.Write(parsedArgs.Out, docgen.Format(parsedArgs.Format), packages)
+}
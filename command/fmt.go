@@ -0,0 +1,111 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/alexflint/go-arg"
+	"github.com/jig/lisp/format"
+	"github.com/jig/lisp/types"
+)
+
+// fmtArgs represents command line arguments of the fmt subcommand
+type fmtArgs struct {
+	Write bool     `arg:"-w" help:"write the result to the source file instead of stdout"`
+	Diff  bool     `arg:"-d" help:"print a diff instead of the formatted source"`
+	Check bool     `arg:"-c,--check" help:"print the files that are not formatted and fail if any"`
+	Files []string `arg:"positional" help:"lisp files to format (stdin if none)" placeholder:"FILE"`
+}
+
+func (fmtArgs) Description() string {
+	return "Format Lisp files to the canonical style"
+}
+
+// runFmt implements lisp fmt [-w|-d|-c] [FILE...]
+func runFmt(cmdArgs []string, _ types.EnvType) error {
+	var parsedArgs fmtArgs
+	parser, err := arg.NewParser(arg.Config{Program: "lisp fmt"}, &parsedArgs)
+	if err != nil {
+		return err
+	}
+	err = parser.Parse(cmdArgs)
+	if err == arg.ErrHelp {
+		parser.WriteHelp(os.Stdout)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(parsedArgs.Files) == 0 {
+		if parsedArgs.Write {
+			return errors.New("cannot use -w with standard input")
+		}
+		b, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return err
+		}
+		unformatted, err := formatFile("<stdin>", string(b), parsedArgs)
+		if err != nil {
+			return err
+		}
+		if unformatted {
+			return errors.New("<stdin> is not formatted")
+		}
+		return nil
+	}
+
+	unformatted := 0
+	for _, fileName := range parsedArgs.Files {
+		b, err := os.ReadFile(fileName)
+		if err != nil {
+			return err
+		}
+		changed, err := formatFile(fileName, string(b), parsedArgs)
+		if err != nil {
+			return err
+		}
+		if changed {
+			unformatted++
+		}
+	}
+	if parsedArgs.Check && unformatted > 0 {
+		return fmt.Errorf("%d file(s) not formatted", unformatted)
+	}
+	return nil
+}
+
+// formatFile formats a single source file as requested on parsedArgs and returns
+// true on check mode if the file was not formatted
+func formatFile(fileName, source string, parsedArgs fmtArgs) (bool, error) {
+	formatted, err := format.Source(source, types.NewCursorFile(fileName))
+	if err != nil {
+		return false, err
+	}
+	changed := formatted != source
+	switch {
+	case parsedArgs.Check:
+		if changed {
+			fmt.Println(fileName)
+		}
+		if parsedArgs.Diff {
+			fmt.Print(format.Diff(fileName, source, formatted))
+		}
+		return changed, nil
+	case parsedArgs.Diff:
+		fmt.Print(format.Diff(fileName, source, formatted))
+	case parsedArgs.Write:
+		if changed {
+			info, err := os.Stat(fileName)
+			if err != nil {
+				return false, err
+			}
+			return false, os.WriteFile(fileName, []byte(formatted), info.Mode().Perm())
+		}
+	default:
+		fmt.Print(formatted)
+	}
+	return false, nil
+}
@@ -0,0 +1,36 @@
+package command
+
+import (
+	"os"
+
+	"github.com/alexflint/go-arg"
+	"github.com/jig/lisp/lsp"
+	"github.com/jig/lisp/types"
+)
+
+// lspArgs represents command line arguments of the lsp subcommand
+type lspArgs struct {
+	Stdio bool `arg:"--stdio" help:"communicate over stdin/stdout (default, accepted for editor compatibility)"`
+}
+
+func (lspArgs) Description() string {
+	return "Run the Language Server Protocol server over stdio"
+}
+
+// runLSP implements lisp lsp
+func runLSP(cmdArgs []string, repl_env types.EnvType) error {
+	var parsedArgs lspArgs
+	parser, err := arg.NewParser(arg.Config{Program: "lisp lsp"}, &parsedArgs)
+	if err != nil {
+		return err
+	}
+	err = parser.Parse(cmdArgs)
+	if err == arg.ErrHelp {
+		parser.WriteHelp(os.Stdout)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return lsp.NewServer(repl_env).Serve(os.Stdin, os.Stdout)
+}
@@ -0,0 +1,49 @@
+package command
+
+import (
+	"fmt"
+	"net"
+	"os"
+
+	"github.com/alexflint/go-arg"
+	"github.com/jig/lisp/nrepl"
+	"github.com/jig/lisp/types"
+)
+
+// nreplArgs represents command line arguments of the nrepl subcommand
+type nreplArgs struct {
+	Listen string `arg:"-l,--listen" default:"127.0.0.1:7888" help:"TCP address to listen on"`
+	Socket string `arg:"-s,--socket" help:"unix socket to listen on (instead of TCP)"`
+}
+
+func (nreplArgs) Description() string {
+	return "Run an nREPL compatible network REPL server"
+}
+
+// runNREPL implements lisp nrepl
+func runNREPL(cmdArgs []string, repl_env types.EnvType) error {
+	var parsedArgs nreplArgs
+	parser, err := arg.NewParser(arg.Config{Program: "lisp nrepl"}, &parsedArgs)
+	if err != nil {
+		return err
+	}
+	err = parser.Parse(cmdArgs)
+	if err == arg.ErrHelp {
+		parser.WriteHelp(os.Stdout)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	network, address := "tcp", parsedArgs.Listen
+	if parsedArgs.Socket != "" {
+		network, address = "unix", parsedArgs.Socket
+	}
+	l, err := net.Listen(network, address)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "nREPL server started on %s %s\n", network, l.Addr())
+	return nrepl.NewServer(repl_env).Serve(l)
+}
@@ -0,0 +1,64 @@
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/alexflint/go-arg"
+	"github.com/jig/lisp/lint"
+	"github.com/jig/lisp/typecheck"
+	"github.com/jig/lisp/types"
+)
+
+// typecheckArgs represents command line arguments of the typecheck subcommand
+type typecheckArgs struct {
+	JSON  bool     `arg:"--json" help:"print findings as JSON (one array with all findings)"`
+	Files []string `arg:"positional,required" help:"lisp files to check" placeholder:"FILE"`
+}
+
+func (typecheckArgs) Description() string {
+	return "Check the type hints of Lisp files without running them"
+}
+
+// runTypecheck implements lisp typecheck FILE...
+func runTypecheck(cmdArgs []string, repl_env types.EnvType) error {
+	var parsedArgs typecheckArgs
+	parser, err := arg.NewParser(arg.Config{Program: "lisp typecheck"}, &parsedArgs)
+	if err != nil {
+		return err
+	}
+	err = parser.Parse(cmdArgs)
+	if err == arg.ErrHelp {
+		parser.WriteHelp(os.Stdout)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	findings := []lint.Finding{}
+	for _, fileName := range parsedArgs.Files {
+		fileFindings, err := typecheck.CheckFile(fileName, repl_env)
+		if err != nil {
+			return err
+		}
+		findings = append(findings, fileFindings...)
+	}
+
+	if parsedArgs.JSON {
+		b, err := json.MarshalIndent(findings, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(b))
+	} else {
+		for _, finding := range findings {
+			fmt.Println(finding)
+		}
+	}
+	if len(findings) > 0 {
+		return fmt.Errorf("%d problem(s) found", len(findings))
+	}
+	return nil
+}
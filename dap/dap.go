@@ -0,0 +1,606 @@
+// Package dap implements a Debug Adapter Protocol server to debug Lisp scripts.
+//
+// The server launches a script and pauses it on breakpoints (set by file and line, and
+// matched against the Position of the evaluated forms), on entry and when stepping.
+// Stepping follows the Lisp call stack: step in enters the Lisp functions (MalFunc)
+// called from the current line, step over does not, and step out runs until the current
+// function returns. While paused, the environment chain of each frame can be inspected
+// and expressions evaluated on it.
+//
+// Paused evaluations keep their context: the launch timeout (if any) still applies and
+// a paused script is aborted when it expires.
+package dap
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jig/lisp"
+	"github.com/jig/lisp/lisperror"
+	"github.com/jig/lisp/printer"
+	"github.com/jig/lisp/reader"
+	. "github.com/jig/lisp/types"
+)
+
+// stepping modes
+type stepMode int
+
+const (
+	modeContinue stepMode = iota
+	modeStepIn
+	modeStepOver
+	modeStepOut
+)
+
+// location is the place where the evaluation is (or was) paused
+type location struct {
+	module string
+	line   int
+	frame  *lisp.DebugFrame
+}
+
+// paused is the state of a paused evaluation
+type paused struct {
+	ctx   context.Context
+	ast   MalType
+	env   EnvType
+	frame *lisp.DebugFrame
+}
+
+// Server is a Debug Adapter Protocol server
+type Server struct {
+	ns EnvType
+
+	wmu sync.Mutex // protects w and seq
+	w   io.Writer
+	seq int
+
+	mu             sync.Mutex
+	launch         *launchArguments
+	configured     bool
+	started        bool
+	cancel         context.CancelFunc
+	done           chan struct{}
+	breakpoints    map[string]map[int]bool // by absolute path and line
+	mode           stepMode
+	stepFrom       location
+	pauseRequested bool
+	last           location
+	paused         *paused
+	resume         chan struct{}
+	refs           map[int]any // variables references (environments and collections)
+}
+
+// NewServer creates a server that runs scripts on ns (the environment with the libraries
+// the scripts use).
+func NewServer(ns EnvType) *Server {
+	return &Server{
+		ns:          ns,
+		breakpoints: map[string]map[int]bool{},
+		resume:      make(chan struct{}),
+		refs:        map[int]any{},
+	}
+}
+
+// Serve reads requests from r and writes responses and events to w until the client
+// disconnects or r is closed.
+func (s *Server) Serve(r io.Reader, w io.Writer) error {
+	s.w = w
+	br := bufio.NewReader(r)
+	for {
+		req, err := readMessage(br)
+		if err == io.EOF {
+			s.terminate()
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		body, err := s.handle(req)
+		resp := response{
+			Type:       "response",
+			RequestSeq: req.Seq,
+			Success:    err == nil,
+			Command:    req.Command,
+			Body:       body,
+		}
+		if err != nil {
+			resp.Message = err.Error()
+		}
+		if err := s.send(&resp); err != nil {
+			return err
+		}
+		switch req.Command {
+		case "initialize":
+			s.event("initialized", nil)
+		case "launch", "configurationDone":
+			s.start()
+		case "disconnect":
+			return nil
+		}
+	}
+}
+
+// Output forwards what is read from r (e.g. the script standard output) as output
+// events of the given category ("stdout" or "stderr") until r is closed.
+func (s *Server) Output(r io.Reader, category string) {
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadString('\n')
+		if line != "" {
+			s.event("output", map[string]any{"category": category, "output": line})
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (s *Server) send(msg any) error {
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+	s.seq++
+	switch msg := msg.(type) {
+	case *response:
+		msg.Seq = s.seq
+	case *event:
+		msg.Seq = s.seq
+	}
+	return writeMessage(s.w, msg)
+}
+
+func (s *Server) event(name string, body any) {
+	_ = s.send(&event{Type: "event", Event: name, Body: body})
+}
+
+func (s *Server) handle(req *request) (any, error) {
+	switch req.Command {
+	case "initialize":
+		return map[string]any{
+			"supportsConfigurationDoneRequest": true,
+			"supportsEvaluateForHovers":        true,
+			"supportsTerminateRequest":         true,
+		}, nil
+	case "launch":
+		var args launchArguments
+		if err := json.Unmarshal(req.Arguments, &args); err != nil {
+			return nil, err
+		}
+		if args.Program == "" {
+			return nil, errors.New("launch: program is required")
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.launch = &args
+		return nil, nil
+	case "configurationDone":
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.configured = true
+		return nil, nil
+	case "setBreakpoints":
+		var args setBreakpointsArguments
+		if err := json.Unmarshal(req.Arguments, &args); err != nil {
+			return nil, err
+		}
+		return s.setBreakpoints(args), nil
+	case "setExceptionBreakpoints":
+		return map[string]any{"breakpoints": []breakpoint{}}, nil
+	case "threads":
+		return map[string]any{"threads": []thread{{ID: 1, Name: "main"}}}, nil
+	case "stackTrace":
+		return s.stackTrace()
+	case "scopes":
+		var args scopesArguments
+		if err := json.Unmarshal(req.Arguments, &args); err != nil {
+			return nil, err
+		}
+		return s.scopes(args.FrameID)
+	case "variables":
+		var args variablesArguments
+		if err := json.Unmarshal(req.Arguments, &args); err != nil {
+			return nil, err
+		}
+		return s.variables(args.VariablesReference)
+	case "evaluate":
+		var args evaluateArguments
+		if err := json.Unmarshal(req.Arguments, &args); err != nil {
+			return nil, err
+		}
+		return s.evaluate(args)
+	case "continue":
+		return map[string]any{"allThreadsContinued": true}, s.step(modeContinue)
+	case "next":
+		return nil, s.step(modeStepOver)
+	case "stepIn":
+		return nil, s.step(modeStepIn)
+	case "stepOut":
+		return nil, s.step(modeStepOut)
+	case "pause":
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.pauseRequested = true
+		return nil, nil
+	case "terminate", "disconnect":
+		s.terminate()
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unsupported request %s", req.Command)
+	}
+}
+
+func (s *Server) setBreakpoints(args setBreakpointsArguments) map[string]any {
+	path := absPath(args.Source.Path)
+	lines := map[int]bool{}
+	result := []breakpoint{}
+	for _, bp := range args.Breakpoints {
+		lines[bp.Line] = true
+		result = append(result, breakpoint{Verified: true, Line: bp.Line})
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.breakpoints[path] = lines
+	return map[string]any{"breakpoints": result}
+}
+
+func absPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return filepath.Clean(path)
+}
+
+// start runs the script once it is launched and configured
+func (s *Server) start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.started || s.launch == nil || !s.configured {
+		return
+	}
+	s.started = true
+	if s.launch.StopOnEntry {
+		s.mode = modeStepIn
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if s.launch.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(context.Background(), time.Duration(s.launch.Timeout)*time.Millisecond)
+	}
+	s.cancel = cancel
+	s.done = make(chan struct{})
+	go s.run(ctx, *s.launch)
+}
+
+func (s *Server) run(ctx context.Context, args launchArguments) {
+	defer close(s.done)
+	defer s.cancel()
+
+	exitCode := 0
+	if err := s.runScript(ctx, args); err != nil {
+		exitCode = 1
+		s.event("output", map[string]any{
+			"category": "stderr",
+			"output":   lisperror.Render(err, lisperror.RenderOptions{Sources: lisperror.FileSources{}}) + "\n",
+		})
+	}
+	s.event("exited", map[string]any{"exitCode": exitCode})
+	s.event("terminated", nil)
+}
+
+func (s *Server) runScript(ctx context.Context, args launchArguments) error {
+	path := absPath(args.Program)
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	argv := make([]MalType, 0, len(args.Args))
+	for _, arg := range args.Args {
+		argv = append(argv, arg)
+	}
+	s.ns.Set(Symbol{Val: "*ARGV*"}, List{Val: argv})
+
+	// read as load-file does, with the positions of the lines of the file
+	ast, err := reader.Read_module(path, string(src), nil, s.ns)
+	if err != nil {
+		return err
+	}
+	_, err = lisp.EVAL(lisp.WithDebugger(ctx, debugger{s}, s.ns), ast, s.ns)
+	return err
+}
+
+// terminate aborts the script (if running) and waits for it to finish
+func (s *Server) terminate() {
+	s.mu.Lock()
+	cancel, done := s.cancel, s.done
+	s.mu.Unlock()
+	if cancel == nil {
+		return
+	}
+	cancel()
+	<-done
+}
+
+// debugger is the lisp.Debugger of the scripts run by the server
+type debugger struct {
+	s *Server
+}
+
+func (d debugger) Eval(ctx context.Context, ast MalType, env EnvType, frame *lisp.DebugFrame) error {
+	s := d.s
+	pos := lisperror.GetPosition(ast)
+	if pos == nil || pos.Module == nil || strings.HasPrefix(*pos.Module, "$") {
+		// code without source file (e.g. generated by macros or loaded from Go packages)
+		return nil
+	}
+	if pos.BeginRow < 1 {
+		// the do wrapping the forms of the file (see reader.Read_module)
+		return nil
+	}
+	here := location{module: *pos.Module, line: pos.BeginRow, frame: frame}
+
+	s.mu.Lock()
+	reason := s.stopReason(here)
+	s.last = here
+	if reason == "" {
+		s.mu.Unlock()
+		return nil
+	}
+	s.pauseRequested = false
+	s.paused = &paused{ctx: ctx, ast: ast, env: env, frame: frame}
+	s.refs = map[int]any{}
+	s.mu.Unlock()
+
+	s.event("stopped", map[string]any{"reason": reason, "threadId": 1, "allThreadsStopped": true})
+	select {
+	case <-s.resume:
+		return nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		s.paused = nil
+		s.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// stopReason returns why the evaluation must pause at here (empty to go on)
+func (s *Server) stopReason(here location) string {
+	if s.pauseRequested {
+		return "pause"
+	}
+	sameLine := here.module == s.stepFrom.module && here.line == s.stepFrom.line && here.frame == s.stepFrom.frame
+	switch s.mode {
+	case modeStepIn:
+		if !sameLine {
+			if s.stepFrom.frame == nil {
+				return "entry"
+			}
+			return "step"
+		}
+	case modeStepOver:
+		if !sameLine && here.frame.Depth <= s.stepFrom.frame.Depth {
+			return "step"
+		}
+	case modeStepOut:
+		if here.frame.Depth < s.stepFrom.frame.Depth {
+			return "step"
+		}
+	}
+	if s.breakpoints[here.module][here.line] {
+		// forms after the first one on the line do not stop again
+		if here.module != s.last.module || here.line != s.last.line || here.frame != s.last.frame {
+			return "breakpoint"
+		}
+	}
+	return ""
+}
+
+// step resumes a paused evaluation
+func (s *Server) step(mode stepMode) error {
+	s.mu.Lock()
+	p := s.paused
+	if p == nil {
+		s.mu.Unlock()
+		return errors.New("not paused")
+	}
+	pos := lisperror.GetPosition(p.ast)
+	s.mode = mode
+	s.stepFrom = location{module: *pos.Module, line: pos.BeginRow, frame: p.frame}
+	s.paused = nil
+	s.mu.Unlock()
+
+	select {
+	case s.resume <- struct{}{}:
+	case <-p.ctx.Done():
+	}
+	return nil
+}
+
+// frames returns the paused call stack, innermost first
+func (s *Server) frames() ([]*lisp.DebugFrame, *paused, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.paused == nil {
+		return nil, nil, errors.New("not paused")
+	}
+	frames := []*lisp.DebugFrame{}
+	for frame := s.paused.frame; frame != nil; frame = frame.Parent {
+		frames = append(frames, frame)
+	}
+	return frames, s.paused, nil
+}
+
+func (s *Server) stackTrace() (any, error) {
+	frames, p, err := s.frames()
+	if err != nil {
+		return nil, err
+	}
+	result := make([]stackFrame, 0, len(frames))
+	pos := lisperror.GetPosition(p.ast)
+	for i, frame := range frames {
+		sf := stackFrame{ID: i, Name: frameName(frame)}
+		if pos != nil && pos.Module != nil {
+			sf.Source = &source{Name: filepath.Base(*pos.Module), Path: *pos.Module}
+			sf.Line = pos.BeginRow
+			sf.Column = 1
+		}
+		result = append(result, sf)
+		// the caller is paused at the call of this frame
+		pos = frame.Call
+	}
+	return map[string]any{"stackFrames": result, "totalFrames": len(result)}, nil
+}
+
+func frameName(frame *lisp.DebugFrame) string {
+	switch frame.Name {
+	case "":
+		return "<top level>"
+	case "__<*fn>__":
+		return "(fn)"
+	default:
+		return frame.Name
+	}
+}
+
+// frameEnv returns the environment of a paused frame
+func (s *Server) frameEnv(frameID int) (EnvType, *paused, error) {
+	frames, p, err := s.frames()
+	if err != nil {
+		return nil, nil, err
+	}
+	if frameID < 0 || frameID >= len(frames) {
+		return nil, nil, fmt.Errorf("unknown frame %d", frameID)
+	}
+	if frameID == 0 {
+		// innermost environment (e.g. with let bindings)
+		return p.env, p, nil
+	}
+	return frames[frameID].Env, p, nil
+}
+
+// envChain is the interface of environments that can be inspected (env.Env)
+type envChain interface {
+	Names() []string
+	Outer() EnvType
+}
+
+func (s *Server) scopes(frameID int) (any, error) {
+	env, _, err := s.frameEnv(frameID)
+	if err != nil {
+		return nil, err
+	}
+	result := []scope{}
+	for level := 0; env != nil; level++ {
+		chain, ok := env.(envChain)
+		if !ok {
+			break
+		}
+		outer := chain.Outer()
+		switch {
+		case outer == nil:
+			result = append(result, scope{Name: "Globals", VariablesReference: s.ref(env), Expensive: true})
+		case level == 0:
+			result = append(result, scope{Name: "Locals", VariablesReference: s.ref(env)})
+		default:
+			result = append(result, scope{Name: fmt.Sprintf("Closure %d", level), VariablesReference: s.ref(env)})
+		}
+		env = outer
+	}
+	return map[string]any{"scopes": result}, nil
+}
+
+// ref returns a new variables reference for an environment or a collection
+func (s *Server) ref(value any) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := len(s.refs) + 1
+	s.refs[id] = value
+	return id
+}
+
+// maxValueLength truncates long printed values
+const maxValueLength = 200
+
+func (s *Server) variable(name string, value MalType) variable {
+	v := variable{Name: name, Value: printer.Pr_str(value, true), Type: fmt.Sprintf("%T", value)}
+	if len(v.Value) > maxValueLength {
+		v.Value = v.Value[:maxValueLength] + "…"
+	}
+	switch value := value.(type) {
+	case List:
+		if len(value.Val) > 0 {
+			v.VariablesReference = s.ref(value)
+		}
+	case Vector:
+		if len(value.Val) > 0 {
+			v.VariablesReference = s.ref(value)
+		}
+	case HashMap:
+		if len(value.Val) > 0 {
+			v.VariablesReference = s.ref(value)
+		}
+	}
+	return v
+}
+
+func (s *Server) variables(ref int) (any, error) {
+	s.mu.Lock()
+	value, ok := s.refs[ref]
+	s.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown variables reference %d", ref)
+	}
+	result := []variable{}
+	switch value := value.(type) {
+	case envChain:
+		env := value.(EnvType)
+		for _, name := range value.Names() {
+			v, err := env.Get(Symbol{Val: name})
+			if err != nil {
+				continue
+			}
+			result = append(result, s.variable(name, v))
+		}
+	case List:
+		for i, item := range value.Val {
+			result = append(result, s.variable(fmt.Sprintf("[%d]", i), item))
+		}
+	case Vector:
+		for i, item := range value.Val {
+			result = append(result, s.variable(fmt.Sprintf("[%d]", i), item))
+		}
+	case HashMap:
+		for _, k := range SortedKeys(value.Val) {
+			result = append(result, s.variable(printer.Pr_str(k, true), value.Val[k]))
+		}
+	}
+	return map[string]any{"variables": result}, nil
+}
+
+// evaluate evaluates an expression on the environment of a paused frame. It runs with
+// the context of the paused evaluation (so it is bound to the same timeout) without
+// stopping on breakpoints.
+func (s *Server) evaluate(args evaluateArguments) (any, error) {
+	env, p, err := s.frameEnv(args.FrameID)
+	if err != nil {
+		return nil, err
+	}
+	ast, err := lisp.READ(args.Expression, NewCursorFile("<eval>"), s.ns)
+	if err != nil {
+		return nil, err
+	}
+	result, err := lisp.EVAL(lisp.WithDebugger(p.ctx, nil, nil), ast, env)
+	if err != nil {
+		return nil, err
+	}
+	v := s.variable("", result)
+	return map[string]any{"result": v.Value, "type": v.Type, "variablesReference": v.VariablesReference}, nil
+}
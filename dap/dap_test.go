@@ -0,0 +1,252 @@
+package dap_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jig/lisp/dap"
+	"github.com/jig/lisp/env"
+	"github.com/jig/lisp/lib/core/nscore"
+)
+
+const script = `(defn add [a b]
+  (let [s (+ a b)]
+    s))
+
+(def r (add 1 2))
+(def l [r {:k "v"}])
+(str l)
+`
+
+// client drives a server through pipes
+type client struct {
+	t      *testing.T
+	w      io.Writer
+	r      *bufio.Reader
+	seq    int
+	events []map[string]any
+	err    chan error
+}
+
+func newClient(t *testing.T) *client {
+	ns := env.NewEnv()
+	if err := nscore.Load(ns); err != nil {
+		t.Fatal(err)
+	}
+	clientR, serverW := io.Pipe()
+	serverR, clientW := io.Pipe()
+	c := &client{t: t, w: clientW, r: bufio.NewReader(clientR), err: make(chan error, 1)}
+	go func() {
+		c.err <- dap.NewServer(ns).Serve(serverR, serverW)
+		serverW.Close()
+	}()
+	return c
+}
+
+func (c *client) receive() map[string]any {
+	length := 0
+	for {
+		line, err := c.r.ReadString('\n')
+		if err != nil {
+			c.t.Fatal(err)
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			break
+		}
+		if value, ok := strings.CutPrefix(line, "Content-Length: "); ok {
+			length, _ = strconv.Atoi(value)
+		}
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(c.r, body); err != nil {
+		c.t.Fatal(err)
+	}
+	var msg map[string]any
+	if err := json.Unmarshal(body, &msg); err != nil {
+		c.t.Fatal(err)
+	}
+	return msg
+}
+
+// request sends a request and returns its response body (events received meanwhile are queued)
+func (c *client) request(command string, arguments any) map[string]any {
+	c.t.Helper()
+	c.seq++
+	body, _ := json.Marshal(map[string]any{"seq": c.seq, "type": "request", "command": command, "arguments": arguments})
+	if _, err := fmt.Fprintf(c.w, "Content-Length: %d\r\n\r\n%s", len(body), body); err != nil {
+		c.t.Fatal(err)
+	}
+	for {
+		msg := c.receive()
+		if msg["type"] == "event" {
+			c.events = append(c.events, msg)
+			continue
+		}
+		if msg["success"] != true {
+			c.t.Fatalf("%s failed: %v", command, msg["message"])
+		}
+		result, _ := msg["body"].(map[string]any)
+		return result
+	}
+}
+
+// waitEvent returns the next event with the given name
+func (c *client) waitEvent(name string) map[string]any {
+	c.t.Helper()
+	for {
+		for i, e := range c.events {
+			if e["event"] == name {
+				c.events = append(c.events[:i], c.events[i+1:]...)
+				body, _ := e["body"].(map[string]any)
+				return body
+			}
+		}
+		msg := c.receive()
+		if msg["type"] == "event" {
+			c.events = append(c.events, msg)
+		}
+	}
+}
+
+// where returns the name and line of the paused frames
+func (c *client) where() string {
+	c.t.Helper()
+	body := c.request("stackTrace", map[string]any{"threadId": 1})
+	frames := []string{}
+	for _, f := range body["stackFrames"].([]any) {
+		frame := f.(map[string]any)
+		frames = append(frames, fmt.Sprintf("%s:%v", frame["name"], frame["line"]))
+	}
+	return strings.Join(frames, " ")
+}
+
+func (c *client) locals(frameID int) string {
+	c.t.Helper()
+	scopes := c.request("scopes", map[string]any{"frameId": frameID})["scopes"].([]any)
+	locals := scopes[0].(map[string]any)
+	if locals["name"] != "Locals" {
+		c.t.Fatalf("unexpected scopes %v", scopes)
+	}
+	variables := c.request("variables", map[string]any{"variablesReference": locals["variablesReference"]})["variables"].([]any)
+	result := []string{}
+	for _, v := range variables {
+		v := v.(map[string]any)
+		result = append(result, fmt.Sprintf("%s=%s", v["name"], v["value"]))
+	}
+	return strings.Join(result, " ")
+}
+
+func writeScript(t *testing.T) string {
+	path := filepath.Join(t.TempDir(), "script.lisp")
+	if err := os.WriteFile(path, []byte(script), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func (c *client) launch(path string, launch map[string]any, lines ...int) {
+	c.request("initialize", map[string]any{"adapterID": "lisp"})
+	c.waitEvent("initialized")
+	launch["program"] = path
+	c.request("launch", launch)
+	breakpoints := []map[string]any{}
+	for _, line := range lines {
+		breakpoints = append(breakpoints, map[string]any{"line": line})
+	}
+	c.request("setBreakpoints", map[string]any{"source": map[string]any{"path": path}, "breakpoints": breakpoints})
+	c.request("configurationDone", nil)
+}
+
+func (c *client) stopped(reason, where string) {
+	c.t.Helper()
+	if body := c.waitEvent("stopped"); body["reason"] != reason {
+		c.t.Fatalf("stopped because of %v instead of %s", body["reason"], reason)
+	}
+	if got := c.where(); got != where {
+		c.t.Fatalf("stopped at %s instead of %s", got, where)
+	}
+}
+
+func TestBreakpointsAndStepping(t *testing.T) {
+	c := newClient(t)
+	c.launch(writeScript(t), map[string]any{}, 2)
+
+	c.stopped("breakpoint", "add:2 <top level>:5")
+	if locals := c.locals(0); locals != "a=1 b=2" {
+		t.Fatalf("unexpected locals %s", locals)
+	}
+	result := c.request("evaluate", map[string]any{"expression": "(* (+ a b) 10)", "frameId": 0})
+	if result["result"] != "30" {
+		t.Fatalf("unexpected evaluation %v", result)
+	}
+
+	c.request("next", map[string]any{"threadId": 1})
+	c.stopped("step", "<top level>:6")
+
+	c.request("stepIn", map[string]any{"threadId": 1})
+	c.stopped("step", "<top level>:7")
+	result = c.request("evaluate", map[string]any{"expression": "l", "frameId": 0})
+	if result["result"] != `[3 {:k "v"}]` || result["variablesReference"] == 0.0 {
+		t.Fatalf("unexpected evaluation %v", result)
+	}
+	items := c.request("variables", map[string]any{"variablesReference": result["variablesReference"]})["variables"].([]any)
+	if len(items) != 2 || items[1].(map[string]any)["variablesReference"] == 0.0 {
+		t.Fatalf("unexpected items %v", items)
+	}
+
+	c.request("continue", map[string]any{"threadId": 1})
+	if body := c.waitEvent("exited"); body["exitCode"] != 0.0 {
+		t.Fatalf("unexpected exit %v", body)
+	}
+	c.waitEvent("terminated")
+	c.request("disconnect", nil)
+	if err := <-c.err; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStepInAndOut(t *testing.T) {
+	c := newClient(t)
+	c.launch(writeScript(t), map[string]any{"stopOnEntry": true})
+
+	c.stopped("entry", "<top level>:1")
+	c.request("next", map[string]any{"threadId": 1})
+	c.stopped("step", "<top level>:5")
+	c.request("stepIn", map[string]any{"threadId": 1})
+	c.stopped("step", "add:2 <top level>:5")
+	c.request("stepOut", map[string]any{"threadId": 1})
+	c.stopped("step", "<top level>:6")
+
+	c.request("disconnect", nil)
+	if err := <-c.err; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPausedScriptTimesOut(t *testing.T) {
+	c := newClient(t)
+	c.launch(writeScript(t), map[string]any{"timeout": 100}, 5)
+	c.stopped("breakpoint", "<top level>:5")
+
+	// nobody resumes the script: the launch timeout aborts it
+	start := time.Now()
+	if body := c.waitEvent("exited"); body["exitCode"] != 1.0 {
+		t.Fatalf("unexpected exit %v", body)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("timeout took %s", elapsed)
+	}
+	c.request("disconnect", nil)
+	if err := <-c.err; err != nil {
+		t.Fatal(err)
+	}
+}
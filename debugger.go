@@ -0,0 +1,93 @@
+package lisp
+
+import (
+	"context"
+	"sync/atomic"
+
+	. "github.com/jig/lisp/types"
+)
+
+// Debugger is notified by [EVAL] before evaluating each list form, when set on the
+// context with [WithDebugger]. It is the hook used by debuggers (e.g. the dap package)
+// to implement breakpoints and stepping.
+type Debugger interface {
+	// Eval is called before evaluating ast on env. frame is the Lisp call stack at that
+	// point. Debuggers pause the evaluation by blocking on Eval, and must return when ctx
+	// is done so timeouts still apply to paused evaluations. A non nil error aborts the
+	// evaluation with that error.
+	Eval(ctx context.Context, ast MalType, env EnvType, frame *DebugFrame) error
+}
+
+// DebugFrame is a frame of the Lisp call stack, as seen by a [Debugger]. A frame is
+// pushed each time EVAL applies a Lisp function (MalFunc); a tail call replaces the frame
+// of the calling function instead, so the stack does not grow on tail recursive loops.
+type DebugFrame struct {
+	Name   string      // name of the function (empty on the top level frame)
+	Call   *Position   // position of the call (nil on the top level frame)
+	Env    EnvType     // environment of the function (with its arguments bound)
+	Parent *DebugFrame // caller frame (nil on the top level frame)
+	Depth  int         // 0 on the top level frame
+}
+
+type debugKey struct{}
+
+type debugState struct {
+	debugger Debugger
+	frame    *DebugFrame
+}
+
+// debuggerAttached avoids looking up the debugger on the context of every EVAL until a
+// debugger is used for the first time
+var debuggerAttached atomic.Bool
+
+// WithDebugger returns a context that makes [EVAL] notify debugger. env is the top level
+// environment. A nil debugger disables debugging (e.g. to evaluate expressions while
+// paused without hitting breakpoints).
+func WithDebugger(ctx context.Context, debugger Debugger, env EnvType) context.Context {
+	if debugger == nil {
+		return context.WithValue(ctx, debugKey{}, (*debugState)(nil))
+	}
+	debuggerAttached.Store(true)
+	return context.WithValue(ctx, debugKey{}, &debugState{
+		debugger: debugger,
+		frame:    &DebugFrame{Env: env},
+	})
+}
+
+func debugging(ctx context.Context) *debugState {
+	if !debuggerAttached.Load() || ctx == nil {
+		return nil
+	}
+	state, _ := ctx.Value(debugKey{}).(*debugState)
+	return state
+}
+
+// call returns the context to evaluate the body of a Lisp function called from ast
+func (state *debugState) call(ctx context.Context, name string, call *Position, env EnvType) context.Context {
+	return context.WithValue(ctx, debugKey{}, &debugState{
+		debugger: state.debugger,
+		frame: &DebugFrame{
+			Name:   name,
+			Call:   call,
+			Env:    env,
+			Parent: state.frame,
+			Depth:  state.frame.Depth + 1,
+		},
+	})
+}
+
+// tailCall returns the context to evaluate the body of a Lisp function tail called from
+// ast, replacing the frame of the calling function. ctx must be the context the calling
+// EVAL was entered with, so the context chain does not grow on each tail call
+func (state *debugState) tailCall(ctx context.Context, name string, call *Position, env EnvType) context.Context {
+	return context.WithValue(ctx, debugKey{}, &debugState{
+		debugger: state.debugger,
+		frame: &DebugFrame{
+			Name:   name,
+			Call:   call,
+			Env:    env,
+			Parent: state.frame.Parent,
+			Depth:  state.frame.Depth,
+		},
+	})
+}
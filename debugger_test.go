@@ -0,0 +1,107 @@
+package lisp
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jig/lisp/types"
+)
+
+// recorder records the forms notified to the debugger and the frames they run on
+type recorder struct {
+	calls []string
+}
+
+func (r *recorder) Eval(_ context.Context, ast types.MalType, _ types.EnvType, frame *DebugFrame) error {
+	if lst, ok := ast.(types.List); ok && len(lst.Val) > 0 {
+		if sym, ok := lst.Val[0].(types.Symbol); ok {
+			r.calls = append(r.calls, strings.Repeat(">", frame.Depth)+sym.Val)
+		}
+	}
+	return nil
+}
+
+func TestDebuggerFrames(t *testing.T) {
+	env := newEnv(t.Name())
+	r := &recorder{}
+	ctx := WithDebugger(context.Background(), r, env)
+	if _, err := REPL(ctx, env, `(do (def inc2 (fn [x] (+ x 2))) (def twice (fn [x] (inc2 (inc2 x)))) (twice 1))`, types.NewCursorFile(t.Name())); err != nil {
+		t.Fatal(err)
+	}
+	const expected = "do def fn def fn twice >do >inc2 >inc2 >>do >>+ >do >+"
+	if got := strings.Join(r.calls, " "); got != expected {
+		t.Fatalf("got %s, expected %s", got, expected)
+	}
+}
+
+// deepest records the deepest frame notified to the debugger
+type deepest struct {
+	depth int
+}
+
+func (d *deepest) Eval(_ context.Context, _ types.MalType, _ types.EnvType, frame *DebugFrame) error {
+	d.depth = max(d.depth, frame.Depth)
+	return nil
+}
+
+func TestDebuggerTailCalls(t *testing.T) {
+	env := newEnv(t.Name())
+	d := &deepest{}
+	ctx := WithDebugger(context.Background(), d, env)
+	res, err := REPL(ctx, env, `(do (def count-down (fn [n] (if (= n 0) :done (count-down (- n 1))))) (count-down 1000))`, types.NewCursorFile(t.Name()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res != ":done" {
+		t.Fatalf("unexpected result %v", res)
+	}
+	if d.depth != 1 {
+		t.Fatalf("tail calls must replace the frame (depth %d)", d.depth)
+	}
+}
+
+func TestDebuggerLongTailRecursion(t *testing.T) {
+	env := newEnv(t.Name())
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	ctx = WithDebugger(ctx, &deepest{}, env)
+	// takes well under a second unless each tail call nests the context of the previous one
+	res, err := REPL(ctx, env, `(do (def count-down (fn [n] (if (= n 0) :done (count-down (- n 1))))) (count-down 100000))`, types.NewCursorFile(t.Name()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res != ":done" {
+		t.Fatalf("unexpected result %v", res)
+	}
+}
+
+// blocker pauses the evaluation until the context is done
+type blocker struct{}
+
+func (blocker) Eval(ctx context.Context, _ types.MalType, _ types.EnvType, _ *DebugFrame) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestPausedEvaluationTimesOut(t *testing.T) {
+	env := newEnv(t.Name())
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := REPL(WithDebugger(ctx, blocker{}, env), env, `(+ 1 2)`, types.NewCursorFile(t.Name()))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+
+	// a nil debugger disables debugging
+	res, err := REPL(WithDebugger(ctx, nil, nil), env, `(+ 1 2)`, types.NewCursorFile(t.Name()))
+	if err == nil || res != nil {
+		t.Fatalf("expected timeout, got %v", res)
+	}
+	res, err = REPL(WithDebugger(context.Background(), nil, nil), env, `(+ 1 2)`, types.NewCursorFile(t.Name()))
+	if err != nil || res != "3" {
+		t.Fatalf("unexpected result %v (%v)", res, err)
+	}
+}
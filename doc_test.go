@@ -0,0 +1,62 @@
+package lisp
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jig/lisp/lib/core"
+	"github.com/jig/lisp/types"
+)
+
+func TestSourceFromFile(t *testing.T) {
+	const code = `(def x 1)
+
+(defn add
+  "Adds a and b."
+  [a b]
+  (+ a b)) ; trailing comment
+
+(def sub (fn [a b] (- a b)))
+`
+	path := filepath.Join(t.TempDir(), "lib.lisp")
+	if err := os.WriteFile(path, []byte(code), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	env := newEnv(t.Name())
+	if _, err := REPL(context.Background(), env, `(load-file "`+path+`")`, types.NewCursorFile(t.Name())); err != nil {
+		t.Fatal(err)
+	}
+	for name, expected := range map[string]string{
+		"add": "(defn add\n  \"Adds a and b.\"\n  [a b]\n  (+ a b))",
+		"sub": "(def sub (fn [a b] (- a b)))",
+	} {
+		fn, err := env.Get(types.Symbol{Val: name})
+		if err != nil {
+			t.Fatal(err)
+		}
+		source, err := core.Source(fn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if source != expected {
+			t.Fatalf("got %q, expected %q", source, expected)
+		}
+	}
+
+	add, _ := env.Get(types.Symbol{Val: "add"})
+	doc := core.DocString("add", add)
+	if !strings.Contains(doc, "([a b])\n  Adds a and b.\n") {
+		t.Fatalf("unexpected doc %q", doc)
+	}
+}
+
+func TestDefMetadataErrors(t *testing.T) {
+	env := newEnv(t.Name())
+	_, err := REPL(context.Background(), env, `(def n "a number" 1)`, types.NewCursorFile(t.Name()))
+	if err == nil || !strings.Contains(err.Error(), "def: cannot attach metadata to int") {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
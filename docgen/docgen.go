@@ -0,0 +1,166 @@
+// Package docgen generates reference documentation for the symbols of an environment
+// and runs the examples found on their docstrings as tests.
+//
+// Go functions are grouped by the Go package recorded on _PACKAGES_ (see lib/call).
+// Lisp functions and macros are grouped by the module where they were defined, and
+// other values are grouped on [LispPackage].
+//
+// Examples are written on docstrings as on the tests/*.mal files: the code followed by
+// a line with the expected printed result prefixed by ;=>
+//
+//	Returns the sum of n1 and n2.
+//
+//	(+ 1 2)
+//	;=> 3
+package docgen
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/jig/lisp/lib/core"
+	. "github.com/jig/lisp/types"
+)
+
+// LispPackage is the group of the symbols that are neither Go functions nor defined on
+// a known module
+const LispPackage = "lisp"
+
+// Package is a group of documented symbols
+type Package struct {
+	Name    string
+	Entries []Entry
+}
+
+// Entry is the documentation of a symbol
+type Entry struct {
+	Name        string
+	Kind        string // function, macro or value
+	Arglists    string // e.g. ([x] [x y]), empty for values
+	GoName      string // Go function name (Go functions only)
+	GoSignature string // Go function type (Go functions only)
+	Doc         string // docstring without the examples
+	Examples    []Example
+}
+
+// Example is an example found on a docstring
+type Example struct {
+	Code     string
+	Expected string // printed result
+}
+
+// Collect documents the symbols of ns (except internal ones, starting with _)
+func Collect(ns EnvType) []Package {
+	goPackages := map[string]string{}
+	if packages, err := ns.Get(Symbol{Val: "_PACKAGES_"}); err == nil {
+		if hm, ok := packages.(HashMap); ok {
+			for pkg, names := range hm.Val {
+				if names, ok := names.(Set); ok {
+					for name := range names.Val {
+						goPackages[name.(string)] = pkg.(string)
+					}
+				}
+			}
+		}
+	}
+
+	groups := map[string][]Entry{}
+	for _, suffix := range ns.Symbols(nil, "") {
+		name := string(suffix)
+		if strings.HasPrefix(name, "_") {
+			continue
+		}
+		value, err := ns.Get(Symbol{Val: name})
+		if err != nil {
+			continue
+		}
+		entry := Entry{Name: name, Kind: "value", Arglists: core.Arglists(value)}
+		entry.Doc, entry.Examples = ParseDoc(Doc(value))
+		pkg := LispPackage
+		switch value := value.(type) {
+		case Func:
+			entry.Kind = "function"
+			if value.Signature != nil {
+				entry.GoName = value.Signature.GoName
+				entry.GoSignature = value.Signature.GoType()
+				pkg = value.Signature.Package
+			}
+			if registered, ok := goPackages[name]; ok {
+				pkg = registered
+			}
+			pkg = goPackage(pkg)
+		case MalFunc:
+			entry.Kind = "function"
+			if value.IsMacro {
+				entry.Kind = "macro"
+			}
+			if value.Cursor != nil && value.Cursor.Module != nil {
+				pkg = *value.Cursor.Module
+			}
+		}
+		groups[pkg] = append(groups[pkg], entry)
+	}
+
+	packages := make([]Package, 0, len(groups))
+	for name, entries := range groups {
+		sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+		packages = append(packages, Package{Name: name, Entries: entries})
+	}
+	sort.Slice(packages, func(i, j int) bool { return packages[i].Name < packages[j].Name })
+	return packages
+}
+
+// goPackage returns the Go package of a package name recorded on _PACKAGES_, which
+// includes the enclosing function for function literals (e.g. github.com/jig/lisp/lib/core.load)
+func goPackage(pkg string) string {
+	slash := strings.LastIndex(pkg, "/")
+	if dot := strings.Index(pkg[slash+1:], "."); dot >= 0 {
+		return pkg[:slash+1+dot]
+	}
+	return pkg
+}
+
+// ParseDoc splits a docstring into its text and its examples
+func ParseDoc(doc string) (string, []Example) {
+	lines := strings.Split(doc, "\n")
+	text := []string{}
+	examples := []Example{}
+	code := []string{} // candidate example code (consecutive lines starting with a form)
+	flush := func() {
+		text = append(text, code...)
+		code = code[:0]
+	}
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(trimmed, ";=>") && len(code) > 0:
+			examples = append(examples, Example{
+				Code:     strings.Join(trimmedLines(code), "\n"),
+				Expected: strings.TrimSpace(strings.TrimPrefix(trimmed, ";=>")),
+			})
+			code = code[:0]
+		case len(code) > 0 && trimmed != "":
+			code = append(code, line)
+		case strings.HasPrefix(trimmed, "("):
+			code = append(code, line)
+		default:
+			flush()
+			text = append(text, line)
+		}
+	}
+	flush()
+	return strings.TrimSpace(strings.Join(trimmedLines(text), "\n")), examples
+}
+
+// trimmedLines trims the lines and collapses blank lines
+func trimmedLines(lines []string) []string {
+	result := make([]string, 0, len(lines))
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" && len(result) > 0 && result[len(result)-1] == "" {
+			continue
+		}
+		result = append(result, line)
+	}
+	return result
+}
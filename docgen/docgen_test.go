@@ -0,0 +1,142 @@
+package docgen_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jig/lisp"
+	"github.com/jig/lisp/docgen"
+	"github.com/jig/lisp/env"
+	"github.com/jig/lisp/lib/core/nscore"
+	"github.com/jig/lisp/types"
+)
+
+func newEnv(t *testing.T) types.EnvType {
+	ns := env.NewEnv()
+	if err := nscore.Load(ns); err != nil {
+		t.Fatal(err)
+	}
+	return ns
+}
+
+func find(t *testing.T, packages []docgen.Package, pkg, name string) docgen.Entry {
+	t.Helper()
+	for _, p := range packages {
+		if p.Name != pkg {
+			continue
+		}
+		for _, entry := range p.Entries {
+			if entry.Name == name {
+				return entry
+			}
+		}
+	}
+	t.Fatalf("%s not found on %s", name, pkg)
+	return docgen.Entry{}
+}
+
+func TestParseDoc(t *testing.T) {
+	text, examples := docgen.ParseDoc(`Adds two numbers.
+  Returns (a number).
+
+  (add 1 2)
+  ;=> 3
+
+  (add
+    1 -1)
+  ;=> 0
+  Trailing note.`)
+	if text != "Adds two numbers.\nReturns (a number).\n\nTrailing note." {
+		t.Fatalf("unexpected text %q", text)
+	}
+	expected := []docgen.Example{{Code: "(add 1 2)", Expected: "3"}, {Code: "(add\n1 -1)", Expected: "0"}}
+	if !reflect.DeepEqual(examples, expected) {
+		t.Fatalf("got %#v, expected %#v", examples, expected)
+	}
+}
+
+func TestCollect(t *testing.T) {
+	ns := newEnv(t)
+	if _, err := lisp.REPL(context.Background(), ns, "(defn twice \"Doubles x.\" [x] (* 2 x))", types.NewCursorFile("mylib")); err != nil {
+		t.Fatal(err)
+	}
+	packages := docgen.Collect(ns)
+
+	plus := find(t, packages, "github.com/jig/lisp/lib/core", "+")
+	if plus.Kind != "function" || plus.Arglists != "([n1 n2])" || plus.GoSignature != "func(int, int) (int, error)" {
+		t.Fatalf("unexpected entry %+v", plus)
+	}
+	if plus.Doc != "Returns the sum of n1 and n2." || len(plus.Examples) != 1 {
+		t.Fatalf("unexpected doc %+v", plus)
+	}
+	if defn := find(t, packages, "$nscore", "defn"); defn.Kind != "macro" {
+		t.Fatalf("unexpected entry %+v", defn)
+	}
+	if twice := find(t, packages, "mylib", "twice"); twice.Doc != "Doubles x." || twice.Arglists != "([x])" {
+		t.Fatalf("unexpected entry %+v", twice)
+	}
+	if host := find(t, packages, docgen.LispPackage, "*host-language*"); host.Kind != "value" {
+		t.Fatalf("unexpected entry %+v", host)
+	}
+}
+
+func TestRunExamples(t *testing.T) {
+	ns := newEnv(t)
+	run, failures := docgen.RunExamples(context.Background(), ns, docgen.Collect(ns))
+	if run == 0 {
+		t.Fatal("no examples found")
+	}
+	for _, failure := range failures {
+		t.Error(failure)
+	}
+
+	broken := []docgen.Package{{Name: "p", Entries: []docgen.Entry{{Name: "f", Examples: []docgen.Example{
+		{Code: "(+ 1 1)", Expected: "3"},
+		{Code: "(undefined)", Expected: "nil"},
+	}}}}}
+	run, failures = docgen.RunExamples(context.Background(), ns, broken)
+	if run != 2 || len(failures) != 2 {
+		t.Fatalf("unexpected result %d %v", run, failures)
+	}
+	if msg := failures[0].Error(); msg != "p: f: (+ 1 1): got 2, expected 3" {
+		t.Fatalf("unexpected failure %q", msg)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	packages := docgen.Collect(newEnv(t))
+	for _, format := range []docgen.Format{docgen.Markdown, docgen.HTML} {
+		dir := t.TempDir()
+		if err := docgen.Write(dir, format, packages); err != nil {
+			t.Fatal(err)
+		}
+		index, err := docgen.FileName("index", format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := os.ReadFile(filepath.Join(dir, index))
+		if err != nil {
+			t.Fatal(err)
+		}
+		page, _ := docgen.FileName("github.com/jig/lisp/lib/core", format)
+		if page != "github.com-jig-lisp-lib-core"+filepath.Ext(index) || !strings.Contains(string(content), page) {
+			t.Fatalf("%s not linked from the index:\n%s", page, content)
+		}
+		content, err = os.ReadFile(filepath.Join(dir, page))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, expected := range []string{`id="_2b"`, "func(int, int) (int, error)", "Returns the sum of n1 and n2.", " 1 2)"} {
+			if !strings.Contains(string(content), expected) {
+				t.Fatalf("%s not found on the %s page", expected, format)
+			}
+		}
+	}
+	if err := docgen.Write(t.TempDir(), "pdf", packages); err == nil {
+		t.Fatal("expected an error for an unknown format")
+	}
+}
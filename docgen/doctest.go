@@ -0,0 +1,67 @@
+package docgen
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jig/lisp"
+	. "github.com/jig/lisp/types"
+)
+
+// Failure is an example that did not print the expected result
+type Failure struct {
+	Package string
+	Name    string
+	Example Example
+	Got     string // printed result (empty if Err is set)
+	Err     error
+}
+
+func (f Failure) Error() string {
+	if f.Err != nil {
+		return fmt.Sprintf("%s: %s: %s: %s", f.Package, f.Name, f.Example.Code, f.Err)
+	}
+	return fmt.Sprintf("%s: %s: %s: got %s, expected %s", f.Package, f.Name, f.Example.Code, f.Got, f.Example.Expected)
+}
+
+// RunExamples evaluates the examples of the packages on ns and returns the ones that
+// fail. It also returns the number of examples run. Results are compared with the
+// expected ones as values, so the order of the entries of maps and sets is not relevant.
+func RunExamples(ctx context.Context, ns EnvType, packages []Package) (int, []Failure) {
+	run := 0
+	failures := []Failure{}
+	for _, pkg := range packages {
+		for _, entry := range pkg.Entries {
+			for _, example := range entry.Examples {
+				run++
+				failure := Failure{Package: pkg.Name, Name: entry.Name, Example: example}
+				failure.Got, failure.Err = runExample(ctx, ns, entry.Name, example)
+				if failure.Got != "" || failure.Err != nil {
+					failures = append(failures, failure)
+				}
+			}
+		}
+	}
+	return run, failures
+}
+
+// runExample returns the printed result of an example if it is not the expected one
+func runExample(ctx context.Context, ns EnvType, name string, example Example) (string, error) {
+	cursor := NewCursorFile("doc:" + name)
+	ast, err := lisp.READ(example.Code, cursor, ns)
+	if err != nil {
+		return "", err
+	}
+	result, err := lisp.EVAL(ctx, ast, ns)
+	if err != nil {
+		return "", err
+	}
+	got := lisp.PRINT(result)
+	if got == example.Expected {
+		return "", nil
+	}
+	if expected, err := lisp.READ(example.Expected, cursor, ns); err == nil && Equal_Q(expected, result) {
+		return "", nil
+	}
+	return got, nil
+}
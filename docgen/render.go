@@ -0,0 +1,236 @@
+package docgen
+
+import (
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	texttemplate "text/template"
+	"unicode"
+)
+
+// Format is the format of the generated pages
+type Format string
+
+const (
+	Markdown Format = "markdown"
+	HTML     Format = "html"
+)
+
+// extension returns the file extension of the pages of the format
+func (f Format) extension() (string, error) {
+	switch f {
+	case Markdown:
+		return ".md", nil
+	case HTML:
+		return ".html", nil
+	default:
+		return "", fmt.Errorf("unknown documentation format %q (use markdown or html)", f)
+	}
+}
+
+// FileName returns the name of the page of a package (e.g. github.com-jig-lisp-lib-core.md)
+func FileName(pkg string, format Format) (string, error) {
+	ext, err := format.extension()
+	if err != nil {
+		return "", err
+	}
+	name := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '.' || r == '_' || r == '-' {
+			return r
+		}
+		return '-'
+	}, strings.TrimPrefix(pkg, "$"))
+	return strings.Trim(name, "-") + ext, nil
+}
+
+// Anchor returns the id of the section of a symbol on its page (symbols like + or
+// nil? are not valid ids)
+func Anchor(name string) string {
+	var sb strings.Builder
+	for _, r := range name {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-') {
+			sb.WriteRune(r)
+		} else {
+			fmt.Fprintf(&sb, "_%x", r)
+		}
+	}
+	return sb.String()
+}
+
+// Write writes an index page and a page per package on dir (created if needed)
+func Write(dir string, format Format, packages []Package) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	index, err := FileName("index", format)
+	if err != nil {
+		return err
+	}
+	if err := writeFile(filepath.Join(dir, index), func(w io.Writer) error {
+		return Index(w, format, packages)
+	}); err != nil {
+		return err
+	}
+	for _, pkg := range packages {
+		name, err := FileName(pkg.Name, format)
+		if err != nil {
+			return err
+		}
+		if err := writeFile(filepath.Join(dir, name), func(w io.Writer) error {
+			return Page(w, format, pkg)
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeFile(path string, write func(io.Writer) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Index writes the page that links to the pages of the packages
+func Index(w io.Writer, format Format, packages []Package) error {
+	switch format {
+	case Markdown:
+		return markdownIndex.Execute(w, packages)
+	case HTML:
+		return htmlIndex.Execute(w, packages)
+	default:
+		_, err := format.extension()
+		return err
+	}
+}
+
+// Page writes the page of a package
+func Page(w io.Writer, format Format, pkg Package) error {
+	switch format {
+	case Markdown:
+		return markdownPage.Execute(w, pkg)
+	case HTML:
+		return htmlPage.Execute(w, pkg)
+	default:
+		_, err := format.extension()
+		return err
+	}
+}
+
+func fileName(format Format) func(string) string {
+	return func(pkg string) string {
+		name, _ := FileName(pkg, format)
+		return name
+	}
+}
+
+var markdownIndex = texttemplate.Must(texttemplate.New("index").Funcs(texttemplate.FuncMap{
+	"file": fileName(Markdown),
+}).Parse(`# Reference
+{{range .}}
+- [{{.Name}}]({{file .Name}}) ({{len .Entries}} symbols)
+{{- end}}
+`))
+
+var markdownPage = texttemplate.Must(texttemplate.New("page").Funcs(texttemplate.FuncMap{
+	"anchor": Anchor,
+}).Parse(`# {{.Name}}
+
+[Index](index.md)
+{{range .Entries}}
+- [` + "`{{.Name}}`" + `](#{{anchor .Name}})
+{{- end}}
+{{range .Entries}}
+<a id="{{anchor .Name}}"></a>
+## ` + "`{{.Name}}`" + `
+
+{{.Kind}}
+{{- if .Arglists}}
+
+` + "```lisp\n{{.Arglists}}\n```" + `
+{{- end}}
+{{- if .GoName}}
+
+Go: ` + "`{{.GoName}}` `{{.GoSignature}}`" + `
+{{- end}}
+{{- if .Doc}}
+
+{{.Doc}}
+{{- end}}
+{{- if .Examples}}
+
+Examples:
+{{range .Examples}}
+` + "```lisp\n{{.Code}}\n;=> {{.Expected}}\n```" + `
+{{- end}}
+{{- end}}
+{{end}}`))
+
+const htmlStyle = `<style>
+body { font-family: sans-serif; max-width: 60em; margin: auto; padding: 1em; }
+pre, code { background: #f4f4f4; }
+pre { padding: .5em; }
+.kind { color: #666; font-style: italic; }
+</style>`
+
+var htmlIndex = htmltemplate.Must(htmltemplate.New("index").Funcs(htmltemplate.FuncMap{
+	"file": fileName(HTML),
+}).Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Reference</title>` + htmlStyle + `</head>
+<body>
+<h1>Reference</h1>
+<ul>
+{{- range .}}
+<li><a href="{{file .Name}}">{{.Name}}</a> ({{len .Entries}} symbols)</li>
+{{- end}}
+</ul>
+</body>
+</html>
+`))
+
+var htmlPage = htmltemplate.Must(htmltemplate.New("page").Funcs(htmltemplate.FuncMap{
+	"anchor": Anchor,
+}).Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>{{.Name}}</title>` + htmlStyle + `</head>
+<body>
+<h1>{{.Name}}</h1>
+<p><a href="index.html">Index</a></p>
+<ul>
+{{- range .Entries}}
+<li><a href="#{{anchor .Name}}"><code>{{.Name}}</code></a></li>
+{{- end}}
+</ul>
+{{- range .Entries}}
+<h2 id="{{anchor .Name}}"><code>{{.Name}}</code></h2>
+<p class="kind">{{.Kind}}</p>
+{{- if .Arglists}}
+<pre>{{.Arglists}}</pre>
+{{- end}}
+{{- if .GoName}}
+<p>Go: <code>{{.GoName}}</code> <code>{{.GoSignature}}</code></p>
+{{- end}}
+{{- if .Doc}}
+<pre>{{.Doc}}</pre>
+{{- end}}
+{{- if .Examples}}
+<p>Examples:</p>
+{{- range .Examples}}
+<pre>{{.Code}}
+;=&gt; {{.Expected}}</pre>
+{{- end}}
+{{- end}}
+{{- end}}
+</body>
+</html>
+`))
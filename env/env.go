@@ -143,6 +143,27 @@ func (e *Env) Symbols(newLine [][]rune, lastPartial string) [][]rune {
 	return newLine
 }
 
+// Names returns the sorted names defined on e (not on its outer environments)
+func (e *Env) Names() []string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	names := make([]string, 0, len(e.data))
+	for key := range e.data {
+		names = append(names, key)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Outer returns the outer environment of e (nil for top level environments)
+func (e *Env) Outer() types.EnvType {
+	if e.outer == nil {
+		return nil
+	}
+	return e.outer
+}
+
 func (e *Env) FindNT(key types.Symbol) types.EnvType {
 	if _, ok := e.data[key.Val]; ok {
 		return e
@@ -9,6 +9,7 @@ import (
 
 	"github.com/jig/lisp/env"
 	"github.com/jig/lisp/lib/core"
+	"github.com/jig/lisp/lisperror"
 	"github.com/jig/lisp/types"
 )
 
@@ -390,6 +391,10 @@ func TestLoadFileErrorStackTrace(t *testing.T) {
 	if !strings.Contains(errStr, "symbol 'undefined-in-loaded-file' not found") {
 		t.Fatalf("error should contain original message: %s", errStr)
 	}
+	// positions are the lines of the file
+	if !strings.HasPrefix(errStr, errorFile+":4:") {
+		t.Fatalf("error should reference line 4 of the file: %s", lines[0])
+	}
 
 	// The stack trace should reference the loaded file
 	// Check if the file path appears in the error
@@ -539,3 +544,51 @@ func TestMacroWithQuasiquoteError(t *testing.T) {
 	t.Logf("Total stack frames from macro+quasiquote: %d", atCount)
 	t.Logf("✓ Macro with quasiquote error stack trace validated")
 }
+
+func TestModuleHeaderPosition(t *testing.T) {
+	ns := env.NewEnv()
+	core.Load(ns)
+
+	// the header is the first line of embedded libraries (e.g. lib/core/header-basic.lisp)
+	_, err := REPL(context.Background(), ns, ";; $MODULE header-x\n\n(do\n  (undefined-sym))", nil)
+	if err == nil {
+		t.Fatal("expected error but got none")
+	}
+	if !strings.HasPrefix(err.Error(), "header-x:4:") {
+		t.Fatalf("error should reference line 4: %s", err)
+	}
+}
+
+func TestRenderError(t *testing.T) {
+	ns := env.NewEnv()
+	core.Load(ns)
+
+	code := "(do\n  (def a 1)\n  (+ a (undefined-fn 2)))"
+	_, err := REPL(context.Background(), ns, code, types.NewCursorFile("config.lisp"))
+	if err == nil {
+		t.Fatal("expected error but got none")
+	}
+
+	rendered := lisperror.Render(err, lisperror.RenderOptions{
+		Sources: lisperror.SourceMap{"config.lisp": code},
+	})
+	expected := "error: symbol 'undefined-fn' not found\n" +
+		" --> config.lisp:3\n" +
+		"  |\n" +
+		"3 |   (+ a (undefined-fn 2)))\n" +
+		"  |         ^~~~~~~~~~~"
+	if !strings.HasPrefix(rendered, expected) {
+		t.Fatalf("unexpected rendering:\n%s", rendered)
+	}
+	if strings.Contains(rendered, "\033[") {
+		t.Fatalf("plain rendering must not contain colors:\n%s", rendered)
+	}
+
+	colored := lisperror.Render(err, lisperror.RenderOptions{
+		Sources: lisperror.SourceMap{"config.lisp": code},
+		Color:   true,
+	})
+	if !strings.Contains(colored, "\033[") {
+		t.Fatalf("colored rendering must contain colors:\n%s", colored)
+	}
+}
@@ -10,6 +10,8 @@ import (
 	"github.com/jig/lisp/lib/concurrent/nsconcurrent"
 	"github.com/jig/lisp/lib/core/nscore"
 	"github.com/jig/lisp/lib/coreextented/nscoreextended"
+	"github.com/jig/lisp/lib/dispatch/nsdispatch"
+	"github.com/jig/lisp/lib/schema/nsschema"
 	"github.com/jig/lisp/lib/system/nssystem"
 	"github.com/jig/lisp/types"
 )
@@ -27,6 +29,8 @@ func ExampleEVAL() {
 		// {"command line args", nscore.LoadCmdLineArgs(command.PreParseArgs(os.Args))} // if needed
 		{"concurrent", nsconcurrent.Load},
 		{"core mal extended", nscoreextended.Load},
+		{"dispatch", nsdispatch.Load},
+		{"schema", nsschema.Load},
 		{"system", nssystem.Load},
 	} {
 		if err := library.load(newEnv); err != nil {
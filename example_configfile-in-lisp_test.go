@@ -25,3 +25,37 @@ func Example_configInLisp() {
 	// Output:
 	// sessions: 10
 }
+
+func ExampleUnmarshal() {
+	type Server struct {
+		Host string `lisp:"host,default=\"localhost\""`
+		Port int    `lisp:"port"`
+	}
+	type Config struct {
+		Sessions int      `lisp:"sessions"`
+		Servers  []Server `lisp:"servers"`
+	}
+
+	ast, _ := lisp.READ(
+		`{
+			:sessions 10
+			:servers [{:port 8080}
+			          {:host "example.com" :port 80}]
+		}`,
+		types.NewCursorFile("ExampleUnmarshal"),
+		nil,
+	)
+	var config Config
+	if err := lisp.Unmarshal(ast, &config); err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Printf("%+v\n", config)
+
+	ast, _ = lisp.READ(`{:servers [{:port "80"}]}`, types.NewCursorFile("config.lisp"), nil)
+	fmt.Println(lisp.Unmarshal(ast, &config))
+
+	// Output:
+	// {Sessions:10 Servers:[{Host:localhost Port:8080} {Host:example.com Port:80}]}
+	// config.lisp:1:12: .servers[0].port: expected int, got string
+}
@@ -0,0 +1,262 @@
+// Package format reformats Lisp source code to a canonical style.
+//
+// The formatter works on the concrete syntax tree read by [reader.ReadCST], so comments
+// and the preamble are kept. Line breaks chosen by the author are kept too (consecutive
+// blank lines are collapsed to one), while the rest of the whitespace is rewritten:
+//   - top level forms start on their own line;
+//   - bodies of special forms and of definitions (let, fn, defn, try, cond...) are
+//     indented two spaces from the opening parenthesis;
+//   - arguments of other calls are aligned with the first argument if it follows the
+//     function name on the same line, or indented one space otherwise;
+//   - items of vectors, maps and sets are aligned with the first item;
+//   - values of maps with one entry per line are aligned;
+//   - closing delimiters are kept on the line of the last item.
+//
+// Formatting is idempotent: formatting already formatted code does not change it.
+package format
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/jig/lisp/reader"
+	. "github.com/jig/lisp/types"
+)
+
+// bodyForms are the forms whose arguments are indented as a body
+var bodyForms = map[string]bool{
+	"def":       true,
+	"defn":      true,
+	"defmacro":  true,
+	"defmulti":  true,
+	"defmethod": true,
+	"fn":        true,
+	"fn*":       true,
+	"let":       true,
+	"let*":      true,
+	"if":        true,
+	"when":      true,
+	"do":        true,
+	"try":       true,
+	"catch":     true,
+	"finally":   true,
+	"cond":      true,
+	"future":    true,
+	"time":      true,
+	"benchmark": true,
+}
+
+// Source formats Lisp source code. cursor is only used to name the module on read errors
+// and might be nil.
+func Source(source string, cursor *Position) (string, error) {
+	cst, err := reader.ReadCST(source, cursor)
+	if err != nil {
+		return "", err
+	}
+	return Node(cst), nil
+}
+
+// Node formats a concrete syntax tree. Files are terminated with a new line, other nodes
+// are formatted as if they started on the first column.
+func Node(n *reader.Node) string {
+	f := &formatter{}
+	if n.Kind == reader.NodeFile {
+		f.file(n)
+	} else {
+		f.node(n)
+	}
+	return f.sb.String()
+}
+
+type formatter struct {
+	sb  strings.Builder
+	col int // column (0 based) where the next character is written
+}
+
+func (f *formatter) write(s string) {
+	f.sb.WriteString(s)
+	if i := strings.LastIndexByte(s, '\n'); i >= 0 {
+		f.col = utf8.RuneCountInString(s[i+1:])
+	} else {
+		f.col += utf8.RuneCountInString(s)
+	}
+}
+
+// newLines writes up to two line breaks (one blank line) and the indentation
+func (f *formatter) newLines(breaks, indent int) {
+	f.write(strings.Repeat("\n", min(breaks, 2)) + strings.Repeat(" ", indent))
+}
+
+// item is a non whitespace child with the number of line breaks found before it
+type item struct {
+	node   *reader.Node
+	breaks int
+}
+
+func items(children []*reader.Node) []item {
+	result := []item{}
+	breaks := 0
+	for _, child := range children {
+		if child.Kind == reader.NodeWhitespace {
+			breaks += strings.Count(child.Text, "\n")
+			continue
+		}
+		result = append(result, item{node: child, breaks: breaks})
+		breaks = 0
+	}
+	return result
+}
+
+func isComment(n *reader.Node) bool {
+	return n.Kind == reader.NodeComment || n.Kind == reader.NodePreamble
+}
+
+func (f *formatter) file(n *reader.Node) {
+	for i, it := range items(n.Children) {
+		switch {
+		case i == 0:
+		case isComment(it.node) && it.breaks == 0:
+			// trailing comment
+			f.write(" ")
+		default:
+			f.newLines(max(it.breaks, 1), 0)
+		}
+		f.node(it.node)
+	}
+	if f.sb.Len() > 0 {
+		f.write("\n")
+	}
+}
+
+func (f *formatter) node(n *reader.Node) {
+	switch n.Kind {
+	case reader.NodeComment, reader.NodePreamble:
+		f.write(strings.TrimRight(n.Text, " \t\r"))
+	case reader.NodePrefixed:
+		f.prefixed(n)
+	case reader.NodeList, reader.NodeVector, reader.NodeHashMap, reader.NodeSet, reader.NodeExternal:
+		f.collection(n)
+	default:
+		f.write(n.Text)
+	}
+}
+
+func (f *formatter) prefixed(n *reader.Node) {
+	start := f.col
+	f.write(n.Text)
+	its := items(n.Children)
+	for i, it := range its {
+		switch {
+		case isComment(it.node):
+			if i > 0 || it.breaks > 0 {
+				f.write(" ")
+			}
+		case i > 0 && isComment(its[i-1].node):
+			f.newLines(1, start+utf8.RuneCountInString(n.Text))
+		case i > 0:
+			// metadata and form of ^
+			f.write(" ")
+		}
+		f.node(it.node)
+	}
+}
+
+func (f *formatter) collection(n *reader.Node) {
+	open := f.col
+	f.write(n.Text)
+	its := items(n.Children)
+	pads := alignment(n, its)
+
+	argCol := -1 // column of the first argument of a call (if on the line of the function)
+	for i, it := range its {
+		switch {
+		case i == 0:
+			if isComment(it.node) {
+				f.write(" ")
+			}
+		case isComment(it.node) && it.breaks == 0:
+			f.write(" ")
+		case it.breaks > 0 || isComment(its[i-1].node):
+			f.newLines(max(it.breaks, 1), f.indent(n, open, its, argCol))
+		default:
+			f.write(strings.Repeat(" ", pads[i]))
+		}
+		if i == 1 && onSameLine(its, 1) {
+			argCol = f.col
+		}
+		f.node(it.node)
+	}
+	if len(its) > 0 && isComment(its[len(its)-1].node) {
+		f.newLines(1, f.indent(n, open, its, argCol))
+	}
+	f.write(n.Close)
+}
+
+// onSameLine reports if the i-th item is written on the line of the first item
+func onSameLine(its []item, i int) bool {
+	for _, it := range its[1 : i+1] {
+		if it.breaks > 0 || isComment(it.node) {
+			return false
+		}
+	}
+	return true
+}
+
+// indent returns the column for items written on a new line inside of collection n
+func (f *formatter) indent(n *reader.Node, open int, its []item, argCol int) int {
+	inner := open + utf8.RuneCountInString(n.Text)
+	if n.Kind != reader.NodeList && n.Kind != reader.NodeExternal {
+		return inner
+	}
+	if len(its) == 0 || its[0].node.Kind != reader.NodeAtom {
+		return inner
+	}
+	head := its[0].node.Text
+	if n.Kind == reader.NodeList && (bodyForms[head] || strings.HasPrefix(head, "def") || strings.HasPrefix(head, "with-")) {
+		return open + 2
+	}
+	if isData(head) {
+		return inner
+	}
+	if argCol >= 0 {
+		return argCol
+	}
+	return inner
+}
+
+// isData returns true for atoms that are not function names (e.g. lists of numbers)
+func isData(text string) bool {
+	if text == "" {
+		return true
+	}
+	switch c := text[0]; {
+	case c >= '0' && c <= '9', c == '"', c == ':', c == '$', c == '-' && len(text) > 1 && text[1] >= '0' && text[1] <= '9':
+		return true
+	}
+	return text == "nil" || text == "true" || text == "false"
+}
+
+// alignment returns the spaces written before each item of a hash map with an entry per
+// line, so values are aligned. Other collections get a single space between items.
+func alignment(n *reader.Node, its []item) []int {
+	pads := make([]int, len(its))
+	for i := range pads {
+		pads[i] = 1
+	}
+	if n.Kind != reader.NodeHashMap || len(its) < 4 || len(its)%2 != 0 {
+		return pads
+	}
+	width := 0
+	for i := 0; i < len(its); i += 2 {
+		key, value := its[i], its[i+1]
+		if isComment(key.node) || isComment(value.node) || key.node.Kind != reader.NodeAtom ||
+			value.breaks > 0 || (i > 0 && key.breaks == 0) {
+			return pads
+		}
+		width = max(width, utf8.RuneCountInString(key.node.Text))
+	}
+	for i := 0; i < len(its); i += 2 {
+		pads[i+1] = width - utf8.RuneCountInString(its[i].node.Text) + 1
+	}
+	return pads
+}
@@ -0,0 +1,161 @@
+package format_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jig/lisp/format"
+	"github.com/jig/lisp/reader"
+)
+
+func TestSource(t *testing.T) {
+	for _, tc := range []struct {
+		name, source, expected string
+	}{
+		{
+			name:     "top level forms",
+			source:   "  (def a 1)   (def b 2)\n\n\n\n(prn a)",
+			expected: "(def a 1)\n(def b 2)\n\n(prn a)\n",
+		},
+		{
+			name:     "defn body",
+			source:   "(defn f [x]\n(let [y 1\nz 2]\n(+ x y z)))",
+			expected: "(defn f [x]\n  (let [y 1\n        z 2]\n    (+ x y z)))\n",
+		},
+		{
+			name:     "try catch finally",
+			source:   "(try\n(throw 1)\n(catch e\n(prn e))\n(finally\n(prn :done)))",
+			expected: "(try\n  (throw 1)\n  (catch e\n    (prn e))\n  (finally\n    (prn :done)))\n",
+		},
+		{
+			name:     "cond",
+			source:   "(cond\n(= x 1) :one\n   :else :other)",
+			expected: "(cond\n  (= x 1) :one\n  :else :other)\n",
+		},
+		{
+			name:     "call arguments",
+			source:   "(str \"a\"\n\"b\")\n(str\n\"a\"\n\"b\")",
+			expected: "(str \"a\"\n     \"b\")\n(str\n \"a\"\n \"b\")\n",
+		},
+		{
+			name:     "map alignment",
+			source:   "{:a 1\n :long-key 2\n   :b {:c 3}}",
+			expected: "{:a        1\n :long-key 2\n :b        {:c 3}}\n",
+		},
+		{
+			name:     "maps on a line are not aligned",
+			source:   "{:a   1 :long-key 2}",
+			expected: "{:a 1 :long-key 2}\n",
+		},
+		{
+			name:     "comments",
+			source:   "; header   \n(do ; trailing\n   (prn 1) ;; after\n ; last\n   )",
+			expected: "; header\n(do ; trailing\n  (prn 1) ;; after\n  ; last\n  )\n",
+		},
+		{
+			name:     "preamble",
+			source:   ";; $A 1\n;; $B \"x\"\n\n(+ $A   1)",
+			expected: ";; $A 1\n;; $B \"x\"\n\n(+ $A 1)\n",
+		},
+		{
+			name:     "prefixes",
+			source:   "(def l '  (1 2))\n`(a ~b ~@ c)\n^{:a 1}   [x]",
+			expected: "(def l '(1 2))\n`(a ~b ~@c)\n^{:a 1} [x]\n",
+		},
+		{
+			name:     "multiline strings",
+			source:   "(prn ¬a\nb¬ 1\n2)",
+			expected: "(prn ¬a\nb¬ 1\n     2)\n",
+		},
+		{
+			name:     "constructors",
+			source:   "«example 1\n\"s\"»",
+			expected: "«example 1\n         \"s\"»\n",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			formatted, err := format.Source(tc.source, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if formatted != tc.expected {
+				t.Fatalf("got:\n%s\nexpected:\n%s", formatted, tc.expected)
+			}
+			again, err := format.Source(formatted, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if again != formatted {
+				t.Fatalf("not idempotent:\n%s\n%s", formatted, again)
+			}
+		})
+	}
+}
+
+func TestSourceError(t *testing.T) {
+	if _, err := format.Source("(a (b)", nil); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+// TestExamples checks that formatting is idempotent and only changes whitespace
+func TestExamples(t *testing.T) {
+	files, err := filepath.Glob("../examples/*.lisp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, fileName := range files {
+		b, err := os.ReadFile(fileName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		formatted, err := format.Source(string(b), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		again, err := format.Source(formatted, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if again != formatted {
+			t.Errorf("%s: not idempotent", fileName)
+		}
+		if before, after := leaves(t, string(b)), leaves(t, formatted); before != after {
+			t.Errorf("%s: formatting changed more than whitespace", fileName)
+		}
+	}
+}
+
+func leaves(t *testing.T, source string) string {
+	cst, err := reader.ReadCST(source, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var texts []string
+	var walk func(n *reader.Node)
+	walk = func(n *reader.Node) {
+		if n.Kind == reader.NodeWhitespace {
+			return
+		}
+		texts = append(texts, strings.TrimRight(n.Text, " \t"))
+		for _, child := range n.Children {
+			walk(child)
+		}
+		texts = append(texts, n.Close)
+	}
+	walk(cst)
+	return strings.Join(texts, "\x00")
+}
+
+func TestDiff(t *testing.T) {
+	if diff := format.Diff("a.lisp", "(a)\n", "(a)\n"); diff != "" {
+		t.Fatalf("expected no diff, got %q", diff)
+	}
+	diff := format.Diff("a.lisp", "(def a\n    1)\n(prn a)\n", "(def a\n  1)\n(prn a)\n")
+	const expected = "--- a.lisp.orig\n+++ a.lisp\n@@ -1,3 +1,3 @@\n (def a\n-    1)\n+  1)\n (prn a)\n"
+	if diff != expected {
+		t.Fatalf("got:\n%s\nexpected:\n%s", diff, expected)
+	}
+}
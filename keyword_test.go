@@ -0,0 +1,103 @@
+package lisp
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/jig/lisp/env"
+	"github.com/jig/lisp/lib/core"
+	"github.com/jig/lisp/lnotation"
+	"github.com/jig/lisp/types"
+)
+
+func TestKeyword(t *testing.T) {
+	if types.NewKeyword("a") != types.NewKeyword("a") || types.NewKeyword("a") == types.NewKeyword("b") {
+		t.Fatal("keywords are not interned")
+	}
+	if types.NewKeyword("") != (types.Keyword{}) {
+		t.Fatal("the keyword of empty name is not the zero keyword")
+	}
+
+	ast, err := READ(`{:a "ʞb" "c" :d}`, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hm := ast.(types.HashMap)
+	if hm.Val[types.NewKeyword("a")] != "ʞb" || hm.Val["c"] != types.NewKeyword("d") {
+		t.Fatalf("unexpected %#v", hm.Val)
+	}
+	if s := PRINT(hm.Val[types.NewKeyword("a")]); s != `"ʞb"` {
+		t.Fatalf("a string starting with ʞ is printed as %s", s)
+	}
+
+	b, err := json.Marshal(hm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != `{"a":"ʞb","c":"d"}` {
+		t.Fatalf("unexpected JSON %s", b)
+	}
+}
+
+func TestKeywordCore(t *testing.T) {
+	ns := env.NewEnv()
+	core.Load(ns)
+	for code, expected := range map[string]string{
+		`(keyword "a")`:                    `:a`,
+		`(keyword :a)`:                     `:a`,
+		`(= :a (keyword "a"))`:             `true`,
+		`(keyword? :a)`:                    `true`,
+		`(keyword? "ʞa")`:                  `false`,
+		`(string? "ʞa")`:                   `true`,
+		`(type? :a)`:                       `"keyword"`,
+		`(get {:a 1 "a" 2} :a)`:            `1`,
+		`(get {:a 1 "a" 2} "a")`:           `2`,
+		`(contains? #{:a} :a)`:             `true`,
+		`(contains? #{:a} "a")`:            `false`,
+		`(get (assoc {} :b 2) :b)`:         `2`,
+		`(count (dissoc {:a 1 "a" 2} :a))`: `1`,
+		`(str :a)`:                         `":a"`,
+	} {
+		result, err := REPL(context.Background(), ns, code, nil)
+		if err != nil {
+			t.Fatalf("%s: %s", code, err)
+		}
+		if result != expected {
+			t.Fatalf("%s: expected %s, got %s", code, expected, result)
+		}
+	}
+}
+
+func TestKeywordLegacy(t *testing.T) {
+	hm := types.LegacyHashMap(map[string]types.MalType{
+		"ʞa": types.Vector{Val: []types.MalType{"ʞb", "c"}},
+		"d":  1,
+	})
+	if s := PRINT(hm.Val[types.NewKeyword("a")]); s != `[:b "c"]` {
+		t.Fatalf("unexpected %s", s)
+	}
+	if hm.Val["d"] != 1 {
+		t.Fatalf("unexpected %#v", hm.Val)
+	}
+	if types.FromLegacy("ʞe") != types.NewKeyword("e") || types.FromLegacy("e") != "e" {
+		t.Fatal("unexpected legacy conversion")
+	}
+
+	// strings are never read as keywords: keywords are explicit
+	m := lnotation.HM(map[string]any{":a": 1, "b": 2, "ʞc": 3})
+	if m.Val[":a"] != 1 || m.Val["b"] != 2 || m.Val["ʞc"] != 3 {
+		t.Fatalf("unexpected %#v", m.Val)
+	}
+	m = lnotation.HMK(map[string]any{"a": 1, "b": map[string]any{"c": 3}})
+	if m.Val[types.NewKeyword("a")] != 1 || m.Val[types.NewKeyword("b")].(types.HashMap).Val[types.NewKeyword("c")] != 3 {
+		t.Fatalf("unexpected %#v", m.Val)
+	}
+	s := lnotation.SET([]string{":a", "ʞb"})
+	if _, ok := s.Val[":a"]; !ok || len(s.Val) != 2 {
+		t.Fatalf("unexpected %#v", s.Val)
+	}
+	if _, ok := lnotation.SETK([]string{"a"}).Val[types.NewKeyword("a")]; !ok {
+		t.Fatal("keyword expected on the set")
+	}
+}
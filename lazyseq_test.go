@@ -0,0 +1,57 @@
+package lisp
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jig/lisp/env"
+	"github.com/jig/lisp/lib/core"
+	"github.com/jig/lisp/types"
+)
+
+func TestLazySeq(t *testing.T) {
+	ns := env.NewEnv()
+	core.Load(ns)
+	ns.Set(types.Symbol{Val: "naturals"}, types.Func{Fn: func(ctx context.Context, a []types.MalType) (types.MalType, error) {
+		i := -1
+		return types.NewLazySeq(func() (types.MalType, bool) {
+			i++
+			return i, true
+		}), nil
+	}})
+	ns.Set(types.Symbol{Val: "countdown"}, types.Func{Fn: func(ctx context.Context, a []types.MalType) (types.MalType, error) {
+		n := a[0].(int) + 1
+		return types.NewLazySeq(func() (types.MalType, bool) {
+			n--
+			return n, n > 0
+		}), nil
+	}})
+
+	for code, expected := range map[string]string{
+		`(first (naturals))`:                        `0`,
+		`(first (rest (rest (naturals))))`:          `2`,
+		`(take 3 (naturals))`:                       `(0 1 2)`,
+		`(nth (naturals) 5)`:                        `5`,
+		`(first (drop 10 (naturals)))`:              `10`,
+		`(empty? (naturals))`:                       `false`,
+		`(countdown 3)`:                             `(3 2 1)`,
+		`(count (countdown 3))`:                     `3`,
+		`(seq (countdown 0))`:                       `nil`,
+		`(empty? (countdown 0))`:                    `true`,
+		`(= [3 2 1] (countdown 3))`:                 `true`,
+		`(= (countdown 2) '(2 1))`:                  `true`,
+		`(sequential? (countdown 1))`:               `true`,
+		`(type? (naturals))`:                        `"lazy-seq"`,
+		`(apply + (countdown 2))`:                   `3`,
+		`(map (fn [x] (* x x)) (countdown 3))`:      `(9 4 1)`,
+		`(let [s (countdown 2)] [(vec s) (vec s)])`: `[[2 1] [2 1]]`,
+	} {
+		result, err := REPL(context.Background(), ns, code, nil)
+		if err != nil {
+			t.Fatalf("%s: %s", code, err)
+		}
+		if result != expected {
+			t.Fatalf("%s: expected %s, got %s", code, expected, result)
+		}
+	}
+}
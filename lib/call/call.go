@@ -20,7 +20,8 @@ func CallOverrideFN(namespace types.EnvType, overrideFN string, fIn types.MalTyp
 }
 
 func call(overrideFN *string, namespace types.EnvType, fIn types.MalType, args ...int) {
-	functionFullName := strings.ToLower(runtime.FuncForPC(reflect.ValueOf(fIn).Pointer()).Name())
+	goFunctionName := runtime.FuncForPC(reflect.ValueOf(fIn).Pointer()).Name()
+	functionFullName := strings.ToLower(goFunctionName)
 	n := strings.LastIndex(functionFullName, ".")
 	if len(functionFullName) == -1 {
 		panic(fmt.Errorf("invalid function full name (name is %s)", runtime.FuncForPC(reflect.ValueOf(fIn).Pointer()).Name()))
@@ -38,12 +39,8 @@ func call(overrideFN *string, namespace types.EnvType, fIn types.MalType, args .
 
 	finType := reflect.TypeOf(fIn)
 	finValue := reflect.ValueOf(fIn)
-	outParams := finType.NumOut()
 
-	contextRequired := false
-	if finType.NumIn() >= 1 && finType.In(0).Implements(reflect.TypeOf((*context.Context)(nil)).Elem()) {
-		contextRequired = true
-	}
+	contextRequired := finType.NumIn() >= 1 && finType.In(0).Implements(contextType)
 
 	var minArgs, maxArgs int
 	switch len(args) {
@@ -58,7 +55,9 @@ func call(overrideFN *string, namespace types.EnvType, fIn types.MalType, args .
 		}
 		minArgs, maxArgs = args[0], args[1]
 	default:
-		if !finType.IsVariadic() {
+		if hasOptions(finType) {
+			minArgs, maxArgs = finType.NumIn()-1, unlimitedArgments // options are optional
+		} else if !finType.IsVariadic() {
 			minArgs, maxArgs = finType.NumIn(), finType.NumIn()
 		} else {
 			minArgs, maxArgs = 0, unlimitedArgments
@@ -71,58 +70,27 @@ func call(overrideFN *string, namespace types.EnvType, fIn types.MalType, args .
 		panic(fmt.Errorf("%s: argument count bounds cannot be negative", functionFullName))
 	}
 
-	var extCall func(context.Context, []types.MalType) (types.MalType, error)
-	switch finType.NumOut() {
-	case 0:
-		if contextRequired {
-			extCall = func(ctx context.Context, args []types.MalType) (result types.MalType, err error) {
-				defer _recover(functionFullName, &err)
-				return _nil_nil(finValue.Call(_args_ctx(ctx, minArgs, maxArgs, args)))
-			}
-		} else {
-			extCall = func(_ context.Context, args []types.MalType) (result types.MalType, err error) {
-				defer _recover(functionFullName, &err)
-				return _nil_nil(finValue.Call(_args(minArgs, maxArgs, args)))
-			}
-		}
-	case 1:
-		if contextRequired {
-			extCall = func(ctx context.Context, args []types.MalType) (result types.MalType, err error) {
-				defer _recover(functionFullName, &err)
-				return _nil_error(finValue.Call(_args_ctx(ctx, minArgs, maxArgs, args)))
-			}
-		} else {
-			extCall = func(_ context.Context, args []types.MalType) (result types.MalType, err error) {
-				defer _recover(functionFullName, &err)
-				return _nil_error(finValue.Call(_args(minArgs, maxArgs, args)))
-			}
-		}
-	case 2:
-		if contextRequired {
-			extCall = func(ctx context.Context, args []types.MalType) (result types.MalType, err error) {
-				defer _recover(functionFullName, &err)
-				return _result_error(finValue.Call(_args_ctx(ctx, minArgs, maxArgs, args)))
-			}
-		} else {
-			extCall = func(_ context.Context, args []types.MalType) (result types.MalType, err error) {
-				defer _recover(functionFullName, &err)
-				return _result_error(finValue.Call(_args(minArgs, maxArgs, args)))
-			}
-		}
-	default:
-		panic(fmt.Errorf("%s: wrong number of results (%d instead of 2)", functionFullName, outParams))
+	extCall, ok := types.ExternalCall(nil), false
+	if !hasOptions(finType) {
+		extCall, ok = staticAdapter(functionFullName, goFunctionName, fIn, contextRequired, minArgs, maxArgs)
+	}
+	if !ok {
+		extCall = adapter(functionFullName, goFunctionName, finValue, contextRequired, minArgs, maxArgs)
 	}
 
-	namespace.Set(types.Symbol{Val: functionName}, types.Func{Fn: extCall})
+	namespace.Set(types.Symbol{Val: functionName}, types.Func{
+		Fn:        extCall,
+		Signature: signature(functionName, packageName, goFunctionName, finType, contextRequired, minArgs, maxArgs),
+	})
 
 	_, err := namespace.Update(types.Symbol{Val: "_PACKAGES_"}, func(_hm types.MalType) (types.MalType, error) {
 		if _hm == nil {
-			_hm = types.HashMap{Val: make(map[string]types.MalType)}
+			_hm = types.HashMap{Val: make(map[types.MalType]types.MalType)}
 		}
 		hm := _hm.(types.HashMap)
 		set, ok := hm.Val[packageName].(types.Set)
 		if !ok {
-			set = types.Set{Val: make(map[string]struct{})}
+			set = types.Set{Val: make(map[types.MalType]struct{})}
 		}
 		set.Val[functionName] = struct{}{}
 		hm.Val[packageName] = set
@@ -133,14 +101,97 @@ func call(overrideFN *string, namespace types.EnvType, fIn types.MalType, args .
 	}
 }
 
-func _recover(fFullName string, err *error) {
+// adapter returns the implementation of a Lisp function calling finValue
+func adapter(functionFullName, goFunctionName string, finValue reflect.Value, contextRequired bool, minArgs, maxArgs int) types.ExternalCall {
+	finType := finValue.Type()
+	var results func(s *sequences, res []reflect.Value) (types.MalType, error)
+	switch outParams := finType.NumOut(); {
+	case outParams == 0:
+		results = _nil_nil
+	case outParams == 1 && !finType.Out(0).Implements(errorType):
+		results = _result
+	case outParams == 1:
+		results = _nil_error
+	case outParams == 2 && finType.Out(1).Implements(errorType):
+		results = _result_error
+	default:
+		// more results (or two without an error) are returned as a vector
+		withError := finType.Out(outParams - 1).Implements(errorType)
+		results = func(s *sequences, res []reflect.Value) (types.MalType, error) {
+			return _vector(s, withError, res)
+		}
+	}
+	return func(ctx context.Context, args []types.MalType) (result types.MalType, err error) {
+		defer _recover(functionFullName, goFunctionName, &err)
+		s := newSequences(ctx)
+		defer s.release()
+		var in []reflect.Value
+		if contextRequired {
+			in = _args_ctx(s, finType, minArgs, maxArgs, args)
+		} else {
+			in = _args(s, finType, minArgs, maxArgs, args)
+		}
+		res := finValue.Call(in)
+		s.check()
+		return results(s, res)
+	}
+}
+
+// argumentError is panicked when a function is called with wrong arguments. Unlike
+// other panics it is not a bug on the Go function, so it does not become a PanicError.
+type argumentError struct {
+	error
+}
+
+func signature(name, packageName, goName string, finType reflect.Type, contextRequired bool, minArgs, maxArgs int) *types.Signature {
+	sig := &types.Signature{
+		Name:     name,
+		Package:  packageName,
+		GoName:   goName,
+		MinArgs:  minArgs,
+		MaxArgs:  maxArgs,
+		Variadic: finType.IsVariadic(),
+	}
+	first := 0
+	if contextRequired {
+		first = 1
+		sig.MinArgs = max(sig.MinArgs-1, 0)
+		if maxArgs != unlimitedArgments {
+			sig.MaxArgs--
+		}
+	}
+	if maxArgs == unlimitedArgments {
+		sig.MaxArgs = types.UnlimitedArgs
+	}
+	for i := first; i < finType.NumIn(); i++ {
+		sig.In = append(sig.In, finType.In(i))
+	}
+	for i := 0; i < finType.NumOut(); i++ {
+		sig.Out = append(sig.Out, finType.Out(i))
+	}
+	if hasOptions(finType) {
+		sig.Arglist = arglist(sig.In[:len(sig.In)-1], false)
+		sig.Arglist.Val = append(sig.Arglist.Val, types.Symbol{Val: "&"}, optionsArglist(sig.In[len(sig.In)-1]))
+	} else {
+		sig.Arglist = arglist(sig.In, sig.Variadic)
+	}
+	return sig
+}
+
+func _recover(fFullName, goFullName string, err *error) {
 	rerr := recover()
 	if rerr != nil {
 		switch rerr := rerr.(type) {
-		case error:
-			*err = lisperror.NewGoError(fFullName, rerr)
+		case argumentError:
+			if argErr, ok := rerr.error.(*ArgumentError); ok {
+				// the function name is already part of the message
+				argErr.Function = fFullName
+				*err = lisperror.NewLispError(argErr, nil)
+				return
+			}
+			*err = lisperror.NewGoError(fFullName, rerr.error)
 		default:
-			*err = lisperror.NewLispError(rerr, nil)
+			*err = lisperror.NewLispError(lisperror.NewPanicError(fFullName, goFullName, rerr), nil)
 		}
 
 	}
@@ -148,69 +199,90 @@ func _recover(fFullName string, err *error) {
 
 const unlimitedArgments = 1000
 
-func _args_ctx(ctx context.Context, minParams, maxParams int, args []types.MalType) []reflect.Value {
-	if len(args) < minParams-1 || len(args) > maxParams-1 {
-		if maxParams == unlimitedArgments {
-			panic(fmt.Errorf("wrong number of arguments (%d instead of a minimum of %d)", len(args), minParams-1))
+var (
+	contextType = reflect.TypeFor[context.Context]()
+	errorType   = reflect.TypeFor[error]()
+)
+
+// _arity panics if the number of arguments n is not within the bounds of the function
+// (that count the context parameter if contextRequired)
+func _arity(contextRequired bool, minParams, maxParams, n int) {
+	unlimited := maxParams == unlimitedArgments
+	if contextRequired {
+		minParams, maxParams = minParams-1, maxParams-1
+	}
+	if n < minParams || n > maxParams {
+		if unlimited {
+			panic(argumentError{fmt.Errorf("wrong number of arguments (%d instead of a minimum of %d)", n, minParams)})
 		} else {
 			if minParams == maxParams {
-				panic(fmt.Errorf("wrong number of arguments (%d instead of %d)", len(args), minParams-1))
+				panic(argumentError{fmt.Errorf("wrong number of arguments (%d instead of %d)", n, minParams)})
 			} else {
-				panic(fmt.Errorf("wrong number of arguments (%d instead of %d…%d)", len(args), minParams-1, maxParams-1))
+				panic(argumentError{fmt.Errorf("wrong number of arguments (%d instead of %d…%d)", n, minParams, maxParams)})
 			}
 		}
 	}
+}
 
-	in := make([]reflect.Value, 1+len(args))
-	in[0] = reflect.ValueOf(ctx)
-	for k, param := range args {
-		if param != nil {
-			in[k+1] = reflect.ValueOf(param)
-		} else {
-			in[k+1] = reflect.Zero(reflect.TypeOf([]types.MalType{}).Elem())
-		}
-	}
-	return in
+func _args_ctx(s *sequences, finType reflect.Type, minParams, maxParams int, args []types.MalType) []reflect.Value {
+	_arity(true, minParams, maxParams, len(args))
+	return _convert(s, finType, []reflect.Value{reflect.ValueOf(s.ctx)}, args)
 }
 
-func _args(minParams, maxParams int, args []types.MalType) []reflect.Value {
-	if len(args) < minParams || len(args) > maxParams {
-		if maxParams == unlimitedArgments {
-			panic(fmt.Errorf("wrong number of arguments (%d instead of a minimum of %d)", len(args), minParams))
-		} else {
-			if minParams == maxParams {
-				panic(fmt.Errorf("wrong number of arguments (%d instead of %d)", len(args), minParams))
-			} else {
-				panic(fmt.Errorf("wrong number of arguments (%d instead of %d…%d)", len(args), minParams, maxParams))
-			}
+func _args(s *sequences, finType reflect.Type, minParams, maxParams int, args []types.MalType) []reflect.Value {
+	_arity(false, minParams, maxParams, len(args))
+	return _convert(s, finType, nil, args)
+}
+
+// _convert appends the arguments converted to the parameter types of the function to
+// the values of its first parameters (the context)
+func _convert(s *sequences, finType reflect.Type, in []reflect.Value, args []types.MalType) []reflect.Value {
+	first := len(in)
+	if hasOptions(finType) {
+		positional := finType.NumIn() - 1 - first
+		for k := 0; k < positional; k++ {
+			in = append(in, convert(s, k+1, args[k], finType.In(first+k)))
 		}
+		return append(in, options(positional+1, args[positional:], finType.In(finType.NumIn()-1)))
 	}
-
-	in := make([]reflect.Value, len(args))
 	for k, param := range args {
-		if param != nil {
-			in[k] = reflect.ValueOf(param)
-		} else {
-			in[k] = reflect.Zero(reflect.TypeOf([]types.MalType{}).Elem())
-		}
+		in = append(in, convert(s, k+1, param, paramType(finType, first+k)))
 	}
 	return in
 }
 
-func _nil_nil(res []reflect.Value) (result types.MalType, err error) {
+func _nil_nil(_ *sequences, res []reflect.Value) (result types.MalType, err error) {
 	return nil, nil
 }
 
-func _nil_error(res []reflect.Value) (result types.MalType, err error) {
+func _result(s *sequences, res []reflect.Value) (result types.MalType, err error) {
+	return fromGo(s, res[0]), nil
+}
+
+func _nil_error(_ *sequences, res []reflect.Value) (result types.MalType, err error) {
 	if res[0].Interface() == nil {
 		return nil, nil
 	}
 	return nil, res[0].Interface().(error)
 }
 
-func _result_error(res []reflect.Value) (result types.MalType, err error) {
+func _result_error(s *sequences, res []reflect.Value) (result types.MalType, err error) {
 	if res[1].Interface() == nil {
-		return res[0].Interface(), nil
+		return fromGo(s, res[0]), nil
+	}
+	return fromGo(s, res[0]), res[1].Interface().(error)
+}
+
+func _vector(s *sequences, withError bool, res []reflect.Value) (result types.MalType, err error) {
+	if withError {
+		if res[len(res)-1].Interface() != nil {
+			err = res[len(res)-1].Interface().(error)
+		}
+		res = res[:len(res)-1]
+	}
+	vector := types.Vector{Val: make([]types.MalType, len(res))}
+	for i, v := range res {
+		vector.Val[i] = fromGo(s, v)
 	}
-	return res[0].Interface(), res[1].Interface().(error)
+	return vector, err
 }
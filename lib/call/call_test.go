@@ -4,12 +4,14 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/jig/lisp"
 	"github.com/jig/lisp/env"
+	"github.com/jig/lisp/lisperror"
 	"github.com/jig/lisp/types"
 )
 
@@ -189,7 +191,7 @@ func TestLispNil(t *testing.T) {
 		t.Fatal(err)
 	}
 	_, err = lisp.EVAL(context.Background(), ast, ns)
-	if !strings.Contains(err.Error(), "reflect: cannot use types.MalType as type int in Call") {
+	if !strings.Contains(err.Error(), "[sum-example]: argument 1 (int): expected int, got nil") {
 		t.Fatal(err)
 	}
 }
@@ -199,9 +201,16 @@ func TestWrongTypePassed(t *testing.T) {
 	Call(ns, divExample)
 
 	_, err := lisp.REPL(context.Background(), ns, `(divexample "hello" "world")`, types.NewCursorFile(t.Name()))
-	if !strings.Contains(err.Error(), "reflect: Call using string as type int") {
+	if !strings.Contains(err.Error(), "[divexample]: argument 1 (int): expected int, got string") {
 		t.Fatal(err)
 	}
+	var argErr *ArgumentError
+	if !errors.As(err, &argErr) {
+		t.Fatalf("expected an *ArgumentError, got %T", err)
+	}
+	if argErr.Function != "github.com/jig/lisp/lib/call[divexample]" || argErr.Index != 1 || argErr.Expected.Kind() != reflect.Int || argErr.Value != "hello" {
+		t.Fatalf("unexpected %+v", argErr)
+	}
 }
 
 func TestCount(t *testing.T) {
@@ -247,6 +256,89 @@ func TestNilResponse(t *testing.T) {
 	}
 }
 
+func TestPanicKeepsGoStack(t *testing.T) {
+	lisperror.CaptureGoStack = true
+	defer func() { lisperror.CaptureGoStack = false }()
+
+	ns := env.NewEnv()
+	Call(ns, divExample)
+	Call(ns, panicExample)
+
+	_, err := lisp.REPL(context.Background(), ns, "(do\n  (divexample (panicexample) 1))", types.NewCursorFile(t.Name()))
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	var panicErr *lisperror.PanicError
+	if !errors.As(err, &panicErr) {
+		t.Fatalf("expected a PanicError (got %T)", err)
+	}
+	if !strings.HasSuffix(panicErr.GoFunction, "call.panicExample") {
+		t.Fatalf("unexpected Go function %q", panicErr.GoFunction)
+	}
+	if panicErr.Position == nil || panicErr.Position.BeginRow != 2 {
+		t.Fatalf("unexpected Lisp position %v", panicErr.Position)
+	}
+	if !strings.Contains(string(panicErr.GoStack), "call.panicExample") {
+		t.Fatalf("Go stack not captured:\n%s", panicErr.GoStack)
+	}
+	if !strings.Contains(fmt.Sprintf("%+v", panicErr), "go stack:") {
+		t.Fatalf("%%+v must print the Go stack")
+	}
+}
+
+func TestPanicWithoutGoStack(t *testing.T) {
+	ns := env.NewEnv()
+	Call(ns, panicExample)
+
+	_, err := lisp.REPL(context.Background(), ns, `(panicexample)`, types.NewCursorFile(t.Name()))
+	var panicErr *lisperror.PanicError
+	if !errors.As(err, &panicErr) {
+		t.Fatalf("expected a PanicError (got %T)", err)
+	}
+	if panicErr.GoStack != nil {
+		t.Fatal("Go stack must not be captured by default")
+	}
+	if !strings.Contains(err.Error(), "call[panicexample]: runtime error: index out of range [3] with length 0") {
+		t.Fatal(err)
+	}
+}
+
+func TestPanicWithValue(t *testing.T) {
+	lisperror.CaptureGoStack = true
+	defer func() { lisperror.CaptureGoStack = false }()
+
+	ns := env.NewEnv()
+	CallOverrideFN(ns, "boom", func() (int, error) { panic("boom") })
+
+	_, err := lisp.REPL(context.Background(), ns, `(boom)`, types.NewCursorFile(t.Name()))
+	var panicErr *lisperror.PanicError
+	if !errors.As(err, &panicErr) {
+		t.Fatalf("expected a PanicError (got %T)", err)
+	}
+	if !strings.HasSuffix(panicErr.Function, "[boom]") || panicErr.GoFunction == "" {
+		t.Fatalf("unexpected functions %q and %q", panicErr.Function, panicErr.GoFunction)
+	}
+	if panicErr.GoStack == nil {
+		t.Fatal("Go stack not captured")
+	}
+	if !strings.Contains(err.Error(), "[boom]: boom") {
+		t.Fatal(err)
+	}
+
+	res, err := lisp.REPL(context.Background(), ns, `(try (boom) (catch e e))`, types.NewCursorFile(t.Name()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res != `"boom"` {
+		t.Fatalf("panic value must be caught as it is (got %v)", res)
+	}
+}
+
+func panicExample() (int, error) {
+	var a []int
+	return a[3], nil
+}
+
 func count(seq types.MalType) (types.MalType, error) {
 	switch seq := seq.(type) {
 	case types.List:
@@ -0,0 +1,70 @@
+package call
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/jig/lisp/bind"
+	"github.com/jig/lisp/types"
+)
+
+// ArgumentError is the error of an argument that cannot be converted to the type of the
+// parameter of a Go function
+type ArgumentError struct {
+	Function string // full name of the function (as on errors)
+	Index    int    // of the argument, starting at 1
+	Expected reflect.Type
+	Value    types.MalType
+	Err      error
+}
+
+func (e *ArgumentError) Error() string {
+	msg := fmt.Sprintf("argument %d (%s): %s", e.Index, e.Expected, e.Err)
+	if e.Function != "" {
+		return e.Function + ": " + msg
+	}
+	return msg
+}
+
+func (e *ArgumentError) Unwrap() error {
+	return e.Err
+}
+
+// convert returns the argument at index (starting at 1) as a value of type t. Values
+// assignable to t are passed as they are; others are converted as package bind does:
+// numbers are widened, keywords become their names, vectors and lists become slices and
+// hash maps become Go maps and structs (by their lisp tags). Lists, vectors and lazy sequences are
+// converted to channels and iterators (see [toSequence]).
+func convert(s *sequences, index int, arg types.MalType, t reflect.Type) reflect.Value {
+	if sequential(t) && !(arg != nil && reflect.TypeOf(arg).AssignableTo(t)) {
+		return toSequence(s, index, arg, t)
+	}
+	if arg == nil {
+		switch t.Kind() {
+		case reflect.Pointer, reflect.Interface, reflect.Slice, reflect.Map, reflect.Func, reflect.Chan:
+			return reflect.Zero(t)
+		}
+	} else if v := reflect.ValueOf(arg); v.Type().AssignableTo(t) {
+		return v
+	}
+
+	v := reflect.New(t)
+	if err := bind.FromLisp(arg, v.Interface(), bind.Lenient); err != nil {
+		var bindErr *bind.Error
+		if errors.As(err, &bindErr) {
+			// the position is the one of the call
+			err = &bind.Error{Path: bindErr.Path, Err: bindErr.Err}
+		}
+		panic(argumentError{&ArgumentError{Index: index, Expected: t, Value: arg, Err: err}})
+	}
+	return v.Elem()
+}
+
+// paramType returns the type of the parameter i of a function
+func paramType(finType reflect.Type, i int) reflect.Type {
+	if finType.IsVariadic() && i >= finType.NumIn()-1 {
+		return finType.In(finType.NumIn() - 1).Elem()
+	}
+	return finType.In(i)
+}
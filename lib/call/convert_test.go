@@ -0,0 +1,111 @@
+package call
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jig/lisp"
+	"github.com/jig/lisp/env"
+	"github.com/jig/lisp/types"
+)
+
+type window struct {
+	Width  int    `lisp:"width"`
+	Height int    `lisp:"height"`
+	Title  string `lisp:"title,omitempty"`
+}
+
+func area(w window) (int, error) {
+	return w.Width * w.Height, nil
+}
+
+func scale(x float64, factor int64, d time.Duration) (float64, error) {
+	return x * float64(factor) * d.Seconds(), nil
+}
+
+func join(sep string, words []string) (string, error) {
+	return strings.Join(words, sep), nil
+}
+
+func total(counts map[string]uint8) (int, error) {
+	sum := 0
+	for _, n := range counts {
+		sum += int(n)
+	}
+	return sum, nil
+}
+
+func lengths(words ...[]byte) (int, error) {
+	n := 0
+	for _, w := range words {
+		n += len(w)
+	}
+	return n, nil
+}
+
+func TestConversion(t *testing.T) {
+	ns := env.NewEnv()
+	Call(ns, area)
+	Call(ns, scale)
+	Call(ns, join)
+	Call(ns, total)
+	Call(ns, lengths)
+	for code, expected := range map[string]string{
+		`(area {:width 3 :height 4})`: `12`,
+		`(scale 2 3 "1s")`:            `6`,
+		`(scale 0.5 2 "2s")`:          `2`,
+		`(join "-" [:a "b" :c])`:      `"a-b-c"`,
+		`(join "," '("x"))`:           `"x"`,
+		`(total {:a 1 "b" 2})`:        `3`,
+		`(lengths "ab" [1 2 3] nil)`:  `5`,
+	} {
+		res, err := lisp.REPL(context.Background(), ns, code, types.NewCursorFile(t.Name()))
+		if err != nil {
+			t.Fatalf("%s: %s", code, err)
+		}
+		if res != expected {
+			t.Errorf("%s: got %s, expected %s", code, res, expected)
+		}
+	}
+}
+
+func TestConversionErrors(t *testing.T) {
+	ns := env.NewEnv()
+	Call(ns, area)
+	Call(ns, join)
+	Call(ns, total)
+	Call(ns, lengths)
+	for code, expected := range map[string]string{
+		`(area {:width "3"})`:  `[area]: argument 1 (call.window): .width: expected int, got string`,
+		`(area [3 4])`:         `[area]: argument 1 (call.window): expected map (call.window), got vector`,
+		`(join "-" ["a" 1])`:   `[join]: argument 2 ([]string): [1]: expected string, got int`,
+		`(join 1 [])`:          `[join]: argument 1 (string): expected string, got int`,
+		`(total {:a 256})`:     `[total]: argument 1 (map[string]uint8): ["a"]: 256 overflows uint8`,
+		`(lengths "a" "b" :c)`: `[lengths]: argument 3 ([]uint8): expected vector, got keyword`,
+	} {
+		_, err := lisp.REPL(context.Background(), ns, code, types.NewCursorFile(t.Name()))
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("%s: got %v, expected %q", code, err, expected)
+		}
+	}
+}
+
+func TestArgumentErrorMessage(t *testing.T) {
+	ns := env.NewEnv()
+	Call(ns, join)
+	_, err := lisp.REPL(context.Background(), ns, `(join 1 [])`, nil)
+	var argErr *ArgumentError
+	if !errors.As(err, &argErr) {
+		t.Fatalf("expected an *ArgumentError, got %v", err)
+	}
+	// the message stands on its own, without the wrapping of the interpreter
+	if expected := "github.com/jig/lisp/lib/call[join]: argument 1 (string): expected string, got int"; argErr.Error() != expected {
+		t.Fatalf("got %q, expected %q", argErr.Error(), expected)
+	}
+	if strings.Count(err.Error(), "[join]") != 1 {
+		t.Fatalf("function repeated on %q", err)
+	}
+}
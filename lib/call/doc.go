@@ -0,0 +1,95 @@
+package call
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/jig/lisp/types"
+)
+
+// Doc sets the docstring of the Go function registered as name on namespace (shown by
+// doc and find-doc). It panics if name is not a function registered with Call.
+func Doc(namespace types.EnvType, name, doc string) {
+	value, err := namespace.Get(types.Symbol{Val: name})
+	if err != nil {
+		panic(fmt.Errorf("%s: cannot document an undefined function", name))
+	}
+	fn, ok := value.(types.Func)
+	if !ok || fn.Signature == nil {
+		panic(fmt.Errorf("%s: cannot document a function not registered with call.Call", name))
+	}
+	sig := *fn.Signature
+	sig.Doc = doc
+	fn.Signature = &sig
+	namespace.Set(types.Symbol{Val: name}, fn)
+}
+
+var (
+	malTypeType = reflect.TypeFor[types.MalType]()
+	typeNames   = map[reflect.Type]string{
+		reflect.TypeFor[types.List]():    "list",
+		reflect.TypeFor[types.Vector]():  "vector",
+		reflect.TypeFor[types.HashMap](): "map",
+		reflect.TypeFor[types.Set]():     "set",
+		reflect.TypeFor[types.Symbol]():  "symbol",
+		reflect.TypeFor[types.Func]():    "f",
+		reflect.TypeFor[types.MalFunc](): "f",
+		reflect.TypeFor[string]():        "s",
+		reflect.TypeFor[int]():           "n",
+		reflect.TypeFor[bool]():          "b",
+		reflect.TypeFor[error]():         "err",
+	}
+)
+
+// paramName names a parameter of type t on arglists
+func paramName(t reflect.Type) string {
+	if name, ok := typeNames[t]; ok {
+		return name
+	}
+	if t == malTypeType || t.Kind() == reflect.Interface && t.NumMethod() == 0 {
+		return "x"
+	}
+	if t.Kind() == reflect.Pointer {
+		return paramName(t.Elem())
+	}
+	if sequential(t) {
+		return "coll"
+	}
+	if t.Name() == "" {
+		return "x"
+	}
+	return strings.ToLower(t.Name())
+}
+
+// arglist derives the parameter names shown to Lisp users from the parameter types of
+// a Go function: repeated names are numbered (e.g. [x1 x2]) and the variadic parameter
+// follows & (e.g. [s & xs])
+func arglist(in []reflect.Type, variadic bool) types.Vector {
+	names := make([]string, len(in))
+	for i, t := range in {
+		if variadic && i == len(in)-1 {
+			names[i] = paramName(t.Elem()) + "s"
+		} else {
+			names[i] = paramName(t)
+		}
+	}
+	count := map[string]int{}
+	for _, name := range names {
+		count[name]++
+	}
+	seen := map[string]int{}
+	result := types.Vector{Val: []types.MalType{}}
+	for i, name := range names {
+		if variadic && i == len(in)-1 {
+			result.Val = append(result.Val, types.Symbol{Val: "&"})
+		}
+		if count[name] > 1 {
+			seen[name]++
+			name += strconv.Itoa(seen[name])
+		}
+		result.Val = append(result.Val, types.Symbol{Val: name})
+	}
+	return result
+}
@@ -0,0 +1,121 @@
+package call
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/jig/lisp/types"
+)
+
+// Register allows Lisp code evaluated on namespace to call the methods and to read the
+// fields of values of type T (or pointers to it) listed on members:
+//
+//	call.Register[url.URL](ns, "Hostname", "Port", "Path")
+//
+// lets Lisp code call (.Hostname u) and read (.-Path u) of a *url.URL returned by a
+// library function. Methods and fields not listed are not reachable from Lisp. Arguments
+// and results are handled as on [Call].
+func Register[T any](namespace types.EnvType, members ...string) {
+	t := baseType(reflect.TypeFor[T]())
+	for _, member := range members {
+		_, isMethod := reflect.PointerTo(t).MethodByName(member)
+		isField := false
+		if t.Kind() == reflect.Struct {
+			f, ok := t.FieldByName(member)
+			isField = ok && f.IsExported()
+		}
+		if !isMethod && !isField {
+			panic(fmt.Errorf("%s has no exported method or field %s", t, member))
+		}
+	}
+
+	_, err := namespace.Update(types.Symbol{Val: types.InteropSymbol}, func(current types.MalType) (types.MalType, error) {
+		registry, ok := current.(*interop)
+		if !ok {
+			registry = &interop{allowed: map[reflect.Type]map[string]bool{}}
+		}
+		allowed := registry.allowed[t]
+		if allowed == nil {
+			allowed = map[string]bool{}
+			registry.allowed[t] = allowed
+		}
+		for _, member := range members {
+			allowed[member] = true
+		}
+		return registry, nil
+	})
+	if err != nil {
+		panic(fmt.Errorf("%s: error registering type", t))
+	}
+}
+
+// interop is the allow-list of the methods and fields reachable from Lisp by type
+type interop struct {
+	allowed map[reflect.Type]map[string]bool
+}
+
+func baseType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t
+}
+
+// Invoke calls the method member of obj, or reads its field if member is prefixed by "-"
+func (i *interop) Invoke(ctx context.Context, member string, obj types.MalType, args []types.MalType) (types.MalType, error) {
+	if obj == nil {
+		return nil, fmt.Errorf("cannot access %s of nil", member)
+	}
+	v := reflect.ValueOf(obj)
+	t := baseType(v.Type())
+	fieldName, isField := strings.CutPrefix(member, "-")
+	name := member
+	if isField {
+		name = fieldName
+	}
+	allowed, registered := i.allowed[t]
+	if !registered {
+		return nil, fmt.Errorf("type %s is not registered for interop", v.Type())
+	}
+	if !allowed[name] {
+		return nil, fmt.Errorf("%s of %s is not registered for interop", name, v.Type())
+	}
+
+	if isField {
+		if len(args) != 0 {
+			return nil, fmt.Errorf("wrong number of arguments to read field %s (%d instead of 0)", name, len(args))
+		}
+		for v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				return nil, fmt.Errorf("cannot read field %s of nil %s", name, v.Type())
+			}
+			v = v.Elem()
+		}
+		f := v.FieldByName(name)
+		if !f.IsValid() {
+			return nil, fmt.Errorf("%s has no field %s", v.Type(), name)
+		}
+		return fromGo(newSequences(ctx), f), nil
+	}
+
+	method := v.MethodByName(name)
+	if !method.IsValid() && v.Kind() != reflect.Pointer {
+		// methods with pointer receiver on a copy of the value
+		ptr := reflect.New(v.Type())
+		ptr.Elem().Set(v)
+		method = ptr.MethodByName(name)
+	}
+	if !method.IsValid() {
+		return nil, fmt.Errorf("%s has no method %s", v.Type(), name)
+	}
+	methodType := method.Type()
+	contextRequired := methodType.NumIn() >= 1 && methodType.In(0).Implements(contextType)
+	minArgs, maxArgs := methodType.NumIn(), methodType.NumIn()
+	if methodType.IsVariadic() {
+		minArgs, maxArgs = methodType.NumIn()-1, unlimitedArgments
+	}
+	fullName := fmt.Sprintf("(%s).%s", v.Type(), name)
+	return adapter(fullName, fullName, method, contextRequired, minArgs, maxArgs)(ctx, args)
+}
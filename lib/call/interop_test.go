@@ -0,0 +1,98 @@
+package call
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/jig/lisp"
+	"github.com/jig/lisp/env"
+	"github.com/jig/lisp/types"
+)
+
+type account struct {
+	Owner   string
+	Balance int
+	secret  string
+	Limit   int
+}
+
+func (a *account) Deposit(amount int) (int, error) {
+	if amount <= 0 {
+		return 0, errors.New("amount must be positive")
+	}
+	a.Balance += amount
+	return a.Balance, nil
+}
+
+func (a account) Describe(ctx context.Context, prefixes ...string) string {
+	return fmt.Sprintf("%s%s: %d", strings.Join(prefixes, ""), a.Owner, a.Balance)
+}
+
+func (a *account) Close() {
+	a.Balance = 0
+}
+
+func newAccount(owner string) (*account, error) {
+	return &account{Owner: owner, Balance: 10, secret: "s"}, nil
+}
+
+func interopEnv() types.EnvType {
+	ns := env.NewEnv()
+	Call(ns, newAccount)
+	Register[account](ns, "Deposit", "Describe", "Owner", "Balance")
+	return ns
+}
+
+func TestInterop(t *testing.T) {
+	ns := interopEnv()
+	for code, expected := range map[string]string{
+		`(.-Owner (newaccount "ann"))`:                                   `"ann"`,
+		`(let [a (newaccount "ann")] (do (.Deposit a 5) (.-Balance a)))`: `15`,
+		`(.Describe (newaccount "bob"))`:                                 `"bob: 10"`,
+		`(.Describe (newaccount "bob") "#" "1 ")`:                        `"#1 bob: 10"`,
+	} {
+		res, err := lisp.REPL(context.Background(), ns, code, types.NewCursorFile(t.Name()))
+		if err != nil {
+			t.Fatalf("%s: %s", code, err)
+		}
+		if res != expected {
+			t.Errorf("%s: got %s, expected %s", code, res, expected)
+		}
+	}
+}
+
+func TestInteropErrors(t *testing.T) {
+	ns := interopEnv()
+	for code, expected := range map[string]string{
+		`(.Close (newaccount "ann"))`:       `Close of *call.account is not registered for interop`,
+		`(.-Limit (newaccount "ann"))`:      `Limit of *call.account is not registered for interop`,
+		`(.-Owner "ann")`:                   `type string is not registered for interop`,
+		`(.-Owner nil)`:                     `cannot access -Owner of nil`,
+		`(.Deposit (newaccount "ann") -1)`:  `amount must be positive`,
+		`(.Deposit (newaccount "ann") 1 2)`: `wrong number of arguments (2 instead of 1)`,
+		`(.-Owner (newaccount "ann") 1)`:    `wrong number of arguments to read field Owner (1 instead of 0)`,
+		`(.Deposit)`:                        `expected (.Method obj args...) or (.-Field obj)`,
+	} {
+		_, err := lisp.REPL(context.Background(), ns, code, types.NewCursorFile(t.Name()))
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("%s: got %v, expected %q", code, err, expected)
+		}
+	}
+
+	_, err := lisp.REPL(context.Background(), env.NewEnv(), `(.-Owner 1)`, types.NewCursorFile(t.Name()))
+	if err == nil || !strings.Contains(err.Error(), "no Go types are registered for interop") {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestRegisterUnknownMember(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil || !strings.Contains(fmt.Sprint(r), "has no exported method or field secret") {
+			t.Fatalf("unexpected panic %v", r)
+		}
+	}()
+	Register[account](env.NewEnv(), "secret")
+}
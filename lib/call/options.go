@@ -0,0 +1,115 @@
+package call
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/jig/lisp/bind"
+	"github.com/jig/lisp/types"
+)
+
+// Options is embedded in a struct to make it the options parameter of the Go functions
+// that take it as their last parameter. Such functions are called from Lisp with the
+// options as trailing :key value pairs or as a final hash map, and they can be left out:
+//
+//	type FetchOptions struct {
+//		call.Options
+//		Timeout time.Duration `lisp:"timeout,default=\"30s\""`
+//		Retries int           `lisp:"retries"`
+//		Token   string        `lisp:"token,required"`
+//	}
+//
+//	func fetch(ctx context.Context, url string, opts FetchOptions) (string, error)
+//
+// (fetch "http://example.com" :token "t" :retries 3) and
+// (fetch "http://example.com" {:token "t" :retries 3}) are the same call. Keys are set
+// by the lisp struct tags (see package bind), with their default and required options.
+// Unknown keys are errors.
+type Options struct{}
+
+var optionsType = reflect.TypeFor[Options]()
+
+// hasOptions reports whether the last parameter of a function is an options struct
+func hasOptions(finType reflect.Type) bool {
+	if finType.NumIn() == 0 || finType.IsVariadic() {
+		return false
+	}
+	last := finType.In(finType.NumIn() - 1)
+	if last.Kind() != reflect.Struct {
+		return false
+	}
+	for i := 0; i < last.NumField(); i++ {
+		if sf := last.Field(i); sf.Anonymous && sf.Type == optionsType {
+			return true
+		}
+	}
+	return false
+}
+
+// options returns the options struct of type t from the trailing arguments of a call,
+// the first one being the argument at index (starting at 1)
+func options(index int, args []types.MalType, t reflect.Type) reflect.Value {
+	hm := types.HashMap{Val: map[types.MalType]types.MalType{}}
+	keyIndex := map[types.Keyword]int{} // argument index of each option
+	if len(args) == 1 {
+		m, ok := args[0].(types.HashMap)
+		if !ok {
+			panic(argumentError{&ArgumentError{Index: index, Expected: t, Value: args[0], Err: &bind.TypeError{Expected: t, Value: args[0]}}})
+		}
+		hm = m
+	} else {
+		for i := 0; i < len(args); i += 2 {
+			key, ok := args[i].(types.Keyword)
+			if !ok {
+				panic(argumentError{&ArgumentError{Index: index + i, Expected: t, Value: args[i], Err: fmt.Errorf("option name %v is not a keyword", args[i])}})
+			}
+			if i+1 == len(args) {
+				panic(argumentError{&ArgumentError{Index: index + i, Expected: t, Value: args[i], Err: fmt.Errorf("missing value of option %s", key)}})
+			}
+			hm.Val[key] = args[i+1]
+			keyIndex[key] = index + i
+		}
+	}
+
+	v := reflect.New(t)
+	err := bind.FromLisp(hm, v.Interface(), bind.Strict)
+	if err == nil {
+		return v.Elem()
+	}
+	argIndex, value := index, types.MalType(hm)
+	var keyErr *bind.KeyError
+	var bindErr *bind.Error
+	if errors.As(err, &keyErr) && !keyErr.Missing {
+		// the unknown key (the keys of a hash map of options may be other than keywords)
+		if key, ok := keyErr.Key.(types.Keyword); ok {
+			if i, ok := keyIndex[key]; ok {
+				argIndex, value = i, keyErr.Key
+			}
+		}
+	} else if errors.As(err, &bindErr) && bindErr.Path != "" {
+		// the value of the option
+		name := strings.TrimPrefix(bindErr.Path, ".")
+		if i := strings.IndexAny(name, ".["); i >= 0 {
+			name = name[:i]
+		}
+		if i, ok := keyIndex[types.NewKeyword(name)]; ok {
+			argIndex, value = i+1, hm.Val[types.NewKeyword(name)]
+		}
+	}
+	if errors.As(err, &bindErr) {
+		// the position is the one of the call
+		err = &bind.Error{Path: bindErr.Path, Err: bindErr.Err}
+	}
+	panic(argumentError{&ArgumentError{Index: argIndex, Expected: t, Value: value, Err: err}})
+}
+
+// optionsArglist returns the arglist of the options of type t (e.g. {:keys [timeout retries]})
+func optionsArglist(t reflect.Type) types.HashMap {
+	names := types.Vector{Val: []types.MalType{}}
+	for _, key := range bind.Keys(t) {
+		names.Val = append(names.Val, types.Symbol{Val: key.Name()})
+	}
+	return types.HashMap{Val: map[types.MalType]types.MalType{types.NewKeyword("keys"): names}}
+}
@@ -0,0 +1,101 @@
+package call
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jig/lisp"
+	"github.com/jig/lisp/env"
+	"github.com/jig/lisp/printer"
+	"github.com/jig/lisp/types"
+)
+
+type fetchOptions struct {
+	Options
+	Timeout time.Duration `lisp:"timeout,default=\"30s\""`
+	Retries int           `lisp:"retries"`
+	Token   string        `lisp:"token,required"`
+	Tags    []string      `lisp:"tags"`
+}
+
+func fetch(ctx context.Context, url string, opts fetchOptions) (string, error) {
+	return fmt.Sprintf("%s %s %d %s %v", url, opts.Timeout, opts.Retries, opts.Token, opts.Tags), nil
+}
+
+type pingOptions struct {
+	Options
+	Count int `lisp:"count,default=1"`
+}
+
+func ping(opts pingOptions) (int, error) {
+	return opts.Count, nil
+}
+
+func TestOptions(t *testing.T) {
+	ns := env.NewEnv()
+	Call(ns, fetch)
+	Call(ns, ping)
+	for code, expected := range map[string]string{
+		`(fetch "u" :token "t")`:                          `"u 30s 0 t []"`,
+		`(fetch "u" :token "t" :retries 3 :timeout "5s")`: `"u 5s 3 t []"`,
+		`(fetch "u" {:token "t" :retries 3})`:             `"u 30s 3 t []"`,
+		`(fetch "u" :tags [:a "b"] :token "t")`:           `"u 30s 0 t [a b]"`,
+		`(ping)`:                                          `1`,
+		`(ping :count 4)`:                                 `4`,
+		`(ping {})`:                                       `1`,
+	} {
+		result, err := lisp.REPL(context.Background(), ns, code, nil)
+		if err != nil {
+			t.Fatalf("%s: %s", code, err)
+		}
+		if result != expected {
+			t.Fatalf("%s: expected %s, got %s", code, expected, result)
+		}
+	}
+
+	for code, expected := range map[string]string{
+		`(fetch)`:                              `[fetch]: wrong number of arguments (0 instead of a minimum of 1)`,
+		`(fetch "u")`:                          `[fetch]: argument 2 (call.fetchOptions): missing required key :token`,
+		`(fetch "u" :token "t" :retires 3)`:    `[fetch]: argument 4 (call.fetchOptions): unknown key :retires`,
+		`(fetch "u" {:token "t" :retires 3})`:  `[fetch]: argument 2 (call.fetchOptions): unknown key :retires`,
+		`(fetch "u" {:token "t" "retires" 3})`: `[fetch]: argument 2 (call.fetchOptions): unknown key "retires"`,
+		`(fetch "u" :token "t" :retries "3")`:  `[fetch]: argument 5 (call.fetchOptions): .retries: expected int, got string`,
+		`(fetch "u" :token "t" :tags [1])`:     `[fetch]: argument 5 (call.fetchOptions): .tags[0]: expected string, got int`,
+		`(fetch "u" :token "t" :retries)`:      `[fetch]: argument 4 (call.fetchOptions): missing value of option :retries`,
+		`(fetch "u" "token" "t")`:              `[fetch]: argument 2 (call.fetchOptions): option name token is not a keyword`,
+		`(fetch "u" [:token "t"])`:             `[fetch]: argument 2 (call.fetchOptions): expected map (call.fetchOptions), got vector`,
+		`(ping :count 1 :count)`:               `[ping]: argument 3 (call.pingOptions): missing value of option :count`,
+	} {
+		_, err := lisp.REPL(context.Background(), ns, code, nil)
+		if err == nil {
+			t.Fatalf("%s: expected error", code)
+		}
+		if !strings.Contains(err.Error(), expected) {
+			t.Fatalf("%s: expected error containing %q, got %q", code, expected, err)
+		}
+		var argErr *ArgumentError
+		if strings.Contains(expected, "argument ") && !errors.As(err, &argErr) {
+			t.Fatalf("%s: expected an ArgumentError, got %T", code, err)
+		}
+	}
+}
+
+func TestOptionsArglist(t *testing.T) {
+	ns := env.NewEnv()
+	Call(ns, fetch)
+	value, err := ns.Get(types.Symbol{Val: "fetch"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig := value.(types.Func).Signature
+	if result, expected := printer.Pr_str(sig.Arglist, true), `[s & {:keys [timeout retries token tags]}]`; result != expected {
+		t.Fatalf("expected %s, got %s", expected, result)
+	}
+	if sig.MinArgs != 1 || sig.MaxArgs != types.UnlimitedArgs || sig.Variadic {
+		t.Fatalf("unexpected signature %+v", sig)
+	}
+}
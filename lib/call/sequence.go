@@ -0,0 +1,242 @@
+package call
+
+import (
+	"context"
+	"iter"
+	"reflect"
+	"runtime"
+	"sync"
+
+	"github.com/jig/lisp/bind"
+	"github.com/jig/lisp/types"
+)
+
+// sequences are the channels and iterators converted from and to Lisp sequences by a
+// call. Returned ones stop when the context of the call is done (or, for iterators, when
+// they are not referenced anymore). Those passed to the function stop when it returns
+// (see release). The first element sent to a channel that cannot be converted is the
+// error of the call (if the function has not returned before).
+type sequences struct {
+	ctx context.Context // of the call
+
+	mu     sync.Mutex
+	failed any                // the panic converting an element sent to a channel
+	params context.Context    // of the sequences passed to the function (nil if none)
+	cancel context.CancelFunc // of params
+}
+
+func newSequences(ctx context.Context) *sequences {
+	return &sequences{ctx: ctx}
+}
+
+func (s *sequences) fail(rerr any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.failed == nil {
+		s.failed = rerr
+	}
+}
+
+// check panics, as the conversion did, if an element sent to a channel could not be
+// converted
+func (s *sequences) check() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.failed != nil {
+		panic(s.failed)
+	}
+}
+
+// paramsDone returns the channel closed when the sequences passed to the function must
+// stop: when the context of the call is done or when release is called
+func (s *sequences) paramsDone() <-chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.params == nil {
+		s.params, s.cancel = context.WithCancel(s.ctx)
+	}
+	return s.params.Done()
+}
+
+// release stops the sequences passed to the function. It is called when the function
+// returns, so they do not outlive it even if the context of the call is never done.
+func (s *sequences) release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cancel != nil {
+		s.cancel()
+	}
+}
+
+// iterator reports whether t is an iterator type as iter.Seq[T] (func(yield func(T) bool))
+func iterator(t reflect.Type) bool {
+	return t.Kind() == reflect.Func && t.NumIn() == 1 && t.NumOut() == 0 && !t.IsVariadic() &&
+		t.In(0).Kind() == reflect.Func && t.In(0).NumIn() == 1 && t.In(0).NumOut() == 1 &&
+		t.In(0).Out(0).Kind() == reflect.Bool
+}
+
+// sequential reports whether values of type t are sequences for Lisp: channels that
+// can be received from and iterators
+func sequential(t reflect.Type) bool {
+	return t.Kind() == reflect.Chan && t.ChanDir()&reflect.RecvDir != 0 || iterator(t)
+}
+
+// fromGo returns the Lisp value of a result of a Go function: channels and iterators
+// become lazy sequences, that end when the context of the call is done, other values
+// are returned as they are
+func fromGo(s *sequences, v reflect.Value) types.MalType {
+	if !sequential(v.Type()) {
+		return v.Interface()
+	}
+	if v.IsNil() {
+		return nil
+	}
+	if v.Kind() == reflect.Chan {
+		cases := []reflect.SelectCase{
+			{Dir: reflect.SelectRecv, Chan: v},
+			{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(s.ctx.Done())},
+		}
+		return types.NewLazySeq(func() (types.MalType, bool) {
+			chosen, elem, ok := reflect.Select(cases)
+			if chosen != 0 || !ok {
+				return nil, false
+			}
+			return fromGo(s, elem), true
+		})
+	}
+	yieldType := v.Type().In(0)
+	seq := func(yield func(types.MalType) bool) {
+		v.Call([]reflect.Value{reflect.MakeFunc(yieldType, func(args []reflect.Value) []reflect.Value {
+			return []reflect.Value{reflect.ValueOf(yield(fromGo(s, args[0])))}
+		})})
+	}
+	// the iterator is stopped when the context is done or when the lazy sequence is not
+	// referenced anymore, if it is not walked to its end before
+	p := &pull{}
+	p.next, p.stop = iter.Pull(seq)
+	unregister := context.AfterFunc(s.ctx, p.close)
+	h := &pullHandle{p}
+	runtime.AddCleanup(h, func(p *pull) {
+		unregister()
+		p.close()
+	}, p)
+	return types.NewLazySeq(func() (types.MalType, bool) {
+		h.p.mu.Lock()
+		defer h.p.mu.Unlock()
+		elem, ok := h.p.next()
+		if !ok {
+			unregister()
+		}
+		return elem, ok
+	})
+}
+
+// pull is an iterator converted to a lazy sequence; next and stop must not be called
+// concurrently
+type pull struct {
+	mu   sync.Mutex
+	next func() (types.MalType, bool)
+	stop func()
+}
+
+func (p *pull) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.stop()
+}
+
+// pullHandle is referenced by the lazy sequence of a pull only, so it is unreachable
+// (and the pull is stopped) once the lazy sequence is (see runtime.AddCleanup)
+type pullHandle struct {
+	p *pull
+}
+
+// toSequence returns the argument at index (a list, a vector, a lazy sequence or nil)
+// as a channel or an iterator of type t. Elements are converted as they are received,
+// until the function returns. Elements of lazy sequences sent to channels that cannot be
+// converted close the channel and fail the call.
+func toSequence(s *sequences, index int, arg types.MalType, t reflect.Type) reflect.Value {
+	var elems types.LazySeq
+	switch arg := arg.(type) {
+	case nil:
+	case types.List:
+		elems = lazy(arg.Val)
+	case types.Vector:
+		elems = lazy(arg.Val)
+	case types.LazySeq:
+		elems = arg
+	default:
+		panic(argumentError{&ArgumentError{Index: index, Expected: t, Value: arg, Err: &bind.TypeError{Expected: t, Value: arg}}})
+	}
+
+	if t.Kind() == reflect.Chan {
+		return toChan(s, index, elems, !types.Q[types.LazySeq](arg), t)
+	}
+
+	elemType := t.In(0).In(0)
+	done := s.paramsDone()
+	return reflect.MakeFunc(t, func(args []reflect.Value) []reflect.Value {
+		yield := args[0]
+		for rest := elems; !rest.Empty(); rest = rest.Rest() {
+			select {
+			case <-done:
+				return nil
+			default:
+			}
+			elem, _ := rest.First()
+			if !yield.Call([]reflect.Value{convert(s, index, elem, elemType)})[0].Bool() {
+				break
+			}
+		}
+		return nil
+	})
+}
+
+// toChan returns a channel of type t of the elements. Finite elements are converted and
+// buffered on the channel, others are sent by a goroutine until the function returns.
+func toChan(s *sequences, index int, elems types.LazySeq, finite bool, t reflect.Type) reflect.Value {
+	elemType := t.Elem()
+	if finite {
+		values := elems.All()
+		ch := reflect.MakeChan(reflect.ChanOf(reflect.BothDir, elemType), len(values))
+		for _, elem := range values {
+			ch.Send(convert(s, index, elem, elemType))
+		}
+		ch.Close()
+		return ch.Convert(t)
+	}
+	ch := reflect.MakeChan(reflect.ChanOf(reflect.BothDir, elemType), 0)
+	done := reflect.ValueOf(s.paramsDone())
+	go func() {
+		defer ch.Close()
+		defer func() {
+			if rerr := recover(); rerr != nil {
+				// an element that cannot be converted
+				s.fail(rerr)
+			}
+		}()
+		for ; !elems.Empty(); elems = elems.Rest() {
+			elem, _ := elems.First()
+			chosen, _, _ := reflect.Select([]reflect.SelectCase{
+				{Dir: reflect.SelectSend, Chan: ch, Send: convert(s, index, elem, elemType)},
+				{Dir: reflect.SelectRecv, Chan: done},
+			})
+			if chosen != 0 {
+				return
+			}
+		}
+	}()
+	return ch.Convert(t)
+}
+
+// lazy returns a lazy sequence of the elements of slc
+func lazy(slc []types.MalType) types.LazySeq {
+	i := 0
+	return types.NewLazySeq(func() (types.MalType, bool) {
+		if i == len(slc) {
+			return nil, false
+		}
+		i++
+		return slc[i-1], true
+	})
+}
@@ -0,0 +1,234 @@
+package call
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jig/lisp"
+	"github.com/jig/lisp/env"
+	"github.com/jig/lisp/types"
+)
+
+func divmod(a, b int) (int, int, error) {
+	if b == 0 {
+		return 0, 0, errors.New("division by zero")
+	}
+	return a / b, a % b, nil
+}
+
+func minmax(xs ...int) (int, int) {
+	lo, hi := xs[0], xs[0]
+	for _, x := range xs {
+		lo, hi = min(lo, x), max(hi, x)
+	}
+	return lo, hi
+}
+
+func countdown(n int) <-chan int {
+	ch := make(chan int)
+	go func() {
+		defer close(ch)
+		for ; n > 0; n-- {
+			ch <- n
+		}
+	}()
+	return ch
+}
+
+func naturals() iter.Seq[int] {
+	return func(yield func(int) bool) {
+		for i := 0; yield(i); i++ {
+		}
+	}
+}
+
+func letters(s string) iter.Seq[string] {
+	return func(yield func(string) bool) {
+		for _, r := range s {
+			if !yield(string(r)) {
+				return
+			}
+		}
+	}
+}
+
+func first_n(n int, xs iter.Seq[int]) []int {
+	result := []int{}
+	for x := range xs {
+		if len(result) == n {
+			break
+		}
+		result = append(result, x)
+	}
+	return result
+}
+
+func drain(ch <-chan string) string {
+	words := []string{}
+	for w := range ch {
+		words = append(words, w)
+	}
+	return strings.Join(words, " ")
+}
+
+func TestResults(t *testing.T) {
+	ns := env.NewEnv()
+	Call(ns, divmod)
+	Call(ns, minmax, 1)
+	Call(ns, countdown)
+	Call(ns, naturals)
+	Call(ns, first_n)
+	Call(ns, drain)
+	Call(ns, letters)
+	for code, expected := range map[string]string{
+		`(divmod 7 2)`:              `[3 1]`,
+		`(minmax 3 1 2)`:            `[1 3]`,
+		`(countdown 3)`:             `(3 2 1)`,
+		`(countdown 0)`:             `()`,
+		`(first-n 3 (naturals))`:    `[0 1 2]`,
+		`(first-n 2 [5 6 7])`:       `[5 6]`,
+		`(first-n 2 '(5))`:          `[5]`,
+		`(first-n 2 nil)`:           `[]`,
+		`(first-n 4 (countdown 9))`: `[9 8 7 6]`,
+		`(drain ["a" "b"])`:         `"a b"`,
+		`(drain (countdown 0))`:     `""`,
+		`(drain (letters "abc"))`:   `"a b c"`,
+		`(drain nil)`:               `""`,
+	} {
+		result, err := lisp.REPL(context.Background(), ns, code, nil)
+		if err != nil {
+			t.Fatalf("%s: %s", code, err)
+		}
+		if result != expected {
+			t.Fatalf("%s: expected %s, got %s", code, expected, result)
+		}
+	}
+
+	for code, expected := range map[string]string{
+		`(divmod 1 0)`:        `division by zero`,
+		`(first-n 2 "abc")`:   `argument 2 (iter.Seq[int]): expected iter.Seq[int], got string`,
+		`(first-n 2 [1 "x"])`: `argument 2 (int): expected int, got string`,
+		`(drain {:a 1})`:      `argument 1 (<-chan string): expected <-chan string, got map`,
+	} {
+		_, err := lisp.REPL(context.Background(), ns, code, nil)
+		if err == nil {
+			t.Fatalf("%s: expected error", code)
+		}
+		if !strings.Contains(err.Error(), expected) {
+			t.Fatalf("%s: expected error containing %q, got %q", code, expected, err)
+		}
+	}
+}
+
+func TestLazySeq(t *testing.T) {
+	calls := 0
+	seq := types.NewLazySeq(func() (types.MalType, bool) {
+		calls++
+		return calls, calls <= 3
+	})
+	if first, ok := seq.First(); !ok || first != 1 || calls != 1 {
+		t.Fatalf("expected 1 realized element, got %v (%d calls)", first, calls)
+	}
+	if elems := seq.Rest().Take(1); len(elems) != 1 || elems[0] != 2 || calls != 2 {
+		t.Fatalf("expected [2], got %v (%d calls)", elems, calls)
+	}
+	if elems := seq.All(); len(elems) != 3 || calls != 4 {
+		t.Fatalf("expected 3 elements, got %v (%d calls)", elems, calls)
+	}
+	if elems := seq.All(); len(elems) != 3 || calls != 4 {
+		t.Fatalf("expected elements to be kept, got %v (%d calls)", elems, calls)
+	}
+	if !seq.Rest().Rest().Rest().Empty() || !seq.Rest().Rest().Rest().Rest().Empty() {
+		t.Fatal("expected empty rest")
+	}
+	if !(types.LazySeq{}).Empty() {
+		t.Fatal("expected empty zero value")
+	}
+}
+
+func TestSequencesContext(t *testing.T) {
+	ns := env.NewEnv()
+	stopped := make(chan struct{})
+	CallOverrideFN(ns, "ticks", func() iter.Seq[int] {
+		return func(yield func(int) bool) {
+			defer close(stopped)
+			for i := 0; yield(i); i++ {
+			}
+		}
+	})
+	var kept <-chan int
+	CallOverrideFN(ns, "head", func(ch <-chan int) int {
+		kept = ch
+		return <-ch
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	result, err := lisp.REPL(ctx, ns, `(head (ticks))`, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != "0" {
+		t.Fatalf("expected 0, got %s", result)
+	}
+	cancel()
+	// the iterators are stopped and the channel is closed when the context is done
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("iterator not stopped")
+	}
+	done := make(chan struct{})
+	go func() {
+		for range kept {
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("channel not closed")
+	}
+}
+
+func TestSequencesDoNotLeak(t *testing.T) {
+	ns := env.NewEnv()
+	Call(ns, naturals)
+	CallOverrideFN(ns, "head", func(ch <-chan int) int { return <-ch })
+	CallOverrideFN(ns, "take", func(n int, xs types.LazySeq) types.Vector { return types.Vector{Val: xs.Take(n)} })
+
+	before := runtime.NumGoroutine()
+	for range 100 {
+		// with a context that is never done
+		for _, code := range []string{`(take 3 (naturals))`, `(head (naturals))`} {
+			if _, err := lisp.REPL(context.Background(), ns, code, nil); err != nil {
+				t.Fatalf("%s: %s", code, err)
+			}
+		}
+	}
+	// unreferenced iterators are stopped by the garbage collector
+	for deadline := time.Now().Add(5 * time.Second); runtime.NumGoroutine() > before+10; {
+		if time.Now().After(deadline) {
+			t.Fatalf("goroutines leaked: %d before, %d after", before, runtime.NumGoroutine())
+		}
+		runtime.GC()
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestSequenceConversionError(t *testing.T) {
+	ns := env.NewEnv()
+	Call(ns, drain)
+	CallOverrideFN(ns, "mixed", func() iter.Seq[any] {
+		return func(yield func(any) bool) {
+			_ = yield("a") && yield(1) && yield("b")
+		}
+	})
+	_, err := lisp.REPL(context.Background(), ns, `(drain (mixed))`, nil)
+	if err == nil || !strings.Contains(err.Error(), "argument 1 (string): expected string, got int") {
+		t.Fatalf("expected a conversion error, got %v", err)
+	}
+}
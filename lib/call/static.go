@@ -0,0 +1,64 @@
+package call
+
+import (
+	"context"
+	"reflect"
+	"sync"
+
+	"github.com/jig/lisp/types"
+)
+
+// staticAdapters are the adapters generated by lispbind by function type
+var staticAdapters sync.Map // reflect.Type → func(any) types.ExternalCall
+
+// Static registers the adapter of the functions of type F generated by the lispbind
+// tool (see cmd/lispbind). [Call] uses it instead of reflection to call functions of
+// type F registered afterwards. The adapter receives the arguments already checked
+// against the argument count bounds of the function.
+func Static[F any](adapter func(fn F) types.ExternalCall) {
+	staticAdapters.Store(reflect.TypeFor[F](), func(fn any) types.ExternalCall {
+		return adapter(fn.(F))
+	})
+}
+
+// Arg returns the argument i (starting at 0, not counting the context) converted to
+// the type T as [Call] does, on the context of the call. It is used by the adapters
+// generated by lispbind.
+func Arg[T any](ctx context.Context, args []types.MalType, i int) T {
+	if v, ok := args[i].(T); ok {
+		return v
+	}
+	// lispbind does not generate the adapters of functions taking channels or iterators,
+	// that stop when the function returns
+	v, _ := convert(newSequences(ctx), i+1, args[i], reflect.TypeFor[T]()).Interface().(T)
+	return v
+}
+
+// Rest returns the arguments from i on converted to the type T as [Call] does with the
+// variadic parameters, on the context of the call. It is used by the adapters generated
+// by lispbind.
+func Rest[T any](ctx context.Context, args []types.MalType, i int) []T {
+	if i >= len(args) {
+		return nil
+	}
+	rest := make([]T, len(args)-i)
+	for k := range rest {
+		rest[k] = Arg[T](ctx, args, i+k)
+	}
+	return rest
+}
+
+// staticAdapter returns the implementation of a Lisp function calling a generated
+// adapter, if there is one for the type of the function
+func staticAdapter(functionFullName, goFunctionName string, fIn types.MalType, contextRequired bool, minArgs, maxArgs int) (types.ExternalCall, bool) {
+	factory, ok := staticAdapters.Load(reflect.TypeOf(fIn))
+	if !ok {
+		return nil, false
+	}
+	static := factory.(func(any) types.ExternalCall)(fIn)
+	return func(ctx context.Context, args []types.MalType) (result types.MalType, err error) {
+		defer _recover(functionFullName, goFunctionName, &err)
+		_arity(contextRequired, minArgs, maxArgs, len(args))
+		return static(ctx, args)
+	}, true
+}
@@ -0,0 +1,99 @@
+package call
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jig/lisp"
+	"github.com/jig/lisp/env"
+	"github.com/jig/lisp/types"
+)
+
+var staticCalls int
+
+func init() {
+	// as generated by lispbind
+	Static(func(fn func(context.Context, int8, ...string) (string, error)) types.ExternalCall {
+		return func(ctx context.Context, args []types.MalType) (types.MalType, error) {
+			staticCalls++
+			return fn(ctx, Arg[int8](ctx, args, 0), Rest[string](ctx, args, 1)...)
+		}
+	})
+}
+
+func repeat(_ context.Context, n int8, words ...string) (string, error) {
+	if n < 0 {
+		return "", errors.New("negative count")
+	}
+	return strings.Repeat(strings.Join(words, " "), int(n)), nil
+}
+
+func TestStatic(t *testing.T) {
+	ns := env.NewEnv()
+	Call(ns, repeat, 3, 4) // counting the context
+	staticCalls = 0
+	for code, expected := range map[string]string{
+		`(repeat 2 "a")`:     `"aa"`,
+		`(repeat 1 "a" :b)`:  `"a b"`,
+		`(repeat 0 "a" "b")`: `""`,
+	} {
+		result, err := lisp.REPL(context.Background(), ns, code, nil)
+		if err != nil {
+			t.Fatalf("%s: %s", code, err)
+		}
+		if result != expected {
+			t.Fatalf("%s: expected %s, got %s", code, expected, result)
+		}
+	}
+	if staticCalls != 3 {
+		t.Fatalf("expected 3 calls to the static adapter, got %d", staticCalls)
+	}
+
+	for code, expected := range map[string]string{
+		`(repeat 1)`:             `[repeat]: wrong number of arguments (1 instead of 2…3)`,
+		`(repeat 1 "a" "b" "c")`: `[repeat]: wrong number of arguments (4 instead of 2…3)`,
+		`(repeat "1" "a")`:       `[repeat]: argument 1 (int8): expected int8, got string`,
+		`(repeat 1 2)`:           `[repeat]: argument 2 (string): expected string, got int`,
+		`(repeat 300 "a")`:       `[repeat]: argument 1 (int8):`,
+		`(repeat -1 "a")`:        `negative count`,
+	} {
+		_, err := lisp.REPL(context.Background(), ns, code, nil)
+		if err == nil {
+			t.Fatalf("%s: expected error", code)
+		}
+		if !strings.Contains(err.Error(), expected) {
+			t.Fatalf("%s: expected error containing %q, got %q", code, expected, err)
+		}
+	}
+}
+
+func TestArgContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	naturals := types.NewLazySeq(func() func() (types.MalType, bool) {
+		i := 0
+		return func() (types.MalType, bool) {
+			i++
+			return i, true
+		}
+	}())
+	ch := Arg[<-chan int](ctx, []types.MalType{naturals}, 0)
+	if n := <-ch; n != 1 {
+		t.Fatalf("expected 1, got %d", n)
+	}
+	// conversions are tied to the context of the call
+	cancel()
+	done := make(chan struct{})
+	go func() {
+		for range ch {
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("channel not closed")
+	}
+}
@@ -1,5 +1,7 @@
 package concurrent
 
+//go:generate go run github.com/jig/lisp/cmd/lispbind
+
 import (
 	"context"
 	_ "embed"
@@ -1,5 +1,7 @@
 package core
 
+//go:generate go run github.com/jig/lisp/cmd/lispbind
+
 import (
 	"bufio"
 	"bytes"
@@ -11,6 +13,7 @@ import (
 	"fmt"
 	"os"
 	"runtime/debug"
+	"slices"
 	"strings"
 	"time"
 
@@ -63,15 +66,12 @@ func Load(env EnvType) {
 	call.Call(env, mAp)
 	call.Call(env, throw)
 	call.CallOverrideFN(env, "symbol", func(a string) (Symbol, error) { return Symbol{Val: a}, nil })
-	call.CallOverrideFN(env, "keyword", func(a string) (string, error) {
-		if Keyword_Q(a) {
-			return a, nil
-		} else {
-			return NewKeyword(a), nil
-		}
-	})
+	// keywords are passed to string parameters by their names
+	call.CallOverrideFN(env, "keyword", func(a string) (Keyword, error) { return NewKeyword(a), nil })
 	call.Call(env, sPew)
-	call.CallOverrideFN(env, "read-string", func(a MalType) (MalType, error) { return reader.Read_str(a.(string), nil, nil) })
+	// values printed as «type ...» are read with the constructors of env
+	call.CallOverrideFN(env, "read-string", func(a MalType) (MalType, error) { return reader.Read_str(a.(string), nil, nil, env) })
+	call.CallOverrideFN(env, "read-module", func(name, source string) (MalType, error) { return reader.Read_module(name, source, nil, env) })
 	call.CallOverrideFN(env, "set", func(a MalType) (Set, error) { return NewSet(a) })
 	call.Call(env, keys)
 	call.Call(env, vals)
@@ -99,6 +99,11 @@ func Load(env EnvType) {
 	call.CallOverrideFN(env, "vector", func(a ...MalType) (Vector, error) { return Vector{Val: a}, nil })
 	call.Call(env, hash_map)
 	call.CallOverrideFN(env, "hash-set", func(a ...MalType) (Set, error) { return NewSet(List{Val: a}) })
+	call.Call(env, record_type)
+	call.Call(env, record)
+	call.CallOverrideFN(env, "record?", func(a MalType) (bool, error) { return Q[HashMap](a) && a.(HashMap).Record != nil, nil })
+	call.CallOverrideFN(env, "instance?", instance_Q)
+	call.CallOverrideFN(env, "_typed-decl", _typed_decl)
 	call.Call(env, assoc)
 	call.Call(env, dissoc)
 	call.Call(env, concat)
@@ -138,6 +143,8 @@ func Load(env EnvType) {
 	call.Call(env, drop)
 	call.Call(env, drop_last)
 	call.Call(env, subvec, 2, 3)
+
+	loadDocs(env)
 }
 
 func subvec(args ...MalType) (MalType, error) {
@@ -175,6 +182,8 @@ func take(elems int, arg MalType) (MalType, error) {
 		for i := 0; i < elems && i < len(arg.Val); i++ {
 			new_list.Val = append(new_list.Val, arg.Val[i])
 		}
+	case LazySeq:
+		new_list.Val = arg.Take(elems)
 	case nil:
 		// if nil return an empty list
 	default:
@@ -236,6 +245,12 @@ func drop(n int, arg MalType) (MalType, error) {
 		for i := n; i < len(arg.Val); i++ {
 			new_list.Val = append(new_list.Val, arg.Val[i])
 		}
+	case LazySeq:
+		// keeps the rest unrealized
+		for ; n > 0 && !arg.Empty(); n-- {
+			arg = arg.Rest()
+		}
+		return arg, nil
 	case nil:
 		// if nil return an empty list
 	default:
@@ -271,6 +286,9 @@ func drop_last(n int, arg MalType) (MalType, error) {
 func LoadInput(env EnvType) {
 	call.Call(env, slurp)
 	call.Call(env, readLine)
+	for name, doc := range inputDocs {
+		call.Doc(env, name, doc)
+	}
 }
 
 func version() (HashMap, error) {
@@ -278,29 +296,29 @@ func version() (HashMap, error) {
 	if !ok {
 		return HashMap{}, nil
 	}
-	build := map[string]MalType{}
+	build := map[MalType]MalType{}
 	for _, s := range bi.Settings {
 		build[s.Key] = s.Value
 	}
-	deps := map[string]MalType{}
+	deps := map[MalType]MalType{}
 	for _, d := range bi.Deps {
 		if d.Replace == nil {
-			deps[d.Path] = HashMap{Val: map[string]MalType{
-				"ʞversion": d.Version,
-				"ʞsum":     d.Sum,
+			deps[d.Path] = HashMap{Val: map[MalType]MalType{
+				NewKeyword("version"): d.Version,
+				NewKeyword("sum"):     d.Sum,
 			}}
 		} else {
-			deps[d.Path] = HashMap{Val: map[string]MalType{
-				"ʞversion": d.Version,
-				"ʞsum":     d.Sum,
-				"ʞreplace": d.Replace,
+			deps[d.Path] = HashMap{Val: map[MalType]MalType{
+				NewKeyword("version"): d.Version,
+				NewKeyword("sum"):     d.Sum,
+				NewKeyword("replace"): d.Replace,
 			}}
 		}
 	}
-	return HashMap{Val: map[string]MalType{
-		"ʞgo-version":   bi.GoVersion,
-		"ʞbuild":        HashMap{Val: build},
-		"ʞdependencies": HashMap{Val: deps},
+	return HashMap{Val: map[MalType]MalType{
+		NewKeyword("go-version"):   bi.GoVersion,
+		NewKeyword("build"):        HashMap{Val: build},
+		NewKeyword("dependencies"): HashMap{Val: deps},
 	}}, nil
 }
 
@@ -349,41 +367,7 @@ func go_error(format string, args ...MalType) (MalType, error) {
 }
 
 func istype(arg MalType) (string, error) {
-	switch arg := arg.(type) {
-	case nil:
-		return "nil", nil
-	case List:
-		return "list", nil
-	case HashMap:
-		return "hash-map", nil
-	case Vector:
-		return "vector", nil
-	case Set:
-		return "set", nil
-	case int:
-		return "integer", nil
-	case bool:
-		return "boolean", nil
-	case Symbol:
-		return "symbol", nil
-	case string:
-		if len(arg) != 0 && strings.HasPrefix(arg, "ʞ") {
-			return "keyword", nil
-		}
-		return "string", nil
-	case MalFunc:
-		return "function", nil
-	case interface{ ErrorValue() MalType }:
-		return "error", nil
-	case Typed:
-		return arg.Type(), nil
-	case error:
-		return "go-error", nil
-	case Func:
-		return "go-function", nil
-	default:
-		return fmt.Sprintf("unsupported(%T)", arg), nil
-	}
+	return TypeName(arg), nil
 }
 
 func fn_q(a MalType) (MalType, error) {
@@ -409,18 +393,18 @@ func str(a ...MalType) (string, error) {
 	return printer.Pr_list(a, false, "", "", ""), nil
 }
 
-func sPew(a MalType) (MalType, error) {
-	spew.Dump(a)
+func sPew(ctx context.Context, a MalType) (MalType, error) {
+	spew.Fdump(Stdout(ctx), a)
 	return nil, nil
 }
 
-func prn(a ...MalType) (MalType, error) {
-	fmt.Println(printer.Pr_list(a, true, "", "", " "))
+func prn(ctx context.Context, a ...MalType) (MalType, error) {
+	fmt.Fprintln(Stdout(ctx), printer.Pr_list(a, true, "", "", " "))
 	return nil, nil
 }
 
-func println(a ...MalType) (MalType, error) {
-	fmt.Println(printer.Pr_list(a, false, "", "", " "))
+func println(ctx context.Context, a ...MalType) (MalType, error) {
+	fmt.Fprintln(Stdout(ctx), printer.Pr_list(a, false, "", "", " "))
 	return nil, nil
 }
 
@@ -442,16 +426,130 @@ func time_ns() (int, error) {
 }
 
 // Hash Map, Set, Vector functions
+// copies keep the metadata of the original (e.g. the :type protocols dispatch on)
 func copy_hash_map(hm HashMap) HashMap {
-	new_hm := HashMap{Val: map[string]MalType{}}
+	new_hm := HashMap{Val: map[MalType]MalType{}, Meta: hm.Meta, Record: hm.Record}
 	for k, v := range hm.Val {
 		new_hm.Val[k] = v
 	}
 	return new_hm
 }
 
+func record_type(name string, fields []Symbol) (*RecordType, error) {
+	keywords := make([]Keyword, len(fields))
+	for i, field := range fields {
+		keywords[i] = NewKeyword(field.Val)
+		if slices.Contains(keywords[:i], keywords[i]) {
+			return nil, fmt.Errorf("record %s: duplicate field %s", name, field.Val)
+		}
+	}
+	return NewRecordType(name, keywords...), nil
+}
+
+func record(rt *RecordType, hm HashMap) (HashMap, error) {
+	return rt.New(hm)
+}
+
+func instance_Q(rt *RecordType, x MalType) (bool, error) {
+	hm, ok := x.(HashMap)
+	return ok && hm.Record == rt, nil
+}
+
+// _typed_decl removes the type hints of the parameters (^type param) and of the
+// parameter vector (^type [params], the type of the result) of the declaration of
+// defn (params body...). It returns the attribute map (a list of zero or one map) with
+// the hints as :param-types and :return-type, and the fn form of the declaration. If
+// assert is true, the function is passed to _assert-args and _assert-return, which
+// check its arguments and its results at its calls without wrapping its body (so errors
+// point to the calls and its tail calls are still optimised).
+func _typed_decl(name string, attrs, decl MalType, assert bool) (MalType, error) {
+	attrList, err := GetSlice(attrs)
+	if err != nil {
+		return nil, err
+	}
+	declList, err := GetSlice(decl)
+	if err != nil {
+		return nil, err
+	}
+	fnForm := List{Val: append([]MalType{Symbol{Val: "fn"}}, declList...)}
+	if len(declList) == 0 {
+		return List{Val: []MalType{attrs, fnForm}}, nil
+	}
+	paramsForm, returnHint, returnHinted := unhint(declList[0])
+	params, err := GetSlice(paramsForm)
+	if err != nil {
+		return List{Val: []MalType{attrs, fnForm}}, nil
+	}
+	hints := []MalType{}
+	hinted := false
+	plain := make([]MalType, len(params))
+	for i, param := range params {
+		param, hint, ok := unhint(param)
+		plain[i] = param
+		if sym, isSym := param.(Symbol); isSym && sym.Val == "&" {
+			continue
+		}
+		hints = append(hints, hintForm(hint))
+		hinted = hinted || ok
+	}
+	if !hinted && !returnHinted {
+		return List{Val: []MalType{attrs, fnForm}}, nil
+	}
+
+	typesMap := HashMap{Val: map[MalType]MalType{}}
+	if len(attrList) > 0 {
+		m, ok := attrList[0].(HashMap)
+		if !ok {
+			return nil, fmt.Errorf("defn %s: attr-map is not a map", name)
+		}
+		typesMap = copy_hash_map(m)
+	}
+	if hinted {
+		typesMap.Val[NewKeyword("param-types")] = Vector{Val: hints}
+	}
+	if returnHinted {
+		typesMap.Val[NewKeyword("return-type")] = hintForm(returnHint)
+	}
+
+	paramVector := Vector{Val: plain}
+	if v, ok := paramsForm.(Vector); ok {
+		paramVector.Cursor = v.Cursor
+	}
+	var fn MalType = List{Val: append([]MalType{Symbol{Val: "fn"}, paramVector}, declList[1:]...)}
+	if assert && hinted {
+		fn = List{Val: []MalType{Symbol{Val: "_assert-args"}, fn, name, Vector{Val: hints}, quote(Vector{Val: plain})}}
+	}
+	if assert && returnHinted {
+		fn = List{Val: []MalType{Symbol{Val: "_assert-return"}, fn, name, hintForm(returnHint)}}
+	}
+	return List{Val: []MalType{List{Val: []MalType{typesMap}}, fn}}, nil
+}
+
+// unhint returns the form of ^hint form (read as (with-meta form hint)) and its hint
+func unhint(form MalType) (MalType, MalType, bool) {
+	if lst, ok := form.(List); ok && len(lst.Val) == 3 {
+		if sym, ok := lst.Val[0].(Symbol); ok && sym.Val == "with-meta" {
+			return lst.Val[1], lst.Val[2], true
+		}
+	}
+	return form, nil, false
+}
+
+// hintForm returns the form evaluating to a type hint: symbols (e.g. int) are quoted,
+// maps (e.g. {:schema [:vector :int]}) are evaluated
+func hintForm(hint MalType) MalType {
+	if _, ok := hint.(Symbol); ok {
+		return quote(hint)
+	}
+	return hint
+}
+
+func quote(form MalType) List {
+	return List{Val: []MalType{Symbol{Val: "quote"}, form}}
+}
+
 func copy_set(s Set) Set {
-	new_s := Set{Val: map[string]struct{}{}}
+	new_s := Set{Val: map[MalType]struct{}{}, Meta: s.Meta}
 	for k, v := range s.Val {
 		new_s.Val[k] = v
 	}
@@ -460,7 +558,8 @@ func copy_set(s Set) Set {
 
 func copy_vector(v Vector) Vector {
 	return Vector{
-		Val: append([]MalType{}, v.Val...),
+		Val:  append([]MalType{}, v.Val...),
+		Meta: v.Meta,
 	}
 }
 
@@ -477,10 +576,13 @@ func assoc(a ...MalType) (MalType, error) {
 		new_hm := copy_hash_map(ms)
 		for i := 1; i < len(a); i += 2 {
 			key := a[i]
-			if !Q[string](key) {
-				return nil, errors.New("assoc called with non-string key")
+			if !HashKey_Q(key) {
+				return nil, errors.New("assoc called with a key that is not a string or a keyword")
 			}
-			new_hm.Val[key.(string)] = a[i+1]
+			if ms.Record != nil && !ms.Record.HasField(key) {
+				return nil, ms.Record.FieldError(key)
+			}
+			new_hm.Val[key] = a[i+1]
 		}
 		return new_hm, nil
 	case Vector:
@@ -503,10 +605,10 @@ func assoc(a ...MalType) (MalType, error) {
 		}
 		new_s := copy_set(ms)
 		for _, value := range a[1:] {
-			if !Q[string](value) {
-				return nil, errors.New("assoc called with non-string key")
+			if !HashKey_Q(value) {
+				return nil, errors.New("assoc called with a key that is not a string or a keyword")
 			}
-			new_s.Val[value.(string)] = struct{}{}
+			new_s.Val[value] = struct{}{}
 		}
 		return new_s, nil
 	default:
@@ -524,19 +626,23 @@ func dissoc(a ...MalType) (MalType, error) {
 		new_hm := copy_hash_map(ms)
 		for i := 1; i < len(a); i += 1 {
 			key := a[i]
-			if !Q[string](key) {
-				return nil, errors.New("dissoc called with non-string key")
+			if !HashKey_Q(key) {
+				return nil, errors.New("dissoc called with a key that is not a string or a keyword")
+			}
+			if _, ok := new_hm.Val[key]; ok && new_hm.Record != nil {
+				// without one of its fields a record is a hash map
+				new_hm.Record = nil
 			}
-			delete(new_hm.Val, key.(string))
+			delete(new_hm.Val, key)
 		}
 		return new_hm, nil
 	case Set:
 		new_s := copy_set(ms)
 		for _, value := range a[1:] {
-			if !Q[string](value) {
-				return nil, errors.New("dissoc called with non-string key")
+			if !HashKey_Q(value) {
+				return nil, errors.New("dissoc called with a key that is not a string or a keyword")
 			}
-			delete(new_s.Val, value.(string))
+			delete(new_s.Val, value)
 		}
 		return new_s, nil
 	default:
@@ -549,22 +655,22 @@ func get(hm, key MalType) (MalType, error) {
 		return nil, nil
 	}
 	switch key.(type) {
-	case string:
+	case string, Keyword:
 	case int:
 	default:
-		return nil, errors.New("get called with non-string key nor a non-int key")
+		return nil, errors.New("get called with a key that is not a string, a keyword or an int")
 	}
 	ms := hm
 	switch ms := ms.(type) {
 	case HashMap:
-		return ms.Val[key.(string)], nil
+		return ms.Val[key], nil
 	case Vector:
 		return ms.Val[key.(int)], nil
 	case List:
 		return ms.Val[key.(int)], nil
 	case Set:
-		if _, ok := ms.Val[key.(string)]; ok {
-			return key.(string), nil
+		if _, ok := ms.Val[key]; ok {
+			return key, nil
 		}
 		return nil, nil
 	default:
@@ -596,7 +702,7 @@ func _getIn(argMapOrVector MalType, posVector Vector) (MalType, error) {
 		var branch MalType
 		switch argMapOrVector := argMapOrVector.(type) {
 		case HashMap:
-			branch = argMapOrVector.Val[index.(string)]
+			branch = argMapOrVector.Val[index]
 			if branch == nil {
 				branch = HashMap{}
 			}
@@ -625,7 +731,7 @@ func update(ctx context.Context, hm, pos, f MalType) (MalType, error) {
 func _update(ctx context.Context, argMapOrVector, index, f MalType) (MalType, error) {
 	switch argMapOrVector := argMapOrVector.(type) {
 	case HashMap:
-		res, err := Apply(ctx, f, []MalType{argMapOrVector.Val[index.(string)]})
+		res, err := Apply(ctx, f, []MalType{argMapOrVector.Val[index]})
 		if err != nil {
 			return nil, err
 		}
@@ -661,7 +767,7 @@ func _updateIn(ctx context.Context, seq MalType, posVector Vector, f MalType) (M
 		var branch MalType
 		switch seq := seq.(type) {
 		case HashMap:
-			branch = seq.Val[index.(string)]
+			branch = seq.Val[index]
 			if branch == nil {
 				branch = HashMap{}
 			}
@@ -703,7 +809,7 @@ func _assocIn(argMapOrVector MalType, posVector Vector, newValue MalType) (MalTy
 		var branch MalType
 		switch argMapOrVector := argMapOrVector.(type) {
 		case HashMap:
-			branch = argMapOrVector.Val[index.(string)]
+			branch = argMapOrVector.Val[index]
 			if branch == nil {
 				branch = HashMap{}
 			}
@@ -721,7 +827,7 @@ func _assocIn(argMapOrVector MalType, posVector Vector, newValue MalType) (MalTy
 	}
 }
 
-func contains_Q(hm MalType, key string) (bool, error) {
+func contains_Q(hm, key MalType) (bool, error) {
 	if Nil_Q(hm) {
 		return false, nil
 	}
@@ -801,6 +907,12 @@ func vec(seq MalType) (MalType, error) {
 }
 
 func nth(seq MalType, idx int) (MalType, error) {
+	if lazy, ok := seq.(LazySeq); ok {
+		if slc := lazy.Take(idx + 1); idx >= 0 && idx < len(slc) {
+			return slc[idx], nil
+		}
+		return nil, errors.New("nth: index out of range")
+	}
 	slc, e := GetSlice(seq)
 	if e != nil {
 		return nil, e
@@ -813,8 +925,12 @@ func nth(seq MalType, idx int) (MalType, error) {
 }
 
 func first(seq MalType) (MalType, error) {
-	if seq == nil {
+	switch seq := seq.(type) {
+	case nil:
 		return nil, nil
+	case LazySeq:
+		v, _ := seq.First()
+		return v, nil
 	}
 	slc, e := GetSlice(seq)
 	if e != nil {
@@ -827,8 +943,11 @@ func first(seq MalType) (MalType, error) {
 }
 
 func rest(seq MalType) (MalType, error) {
-	if seq == nil {
+	switch seq := seq.(type) {
+	case nil:
 		return List{}, nil
+	case LazySeq:
+		return seq.Rest(), nil
 	}
 	slc, e := GetSlice(seq)
 	if e != nil {
@@ -850,6 +969,8 @@ func empty_Q(seq MalType) (bool, error) {
 		return len(seq.Val) == 0, nil
 	case Set:
 		return len(seq.Val) == 0, nil
+	case LazySeq:
+		return seq.Empty(), nil
 	case nil:
 		return true, nil
 	default:
@@ -867,6 +988,8 @@ func count(seq MalType) (int, error) {
 		return len(seq.Val), nil
 	case Set:
 		return len(seq.Val), nil
+	case LazySeq:
+		return len(seq.All()), nil
 	case nil:
 		return 0, nil
 	default:
@@ -929,19 +1052,22 @@ func conj(a ...MalType) (MalType, error) {
 		new_hm := copy_hash_map(seq)
 		for i := 1; i < len(a); i += 2 {
 			key := a[i]
-			if !Q[string](key) {
-				return nil, errors.New("conj called with non-string key")
+			if !HashKey_Q(key) {
+				return nil, errors.New("conj called with a key that is not a string or a keyword")
 			}
-			new_hm.Val[key.(string)] = a[i+1]
+			if seq.Record != nil && !seq.Record.HasField(key) {
+				return nil, seq.Record.FieldError(key)
+			}
+			new_hm.Val[key] = a[i+1]
 		}
 		return new_hm, nil
 	case Set:
 		new_s := copy_set(seq)
 		for _, key := range a[1:] {
-			if !Q[string](key) {
-				return nil, errors.New("conj called with non-string key")
+			if !HashKey_Q(key) {
+				return nil, errors.New("conj called with a key that is not a string or a keyword")
 			}
-			new_s.Val[key.(string)] = struct{}{}
+			new_s.Val[key] = struct{}{}
 		}
 		return new_s, nil
 	default:
@@ -961,6 +1087,11 @@ func seq(seq MalType) (MalType, error) {
 			return nil, nil
 		}
 		return List{Val: arg.Val}, nil
+	case LazySeq:
+		if arg.Empty() {
+			return nil, nil
+		}
+		return arg, nil
 	case Set:
 		slc := []MalType{}
 		for k := range arg.Val {
@@ -984,43 +1115,11 @@ func seq(seq MalType) (MalType, error) {
 
 // Metadata functions
 func with_meta(obj, meta MalType) (MalType, error) {
-	switch tobj := obj.(type) {
-	case List:
-		return List{Val: tobj.Val, Meta: meta}, nil
-	case Vector:
-		return Vector{Val: tobj.Val, Meta: meta}, nil
-	case HashMap:
-		return HashMap{Val: tobj.Val, Meta: meta}, nil
-	case Set:
-		return Set{Val: tobj.Val, Meta: meta}, nil
-	case Func:
-		return Func{Fn: tobj.Fn, Meta: meta}, nil
-	case MalFunc:
-		fn := tobj
-		fn.Meta = meta
-		return fn, nil
-	default:
-		return nil, errors.New("with-meta not supported on type")
-	}
+	return WithMeta(obj, meta)
 }
 
-func meta(meta MalType) (MalType, error) {
-	switch meta := meta.(type) {
-	case List:
-		return meta.Meta, nil
-	case Vector:
-		return meta.Meta, nil
-	case HashMap:
-		return meta.Meta, nil
-	case Set:
-		return meta.Meta, nil
-	case Func:
-		return meta.Meta, nil
-	case MalFunc:
-		return meta.Meta, nil
-	default:
-		return nil, errors.New("meta not supported on type")
-	}
+func meta(obj MalType) (MalType, error) {
+	return MetaOf(obj)
 }
 
 func deref(ctx context.Context, ref Dereferable) (MalType, error) {
@@ -1044,20 +1143,31 @@ func split(str, sep string) (Vector, error) {
 }
 
 func rename_keys(data, alternative HashMap) (HashMap, error) {
-	output := map[string]MalType{}
+	output := map[MalType]MalType{}
 	for k, v := range data.Val {
 		newKey, ok := alternative.Val[k]
 		if ok {
-			output[newKey.(string)] = v
+			output[newKey] = v
 		} else {
 			output[k] = v
 		}
 	}
-	return HashMap{
+	renamed := HashMap{
 		Val:    output,
 		Meta:   data.Meta,
 		Cursor: data.Cursor,
-	}, nil
+	}
+	// a record keeps its type unless a field was renamed to a key that is not a field
+	if rt := data.Record; rt != nil && len(output) == len(rt.Fields) {
+		renamed.Record = rt
+		for k := range output {
+			if !rt.HasField(k) {
+				renamed.Record = nil
+				break
+			}
+		}
+	}
+	return renamed, nil
 }
 
 func assert(a ...MalType) (MalType, error) {
@@ -1125,12 +1235,17 @@ func mErge(_hm0, _hm1 MalType) (MalType, error) {
 		return nil, nil
 	}
 	merged := HashMap{
-		Val: make(map[string]MalType),
+		Val:    make(map[MalType]MalType),
+		Meta:   hm0.Meta,
+		Record: hm0.Record,
 	}
 	for k, v := range hm0.Val {
 		merged.Val[k] = v
 	}
 	for k, v := range hm1.Val {
+		if merged.Record != nil && !merged.Record.HasField(k) {
+			return nil, merged.Record.FieldError(k)
+		}
 		merged.Val[k] = v
 	}
 	return merged, nil
@@ -1139,6 +1254,9 @@ func mErge(_hm0, _hm1 MalType) (MalType, error) {
 func json_encode(obj MalType) (MalType, error) {
 	b, err := json.Marshal(obj)
 	if err != nil {
+		if mErr := (*json.MarshalerError)(nil); errors.As(err, &mErr) {
+			return nil, mErr.Unwrap()
+		}
 		return nil, err
 	}
 	return string(b), nil
@@ -1159,7 +1277,13 @@ func hash_map_decode(objFactory marshaler.FactoryHashMap, hm HashMap) (MalType,
 	return objFactory.FromHashMap(hm)
 }
 
-func JSON_Decode(obj, bytesIn MalType) (MalType, error) {
+// JSONDecodeOptions are the options of json-decode
+type JSONDecodeOptions struct {
+	call.Options
+	Keywordize bool `lisp:"keywordize,default=true"` // decode the names of objects as keywords
+}
+
+func JSON_Decode(obj, bytesIn MalType, opts JSONDecodeOptions) (MalType, error) {
 	var b []byte
 
 	switch a := bytesIn.(type) {
@@ -1180,14 +1304,14 @@ func JSON_Decode(obj, bytesIn MalType) (MalType, error) {
 		if err != nil {
 			return nil, err
 		}
-		return array2list(v), nil
+		return array2list(v, opts.Keywordize), nil
 	case Vector:
 		v := []interface{}{}
 		err := json.Unmarshal(b, &v)
 		if err != nil {
 			return nil, err
 		}
-		return array2vector(v), nil
+		return array2vector(v, opts.Keywordize), nil
 	case HashMap:
 		v := map[string]interface{}{}
 		d := json.NewDecoder(bytes.NewReader(b))
@@ -1196,30 +1320,45 @@ func JSON_Decode(obj, bytesIn MalType) (MalType, error) {
 		if err != nil {
 			return nil, err
 		}
-		return map2hashmap(v), nil
+		return map2hashmap(v, opts.Keywordize), nil
 	case Set:
 		v := []interface{}{}
 		err := json.Unmarshal(b, &v)
 		if err != nil {
 			return nil, err
 		}
-		return NewSet(array2vector(v))
+		items := array2vector(v, opts.Keywordize)
+		if opts.Keywordize {
+			// items of sets are names, as those of objects
+			for i, item := range items.Val {
+				if name, ok := item.(string); ok {
+					items.Val[i] = NewKeyword(name)
+				}
+			}
+		}
+		return NewSet(items)
 	default:
 		return nil, fmt.Errorf("type %T cannot be decoded", value)
 	}
 }
 
-func map2hashmap(m map[string]interface{}) HashMap {
+// map2hashmap returns a decoded JSON object as a hash map, keyed by keywords or by
+// strings
+func map2hashmap(m map[string]interface{}, keywordize bool) HashMap {
 	hm := HashMap{
-		Val:  map[string]MalType{},
+		Val:  map[MalType]MalType{},
 		Meta: nil,
 	}
-	for k, v := range m {
+	for name, v := range m {
+		var k MalType = name
+		if keywordize {
+			k = NewKeyword(name)
+		}
 		switch v := v.(type) {
 		case map[string]interface{}:
-			hm.Val[k] = map2hashmap(v)
+			hm.Val[k] = map2hashmap(v, keywordize)
 		case []interface{}:
-			hm.Val[k] = array2vector(v)
+			hm.Val[k] = array2vector(v, keywordize)
 		default:
 			hm.Val[k] = v
 		}
@@ -1227,7 +1366,7 @@ func map2hashmap(m map[string]interface{}) HashMap {
 	return hm
 }
 
-func array2vector(a []interface{}) Vector {
+func array2vector(a []interface{}, keywordize bool) Vector {
 	l := Vector{
 		Val:  []MalType{},
 		Meta: nil,
@@ -1235,9 +1374,9 @@ func array2vector(a []interface{}) Vector {
 	for _, v := range a {
 		switch v := v.(type) {
 		case map[string]interface{}:
-			l.Val = append(l.Val, map2hashmap(v))
+			l.Val = append(l.Val, map2hashmap(v, keywordize))
 		case []interface{}:
-			l.Val = append(l.Val, array2vector(v))
+			l.Val = append(l.Val, array2vector(v, keywordize))
 		default:
 			l.Val = append(l.Val, v)
 		}
@@ -1245,7 +1384,7 @@ func array2vector(a []interface{}) Vector {
 	return l
 }
 
-func array2list(a []interface{}) List {
+func array2list(a []interface{}, keywordize bool) List {
 	l := List{
 		Val:  []MalType{},
 		Meta: nil,
@@ -1253,9 +1392,9 @@ func array2list(a []interface{}) List {
 	for _, v := range a {
 		switch v := v.(type) {
 		case map[string]interface{}:
-			l.Val = append(l.Val, map2hashmap(v))
+			l.Val = append(l.Val, map2hashmap(v, keywordize))
 		case []interface{}:
-			l.Val = append(l.Val, array2vector(v))
+			l.Val = append(l.Val, array2vector(v, keywordize))
 		default:
 			l.Val = append(l.Val, v)
 		}
@@ -0,0 +1,300 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/jig/lisp/lib/call"
+	"github.com/jig/lisp/lisperror"
+	"github.com/jig/lisp/printer"
+	. "github.com/jig/lisp/types"
+)
+
+// docs are the docstrings of the functions registered by Load
+var docs = map[string]string{
+	"assoc-in":        "Returns the map with the value at the path of keys (a vector) set to data, creating nested maps as needed.\n\n(assoc-in {:a {:b 1}} [:a :c] 2)\n;=> {:a {:b 1 :c 2}}",
+	"update":          "Returns the map with the value of key replaced by the result of calling f on it.",
+	"update-in":       "Returns the map with the value at the path of keys (a vector) replaced by the result of calling f on it.",
+	"<":               "Returns true if n1 is less than n2.",
+	"<=":              "Returns true if n1 is less than or equal to n2.",
+	">":               "Returns true if n1 is greater than n2.",
+	">=":              "Returns true if n1 is greater than or equal to n2.",
+	"+":               "Returns the sum of n1 and n2.\n\n(+ 1 2)\n;=> 3",
+	"-":               "Returns n1 minus n2.\n\n(- 5 2)\n;=> 3",
+	"*":               "Returns the product of n1 and n2.\n\n(* 3 4)\n;=> 12",
+	"/":               "Returns the integer division of n1 by n2.\n\n(/ 7 2)\n;=> 3",
+	"=":               "Returns true if x1 and x2 are equal (collections are compared by value).\n\n(= [1 2] (list 1 2))\n;=> true",
+	"not=":            "Returns true if x1 and x2 are not equal.",
+	"get":             "Returns the value of key on a map (or the item at an index of a sequence), nil if not found.\n\n(get {:a 1} :a)\n;=> 1",
+	"get-in":          "Returns the value at the path of keys (a vector) on nested maps, nil if not found.\n\n(get-in {:a {:b 2}} [:a :b])\n;=> 2",
+	"contains?":       "Returns true if the map (or set) contains key.",
+	"cons":            "Returns a new list with x prepended to the sequence.\n\n(cons 1 [2 3])\n;=> (1 2 3)",
+	"nth":             "Returns the item at index n of a sequence. Fails if out of bounds.\n\n(nth [:a :b] 1)\n;=> :b",
+	"with-meta":       "Returns a copy of x with meta as its metadata.",
+	"range":           "Returns a vector with the integers from n1 (inclusive) to n2 (exclusive).\n\n(range 1 4)\n;=> [1 2 3]",
+	"hash-map-decode": "Decodes a map into a value created by the constructor factory.",
+	"json-decode":     "Decodes a JSON string into a value of the type of the first argument (as created by a constructor). The names of JSON objects (and the strings decoded into a set) are decoded as keywords unless the option :keywordize is false.",
+	"merge":           "Returns a map with the entries of the second map added to (or replacing) those of the first one.",
+	"rename-keys":     "Returns the map with the keys renamed as given by the second map (old key to new key).",
+	"split":           "Splits s1 around each occurrence of the separator s2, and returns a vector of strings.\n\n(split \"a,b\" \",\")\n;=> [\"a\" \"b\"]",
+	"map":             "Returns a list with the results of calling f on each item of the sequence.\n\n(map (fn [x] (* x x)) [1 2 3])\n;=> (1 4 9)",
+	"throw":           "Throws x as an exception (catch it with try/catch).",
+	"symbol":          "Returns the symbol named s.",
+	"keyword":         "Returns the keyword named s (s itself if it already is a keyword).",
+	"spew":            "Prints the Go representation of x (for debugging).",
+	"read-string":     "Reads a string of Lisp code and returns its first form, unevaluated.",
+	"read-module":     "Reads the Lisp code of the file (or module) named s1 from s2 and returns all its forms on a do form, unevaluated. Positions refer to the lines of s2.",
+	"set":             "Returns a set with the items of the sequence (strings or keywords).",
+	"keys":            "Returns a list with the keys of the map.",
+	"vals":            "Returns a list with the values of the map.",
+	"vec":             "Returns a vector with the items of the sequence.",
+	"first":           "Returns the first item of the sequence, nil if empty.\n\n(first [1 2 3])\n;=> 1",
+	"rest":            "Returns a list with the items of the sequence after the first one.\n\n(rest [1 2 3])\n;=> (2 3)",
+	"count":           "Returns the number of items of the sequence, map or set (0 for nil).\n\n(count [1 2 3])\n;=> 3",
+	"seq":             "Returns a list with the items of the sequence, or the characters of a string (nil if empty).",
+	"meta":            "Returns the metadata of x.",
+	"deref":           "Returns the value of a reference (e.g. an atom or a future), waiting for it if needed.",
+	"base64":          "Encodes bytes as a base64 string.",
+	"unbase64":        "Decodes a base64 string into bytes.",
+	"str2binary":      "Converts a string into bytes.",
+	"binary2str":      "Converts bytes into a string.",
+	"json-encode":     "Encodes x as JSON.",
+	"sleep":           "Waits n milliseconds. Fails if the evaluation times out meanwhile.",
+	"time-ms":         "Returns the current time in milliseconds.",
+	"time-ns":         "Returns the current time in nanoseconds.",
+	"uuid":            "Returns a new random UUID string.",
+	"pr-str":          "Returns the readable representation of the arguments separated by spaces.",
+	"str":             "Returns the concatenation of the arguments printed without quotes.\n\n(str \"a\" 1 :b)\n;=> \"a1:b\"",
+	"prn":             "Prints the readable representation of the arguments separated by spaces, and a newline.",
+	"println":         "Prints the arguments separated by spaces without quotes, and a newline.",
+	"list":            "Returns a list with the arguments.",
+	"vector":          "Returns a vector with the arguments.",
+	"hash-map":        "Returns a map with the arguments as key value pairs.",
+	"hash-set":        "Returns a set with the arguments (strings or keywords).",
+	"record-type":     "Returns a record type named s of the fields (a vector of symbols), as defined by defrecord.",
+	"record":          "Returns a record of the record type with the fields of the map (nil the missing ones). Keys that are not fields are errors.",
+	"record?":         "Returns true if x is a record.",
+	"instance?":       "Returns true if x is a record of the record type.",
+	"assoc":           "Returns the map with the given key value pairs added (fields only on records).",
+	"dissoc":          "Returns the map without the given keys (a hash map if a field of a record is removed).",
+	"concat":          "Returns a list with the items of all the sequences.\n\n(concat [1 2] (list 3))\n;=> (1 2 3)",
+	"nil?":            "Returns true if x is nil.",
+	"true?":           "Returns true if x is true.",
+	"false?":          "Returns true if x is false.",
+	"empty?":          "Returns true if the sequence, map or set has no items.",
+	"symbol?":         "Returns true if x is a symbol.",
+	"keyword?":        "Returns true if x is a keyword.",
+	"string?":         "Returns true if x is a string (and not a keyword).",
+	"number?":         "Returns true if x is an integer.",
+	"fn?":             "Returns true if x is a function (and not a macro).",
+	"macro?":          "Returns true if x is a macro.",
+	"list?":           "Returns true if x is a list.",
+	"vector?":         "Returns true if x is a vector.",
+	"map?":            "Returns true if x is a map.",
+	"set?":            "Returns true if x is a set.",
+	"sequential?":     "Returns true if x is a list or a vector.",
+	"apply":           "Calls f with the given arguments followed by the items of the last argument (a sequence).\n\n(apply str \"a\" [\"b\" \"c\"])\n;=> \"abc\"",
+	"conj":            "Returns the collection with the items added (at the front of lists, at the end of vectors, into sets).\n\n(conj [1 2] 3)\n;=> [1 2 3]",
+	"assert":          "Throws an exception (with the optional message) if x is false or nil.",
+	"go-error":        "Returns a Go error created from a format string and its arguments (as fmt.Errorf).",
+	"panic":           "Panics with x.",
+	"unwrap-error":    "Returns the error wrapped by err (nil if none).",
+	"error-string":    "Returns the message of err.",
+	"type?":           "Returns the name of the type of x.\n\n(type? [1])\n;=> \"vector\"",
+	"new-error":       "Returns a Lisp error with x as its value, optionally located at a position.",
+	"new-go-error":    "Returns a Go error with the message s.",
+	"version":         "Returns a map with the build information of the interpreter.",
+	"take":            "Returns a list with the first n items of the sequence.\n\n(take 2 [1 2 3])\n;=> (1 2)",
+	"take-last":       "Returns a list with the last n items of the sequence.",
+	"drop":            "Returns a list with the items of the sequence except the first n.\n\n(drop 2 [1 2 3])\n;=> (3)",
+	"drop-last":       "Returns a list with the items of the sequence except the last n.",
+	"subvec":          "Returns the vector from index n1 (inclusive) to n2 (exclusive, the end if not given).\n\n(subvec [1 2 3 4] 1 3)\n;=> [2 3]",
+	"print-doc":       "Prints the documentation of value, named name (see the doc macro).",
+	"print-source":    "Prints the source code of the function value, named name (see the source macro).",
+	"find-doc":        "Prints the documentation of the symbols whose name or docstring match the regular expression s.",
+}
+
+// inputDocs are the docstrings of the functions registered by LoadInput
+var inputDocs = map[string]string{
+	"slurp":    "Returns the content of the file named s.",
+	"readline": "Prints the prompt s and returns the line read from the standard input.",
+}
+
+func loadDocs(env EnvType) {
+	call.Call(env, print_doc)
+	call.Call(env, print_source)
+	call.CallOverrideFN(env, "find-doc", func(ctx context.Context, pattern string) (MalType, error) {
+		return nil, findDoc(Stdout(ctx), env, pattern)
+	})
+	for name, doc := range docs {
+		call.Doc(env, name, doc)
+	}
+}
+
+// DocString returns the documentation of value, named name: its arglists, whether it
+// is a macro, where it is implemented (for Go functions) and its docstring
+func DocString(name string, value MalType) string {
+	var sb strings.Builder
+	sb.WriteString("-------------------------\n")
+	sb.WriteString(name + "\n")
+	if arglists := Arglists(value); arglists != "" {
+		sb.WriteString(arglists + "\n")
+	}
+	switch value := value.(type) {
+	case MalFunc:
+		if value.IsMacro {
+			sb.WriteString("Macro\n")
+		}
+	case Func:
+		if value.Signature != nil {
+			sb.WriteString("Go: " + value.Signature.GoName + "\n")
+		}
+	}
+	if doc := Doc(value); doc != "" {
+		for _, line := range strings.Split(doc, "\n") {
+			sb.WriteString("  " + strings.TrimSpace(line) + "\n")
+		}
+	}
+	return sb.String()
+}
+
+// Arglists returns the printed arglists of a function (e.g. "([x] [x y])"): the
+// :arglists metadata if set, the parameters of Lisp functions or the ones derived from
+// the signature of Go functions. It returns "" for other values.
+func Arglists(value MalType) string {
+	meta, _ := MetaOf(value)
+	if meta, ok := meta.(HashMap); ok {
+		if arglists, ok := meta.Val[NewKeyword("arglists")]; ok {
+			return printer.Pr_str(arglists, true)
+		}
+	}
+	switch value := value.(type) {
+	case MalFunc:
+		return "(" + printer.Pr_str(value.Params, true) + ")"
+	case Func:
+		if value.Signature != nil {
+			return "(" + printer.Pr_str(value.Signature.Arglist, true) + ")"
+		}
+	}
+	return ""
+}
+
+func print_doc(ctx context.Context, name Symbol, value MalType) (MalType, error) {
+	_, err := io.WriteString(Stdout(ctx), DocString(name.Val, value))
+	return nil, err
+}
+
+func findDoc(w io.Writer, env EnvType, pattern string) error {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return err
+	}
+	names := []string{}
+	for _, suffix := range env.Symbols(nil, "") {
+		name := string(suffix)
+		if strings.HasPrefix(name, "_") {
+			// internal symbols (e.g. _PACKAGES_)
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value, err := env.Get(Symbol{Val: name})
+		if err != nil {
+			continue
+		}
+		if re.MatchString(name) || re.MatchString(Doc(value)) {
+			if _, err := io.WriteString(w, DocString(name, value)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Source returns the source code of a function: the form defining it, read from the
+// file where it was defined, or (if the file is not available, e.g. on the REPL) the
+// printed function
+func Source(value MalType) (string, error) {
+	switch value := value.(type) {
+	case MalFunc:
+		if value.Cursor != nil && value.Cursor.Module != nil {
+			if source, ok := (lisperror.FileSources{}).Source(*value.Cursor.Module); ok {
+				if form, ok := formAt(source, value.Cursor.BeginRow); ok {
+					return form, nil
+				}
+			}
+		}
+		body := []MalType{Symbol{Val: "fn"}, value.Params}
+		if do, ok := value.Exp.(List); ok && len(do.Val) > 0 && Equal_Q(do.Val[0], Symbol{Val: "do"}) {
+			body = append(body, do.Val[1:]...)
+		} else {
+			body = append(body, value.Exp)
+		}
+		return printer.Pr_str(List{Val: body}, true), nil
+	case Func:
+		if value.Signature != nil {
+			return fmt.Sprintf(";; implemented in Go: %s", value.Signature.GoName), nil
+		}
+		return ";; implemented in Go", nil
+	default:
+		return "", fmt.Errorf("source not available for %T (only for functions and macros)", value)
+	}
+}
+
+func print_source(ctx context.Context, name Symbol, value MalType) (MalType, error) {
+	source, err := Source(value)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name.Val, err)
+	}
+	_, err = fmt.Fprintln(Stdout(ctx), source)
+	return nil, err
+}
+
+// formAt returns the text of the form that starts on line (the first form starting on
+// that line)
+func formAt(source string, line int) (string, bool) {
+	lines := strings.SplitAfter(source, "\n")
+	if line < 1 || line > len(lines) {
+		return "", false
+	}
+	text := []rune(strings.Join(lines[line-1:], ""))
+	start := 0
+	for start < len(text) && (text[start] == ' ' || text[start] == '\t') {
+		start++
+	}
+	if start == len(text) || !strings.ContainsRune("([{", text[start]) {
+		return "", false
+	}
+	depth := 0
+	for i := start; i < len(text); i++ {
+		switch text[i] {
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
+			depth--
+			if depth == 0 {
+				return string(text[start : i+1]), true
+			}
+		case '"':
+			for i++; i < len(text) && text[i] != '"'; i++ {
+				if text[i] == '\\' {
+					i++
+				}
+			}
+		case '¬':
+			for i++; i < len(text) && text[i] != '¬'; i++ {
+			}
+		case ';':
+			for i < len(text) && text[i] != '\n' {
+				i++
+			}
+		}
+	}
+	return "", false
+}
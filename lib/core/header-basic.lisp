@@ -3,20 +3,56 @@
 (do
     (def *host-language* "go")
 
-    (def not (fn [a]
-        (if a
-            false
-            true)))
-
-    (defmacro cond (fn [& xs]
-        (if (> (count xs) 0)
-            (list
-                'if (first xs)
-                    (if (> (count xs) 1)
-                        (nth xs 1)
-                        (throw "odd number of forms to cond"))
-                    (cons 'cond (rest (rest xs)))))))
-
-    (defmacro defn (fn [name params & body]
-        `(def ~name
-            (fn ~params ~@body)))))
+    ;; true to assert the type hints of the functions defined by defn on their calls
+    (def *assert-types* false)
+
+    (def not
+        "Returns true if a is false or nil, false otherwise."
+        (fn [a]
+            (if a
+                false
+                true)))
+
+    (defmacro cond
+        "Takes test/expression pairs and evaluates the expression of the first test that is not false or nil (nil if none)."
+        (fn [& xs]
+            (if (> (count xs) 0)
+                (list
+                    'if (first xs)
+                        (if (> (count xs) 1)
+                            (nth xs 1)
+                            (throw "odd number of forms to cond"))
+                        (cons 'cond (rest (rest xs)))))))
+
+    (defmacro defn
+        "Defines a function: (defn name docstring? attr-map? [params] body...). Parameters take optional type hints, and the parameter vector the one of the result: (defn f ^int [^int n ^{:schema [:vector :int]} xs] ...). Hints are names of types (any, nil, boolean, int, double, number, string, keyword, symbol, list, vector, seq, map, set or fn) or schemas; they are set as :param-types and :return-type on the metadata, checked by lisp typecheck and asserted on every call if *assert-types* is true."
+        (fn [name & decl]
+            (let [doc   (if (string? (first decl)) (list (first decl)) ())
+                  decl  (if (string? (first decl)) (rest decl) decl)
+                  attrs (if (map? (first decl)) (list (first decl)) ())
+                  decl  (if (map? (first decl)) (rest decl) decl)
+                  typed (_typed-decl (str name) attrs decl *assert-types*)]
+                `(def ~name ~@doc ~@(first typed) ~(nth typed 1)))))
+
+    (defmacro defrecord
+        "Defines a record type: (defrecord Name [fields] protocol (method [this params...] body...)...). Records are maps of the fields that type? names Name. Defines Name (the record type), ->Name (the positional factory), map->Name and new-Name (the constructors from a map, new-Name being the one reading «Name {...}»), and extends the protocols to Name (see extend-type)."
+        (fn [name fields & specs]
+            `(do
+                (def ~name (record-type ~(str name) '~fields))
+                (def ~(symbol (str "->" name))
+                    (fn ~fields
+                        (record ~name (hash-map ~@(apply concat (map (fn [field] (list (keyword (str field)) field)) fields))))))
+                (def ~(symbol (str "map->" name)) (fn [m] (record ~name m)))
+                (def ~(symbol (str "new-" name)) (fn [m] (record ~name m)))
+                ~@(if (empty? specs) () (list `(extend-type ~name ~@specs)))
+                ~name)))
+
+    (defmacro doc
+        "Prints the documentation of the function, macro or value named name."
+        (fn [name]
+            `(print-doc '~name ~name)))
+
+    (defmacro source
+        "Prints the source code of the function or macro named name."
+        (fn [name]
+            `(print-source '~name ~name))))
@@ -1,8 +1,4 @@
 ;; $MODULE header-load-file
 
 (defn load-file [file-path]
-    (eval
-        (read-string
-            (str
-                ";; $MODULE " file-path "\n"
-                "(do " (slurp file-path) "\n)"))))
+    (eval (read-module file-path (slurp file-path))))
@@ -0,0 +1,276 @@
+// Code generated by lispbind; DO NOT EDIT.
+
+package core
+
+import (
+	"context"
+
+	"github.com/jig/lisp/lib/call"
+	"github.com/jig/lisp/lisperror"
+	"github.com/jig/lisp/marshaler"
+	"github.com/jig/lisp/types"
+)
+
+func init() {
+	call.Static(func(fn func() (int, error)) types.ExternalCall {
+		return func(_ context.Context, args []types.MalType) (types.MalType, error) {
+			return fn()
+		}
+	})
+	call.Static(func(fn func() (string, error)) types.ExternalCall {
+		return func(_ context.Context, args []types.MalType) (types.MalType, error) {
+			return fn()
+		}
+	})
+	call.Static(func(fn func() (types.HashMap, error)) types.ExternalCall {
+		return func(_ context.Context, args []types.MalType) (types.MalType, error) {
+			return fn()
+		}
+	})
+	call.Static(func(fn func(*types.RecordType, types.HashMap) (types.HashMap, error)) types.ExternalCall {
+		return func(ctx context.Context, args []types.MalType) (types.MalType, error) {
+			return fn(call.Arg[*types.RecordType](ctx, args, 0), call.Arg[types.HashMap](ctx, args, 1))
+		}
+	})
+	call.Static(func(fn func(*types.RecordType, types.MalType) (bool, error)) types.ExternalCall {
+		return func(ctx context.Context, args []types.MalType) (types.MalType, error) {
+			return fn(call.Arg[*types.RecordType](ctx, args, 0), call.Arg[types.MalType](ctx, args, 1))
+		}
+	})
+	call.Static(func(fn func(...types.MalType) (string, error)) types.ExternalCall {
+		return func(ctx context.Context, args []types.MalType) (types.MalType, error) {
+			return fn(call.Rest[types.MalType](ctx, args, 0)...)
+		}
+	})
+	call.Static(func(fn func(...types.MalType) (types.List, error)) types.ExternalCall {
+		return func(ctx context.Context, args []types.MalType) (types.MalType, error) {
+			return fn(call.Rest[types.MalType](ctx, args, 0)...)
+		}
+	})
+	call.Static(func(fn func(...types.MalType) (types.MalType, error)) types.ExternalCall {
+		return func(ctx context.Context, args []types.MalType) (types.MalType, error) {
+			return fn(call.Rest[types.MalType](ctx, args, 0)...)
+		}
+	})
+	call.Static(func(fn func(...types.MalType) (types.Set, error)) types.ExternalCall {
+		return func(ctx context.Context, args []types.MalType) (types.MalType, error) {
+			return fn(call.Rest[types.MalType](ctx, args, 0)...)
+		}
+	})
+	call.Static(func(fn func(...types.MalType) (types.Vector, error)) types.ExternalCall {
+		return func(ctx context.Context, args []types.MalType) (types.MalType, error) {
+			return fn(call.Rest[types.MalType](ctx, args, 0)...)
+		}
+	})
+	call.Static(func(fn func([]byte) (string, error)) types.ExternalCall {
+		return func(ctx context.Context, args []types.MalType) (types.MalType, error) {
+			return fn(call.Arg[[]byte](ctx, args, 0))
+		}
+	})
+	call.Static(func(fn func(context.Context, ...types.MalType) (types.MalType, error)) types.ExternalCall {
+		return func(ctx context.Context, args []types.MalType) (types.MalType, error) {
+			return fn(ctx, call.Rest[types.MalType](ctx, args, 0)...)
+		}
+	})
+	call.Static(func(fn func(context.Context, int) error) types.ExternalCall {
+		return func(ctx context.Context, args []types.MalType) (types.MalType, error) {
+			return nil, fn(ctx, call.Arg[int](ctx, args, 0))
+		}
+	})
+	call.Static(func(fn func(context.Context, string) (types.MalType, error)) types.ExternalCall {
+		return func(ctx context.Context, args []types.MalType) (types.MalType, error) {
+			return fn(ctx, call.Arg[string](ctx, args, 0))
+		}
+	})
+	call.Static(func(fn func(context.Context, types.Dereferable) (types.MalType, error)) types.ExternalCall {
+		return func(ctx context.Context, args []types.MalType) (types.MalType, error) {
+			return fn(ctx, call.Arg[types.Dereferable](ctx, args, 0))
+		}
+	})
+	call.Static(func(fn func(context.Context, types.MalType) (types.MalType, error)) types.ExternalCall {
+		return func(ctx context.Context, args []types.MalType) (types.MalType, error) {
+			return fn(ctx, call.Arg[types.MalType](ctx, args, 0))
+		}
+	})
+	call.Static(func(fn func(context.Context, types.MalType, types.MalType) (types.MalType, error)) types.ExternalCall {
+		return func(ctx context.Context, args []types.MalType) (types.MalType, error) {
+			return fn(ctx, call.Arg[types.MalType](ctx, args, 0), call.Arg[types.MalType](ctx, args, 1))
+		}
+	})
+	call.Static(func(fn func(context.Context, types.MalType, types.MalType, types.MalType) (types.MalType, error)) types.ExternalCall {
+		return func(ctx context.Context, args []types.MalType) (types.MalType, error) {
+			return fn(ctx, call.Arg[types.MalType](ctx, args, 0), call.Arg[types.MalType](ctx, args, 1), call.Arg[types.MalType](ctx, args, 2))
+		}
+	})
+	call.Static(func(fn func(context.Context, types.MalType, types.Vector, types.MalType) (types.MalType, error)) types.ExternalCall {
+		return func(ctx context.Context, args []types.MalType) (types.MalType, error) {
+			return fn(ctx, call.Arg[types.MalType](ctx, args, 0), call.Arg[types.Vector](ctx, args, 1), call.Arg[types.MalType](ctx, args, 2))
+		}
+	})
+	call.Static(func(fn func(context.Context, types.Symbol, types.MalType) (types.MalType, error)) types.ExternalCall {
+		return func(ctx context.Context, args []types.MalType) (types.MalType, error) {
+			return fn(ctx, call.Arg[types.Symbol](ctx, args, 0), call.Arg[types.MalType](ctx, args, 1))
+		}
+	})
+	call.Static(func(fn func(error) (string, error)) types.ExternalCall {
+		return func(ctx context.Context, args []types.MalType) (types.MalType, error) {
+			return fn(call.Arg[error](ctx, args, 0))
+		}
+	})
+	call.Static(func(fn func(error) (types.MalType, error)) types.ExternalCall {
+		return func(ctx context.Context, args []types.MalType) (types.MalType, error) {
+			return fn(call.Arg[error](ctx, args, 0))
+		}
+	})
+	call.Static(func(fn func(int, int) (bool, error)) types.ExternalCall {
+		return func(ctx context.Context, args []types.MalType) (types.MalType, error) {
+			return fn(call.Arg[int](ctx, args, 0), call.Arg[int](ctx, args, 1))
+		}
+	})
+	call.Static(func(fn func(int, int) (int, error)) types.ExternalCall {
+		return func(ctx context.Context, args []types.MalType) (types.MalType, error) {
+			return fn(call.Arg[int](ctx, args, 0), call.Arg[int](ctx, args, 1))
+		}
+	})
+	call.Static(func(fn func(int, int) (types.Vector, error)) types.ExternalCall {
+		return func(ctx context.Context, args []types.MalType) (types.MalType, error) {
+			return fn(call.Arg[int](ctx, args, 0), call.Arg[int](ctx, args, 1))
+		}
+	})
+	call.Static(func(fn func(int, types.MalType) (types.MalType, error)) types.ExternalCall {
+		return func(ctx context.Context, args []types.MalType) (types.MalType, error) {
+			return fn(call.Arg[int](ctx, args, 0), call.Arg[types.MalType](ctx, args, 1))
+		}
+	})
+	call.Static(func(fn func(marshaler.FactoryHashMap, types.HashMap) (types.MalType, error)) types.ExternalCall {
+		return func(ctx context.Context, args []types.MalType) (types.MalType, error) {
+			return fn(call.Arg[marshaler.FactoryHashMap](ctx, args, 0), call.Arg[types.HashMap](ctx, args, 1))
+		}
+	})
+	call.Static(func(fn func(string) ([]byte, error)) types.ExternalCall {
+		return func(ctx context.Context, args []types.MalType) (types.MalType, error) {
+			return fn(call.Arg[string](ctx, args, 0))
+		}
+	})
+	call.Static(func(fn func(string) (error, error)) types.ExternalCall {
+		return func(ctx context.Context, args []types.MalType) (types.MalType, error) {
+			return fn(call.Arg[string](ctx, args, 0))
+		}
+	})
+	call.Static(func(fn func(string) (string, error)) types.ExternalCall {
+		return func(ctx context.Context, args []types.MalType) (types.MalType, error) {
+			return fn(call.Arg[string](ctx, args, 0))
+		}
+	})
+	call.Static(func(fn func(string) (types.Keyword, error)) types.ExternalCall {
+		return func(ctx context.Context, args []types.MalType) (types.MalType, error) {
+			return fn(call.Arg[string](ctx, args, 0))
+		}
+	})
+	call.Static(func(fn func(string) (types.MalType, error)) types.ExternalCall {
+		return func(ctx context.Context, args []types.MalType) (types.MalType, error) {
+			return fn(call.Arg[string](ctx, args, 0))
+		}
+	})
+	call.Static(func(fn func(string) (types.Symbol, error)) types.ExternalCall {
+		return func(ctx context.Context, args []types.MalType) (types.MalType, error) {
+			return fn(call.Arg[string](ctx, args, 0))
+		}
+	})
+	call.Static(func(fn func(string, ...types.MalType) (types.MalType, error)) types.ExternalCall {
+		return func(ctx context.Context, args []types.MalType) (types.MalType, error) {
+			return fn(call.Arg[string](ctx, args, 0), call.Rest[types.MalType](ctx, args, 1)...)
+		}
+	})
+	call.Static(func(fn func(string, []types.Symbol) (*types.RecordType, error)) types.ExternalCall {
+		return func(ctx context.Context, args []types.MalType) (types.MalType, error) {
+			return fn(call.Arg[string](ctx, args, 0), call.Arg[[]types.Symbol](ctx, args, 1))
+		}
+	})
+	call.Static(func(fn func(string, string) (types.MalType, error)) types.ExternalCall {
+		return func(ctx context.Context, args []types.MalType) (types.MalType, error) {
+			return fn(call.Arg[string](ctx, args, 0), call.Arg[string](ctx, args, 1))
+		}
+	})
+	call.Static(func(fn func(string, string) (types.Vector, error)) types.ExternalCall {
+		return func(ctx context.Context, args []types.MalType) (types.MalType, error) {
+			return fn(call.Arg[string](ctx, args, 0), call.Arg[string](ctx, args, 1))
+		}
+	})
+	call.Static(func(fn func(string, types.MalType, types.MalType, bool) (types.MalType, error)) types.ExternalCall {
+		return func(ctx context.Context, args []types.MalType) (types.MalType, error) {
+			return fn(call.Arg[string](ctx, args, 0), call.Arg[types.MalType](ctx, args, 1), call.Arg[types.MalType](ctx, args, 2), call.Arg[bool](ctx, args, 3))
+		}
+	})
+	call.Static(func(fn func(types.HashMap, types.HashMap) (types.HashMap, error)) types.ExternalCall {
+		return func(ctx context.Context, args []types.MalType) (types.MalType, error) {
+			return fn(call.Arg[types.HashMap](ctx, args, 0), call.Arg[types.HashMap](ctx, args, 1))
+		}
+	})
+	call.Static(func(fn func(types.MalType)) types.ExternalCall {
+		return func(ctx context.Context, args []types.MalType) (types.MalType, error) {
+			fn(call.Arg[types.MalType](ctx, args, 0))
+			return nil, nil
+		}
+	})
+	call.Static(func(fn func(types.MalType) (bool, error)) types.ExternalCall {
+		return func(ctx context.Context, args []types.MalType) (types.MalType, error) {
+			return fn(call.Arg[types.MalType](ctx, args, 0))
+		}
+	})
+	call.Static(func(fn func(types.MalType) (int, error)) types.ExternalCall {
+		return func(ctx context.Context, args []types.MalType) (types.MalType, error) {
+			return fn(call.Arg[types.MalType](ctx, args, 0))
+		}
+	})
+	call.Static(func(fn func(types.MalType) (string, error)) types.ExternalCall {
+		return func(ctx context.Context, args []types.MalType) (types.MalType, error) {
+			return fn(call.Arg[types.MalType](ctx, args, 0))
+		}
+	})
+	call.Static(func(fn func(types.MalType) (types.List, error)) types.ExternalCall {
+		return func(ctx context.Context, args []types.MalType) (types.MalType, error) {
+			return fn(call.Arg[types.MalType](ctx, args, 0))
+		}
+	})
+	call.Static(func(fn func(types.MalType) (types.MalType, error)) types.ExternalCall {
+		return func(ctx context.Context, args []types.MalType) (types.MalType, error) {
+			return fn(call.Arg[types.MalType](ctx, args, 0))
+		}
+	})
+	call.Static(func(fn func(types.MalType) (types.Set, error)) types.ExternalCall {
+		return func(ctx context.Context, args []types.MalType) (types.MalType, error) {
+			return fn(call.Arg[types.MalType](ctx, args, 0))
+		}
+	})
+	call.Static(func(fn func(types.MalType, ...*types.Position) (lisperror.LispError, error)) types.ExternalCall {
+		return func(ctx context.Context, args []types.MalType) (types.MalType, error) {
+			return fn(call.Arg[types.MalType](ctx, args, 0), call.Rest[*types.Position](ctx, args, 1)...)
+		}
+	})
+	call.Static(func(fn func(types.MalType, int) (types.MalType, error)) types.ExternalCall {
+		return func(ctx context.Context, args []types.MalType) (types.MalType, error) {
+			return fn(call.Arg[types.MalType](ctx, args, 0), call.Arg[int](ctx, args, 1))
+		}
+	})
+	call.Static(func(fn func(types.MalType, types.MalType) (bool, error)) types.ExternalCall {
+		return func(ctx context.Context, args []types.MalType) (types.MalType, error) {
+			return fn(call.Arg[types.MalType](ctx, args, 0), call.Arg[types.MalType](ctx, args, 1))
+		}
+	})
+	call.Static(func(fn func(types.MalType, types.MalType) (types.List, error)) types.ExternalCall {
+		return func(ctx context.Context, args []types.MalType) (types.MalType, error) {
+			return fn(call.Arg[types.MalType](ctx, args, 0), call.Arg[types.MalType](ctx, args, 1))
+		}
+	})
+	call.Static(func(fn func(types.MalType, types.MalType) (types.MalType, error)) types.ExternalCall {
+		return func(ctx context.Context, args []types.MalType) (types.MalType, error) {
+			return fn(call.Arg[types.MalType](ctx, args, 0), call.Arg[types.MalType](ctx, args, 1))
+		}
+	})
+	call.Static(func(fn func(types.MalType, types.Vector, types.MalType) (types.MalType, error)) types.ExternalCall {
+		return func(ctx context.Context, args []types.MalType) (types.MalType, error) {
+			return fn(call.Arg[types.MalType](ctx, args, 0), call.Arg[types.Vector](ctx, args, 1), call.Arg[types.MalType](ctx, args, 2))
+		}
+	})
+}
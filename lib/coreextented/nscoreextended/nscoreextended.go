@@ -8,6 +8,7 @@ import (
 
 	"github.com/jig/lisp"
 	"github.com/jig/lisp/lib/coreextented"
+	"github.com/jig/lisp/lib/dispatch/nsdispatch"
 	"github.com/jig/lisp/types"
 )
 
@@ -19,6 +20,10 @@ var (
 )
 
 func Load(env types.EnvType) error {
+	// protocols (defprotocol, extend and satisfies?) are those of the dispatch library
+	if err := nsdispatch.Load(env); err != nil {
+		return err
+	}
 	if _, err := lisp.REPL(context.Background(), env, coreextented.HeaderCoreExtended(), types.NewCursorFile(_package_)); err != nil {
 		return err
 	}
@@ -0,0 +1,259 @@
+// Package dispatch implements polymorphic dispatch: multimethods (defmulti and
+// defmethod), dispatching on the value returned by a dispatch function and on a
+// hierarchy of dispatch values, and protocols (defprotocol and extend-type),
+// dispatching on the type of the first argument.
+//
+// Types are named as by type-of: by the :type of the metadata of a value (as set on
+// records) or else as by type? (e.g. "vector", or the Type() of Typed Go values such
+// as "atom" and "future-call"), so Lisp code extends protocols to the Go types
+// registered by libraries.
+package dispatch
+
+//go:generate go run github.com/jig/lisp/cmd/lispbind
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+
+	"github.com/jig/lisp/lib/call"
+	. "github.com/jig/lisp/types"
+)
+
+//go:embed header-dispatch.lisp
+var headerDispatch string
+
+func HeaderDispatch() string { return headerDispatch }
+
+func Load(env EnvType) {
+	// global is the hierarchy of the multimethods and of derive, isa?, parents and
+	// ancestors of env when no hierarchy is passed
+	global := NewHierarchy()
+
+	call.Call(env, make_hierarchy)
+	call.CallOverrideFN(env, "derive", func(args ...MalType) (MalType, error) { return derive(global, args) })
+	call.CallOverrideFN(env, "underive", func(args ...MalType) (MalType, error) { return underive(global, args) })
+	call.CallOverrideFN(env, "isa?", func(args ...MalType) (bool, error) { return isa_Q(global, args) })
+	call.CallOverrideFN(env, "parents", func(args ...MalType) (MalType, error) { return parents(global, args) })
+	call.CallOverrideFN(env, "ancestors", func(args ...MalType) (MalType, error) { return ancestors(global, args) })
+
+	call.CallOverrideFN(env, "new-multimethod", func(name string, dispatch MalType, opts multimethodOptions) (MultiFn, error) {
+		return new_multimethod(global, name, dispatch, opts)
+	})
+	call.Call(env, add_method)
+	call.Call(env, remove_method)
+	call.Call(env, prefer_method)
+	call.Call(env, get_method)
+
+	call.Call(env, new_protocol)
+	call.Call(env, protocol_method)
+	call.Call(env, extend)
+	call.CallOverrideFN(env, "satisfies?", satisfies_Q)
+	call.CallOverrideFN(env, "extends?", extends_Q)
+	call.Call(env, extenders)
+	call.Call(env, type_of)
+	call.CallOverrideFN(env, "_extend-specs", _extend_specs)
+}
+
+func make_hierarchy() (*Hierarchy, error) {
+	return NewHierarchy(), nil
+}
+
+// hierarchyArgs returns the hierarchy (the first argument if there are n+1 arguments,
+// else global) and the other n arguments
+func hierarchyArgs(global *Hierarchy, name string, n int, args []MalType) (*Hierarchy, []MalType, error) {
+	switch len(args) {
+	case n:
+		return global, args, nil
+	case n + 1:
+		h, ok := args[0].(*Hierarchy)
+		if !ok {
+			return nil, nil, fmt.Errorf("%s called with a first argument that is not a hierarchy", name)
+		}
+		return h, args[1:], nil
+	default:
+		return nil, nil, fmt.Errorf("%s requires %d or %d arguments", name, n, n+1)
+	}
+}
+
+func derive(global *Hierarchy, args []MalType) (MalType, error) {
+	h, args, err := hierarchyArgs(global, "derive", 2, args)
+	if err != nil {
+		return nil, err
+	}
+	return nil, h.Derive(args[0], args[1])
+}
+
+func underive(global *Hierarchy, args []MalType) (MalType, error) {
+	h, args, err := hierarchyArgs(global, "underive", 2, args)
+	if err != nil {
+		return nil, err
+	}
+	h.Underive(args[0], args[1])
+	return nil, nil
+}
+
+func isa_Q(global *Hierarchy, args []MalType) (bool, error) {
+	h, args, err := hierarchyArgs(global, "isa?", 2, args)
+	if err != nil {
+		return false, err
+	}
+	return h.Isa(args[0], args[1]), nil
+}
+
+func parents(global *Hierarchy, args []MalType) (MalType, error) {
+	h, args, err := hierarchyArgs(global, "parents", 1, args)
+	if err != nil {
+		return nil, err
+	}
+	return tagSet(h.Parents(args[0])), nil
+}
+
+func ancestors(global *Hierarchy, args []MalType) (MalType, error) {
+	h, args, err := hierarchyArgs(global, "ancestors", 1, args)
+	if err != nil {
+		return nil, err
+	}
+	return tagSet(h.Ancestors(args[0])), nil
+}
+
+// tagSet returns the set of tags (nil if empty)
+func tagSet(tags []MalType) MalType {
+	if len(tags) == 0 {
+		return nil
+	}
+	set := Set{Val: map[MalType]struct{}{}}
+	for _, tag := range tags {
+		set.Val[tag] = struct{}{}
+	}
+	return set
+}
+
+type multimethodOptions struct {
+	call.Options
+	Default   MalType `lisp:"default,default=:default"`
+	Hierarchy MalType `lisp:"hierarchy"`
+}
+
+func new_multimethod(global *Hierarchy, name string, dispatch MalType, opts multimethodOptions) (MultiFn, error) {
+	if !Callable_Q(dispatch) {
+		return MultiFn{}, fmt.Errorf("dispatch of multimethod '%s' is not callable", name)
+	}
+	h := global
+	if opts.Hierarchy != nil {
+		var ok bool
+		if h, ok = opts.Hierarchy.(*Hierarchy); !ok {
+			return MultiFn{}, fmt.Errorf("hierarchy of multimethod '%s' is not a hierarchy", name)
+		}
+	}
+	return NewMultiFn(name, dispatch, opts.Default, h), nil
+}
+
+func add_method(m MultiFn, value, fn MalType) (MultiFn, error) {
+	if !Callable_Q(fn) {
+		return MultiFn{}, errors.New("add-method called with a method that is not a function")
+	}
+	m.AddMethod(value, fn)
+	return m, nil
+}
+
+func remove_method(m MultiFn, value MalType) (MultiFn, error) {
+	m.RemoveMethod(value)
+	return m, nil
+}
+
+func prefer_method(m MultiFn, value, other MalType) (MultiFn, error) {
+	m.PreferMethod(value, other)
+	return m, nil
+}
+
+func get_method(m MultiFn, value MalType) (MalType, error) {
+	return m.Method(value)
+}
+
+func new_protocol(name string, methods ...string) (Protocol, error) {
+	return NewProtocol(name, methods...), nil
+}
+
+func protocol_method(p Protocol, name string) (ProtocolMethod, error) {
+	return p.Method(name)
+}
+
+// extend extends protocols to a type: (extend type protocol {:method fn ...} protocol2 ...)
+func extend(t MalType, specs ...MalType) (MalType, error) {
+	name, err := typeName(t)
+	if err != nil {
+		return nil, err
+	}
+	if len(specs)%2 != 0 {
+		return nil, errors.New("extend requires protocol and method map pairs")
+	}
+	for i := 0; i < len(specs); i += 2 {
+		p, ok := specs[i].(Protocol)
+		if !ok {
+			return nil, fmt.Errorf("extend called with %s instead of a protocol", TypeName(specs[i]))
+		}
+		methods, ok := specs[i+1].(HashMap)
+		if !ok {
+			return nil, fmt.Errorf("extend called with %s instead of the method map of protocol '%s'", TypeName(specs[i+1]), p.name)
+		}
+		impls := map[string]MalType{}
+		for key, fn := range methods.Val {
+			if !Callable_Q(fn) {
+				return nil, fmt.Errorf("method '%s' of protocol '%s' is not a function", KeyName(key), p.name)
+			}
+			impls[KeyName(key)] = fn
+		}
+		if err := p.Extend(name, impls); err != nil {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
+func satisfies_Q(p Protocol, obj MalType) (bool, error) {
+	return p.Satisfies(obj), nil
+}
+
+func extends_Q(p Protocol, t MalType) (bool, error) {
+	name, err := typeName(t)
+	if err != nil {
+		return false, err
+	}
+	return p.Extends(name), nil
+}
+
+func extenders(p Protocol) (MalType, error) {
+	types := []MalType{}
+	for _, t := range p.Types() {
+		types = append(types, t)
+	}
+	return List{Val: types}, nil
+}
+
+func type_of(obj MalType) (string, error) {
+	return TypeOf(obj), nil
+}
+
+// _extend_specs groups the forms of extend-type, protocol (method [this params...]
+// body...)..., in protocol and method map pairs, the methods being fn forms
+func _extend_specs(specs []MalType) (MalType, error) {
+	result := []MalType{}
+	var methods HashMap
+	for _, spec := range specs {
+		switch spec := spec.(type) {
+		case List:
+			if methods.Val == nil {
+				return nil, errors.New("extend-type: method defined before its protocol")
+			}
+			if len(spec.Val) < 2 || !Q[Symbol](spec.Val[0]) {
+				return nil, fmt.Errorf("extend-type: invalid method %v", spec.Val)
+			}
+			methods.Val[NewKeyword(spec.Val[0].(Symbol).Val)] = List{Val: append([]MalType{Symbol{Val: "fn"}}, spec.Val[1:]...), Cursor: spec.Cursor}
+		default:
+			methods = HashMap{Val: map[MalType]MalType{}}
+			result = append(result, spec, methods)
+		}
+	}
+	return List{Val: result}, nil
+}
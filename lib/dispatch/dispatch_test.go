@@ -0,0 +1,114 @@
+package dispatch
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jig/lisp"
+	"github.com/jig/lisp/env"
+	"github.com/jig/lisp/lib/concurrent"
+	"github.com/jig/lisp/lib/core"
+	"github.com/jig/lisp/types"
+)
+
+func dispatchEnv(t testing.TB) types.EnvType {
+	ns := env.NewEnv()
+	core.Load(ns)
+	concurrent.Load(ns)
+	Load(ns)
+	for _, header := range []string{core.HeaderBasic(), HeaderDispatch()} {
+		if _, err := lisp.REPL(context.Background(), ns, header, types.NewCursorFile(t.Name())); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return ns
+}
+
+func TestDispatch(t *testing.T) {
+	ns := dispatchEnv(t)
+	for _, step := range []struct{ code, expected string }{
+		{`(defmulti rule (fn [fact] [(:kind fact) (:level fact)]))`, `«multimethod rule»`},
+		{`(defmethod rule [:alert :high] [_] "page")`, `«multimethod rule»`},
+		{`(defmethod rule :default [_] "log")`, `«multimethod rule»`},
+		{`(rule {:kind :alert :level :high})`, `"page"`},
+		{`(rule {:kind :alert :level :low})`, `"log"`},
+		{`(derive :critical :high)`, `nil`},
+		{`(rule {:kind :alert :level :critical})`, `"page"`},
+		{`(underive :critical :high)`, `nil`},
+		{`(rule {:kind :alert :level :critical})`, `"log"`},
+		{`(defprotocol Deref (value [x]))`, `«protocol Deref»`},
+		{`(extend-type atom Deref (value [a] @a))`, `nil`},
+		{`(extend-type future-call Deref (value [f] @f))`, `nil`},
+		{`(value (atom 1))`, `1`},
+		{`(value (future-call (fn [] 2)))`, `2`},
+		{`(satisfies? Deref (atom 1))`, `true`},
+		{`(type-of (atom 1))`, `"atom"`},
+	} {
+		result, err := lisp.REPL(context.Background(), ns, step.code, types.NewCursorFile(t.Name()))
+		if err != nil {
+			t.Fatalf("%s: %s", step.code, err)
+		}
+		if result != step.expected {
+			t.Fatalf("%s: expected %s, got %s", step.code, step.expected, result)
+		}
+	}
+}
+
+func TestHierarchyPerEnv(t *testing.T) {
+	ns1, ns2 := dispatchEnv(t), dispatchEnv(t)
+	if _, err := lisp.REPL(context.Background(), ns1, `(derive :critical :high)`, types.NewCursorFile(t.Name())); err != nil {
+		t.Fatal(err)
+	}
+	for _, step := range []struct {
+		ns       types.EnvType
+		expected string
+	}{{ns1, "true"}, {ns2, "false"}} {
+		result, err := lisp.REPL(context.Background(), step.ns, `(isa? :critical :high)`, types.NewCursorFile(t.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result != step.expected {
+			t.Fatalf("expected %s, got %s", step.expected, result)
+		}
+	}
+}
+
+func BenchmarkMultimethod(b *testing.B) {
+	ns := dispatchEnv(b)
+	if _, err := lisp.REPL(context.Background(), ns, `(do
+		(defmulti area :shape)
+		(defmethod area :square [s] (* (:side s) (:side s)))
+		(defmethod area :rect [r] (* (:w r) (:h r))))`, nil); err != nil {
+		b.Fatal(err)
+	}
+	call, err := lisp.READ(`(area {:shape :square :side 3})`, nil, nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for range b.N {
+		if _, err := lisp.EVAL(context.Background(), call, ns); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkProtocol(b *testing.B) {
+	ns := dispatchEnv(b)
+	if _, err := lisp.REPL(context.Background(), ns, `(do
+		(defprotocol Shape (area [s]))
+		(extend-type :square Shape (area [s] (* (:side s) (:side s))))
+		(def sq ^{:type :square} {:side 3}))`, nil); err != nil {
+		b.Fatal(err)
+	}
+	call, err := lisp.READ(`(area sq)`, nil, nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for range b.N {
+		if _, err := lisp.EVAL(context.Background(), call, ns); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
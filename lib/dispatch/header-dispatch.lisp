@@ -0,0 +1,37 @@
+;; $MODULE header-dispatch
+
+(do
+    (defmacro defmulti
+        "Defines a multimethod: (defmulti name docstring? attr-map? dispatch-fn & options). Options are :default, the dispatch value of the method called when no other matches (:default if not set), and :hierarchy (see make-hierarchy)."
+        (fn [name & decl]
+            (let [doc   (if (string? (first decl)) (list (first decl)) ())
+                  decl  (if (string? (first decl)) (rest decl) decl)
+                  attrs (if (map? (first decl)) (list (first decl)) ())
+                  decl  (if (map? (first decl)) (rest decl) decl)]
+                `(def ~name ~@doc ~@attrs
+                    (new-multimethod ~(str name) ~@decl)))))
+
+    (defmacro defmethod
+        "Adds the method of a dispatch value to a multimethod: (defmethod name dispatch-value [params] body...)"
+        (fn [name value & decl]
+            `(add-method ~name ~value (fn ~@decl))))
+
+    (defmacro defprotocol
+        "Defines a protocol and its methods: (defprotocol name docstring? (method [this params...] docstring?)...)"
+        (fn [name & decl]
+            (let [doc     (if (string? (first decl)) (list (first decl)) ())
+                  methods (if (string? (first decl)) (rest decl) decl)]
+                `(do
+                    (def ~name ~@doc
+                        (new-protocol ~(str name) ~@(map (fn [method] (str (first method))) methods)))
+                    ~@(map
+                        (fn [method]
+                            `(def ~(first method) ~@(drop 2 method) {:arglists '(~(nth method 1))}
+                                (protocol-method ~name ~(str (first method)))))
+                        methods)
+                    ~name))))
+
+    (defmacro extend-type
+        "Extends protocols to a type: (extend-type type protocol (method [this params...] body...)... protocol2 ...). The type is named as by type-of (e.g. vector, nil, atom or :shape/circle)."
+        (fn [type & specs]
+            `(extend '~type ~@(_extend-specs specs)))))
@@ -0,0 +1,143 @@
+package dispatch
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	. "github.com/jig/lisp/types"
+)
+
+// Hierarchy holds the parent/child relations between the dispatch values of
+// multimethods (strings and keywords, e.g. the names of types returned by type?)
+type Hierarchy struct {
+	mu      sync.RWMutex
+	parents map[MalType][]MalType
+	version atomic.Uint64 // incremented on every change, so multimethods invalidate their caches
+}
+
+// NewHierarchy returns an empty hierarchy
+func NewHierarchy() *Hierarchy {
+	return &Hierarchy{parents: map[MalType][]MalType{}}
+}
+
+func (h *Hierarchy) Type() string {
+	return "hierarchy"
+}
+
+func (h *Hierarchy) LispPrint(_ func(MalType, bool) string) string {
+	return "«hierarchy»"
+}
+
+// Derive makes parent a parent of child
+func (h *Hierarchy) Derive(child, parent MalType) error {
+	if !HashKey_Q(child) || !HashKey_Q(parent) {
+		return errors.New("derive called with a tag that is not a string or a keyword")
+	}
+	if child == parent {
+		return fmt.Errorf("%s cannot be derived from itself", tagString(child))
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.isa(parent, child) {
+		return fmt.Errorf("cyclic derivation: %s is an ancestor of %s", tagString(child), tagString(parent))
+	}
+	for _, p := range h.parents[child] {
+		if p == parent {
+			return nil
+		}
+	}
+	h.parents[child] = append(h.parents[child], parent)
+	h.version.Add(1)
+	return nil
+}
+
+// Underive removes parent from the parents of child
+func (h *Hierarchy) Underive(child, parent MalType) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	parents := h.parents[child]
+	for i, p := range parents {
+		if p == parent {
+			h.parents[child] = append(parents[:i:i], parents[i+1:]...)
+			h.version.Add(1)
+			return
+		}
+	}
+}
+
+// Parents returns the immediate parents of tag
+func (h *Hierarchy) Parents(tag MalType) []MalType {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if !HashKey_Q(tag) {
+		return nil
+	}
+	return append([]MalType{}, h.parents[tag]...)
+}
+
+// Ancestors returns the parents of tag, their parents and so on (nearest first)
+func (h *Hierarchy) Ancestors(tag MalType) []MalType {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if !HashKey_Q(tag) {
+		return nil
+	}
+	ancestors := []MalType{}
+	seen := map[MalType]bool{}
+	queue := []MalType{tag}
+	for len(queue) > 0 {
+		for _, p := range h.parents[queue[0]] {
+			if !seen[p] {
+				seen[p] = true
+				ancestors = append(ancestors, p)
+				queue = append(queue, p)
+			}
+		}
+		queue = queue[1:]
+	}
+	return ancestors
+}
+
+// Isa reports whether child equals parent or derives from it; vectors are compared item
+// by item (e.g. [:square :red] isa [:shape :color])
+func (h *Hierarchy) Isa(child, parent MalType) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.isa(child, parent)
+}
+
+func (h *Hierarchy) isa(child, parent MalType) bool {
+	if Equal_Q(child, parent) {
+		return true
+	}
+	if child, ok := child.(Vector); ok {
+		parent, ok := parent.(Vector)
+		if !ok || len(child.Val) != len(parent.Val) {
+			return false
+		}
+		for i := range child.Val {
+			if !h.isa(child.Val[i], parent.Val[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	if !HashKey_Q(child) {
+		return false
+	}
+	for _, p := range h.parents[child] {
+		if p == parent || h.isa(p, parent) {
+			return true
+		}
+	}
+	return false
+}
+
+func tagString(tag MalType) string {
+	if k, ok := tag.(Keyword); ok {
+		return k.String()
+	}
+	return fmt.Sprintf("%q", tag)
+}
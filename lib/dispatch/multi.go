@@ -0,0 +1,199 @@
+package dispatch
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/jig/lisp/printer"
+	. "github.com/jig/lisp/types"
+)
+
+// MultiFn is a multimethod: calling it applies its dispatch function to the arguments
+// and calls the method of the dispatch value, or of its nearest ancestor on the
+// hierarchy, or the method of the default dispatch value (:default unless set)
+type MultiFn struct {
+	*multi
+	meta MalType
+}
+
+type multi struct {
+	name      string
+	dispatch  MalType
+	def       MalType
+	hierarchy *Hierarchy
+
+	mu      sync.RWMutex
+	methods map[MalType]method
+	prefers map[MalType][]MalType // dispatch value → values it is preferred over
+
+	// methods resolved by dispatch value, valid while the hierarchy is unchanged
+	cache        map[MalType]MalType
+	cacheVersion uint64
+}
+
+type method struct {
+	value MalType // dispatch value
+	fn    MalType
+}
+
+// printed is the key of the dispatch values that cannot be map keys (e.g. vectors)
+type printed string
+
+func key(value MalType) MalType {
+	if value == nil || reflect.TypeOf(value).Comparable() {
+		return value
+	}
+	return printed(printer.Pr_str(value, true))
+}
+
+// NewMultiFn returns a multimethod without methods
+func NewMultiFn(name string, dispatch, def MalType, hierarchy *Hierarchy) MultiFn {
+	return MultiFn{multi: &multi{
+		name:      name,
+		dispatch:  dispatch,
+		def:       def,
+		hierarchy: hierarchy,
+		methods:   map[MalType]method{},
+		prefers:   map[MalType][]MalType{},
+		cache:     map[MalType]MalType{},
+	}}
+}
+
+func (m MultiFn) Type() string {
+	return "multimethod"
+}
+
+func (m MultiFn) Meta() MalType {
+	return m.meta
+}
+
+func (m MultiFn) WithMeta(meta MalType) MalType {
+	return MultiFn{multi: m.multi, meta: meta}
+}
+
+func (m MultiFn) LispPrint(_ func(MalType, bool) string) string {
+	return "«multimethod " + m.name + "»"
+}
+
+// AddMethod sets the method of a dispatch value
+func (m MultiFn) AddMethod(value, fn MalType) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.methods[key(value)] = method{value: value, fn: fn}
+	m.reset()
+}
+
+// RemoveMethod removes the method of a dispatch value
+func (m MultiFn) RemoveMethod(value MalType) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.methods, key(value))
+	m.reset()
+}
+
+// PreferMethod prefers the method of value over the one of other when both match a
+// dispatch value and neither derives from the other
+func (m MultiFn) PreferMethod(value, other MalType) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.prefers[key(value)] = append(m.prefers[key(value)], other)
+	m.reset()
+}
+
+// Method returns the method called for a dispatch value (nil if none)
+func (m MultiFn) Method(value MalType) (MalType, error) {
+	version := m.hierarchy.version.Load()
+	k := key(value)
+	m.mu.RLock()
+	fn, ok := m.cache[k]
+	ok = ok && m.cacheVersion == version
+	m.mu.RUnlock()
+	if ok {
+		return fn, nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.cacheVersion != version {
+		m.reset()
+		m.cacheVersion = version
+	}
+	fn, err := m.resolve(value)
+	if err != nil {
+		return nil, err
+	}
+	m.cache[k] = fn
+	return fn, nil
+}
+
+// Call calls the method of the dispatch value of the arguments
+func (m MultiFn) Call(ctx context.Context, args []MalType) (MalType, error) {
+	value, err := Apply(ctx, m.dispatch, args)
+	if err != nil {
+		return nil, err
+	}
+	fn, err := m.Method(value)
+	if err != nil {
+		return nil, err
+	}
+	if fn == nil {
+		return nil, fmt.Errorf("no method in multimethod '%s' for dispatch value %s", m.name, printer.Pr_str(value, true))
+	}
+	return Apply(ctx, fn, args)
+}
+
+func (m *multi) reset() {
+	clear(m.cache)
+}
+
+// resolve finds the method of a dispatch value: its own, the one of its most specific
+// ancestor or the default one
+func (m *multi) resolve(value MalType) (MalType, error) {
+	if method, ok := m.methods[key(value)]; ok {
+		return method.fn, nil
+	}
+	matches := []method{}
+	for _, candidate := range m.methods {
+		if m.hierarchy.Isa(value, candidate.value) {
+			matches = append(matches, candidate)
+		}
+	}
+	slices.SortFunc(matches, func(a, b method) int {
+		return strings.Compare(printer.Pr_str(a.value, true), printer.Pr_str(b.value, true))
+	})
+	// the methods no other method is chosen over
+	best := []method{}
+	for _, candidate := range matches {
+		if !slices.ContainsFunc(matches, func(other method) bool {
+			return key(other.value) != key(candidate.value) && m.dominates(other.value, candidate.value)
+		}) {
+			best = append(best, candidate)
+		}
+	}
+	switch len(best) {
+	case 0:
+	case 1:
+		return best[0].fn, nil
+	default:
+		return nil, fmt.Errorf("multiple methods in multimethod '%s' match dispatch value %s: %s and %s, and neither is preferred",
+			m.name, printer.Pr_str(value, true), printer.Pr_str(best[0].value, true), printer.Pr_str(best[1].value, true))
+	}
+	if method, ok := m.methods[key(m.def)]; ok {
+		return method.fn, nil
+	}
+	return nil, nil
+}
+
+// dominates reports whether the method of value is chosen over the one of other
+func (m *multi) dominates(value, other MalType) bool {
+	for _, p := range m.prefers[key(value)] {
+		if m.hierarchy.Isa(other, p) {
+			return true
+		}
+	}
+	return m.hierarchy.Isa(value, other)
+}
@@ -0,0 +1,28 @@
+package nsdispatch
+
+import (
+	"context"
+	"reflect"
+	"strings"
+
+	"github.com/jig/lisp"
+	"github.com/jig/lisp/lib/dispatch"
+	"github.com/jig/lisp/types"
+)
+
+type Here struct{}
+
+var (
+	__package_fullpath__ = strings.Split(reflect.TypeFor[Here]().PkgPath(), "/")
+	_package_            = "$" + __package_fullpath__[len(__package_fullpath__)-1]
+)
+
+func Load(env types.EnvType) error {
+	dispatch.Load(env)
+
+	if _, err := lisp.REPL(context.Background(), env, dispatch.HeaderDispatch(), types.NewCursorFile(_package_)); err != nil {
+		return err
+	}
+
+	return nil
+}
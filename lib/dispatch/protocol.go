@@ -0,0 +1,188 @@
+package dispatch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+
+	. "github.com/jig/lisp/types"
+)
+
+// Default is the type name of the implementations of a protocol used for the types
+// that do not extend it
+const Default = "default"
+
+// Protocol is a named set of methods that types implement (extend) separately. The
+// method called is the one of the type of the first argument.
+type Protocol struct {
+	*protocol
+	meta MalType
+}
+
+type protocol struct {
+	name    string
+	methods map[string]ProtocolMethod
+
+	mu    sync.RWMutex
+	types map[string]bool // types extending the protocol
+}
+
+// ProtocolMethod is a method of a protocol
+type ProtocolMethod struct {
+	*protocolMethod
+	meta MalType
+}
+
+type protocolMethod struct {
+	protocol *protocol
+	name     string
+
+	mu    sync.RWMutex
+	impls map[string]MalType // type name → function
+}
+
+// NewProtocol returns a protocol of the methods named
+func NewProtocol(name string, methods ...string) Protocol {
+	p := &protocol{name: name, methods: map[string]ProtocolMethod{}, types: map[string]bool{}}
+	for _, m := range methods {
+		p.methods[m] = ProtocolMethod{protocolMethod: &protocolMethod{protocol: p, name: m, impls: map[string]MalType{}}}
+	}
+	return Protocol{protocol: p}
+}
+
+// TypeOf returns the type name protocols dispatch on: the :type of the metadata if it
+// is a keyword or a string (e.g. :shape/circle is "shape/circle"), else the name
+// returned by type? (e.g. "vector", or "atom" on Typed values)
+func TypeOf(obj MalType) string {
+	switch obj.(type) {
+	case List, Vector, HashMap, Set, MalFunc, Metadata:
+		meta, _ := MetaOf(obj)
+		if meta, ok := meta.(HashMap); ok {
+			if t, ok := meta.Val[NewKeyword("type")]; ok && HashKey_Q(t) {
+				return KeyName(t)
+			}
+		}
+	}
+	return TypeName(obj)
+}
+
+// typeName returns the type name of the types passed to extend: strings, and the names
+// of keywords and symbols (nil is the type of nil)
+func typeName(t MalType) (string, error) {
+	switch t := t.(type) {
+	case nil:
+		return "nil", nil
+	case string:
+		return t, nil
+	case Keyword:
+		return t.Name(), nil
+	case Symbol:
+		return t.Val, nil
+	default:
+		return "", fmt.Errorf("type name must be a string, a keyword or a symbol (found %s)", TypeName(t))
+	}
+}
+
+func (p Protocol) Type() string {
+	return "protocol"
+}
+
+func (p Protocol) Meta() MalType {
+	return p.meta
+}
+
+func (p Protocol) WithMeta(meta MalType) MalType {
+	return Protocol{protocol: p.protocol, meta: meta}
+}
+
+func (p Protocol) LispPrint(_ func(MalType, bool) string) string {
+	return "«protocol " + p.name + "»"
+}
+
+// Method returns the method of the protocol named name
+func (p Protocol) Method(name string) (ProtocolMethod, error) {
+	m, ok := p.methods[name]
+	if !ok {
+		return ProtocolMethod{}, fmt.Errorf("protocol '%s' has no method '%s'", p.name, name)
+	}
+	return m, nil
+}
+
+// Extend sets the implementations of the methods of the protocol for a type
+func (p Protocol) Extend(typeName string, impls map[string]MalType) error {
+	for name := range impls {
+		if _, err := p.Method(name); err != nil {
+			return err
+		}
+	}
+	p.mu.Lock()
+	p.types[typeName] = true
+	p.mu.Unlock()
+	for name, fn := range impls {
+		m := p.methods[name]
+		m.mu.Lock()
+		m.impls[typeName] = fn
+		m.mu.Unlock()
+	}
+	return nil
+}
+
+// Extends reports whether the type extends the protocol
+func (p Protocol) Extends(typeName string) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.types[typeName]
+}
+
+// Satisfies reports whether the protocol is implemented for the type of obj
+func (p Protocol) Satisfies(obj MalType) bool {
+	return p.Extends(TypeOf(obj)) || p.Extends(Default)
+}
+
+// Types returns the names of the types extending the protocol
+func (p Protocol) Types() []string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	types := make([]string, 0, len(p.types))
+	for t := range p.types {
+		types = append(types, t)
+	}
+	slices.Sort(types)
+	return types
+}
+
+func (m ProtocolMethod) Type() string {
+	return "protocol-method"
+}
+
+func (m ProtocolMethod) Meta() MalType {
+	return m.meta
+}
+
+func (m ProtocolMethod) WithMeta(meta MalType) MalType {
+	return ProtocolMethod{protocolMethod: m.protocolMethod, meta: meta}
+}
+
+func (m ProtocolMethod) LispPrint(_ func(MalType, bool) string) string {
+	return "«protocol-method " + m.protocol.name + "/" + m.name + "»"
+}
+
+// Call calls the implementation of the method for the type of the first argument
+func (m ProtocolMethod) Call(ctx context.Context, args []MalType) (MalType, error) {
+	if len(args) == 0 {
+		return nil, errors.New(m.name + ": protocol methods take at least one argument")
+	}
+	t := TypeOf(args[0])
+	m.mu.RLock()
+	fn, ok := m.impls[t]
+	if !ok {
+		fn, ok = m.impls[Default]
+	}
+	m.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("no implementation of method '%s' of protocol '%s' for type %q", m.name, m.protocol.name, t)
+	}
+	return Apply(ctx, fn, args)
+}
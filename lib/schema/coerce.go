@@ -0,0 +1,197 @@
+package schema
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"maps"
+	"math"
+
+	. "github.com/jig/lisp/types"
+)
+
+// Coerce converts v to the types of the schema where there is an unambiguous
+// conversion (e.g. of data decoded from JSON) and validates the result: string keys of
+// maps become the keyword keys of their entries, strings become keywords and symbols,
+// numbers become ints or doubles, and sequences become vectors and sets. The error is
+// an *Error if the result does not conform to the schema.
+func (s *Schema) Coerce(ctx context.Context, v MalType) (MalType, error) {
+	v, err := s.coerce(ctx, v)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Validate(ctx, v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// DecodeJSON decodes a JSON document and coerces it to the schema
+func (s *Schema) DecodeJSON(ctx context.Context, data []byte) (MalType, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var doc any
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, err
+	}
+	return s.Coerce(ctx, fromJSON(doc))
+}
+
+// fromJSON returns a decoded JSON document as Lisp data (objects as hash maps of string
+// keys, arrays as vectors and numbers as ints if integral, else as doubles)
+func fromJSON(doc any) MalType {
+	switch doc := doc.(type) {
+	case map[string]any:
+		hm := HashMap{Val: make(map[MalType]MalType, len(doc))}
+		for k, v := range doc {
+			hm.Val[k] = fromJSON(v)
+		}
+		return hm
+	case []any:
+		v := Vector{Val: make([]MalType, len(doc))}
+		for i, item := range doc {
+			v.Val[i] = fromJSON(item)
+		}
+		return v
+	case json.Number:
+		if i, err := doc.Int64(); err == nil {
+			return int(i)
+		}
+		f, _ := doc.Float64()
+		return f
+	default:
+		return doc
+	}
+}
+
+func (s *Schema) coerce(ctx context.Context, v MalType) (MalType, error) {
+	switch s.kind {
+	case "int":
+		if f, ok := v.(float64); ok && f == math.Trunc(f) && math.Abs(f) < 1<<53 {
+			return int(f), nil
+		}
+	case "double":
+		if n, ok := v.(int); ok {
+			return float64(n), nil
+		}
+	case "keyword":
+		if str, ok := v.(string); ok {
+			return NewKeyword(str), nil
+		}
+	case "symbol":
+		if str, ok := v.(string); ok {
+			return Symbol{Val: str}, nil
+		}
+	case "enum":
+		if str, ok := v.(string); ok {
+			for _, value := range s.values {
+				if k, ok := value.(Keyword); ok && k.Name() == str {
+					return k, nil
+				}
+			}
+		}
+	case "maybe":
+		if v != nil {
+			return s.children[0].coerce(ctx, v)
+		}
+	case "and":
+		for _, child := range s.children {
+			var err error
+			if v, err = child.coerce(ctx, v); err != nil {
+				return nil, err
+			}
+		}
+	case "or":
+		for _, child := range s.children {
+			c, err := child.coerce(ctx, v)
+			if err != nil {
+				return nil, err
+			}
+			if ok, err := child.Valid(ctx, c); err != nil || ok {
+				return c, err
+			}
+		}
+	case "map":
+		hm, ok := v.(HashMap)
+		if !ok {
+			return v, nil
+		}
+		hm = HashMap{Val: maps.Clone(hm.Val), Meta: hm.Meta, Cursor: hm.Cursor, Record: hm.Record}
+		for _, entry := range s.entries {
+			if k, ok := entry.key.(Keyword); ok {
+				if value, ok := hm.Val[k.Name()]; ok {
+					if _, dup := hm.Val[k]; !dup {
+						delete(hm.Val, k.Name())
+						hm.Val[k] = value
+					}
+				}
+			}
+			value, ok := hm.Val[entry.key]
+			if !ok {
+				continue
+			}
+			c, err := entry.schema.coerce(ctx, value)
+			if err != nil {
+				return nil, err
+			}
+			hm.Val[entry.key] = c
+		}
+		return hm, nil
+	case "map-of":
+		hm, ok := v.(HashMap)
+		if !ok {
+			return v, nil
+		}
+		result := HashMap{Val: make(map[MalType]MalType, len(hm.Val)), Meta: hm.Meta, Cursor: hm.Cursor}
+		for key, value := range hm.Val {
+			k, err := s.children[0].coerce(ctx, key)
+			if err != nil {
+				return nil, err
+			}
+			if !HashKey_Q(k) {
+				k = key
+			}
+			if result.Val[k], err = s.children[1].coerce(ctx, value); err != nil {
+				return nil, err
+			}
+		}
+		return result, nil
+	case "vector", "sequential", "tuple", "set":
+		if !Sequential_Q(v) {
+			return v, nil
+		}
+		items, _ := GetSlice(v)
+		coerced := make([]MalType, len(items))
+		for i, item := range items {
+			child := s.children[0]
+			if s.kind == "tuple" {
+				if len(items) != len(s.children) {
+					return v, nil
+				}
+				child = s.children[i]
+			}
+			var err error
+			if coerced[i], err = child.coerce(ctx, item); err != nil {
+				return nil, err
+			}
+		}
+		switch s.kind {
+		case "set":
+			for _, item := range coerced {
+				if !HashKey_Q(item) {
+					return v, nil
+				}
+			}
+			return NewSet(List{Val: coerced})
+		case "sequential":
+			if l, ok := v.(List); ok {
+				return List{Val: coerced, Meta: l.Meta, Cursor: l.Cursor}, nil
+			}
+		}
+		if vec, ok := v.(Vector); ok {
+			return Vector{Val: coerced, Meta: vec.Meta, Cursor: vec.Cursor}, nil
+		}
+		return Vector{Val: coerced}, nil
+	}
+	return v, nil
+}
@@ -0,0 +1,206 @@
+package schema
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand/v2"
+
+	"github.com/jig/lisp/printer"
+	. "github.com/jig/lisp/types"
+)
+
+// retries is the number of values generated for :and schemas until one
+// conforms, and for sets until they have enough distinct items
+const retries = 100
+
+// Generate returns a random value that conforms to the schema (e.g. as sample data for
+// tests). Predicates cannot be generated but as the later children of an :and schema
+// (e.g. [:and :int [:fn even?]]), filtering the values of its first child.
+func (s *Schema) Generate(ctx context.Context, r *rand.Rand) (MalType, error) {
+	g := &generator{ctx: ctx, r: r}
+	return g.generate(s)
+}
+
+type generator struct {
+	ctx context.Context
+	r   *rand.Rand
+}
+
+func (g *generator) generate(s *Schema) (MalType, error) {
+	switch s.kind {
+	case "any":
+		return g.generate(anySchema)
+	case "nil":
+		return nil, nil
+	case "string":
+		return g.word(g.intIn(g.count(s, 0, 8))), nil
+	case "int":
+		lo, hi := g.interval(s, -100, 100)
+		l, h := math.Ceil(lo), math.Floor(hi)
+		if h < l {
+			return nil, fmt.Errorf("no int conforms to schema %s", printer.Pr_str(s.form, true))
+		}
+		return g.intIn(toInt(l), toInt(h)), nil
+	case "double", "number":
+		lo, hi := g.interval(s, -100, 100)
+		f := g.r.Float64()
+		return lo*(1-f) + hi*f, nil
+	case "boolean":
+		return g.r.IntN(2) == 0, nil
+	case "keyword":
+		return NewKeyword(g.word(1 + g.r.IntN(8))), nil
+	case "symbol":
+		return Symbol{Val: g.word(1 + g.r.IntN(8))}, nil
+	case "enum":
+		return s.values[g.r.IntN(len(s.values))], nil
+	case "maybe":
+		if g.r.IntN(2) == 0 {
+			return nil, nil
+		}
+		return g.generate(s.children[0])
+	case "or":
+		return g.generate(s.children[g.r.IntN(len(s.children))])
+	case "and":
+		for range retries {
+			v, err := g.generate(s.children[0])
+			if err != nil {
+				return nil, err
+			}
+			if ok, err := s.Valid(g.ctx, v); err != nil || ok {
+				return v, err
+			}
+		}
+		return nil, fmt.Errorf("no value conforming to schema %s generated in %d attempts", printer.Pr_str(s.form, true), retries)
+	case "map":
+		hm := HashMap{Val: map[MalType]MalType{}}
+		for _, entry := range s.entries {
+			if entry.optional && g.r.IntN(2) == 0 {
+				continue
+			}
+			v, err := g.generate(entry.schema)
+			if err != nil {
+				return nil, err
+			}
+			hm.Val[entry.key] = v
+		}
+		return hm, nil
+	case "map-of":
+		hm := HashMap{Val: map[MalType]MalType{}}
+		n := g.intIn(g.count(s, 0, 3))
+		for range n * retries {
+			if len(hm.Val) == n {
+				break
+			}
+			k, err := g.generate(s.children[0])
+			if err != nil {
+				return nil, err
+			}
+			if !HashKey_Q(k) {
+				return nil, fmt.Errorf("cannot generate map keys of schema %s", printer.Pr_str(s.children[0].form, true))
+			}
+			if hm.Val[k], err = g.generate(s.children[1]); err != nil {
+				return nil, err
+			}
+		}
+		return hm, nil
+	case "vector", "sequential":
+		items := make([]MalType, g.intIn(g.count(s, 0, 3)))
+		for i := range items {
+			var err error
+			if items[i], err = g.generate(s.children[0]); err != nil {
+				return nil, err
+			}
+		}
+		return Vector{Val: items}, nil
+	case "tuple":
+		items := make([]MalType, len(s.children))
+		for i, child := range s.children {
+			var err error
+			if items[i], err = g.generate(child); err != nil {
+				return nil, err
+			}
+		}
+		return Vector{Val: items}, nil
+	case "set":
+		set := Set{Val: map[MalType]struct{}{}}
+		lo, hi := g.count(s, 0, 3)
+		n := g.intIn(lo, hi)
+		for range n * retries {
+			if len(set.Val) == n {
+				break
+			}
+			item, err := g.generate(s.children[0])
+			if err != nil {
+				return nil, err
+			}
+			if !HashKey_Q(item) {
+				return nil, fmt.Errorf("cannot generate set items of schema %s", printer.Pr_str(s.children[0].form, true))
+			}
+			set.Val[item] = struct{}{}
+		}
+		if len(set.Val) < lo {
+			return nil, fmt.Errorf("no value conforming to schema %s generated in %d attempts", printer.Pr_str(s.form, true), retries)
+		}
+		return set, nil
+	default: // re, fn
+		return nil, fmt.Errorf("cannot generate values of schema %s", printer.Pr_str(s.form, true))
+	}
+}
+
+// anySchema is the schema of the values generated for :any
+var anySchema = MustRead("[:or :nil :boolean :int :string :keyword]")
+
+// interval returns the bounds of a number: its :min and :max (Parse rejects a :min
+// greater than :max), the default ones if unset, or a range of the default width from
+// the only one set
+func (g *generator) interval(s *Schema, defMin, defMax float64) (float64, float64) {
+	lo, hi := defMin, defMax
+	switch {
+	case s.props.min != nil && s.props.max != nil:
+		lo, hi = *s.props.min, *s.props.max
+	case s.props.min != nil:
+		lo, hi = *s.props.min, *s.props.min+(defMax-defMin)
+	case s.props.max != nil:
+		lo, hi = *s.props.max-(defMax-defMin), *s.props.max
+	}
+	return lo, hi
+}
+
+// count returns the bounds of a length or count
+func (g *generator) count(s *Schema, defMin, defMax int) (int, int) {
+	lo, hi := g.interval(s, float64(defMin), float64(defMax))
+	return max(toInt(lo), 0), max(toInt(hi), 0)
+}
+
+// intIn returns a random int from l to h (l <= h), drawn on the unsigned span of the
+// range so that bounds as far apart as math.MinInt and math.MaxInt do not overflow
+func (g *generator) intIn(l, h int) int {
+	span := uint64(h) - uint64(l)
+	if span == math.MaxUint64 {
+		return int(g.r.Uint64())
+	}
+	return int(uint64(l) + g.r.Uint64N(span+1))
+}
+
+// toInt returns an integral float64 as an int, bounded to the range of int
+func toInt(f float64) int {
+	switch {
+	case f <= math.MinInt:
+		return math.MinInt
+	case f >= math.MaxInt:
+		return math.MaxInt
+	default:
+		return int(f)
+	}
+}
+
+const letters = "abcdefghijklmnopqrstuvwxyz"
+
+func (g *generator) word(n int) string {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = letters[g.r.IntN(len(letters))]
+	}
+	return string(b)
+}
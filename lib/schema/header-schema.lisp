@@ -0,0 +1,9 @@
+;; $MODULE header-schema
+
+(do
+    (defmacro defschema
+        "Defines a compiled schema: (defschema name docstring? schema)"
+        (fn [name & decl]
+            (let [doc  (if (string? (first decl)) (list (first decl)) ())
+                  form (if (string? (first decl)) (nth decl 1) (first decl))]
+                `(def ~name ~@doc (schema ~form))))))
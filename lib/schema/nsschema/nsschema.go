@@ -0,0 +1,28 @@
+package nsschema
+
+import (
+	"context"
+	"reflect"
+	"strings"
+
+	"github.com/jig/lisp"
+	"github.com/jig/lisp/lib/schema"
+	"github.com/jig/lisp/types"
+)
+
+type Here struct{}
+
+var (
+	__package_fullpath__ = strings.Split(reflect.TypeFor[Here]().PkgPath(), "/")
+	_package_            = "$" + __package_fullpath__[len(__package_fullpath__)-1]
+)
+
+func Load(env types.EnvType) error {
+	schema.Load(env)
+
+	if _, err := lisp.REPL(context.Background(), env, schema.HeaderSchema(), types.NewCursorFile(_package_)); err != nil {
+		return err
+	}
+
+	return nil
+}
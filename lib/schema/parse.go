@@ -0,0 +1,295 @@
+package schema
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/jig/lisp/printer"
+	"github.com/jig/lisp/reader"
+	. "github.com/jig/lisp/types"
+)
+
+// Schema is a compiled schema
+type Schema struct {
+	form  MalType // as given to Parse
+	kind  string  // e.g. "string", "map" or "fn"
+	props props
+
+	children []*Schema // of :maybe, :or, :and, :vector, :sequential, :set, :tuple and :map-of
+	entries  []entry   // of :map
+	values   []MalType // of :enum
+	re       *regexp.Regexp
+	pred     MalType // of :fn and predicate functions
+
+	meta MalType
+}
+
+type props struct {
+	min, max *float64
+	optional bool
+	closed   bool
+	error    string // message of the problems of the value
+}
+
+// entry is an entry of a :map schema
+type entry struct {
+	key      MalType
+	optional bool
+	schema   *Schema
+}
+
+var kinds = map[string]bool{
+	"any": true, "nil": true, "string": true, "int": true, "double": true, "number": true,
+	"boolean": true, "keyword": true, "symbol": true,
+	"enum": true, "maybe": true, "or": true, "and": true, "map": true, "map-of": true,
+	"vector": true, "sequential": true, "set": true, "tuple": true, "re": true, "fn": true,
+}
+
+// Parse compiles a schema written as Lisp data: a type keyword (e.g. :string), a vector
+// of a type keyword, optional properties and children (e.g. [:vector {:min 1} :int]),
+// a predicate function or a compiled schema
+func Parse(form MalType) (*Schema, error) {
+	switch f := form.(type) {
+	case *Schema:
+		return f, nil
+	case Keyword:
+		return parse(form, f, nil)
+	case Vector:
+		if len(f.Val) == 0 {
+			return nil, fmt.Errorf("invalid schema %s", printer.Pr_str(form, true))
+		}
+		kind, ok := f.Val[0].(Keyword)
+		if !ok {
+			return nil, fmt.Errorf("invalid schema %s: the first item is not a type keyword", printer.Pr_str(form, true))
+		}
+		return parse(form, kind, f.Val[1:])
+	default:
+		if Callable_Q(form) {
+			return &Schema{form: form, kind: "fn", pred: form}, nil
+		}
+		return nil, fmt.Errorf("invalid schema %s", printer.Pr_str(form, true))
+	}
+}
+
+// Read compiles a schema written as Lisp source (e.g. "[:map [:port :int]]"). Predicate
+// functions cannot be used, as the source is not evaluated.
+func Read(source string) (*Schema, error) {
+	form, err := reader.Read_str(source, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(form)
+}
+
+// MustRead is like Read but panics on errors (e.g. to set package level variables)
+func MustRead(source string) *Schema {
+	s, err := Read(source)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+func (s *Schema) Type() string {
+	return "schema"
+}
+
+func (s *Schema) LispPrint(pr_str func(MalType, bool) string) string {
+	return "«schema " + pr_str(s.form, true) + "»"
+}
+
+func (s *Schema) Meta() MalType {
+	return s.meta
+}
+
+func (s *Schema) WithMeta(meta MalType) MalType {
+	c := *s
+	c.meta = meta
+	return &c
+}
+
+// Form returns the schema as given to Parse
+func (s *Schema) Form() MalType {
+	return s.form
+}
+
+func parse(form MalType, kind Keyword, args []MalType) (*Schema, error) {
+	s := &Schema{form: form, kind: kind.Name()}
+	if !kinds[s.kind] {
+		return nil, fmt.Errorf("unknown schema type %s", kind)
+	}
+	invalid := func(format string, a ...any) error {
+		return fmt.Errorf("invalid schema %s: %s", printer.Pr_str(form, true), fmt.Sprintf(format, a...))
+	}
+	if len(args) > 0 {
+		if hm, ok := args[0].(HashMap); ok && s.kind != "enum" {
+			p, err := parseProps(hm)
+			if err != nil {
+				return nil, invalid("%s", err)
+			}
+			s.props = p
+			args = args[1:]
+		}
+	}
+
+	switch s.kind {
+	case "any", "nil", "string", "int", "double", "number", "boolean", "keyword", "symbol":
+		if len(args) != 0 {
+			return nil, invalid("%s takes no children", kind)
+		}
+	case "enum":
+		if len(args) == 0 {
+			return nil, invalid(":enum requires values")
+		}
+		s.values = args
+	case "maybe", "vector", "sequential", "set":
+		if len(args) != 1 {
+			return nil, invalid("%s takes one child", kind)
+		}
+	case "map-of":
+		if len(args) != 2 {
+			return nil, invalid(":map-of takes a key and a value schema")
+		}
+	case "or", "and", "tuple":
+		if len(args) == 0 {
+			return nil, invalid("%s requires children", kind)
+		}
+	case "re":
+		pattern, ok := args[0].(string)
+		if len(args) != 1 || !ok {
+			return nil, invalid(":re takes a regular expression string")
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, invalid("%s", err)
+		}
+		s.re = re
+		return s, nil
+	case "fn":
+		if len(args) != 1 || !Callable_Q(args[0]) {
+			return nil, invalid(":fn takes a predicate function")
+		}
+		s.pred = args[0]
+		return s, nil
+	case "map":
+		for _, arg := range args {
+			e, err := parseEntry(arg)
+			if err != nil {
+				return nil, invalid("%s", err)
+			}
+			s.entries = append(s.entries, e)
+		}
+		return s, nil
+	}
+
+	if s.kind != "enum" {
+		for _, arg := range args {
+			child, err := Parse(arg)
+			if err != nil {
+				return nil, err
+			}
+			s.children = append(s.children, child)
+		}
+	}
+	return s, nil
+}
+
+// parseEntry parses a [key props? schema] entry of a :map schema
+func parseEntry(form MalType) (entry, error) {
+	v, ok := form.(Vector)
+	if !ok || len(v.Val) < 2 || len(v.Val) > 3 || !HashKey_Q(v.Val[0]) {
+		return entry{}, fmt.Errorf("invalid entry %s (expected [key props? schema])", printer.Pr_str(form, true))
+	}
+	e := entry{key: v.Val[0]}
+	if len(v.Val) == 3 {
+		hm, ok := v.Val[1].(HashMap)
+		if !ok {
+			return entry{}, fmt.Errorf("invalid entry %s (expected [key props? schema])", printer.Pr_str(form, true))
+		}
+		p, err := parseProps(hm)
+		if err != nil {
+			return entry{}, err
+		}
+		e.optional = p.optional
+	}
+	s, err := Parse(v.Val[len(v.Val)-1])
+	if err != nil {
+		return entry{}, err
+	}
+	e.schema = s
+	return e, nil
+}
+
+func parseProps(hm HashMap) (props, error) {
+	var p props
+	for _, key := range SortedKeys(hm.Val) {
+		value := hm.Val[key]
+		switch KeyName(key) {
+		case "min", "max":
+			n, ok := number(value)
+			if !ok {
+				return p, fmt.Errorf("%s is not a number", printer.Pr_str(key, true))
+			}
+			if KeyName(key) == "min" {
+				p.min = &n
+			} else {
+				p.max = &n
+			}
+		case "optional":
+			p.optional = value == true
+		case "closed":
+			p.closed = value == true
+		case "error":
+			msg, ok := value.(string)
+			if !ok {
+				return p, fmt.Errorf(":error is not a string")
+			}
+			p.error = msg
+		default:
+			return p, fmt.Errorf("unknown property %s", printer.Pr_str(key, true))
+		}
+	}
+	if p.min != nil && p.max != nil && *p.min > *p.max {
+		return p, fmt.Errorf(":min %v is greater than :max %v", *p.min, *p.max)
+	}
+	return p, nil
+}
+
+// number returns a Lisp number as float64
+func number(v MalType) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case float32:
+		return float64(n), true
+	case float64:
+		return n, true
+	default:
+		return 0, false
+	}
+}
+
+// valueType returns the name of the type of a value on problems: the name of its
+// schema type if any, else as returned by type?
+func valueType(v MalType) string {
+	switch v := v.(type) {
+	case int:
+		return "int"
+	case float32, float64:
+		return "double"
+	case HashMap:
+		if v.Record == nil {
+			return "map"
+		}
+	}
+	return TypeName(v)
+}
+
+func printValues(values []MalType) string {
+	s := make([]string, len(values))
+	for i, v := range values {
+		s[i] = printer.Pr_str(v, true)
+	}
+	return strings.Join(s, " ")
+}
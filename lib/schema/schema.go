@@ -0,0 +1,145 @@
+// Package schema validates data against schemas written as Lisp data, in the style of
+// malli: a type keyword (:string, :int, :double, :number, :boolean, :keyword,
+// :symbol, :nil or :any), a vector of a type keyword, optional properties and
+// children (e.g. [:map {:closed true} [:port {:optional true} [:int {:min 1}]]]), a
+// predicate function or a compiled schema.
+//
+// Schemas are validated, reporting problems with the path to the offending value and
+// the position of the source it was read from, coerce data (e.g. decoded from JSON)
+// to their types and generate sample data. The same schemas are used from Go (see
+// Parse and Read) and from Lisp (schema, valid?, explain, validate, coerce,
+// coerce-json, generate and sample).
+package schema
+
+//go:generate go run github.com/jig/lisp/cmd/lispbind
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"math/rand/v2"
+
+	"github.com/jig/lisp/lib/call"
+	. "github.com/jig/lisp/types"
+)
+
+//go:embed header-schema.lisp
+var headerSchema string
+
+func HeaderSchema() string { return headerSchema }
+
+func Load(env EnvType) {
+	call.Call(env, schema)
+	call.CallOverrideFN(env, "valid?", valid_Q)
+	call.Call(env, explain)
+	call.Call(env, validate)
+	call.Call(env, coerce)
+	call.Call(env, coerce_json)
+	call.Call(env, generate)
+	call.Call(env, sample)
+}
+
+func schema(form MalType) (*Schema, error) {
+	return Parse(form)
+}
+
+func valid_Q(ctx context.Context, form, v MalType) (bool, error) {
+	s, err := Parse(form)
+	if err != nil {
+		return false, err
+	}
+	return s.Valid(ctx, v)
+}
+
+// explain returns the problems of v as a vector of hash maps of :path, :value,
+// :message and :pos (if known), or nil if v conforms to the schema
+func explain(ctx context.Context, form, v MalType) (MalType, error) {
+	s, err := Parse(form)
+	if err != nil {
+		return nil, err
+	}
+	problems, err := s.Explain(ctx, v)
+	if err != nil || len(problems) == 0 {
+		return nil, err
+	}
+	result := Vector{Val: make([]MalType, len(problems))}
+	for i, p := range problems {
+		hm := HashMap{Val: map[MalType]MalType{
+			NewKeyword("path"):    Vector{Val: p.Path},
+			NewKeyword("value"):   p.Value,
+			NewKeyword("message"): p.Message,
+		}}
+		if p.Position != nil {
+			hm.Val[NewKeyword("pos")] = positionString(p.Position)
+		}
+		result.Val[i] = hm
+	}
+	return result, nil
+}
+
+// validate returns v, or fails with its problems if it does not conform to the schema
+func validate(ctx context.Context, form, v MalType) (MalType, error) {
+	s, err := Parse(form)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Validate(ctx, v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func coerce(ctx context.Context, form, v MalType) (MalType, error) {
+	s, err := Parse(form)
+	if err != nil {
+		return nil, err
+	}
+	return s.Coerce(ctx, v)
+}
+
+func coerce_json(ctx context.Context, form MalType, doc string) (MalType, error) {
+	s, err := Parse(form)
+	if err != nil {
+		return nil, err
+	}
+	return s.DecodeJSON(ctx, []byte(doc))
+}
+
+type generateOptions struct {
+	call.Options
+	Seed *int `lisp:"seed"` // random if not set
+}
+
+func (opts generateOptions) rand() *rand.Rand {
+	if opts.Seed == nil {
+		return rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64()))
+	}
+	return rand.New(rand.NewPCG(uint64(*opts.Seed), 0))
+}
+
+func generate(ctx context.Context, form MalType, opts generateOptions) (MalType, error) {
+	s, err := Parse(form)
+	if err != nil {
+		return nil, err
+	}
+	return s.Generate(ctx, opts.rand())
+}
+
+// sample returns a list of n values generated from the schema
+func sample(ctx context.Context, form MalType, n int, opts generateOptions) (MalType, error) {
+	s, err := Parse(form)
+	if err != nil {
+		return nil, err
+	}
+	if n < 0 {
+		return nil, fmt.Errorf("sample count cannot be negative (found %d)", n)
+	}
+	r := opts.rand()
+	values := make([]MalType, n)
+	for i := range values {
+		if values[i], err = s.Generate(ctx, r); err != nil {
+			return nil, err
+		}
+	}
+	return List{Val: values}, nil
+}
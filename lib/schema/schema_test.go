@@ -0,0 +1,113 @@
+package schema
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"strings"
+	"testing"
+
+	"github.com/jig/lisp/printer"
+	"github.com/jig/lisp/reader"
+	"github.com/jig/lisp/types"
+)
+
+var config = MustRead(`[:map {:closed true}
+	[:name [:string {:min 1}]]
+	[:env [:enum :dev :prod]]
+	[:servers [:vector {:min 1}
+		[:map
+			[:host :string]
+			[:port [:int {:min 1 :max 65535}]]
+			[:tags {:optional true} [:set :keyword]]]]]]`)
+
+func TestValidate(t *testing.T) {
+	data, err := reader.Read_str(`{:name "api"
+ :env :test
+ :servers [{:host "a" :port 80}
+           {:host "b" :port 0}]}`, types.NewCursorFile("config.lisp"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = config.Validate(context.Background(), data)
+	var schemaErr *Error
+	if !errors.As(err, &schemaErr) {
+		t.Fatalf("expected a schema error, got %v", err)
+	}
+	expected := []string{
+		"config.lisp:1:1: [:env]: should be one of :dev :prod",
+		"config.lisp:4:12: [:servers 1 :port]: should be at least 1",
+	}
+	if len(schemaErr.Problems) != len(expected) {
+		t.Fatalf("expected %d problems, got %v", len(expected), err)
+	}
+	for i, p := range schemaErr.Problems {
+		if p.Error() != expected[i] {
+			t.Errorf("expected %q, got %q", expected[i], p.Error())
+		}
+	}
+}
+
+func TestDecodeJSON(t *testing.T) {
+	v, err := config.DecodeJSON(context.Background(), []byte(`{
+		"name": "api",
+		"env": "prod",
+		"servers": [{"host": "a", "port": 8080, "tags": ["web", "web"]}]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, err := reader.Read_str(`{:name "api" :env :prod :servers [{:host "a" :port 8080 :tags #{:web}}]}`, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !types.Equal_Q(v, expected) {
+		t.Fatalf("expected %s, got %s", printer.Pr_str(expected, true), printer.Pr_str(v, true))
+	}
+
+	_, err = config.DecodeJSON(context.Background(), []byte(`{"name": "api", "env": "prod", "servers": [{"host": "a", "port": "80"}]}`))
+	if err == nil || err.Error() != "[:servers 0 :port]: expected int, got string" {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	r := rand.New(rand.NewPCG(1, 2))
+	for range 100 {
+		v, err := config.Generate(context.Background(), r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := config.Validate(context.Background(), v); err != nil {
+			t.Fatalf("generated %s: %s", printer.Pr_str(v, true), err)
+		}
+	}
+}
+
+func TestGenerateBounds(t *testing.T) {
+	r := rand.New(rand.NewPCG(1, 2))
+	for _, form := range []string{
+		"[:int {:min -5e18 :max 5e18}]",
+		"[:int {:min -1e19 :max 1e19}]",
+		"[:int {:min 7 :max 7}]",
+		"[:double {:min -3e38 :max 3e38}]",
+		"[:string {:min 2 :max 2}]",
+	} {
+		s, err := Read(form)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for range 100 {
+			v, err := s.Generate(context.Background(), r)
+			if err != nil {
+				t.Fatalf("%s: %s", form, err)
+			}
+			if err := s.Validate(context.Background(), v); err != nil {
+				t.Fatalf("%s: generated %s: %s", form, printer.Pr_str(v, true), err)
+			}
+		}
+	}
+	if _, err := Read("[:int {:min 2 :max 1}]"); err == nil || !strings.Contains(err.Error(), ":min 2 is greater than :max 1") {
+		t.Fatalf("expected :min greater than :max error, got %v", err)
+	}
+}
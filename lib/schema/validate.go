@@ -0,0 +1,367 @@
+package schema
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jig/lisp/printer"
+	. "github.com/jig/lisp/types"
+)
+
+// Problem is a value that does not conform to its schema
+type Problem struct {
+	Path     []MalType // keys and indexes from the validated value to Value
+	Value    MalType
+	Position *Position // of the innermost collection holding Value (nil if unknown)
+	Message  string
+}
+
+func (p Problem) Error() string {
+	s := PathString(p.Path) + ": " + p.Message
+	if p.Position != nil {
+		s = positionString(p.Position) + ": " + s
+	}
+	return s
+}
+
+// Error is the error of a value with problems
+type Error struct {
+	Problems []Problem
+}
+
+func (e *Error) Error() string {
+	s := make([]string, len(e.Problems))
+	for i, p := range e.Problems {
+		s[i] = p.Error()
+	}
+	return strings.Join(s, "\n")
+}
+
+// PathString returns a path as a Lisp vector (e.g. [:servers 0 :port])
+func PathString(path []MalType) string {
+	return printer.Pr_str(Vector{Val: path}, true)
+}
+
+func positionString(pos *Position) string {
+	s := fmt.Sprintf("%d:%d", pos.BeginRow, max(pos.BeginCol-1, 1))
+	if pos.Module != nil {
+		s = *pos.Module + ":" + s
+	}
+	return s
+}
+
+// position returns the position of data if it is a collection read from source, else
+// the one of the enclosing collection
+func position(data MalType, enclosing *Position) *Position {
+	var cursor *Position
+	switch data := data.(type) {
+	case List:
+		cursor = data.Cursor
+	case Vector:
+		cursor = data.Cursor
+	case HashMap:
+		cursor = data.Cursor
+	case Set:
+		cursor = data.Cursor
+	}
+	if cursor != nil {
+		return cursor
+	}
+	return enclosing
+}
+
+// Validate returns an *Error with the problems of v, or nil if v conforms to the schema
+func (s *Schema) Validate(ctx context.Context, v MalType) error {
+	problems, err := s.Explain(ctx, v)
+	if err != nil {
+		return err
+	}
+	if len(problems) > 0 {
+		return &Error{Problems: problems}
+	}
+	return nil
+}
+
+// Valid reports whether v conforms to the schema
+func (s *Schema) Valid(ctx context.Context, v MalType) (bool, error) {
+	problems, err := s.Explain(ctx, v)
+	return len(problems) == 0, err
+}
+
+// Explain returns the problems of v (none if it conforms to the schema). Errors are
+// those of the predicates of the schema.
+func (s *Schema) Explain(ctx context.Context, v MalType) ([]Problem, error) {
+	e := &explainer{ctx: ctx}
+	if err := e.explain(s, v, nil, nil); err != nil {
+		return nil, err
+	}
+	return e.problems, nil
+}
+
+type explainer struct {
+	ctx      context.Context
+	problems []Problem
+}
+
+func (e *explainer) fail(s *Schema, v MalType, path []MalType, pos *Position, format string, a ...any) {
+	msg := s.props.error
+	if msg == "" {
+		msg = fmt.Sprintf(format, a...)
+	}
+	e.problems = append(e.problems, Problem{Path: append([]MalType{}, path...), Value: v, Position: pos, Message: msg})
+}
+
+func (e *explainer) typeError(s *Schema, v MalType, path []MalType, pos *Position, expected string) {
+	e.fail(s, v, path, pos, "expected %s, got %s", expected, valueType(v))
+}
+
+// valid reports whether v conforms to s without recording its problems
+func (e *explainer) valid(s *Schema, v MalType, path []MalType, pos *Position) (bool, error) {
+	sub := &explainer{ctx: e.ctx}
+	if err := sub.explain(s, v, path, pos); err != nil {
+		return false, err
+	}
+	return len(sub.problems) == 0, nil
+}
+
+func (e *explainer) explain(s *Schema, v MalType, path []MalType, pos *Position) error {
+	pos = position(v, pos)
+	switch s.kind {
+	case "any":
+	case "nil":
+		if v != nil {
+			e.typeError(s, v, path, pos, "nil")
+		}
+	case "string":
+		str, ok := v.(string)
+		if !ok {
+			e.typeError(s, v, path, pos, "string")
+			return nil
+		}
+		e.bounds(s, v, float64(len([]rune(str))), path, pos, "length")
+	case "int":
+		n, ok := v.(int)
+		if !ok {
+			e.typeError(s, v, path, pos, "int")
+			return nil
+		}
+		e.bounds(s, v, float64(n), path, pos, "")
+	case "double", "number":
+		switch v.(type) {
+		case float32, float64:
+		case int:
+			if s.kind == "double" {
+				e.typeError(s, v, path, pos, "double")
+				return nil
+			}
+		default:
+			e.typeError(s, v, path, pos, s.kind)
+			return nil
+		}
+		n, _ := number(v)
+		e.bounds(s, v, n, path, pos, "")
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			e.typeError(s, v, path, pos, "boolean")
+		}
+	case "keyword":
+		if _, ok := v.(Keyword); !ok {
+			e.typeError(s, v, path, pos, "keyword")
+		}
+	case "symbol":
+		if _, ok := v.(Symbol); !ok {
+			e.typeError(s, v, path, pos, "symbol")
+		}
+	case "enum":
+		for _, value := range s.values {
+			if Equal_Q(v, value) {
+				return nil
+			}
+		}
+		e.fail(s, v, path, pos, "should be one of %s", printValues(s.values))
+	case "re":
+		str, ok := v.(string)
+		if !ok {
+			e.typeError(s, v, path, pos, "string")
+			return nil
+		}
+		if !s.re.MatchString(str) {
+			e.fail(s, v, path, pos, "should match %q", s.re.String())
+		}
+	case "fn":
+		ok, err := Apply(e.ctx, s.pred, []MalType{v})
+		if err != nil {
+			return err
+		}
+		if ok == nil || ok == false {
+			e.fail(s, v, path, pos, "should satisfy %s", predName(s.pred))
+		}
+	case "maybe":
+		if v != nil {
+			return e.explain(s.children[0], v, path, pos)
+		}
+	case "and":
+		for _, child := range s.children {
+			n := len(e.problems)
+			if err := e.explain(child, v, path, pos); err != nil {
+				return err
+			}
+			if len(e.problems) > n {
+				return nil
+			}
+		}
+	case "or":
+		for _, child := range s.children {
+			ok, err := e.valid(child, v, path, pos)
+			if err != nil || ok {
+				return err
+			}
+		}
+		e.fail(s, v, path, pos, "should match one of %s", printValues(childForms(s.children)))
+	case "map":
+		return e.explainMap(s, v, path, pos)
+	case "map-of":
+		hm, ok := v.(HashMap)
+		if !ok {
+			e.typeError(s, v, path, pos, "map")
+			return nil
+		}
+		e.bounds(s, v, float64(len(hm.Val)), path, pos, "count")
+		for _, key := range SortedKeys(hm.Val) {
+			if err := e.explain(s.children[0], key, append(path, key), pos); err != nil {
+				return err
+			}
+			if err := e.explain(s.children[1], hm.Val[key], append(path, key), pos); err != nil {
+				return err
+			}
+		}
+	case "vector", "sequential", "tuple":
+		var items []MalType
+		switch seq := v.(type) {
+		case Vector:
+			items = seq.Val
+		case List, LazySeq:
+			if s.kind == "vector" {
+				e.typeError(s, v, path, pos, "vector")
+				return nil
+			}
+			items, _ = GetSlice(seq)
+		default:
+			e.typeError(s, v, path, pos, map[string]string{"vector": "vector", "sequential": "sequence", "tuple": "vector"}[s.kind])
+			return nil
+		}
+		if s.kind == "tuple" {
+			if len(items) != len(s.children) {
+				e.fail(s, v, path, pos, "expected %d items, got %d", len(s.children), len(items))
+				return nil
+			}
+			for i, item := range items {
+				if err := e.explain(s.children[i], item, append(path, i), pos); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		e.bounds(s, v, float64(len(items)), path, pos, "count")
+		for i, item := range items {
+			if err := e.explain(s.children[0], item, append(path, i), pos); err != nil {
+				return err
+			}
+		}
+	case "set":
+		set, ok := v.(Set)
+		if !ok {
+			e.typeError(s, v, path, pos, "set")
+			return nil
+		}
+		e.bounds(s, v, float64(len(set.Val)), path, pos, "count")
+		for _, item := range SortedKeys(set.Val) {
+			if err := e.explain(s.children[0], item, append(path, item), pos); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (e *explainer) explainMap(s *Schema, v MalType, path []MalType, pos *Position) error {
+	hm, ok := v.(HashMap)
+	if !ok {
+		e.typeError(s, v, path, pos, "map")
+		return nil
+	}
+	for _, entry := range s.entries {
+		value, ok := hm.Val[entry.key]
+		if !ok {
+			if !entry.optional {
+				e.problems = append(e.problems, Problem{
+					Path:     append(append([]MalType{}, path...), entry.key),
+					Position: pos,
+					Message:  "missing required key " + printer.Pr_str(entry.key, true),
+				})
+			}
+			continue
+		}
+		if err := e.explain(entry.schema, value, append(path, entry.key), pos); err != nil {
+			return err
+		}
+	}
+	if s.props.closed {
+		for _, key := range SortedKeys(hm.Val) {
+			if s.entry(key) == nil {
+				e.problems = append(e.problems, Problem{
+					Path:     append(append([]MalType{}, path...), key),
+					Value:    hm.Val[key],
+					Position: pos,
+					Message:  "unknown key " + printer.Pr_str(key, true),
+				})
+			}
+		}
+	}
+	return nil
+}
+
+// bounds checks the :min and :max properties against n (the value, or its length or
+// count as told by what)
+func (e *explainer) bounds(s *Schema, v MalType, n float64, path []MalType, pos *Position, what string) {
+	prefix := "should be"
+	if what != "" {
+		prefix = what + " should be"
+	}
+	if s.props.min != nil && n < *s.props.min {
+		e.fail(s, v, path, pos, "%s at least %s", prefix, printNumber(*s.props.min))
+	} else if s.props.max != nil && n > *s.props.max {
+		e.fail(s, v, path, pos, "%s at most %s", prefix, printNumber(*s.props.max))
+	}
+}
+
+// entry returns the entry of key of a :map schema (nil if none)
+func (s *Schema) entry(key MalType) *entry {
+	for i := range s.entries {
+		if s.entries[i].key == key {
+			return &s.entries[i]
+		}
+	}
+	return nil
+}
+
+func childForms(children []*Schema) []MalType {
+	forms := make([]MalType, len(children))
+	for i, child := range children {
+		forms[i] = child.form
+	}
+	return forms
+}
+
+// predName returns the name of a predicate registered from Go (e.g. even?)
+func predName(pred MalType) string {
+	if f, ok := pred.(Func); ok && f.Signature != nil {
+		return f.Signature.Name
+	}
+	return "the predicate"
+}
+
+func printNumber(n float64) string {
+	return fmt.Sprint(n)
+}
@@ -0,0 +1,189 @@
+package lint
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jig/lisp"
+	"github.com/jig/lisp/env"
+	. "github.com/jig/lisp/types"
+)
+
+const (
+	// ExpansionTimeout bounds the time spent expanding the macros of the code checked
+	// by Check
+	ExpansionTimeout = 2 * time.Second
+	// MaxExpansionDepth bounds the nesting of macro expansions (e.g. of a macro that
+	// expands to a call to itself)
+	MaxExpansionDepth = 1000
+)
+
+// pureFunctions are the library functions available to the macros defined by the
+// checked code: they build and inspect values but have no side effects
+var pureFunctions = []string{
+	"list", "list?", "vector", "vector?", "hash-map", "map?", "hash-set", "set", "set?",
+	"assoc", "dissoc", "get", "get-in", "contains?", "keys", "vals", "merge",
+	"cons", "concat", "conj", "vec", "nth", "first", "rest", "count", "empty?", "seq", "sequential?",
+	"take", "take-last", "drop", "drop-last", "subvec", "apply", "map",
+	"symbol", "symbol?", "keyword", "keyword?", "string?", "number?", "fn?", "macro?",
+	"nil?", "true?", "false?", "not", "=", "not=", "<", "<=", ">", ">=", "+", "-", "*", "/",
+	"str", "pr-str", "split", "meta", "with-meta", "throw", "type?", "gensym", "inc", "dec",
+}
+
+// Expander expands the macros of the code being checked without running it. The
+// macros of the environment (library code) are expanded as they are. The macros
+// defined by the checked code are evaluated on a sandbox that only holds the macros
+// and the functions without side effects of the environment, and expansions are
+// bounded by the deadline of the context and by MaxExpansionDepth.
+type Expander struct {
+	ctx     context.Context
+	sandbox EnvType
+}
+
+// NewExpander returns an expander of the macros of ns. The checked code is expanded
+// until ctx is done.
+func NewExpander(ctx context.Context, ns EnvType) *Expander {
+	sandbox := env.NewEnv()
+	allowed := map[string]bool{}
+	for _, name := range pureFunctions {
+		allowed[name] = true
+	}
+	for _, name := range ns.Symbols(nil, "") {
+		sym := Symbol{Val: string(name)}
+		v, err := ns.Get(sym)
+		if err != nil {
+			continue
+		}
+		if fn, ok := v.(MalFunc); (ok && fn.GetMacro()) || allowed[sym.Val] {
+			sandbox.Set(sym, v)
+		}
+	}
+	return &Expander{ctx: ctx, sandbox: sandbox}
+}
+
+// IsMacro reports whether sym names a macro of the environment or of the checked code
+func (x *Expander) IsMacro(sym Symbol) bool {
+	if x.sandbox.Find(sym) == nil {
+		return false
+	}
+	v, err := x.sandbox.Get(sym)
+	if err != nil {
+		return false
+	}
+	fn, ok := v.(MalFunc)
+	return ok && fn.GetMacro()
+}
+
+// Expand returns form with its macro calls expanded, in order, so the macros defined
+// by defmacro forms expand the code that follows them. Calls that cannot be expanded
+// are reported to failed and left as they are.
+func (x *Expander) Expand(form MalType, failed func(call List, err error)) MalType {
+	return x.expand(form, 0, List{}, failed)
+}
+
+// expand expands form, nested on depth expansions of the macro call outermost
+func (x *Expander) expand(form MalType, depth int, outermost List, failed func(List, error)) MalType {
+	switch f := form.(type) {
+	case List:
+		if len(f.Val) == 0 {
+			return f
+		}
+		if head, ok := f.Val[0].(Symbol); ok {
+			switch head.Val {
+			case "quote":
+				return f
+			case "quasiquote", "quasiquoteexpand":
+				return x.expandItems(f, func(item MalType) MalType { return x.expandQuasiquote(item, depth, outermost, failed) })
+			case "defmacro":
+				x.define(f, failed)
+			}
+			if x.IsMacro(head) {
+				if depth == 0 {
+					outermost = f
+				}
+				if depth >= MaxExpansionDepth {
+					failed(outermost, fmt.Errorf("more than %d nested expansions", MaxExpansionDepth))
+					return f
+				}
+				expanded, err := x.expand1(f)
+				if err != nil {
+					failed(f, err)
+					return f
+				}
+				return x.expand(expanded, depth+1, outermost, failed)
+			}
+		}
+		return x.expandItems(f, func(item MalType) MalType { return x.expand(item, depth, outermost, failed) })
+	case Vector:
+		items := make([]MalType, len(f.Val))
+		for i, item := range f.Val {
+			items[i] = x.expand(item, depth, outermost, failed)
+		}
+		return Vector{Val: items, Meta: f.Meta, Cursor: f.Cursor}
+	case HashMap:
+		items := make(map[MalType]MalType, len(f.Val))
+		for k, v := range f.Val {
+			items[k] = x.expand(v, depth, outermost, failed)
+		}
+		return HashMap{Val: items, Meta: f.Meta, Cursor: f.Cursor, Record: f.Record}
+	default:
+		return form
+	}
+}
+
+func (x *Expander) expandItems(lst List, expand func(MalType) MalType) List {
+	items := make([]MalType, len(lst.Val))
+	for i, item := range lst.Val {
+		items[i] = expand(item)
+	}
+	return List{Val: items, Meta: lst.Meta, Cursor: lst.Cursor}
+}
+
+// expandQuasiquote expands the unquoted forms of a quasiquoted form
+func (x *Expander) expandQuasiquote(form MalType, depth int, outermost List, failed func(List, error)) MalType {
+	switch f := form.(type) {
+	case List:
+		switch headName(f) {
+		case "unquote", "splice-unquote":
+			return x.expandItems(f, func(item MalType) MalType { return x.expand(item, depth, outermost, failed) })
+		}
+		return x.expandItems(f, func(item MalType) MalType { return x.expandQuasiquote(item, depth, outermost, failed) })
+	case Vector:
+		items := make([]MalType, len(f.Val))
+		for i, item := range f.Val {
+			items[i] = x.expandQuasiquote(item, depth, outermost, failed)
+		}
+		return Vector{Val: items, Meta: f.Meta, Cursor: f.Cursor}
+	default:
+		return form
+	}
+}
+
+// define evaluates a defmacro form on the sandbox, so the macro expands the code that
+// follows it
+func (x *Expander) define(lst List, failed func(List, error)) {
+	if _, err := lisp.EVAL(x.ctx, lst, x.sandbox); err != nil {
+		failed(lst, err)
+	}
+}
+
+// expand1 expands a macro call once, as macroexpand does
+func (x *Expander) expand1(call List) (MalType, error) {
+	if err := x.ctx.Err(); err != nil {
+		return nil, fmt.Errorf("timeout while expanding macros")
+	}
+	macro, err := x.sandbox.Get(call.Val[0].(Symbol))
+	if err != nil {
+		return nil, err
+	}
+	expanded, err := Apply(x.ctx, macro, call.Val[1:])
+	if err != nil {
+		return nil, err
+	}
+	if lst, ok := expanded.(List); ok && lst.Cursor == nil {
+		lst.Cursor = call.Cursor
+		expanded = lst
+	}
+	return expanded, nil
+}
@@ -0,0 +1,610 @@
+// Package lint analyzes Lisp code without running it.
+//
+// The analysis reports undefined symbols, unused let bindings, shadowing of library
+// functions, calls with the wrong number of arguments, unreachable catch clauses and
+// uses of recur (not supported by this dialect). Macros are expanded before the analysis,
+// both the ones loaded on the environment and the ones defined by the checked code. The
+// latter are the only code evaluated, on a sandbox with no side effects and under a
+// deadline (see Expander).
+package lint
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+
+	"github.com/jig/lisp/env"
+	"github.com/jig/lisp/lisperror"
+	"github.com/jig/lisp/reader"
+	. "github.com/jig/lisp/types"
+)
+
+// Rules reported on [Finding].Rule
+const (
+	RuleSyntax          = "syntax"
+	RuleUndefinedSymbol = "undefined-symbol"
+	RuleUnusedBinding   = "unused-binding"
+	RuleShadowing       = "shadowing"
+	RuleArity           = "arity"
+	RuleUnreachable     = "unreachable-catch"
+	RuleRecur           = "misplaced-recur"
+	RuleMacroExpansion  = "macro-expansion"
+)
+
+// Severities reported on [Finding].Severity
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// Finding is a problem found on the checked code. Column, EndLine and EndColumn locate
+// the form on the source code when it is known (as on [CheckFile] and [CheckSource]);
+// otherwise Column is the one of the position of the form and the end is not set.
+// Columns start at 1, count characters and EndColumn is exclusive.
+type Finding struct {
+	Position  *Position `json:"-"`
+	File      string    `json:"file"`
+	Line      int       `json:"line"`
+	Column    int       `json:"column"`
+	EndLine   int       `json:"end_line,omitempty"`
+	EndColumn int       `json:"end_column,omitempty"`
+	Rule      string    `json:"rule"`
+	Severity  string    `json:"severity"`
+	Message   string    `json:"message"`
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("%s: %s: %s (%s)", f.Position, f.Severity, f.Message, f.Rule)
+}
+
+// CheckFile checks a Lisp source file. The file is read the same way load-file reads it.
+func CheckFile(fileName string, ns EnvType) ([]Finding, error) {
+	return CheckFileWith(fileName, ns, Check)
+}
+
+// CheckSource reads and checks Lisp source code. Read errors are reported as findings.
+func CheckSource(source string, cursor *Position, ns EnvType) ([]Finding, error) {
+	return CheckSourceWith(source, cursor, ns, Check)
+}
+
+// CheckFileWith reads a Lisp source file as CheckFile does, and checks it with check
+// (e.g. the one of package typecheck). Findings are located on the file.
+func CheckFileWith(fileName string, ns EnvType, check func(MalType, EnvType) []Finding) ([]Finding, error) {
+	source, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	ast, err := reader.Read_module(fileName, string(source), placeholders(string(source)), ns)
+	return checkWith(ast, err, string(source), NewCursorFile(fileName), ns, check)
+}
+
+// CheckSourceWith reads Lisp source code as CheckSource does, and checks it with check.
+// Placeholders (see lisp.READWithPreamble) are read as the values on the preamble of
+// source, or as nil when these are not defined or cannot be read without running
+// «constructors». Findings are located on source.
+func CheckSourceWith(source string, cursor *Position, ns EnvType, check func(MalType, EnvType) []Finding) ([]Finding, error) {
+	ast, err := reader.Read_str(source, cursor, placeholders(source), ns)
+	return checkWith(ast, err, source, cursor, ns, check)
+}
+
+// checkWith reports the read error err as a finding, or checks ast
+func checkWith(ast MalType, err error, source string, cursor *Position, ns EnvType, check func(MalType, EnvType) []Finding) ([]Finding, error) {
+	if err != nil {
+		if lispErr, ok := err.(lisperror.LispError); ok {
+			return []Finding{newFinding(lispErr.Position(), RuleSyntax, SeverityError, fmt.Sprint(lispErr.ErrorValue()))}, nil
+		}
+		return nil, err
+	}
+	findings := check(ast, ns)
+	locate(findings, source, cursor)
+	return findings, nil
+}
+
+// locate sets the columns and the ends of the findings to those of the forms of source
+// they point to
+func locate(findings []Finding, source string, cursor *Position) {
+	cst, err := reader.ReadCST(source, cursor)
+	if err != nil {
+		return
+	}
+	for i := range findings {
+		pos := findings[i].Position
+		if pos == nil {
+			continue
+		}
+		if n := cst.FormAt(pos.BeginRow, pos.BeginCol); n != nil {
+			findings[i].Column = n.Span.StartCol
+			findings[i].EndLine = n.Span.EndRow
+			findings[i].EndColumn = n.Span.EndCol
+		}
+	}
+}
+
+var preambleRE = regexp.MustCompile(`^;; (\$[\-\d\w]+)\s(.+)`)
+
+// placeholders reads the preamble of source. Values are read with no environment, so
+// «constructors» are not run.
+func placeholders(source string) *HashMap {
+	placeholderMap := &HashMap{Val: map[MalType]MalType{}}
+	cst, err := reader.ReadCST(source, nil)
+	if err != nil {
+		// reported when the source is read
+		return placeholderMap
+	}
+	for _, child := range cst.Children {
+		if child.Kind != reader.NodePreamble {
+			continue
+		}
+		kv := preambleRE.FindStringSubmatch(child.Text)
+		if kv == nil || kv[1] == "$MODULE" {
+			continue
+		}
+		placeholderMap.Val[kv[1]], _ = reader.Read_str(kv[2], nil, nil)
+	}
+	return placeholderMap
+}
+
+// Check analyzes an AST. ns is the environment the code is expected to run on,
+// it is used to resolve library symbols and macros but it is not modified. Macros
+// are expanded for at most ExpansionTimeout.
+func Check(ast MalType, ns EnvType) []Finding {
+	ctx, cancel := context.WithTimeout(context.Background(), ExpansionTimeout)
+	defer cancel()
+	return CheckContext(ctx, ast, ns)
+}
+
+// CheckContext is like Check, expanding macros until ctx is done
+func CheckContext(ctx context.Context, ast MalType, ns EnvType) []Finding {
+	if ns == nil {
+		ns = env.NewEnv()
+	}
+	c := &checker{
+		ns:       ns,
+		expander: NewExpander(ctx, ns),
+		globals:  map[string]MalType{},
+	}
+	ast = c.expander.Expand(ast, func(call List, err error) {
+		if headName(call) == "defmacro" {
+			c.report(call, RuleMacroExpansion, SeverityError, "macro cannot be defined: %s", err)
+			return
+		}
+		c.report(call, RuleMacroExpansion, SeverityError, "macro '%s' expansion failed: %s", headName(call), err)
+	})
+	c.collectDefinitions(ast)
+	c.walk(ast, nil, false)
+	sort.SliceStable(c.findings, func(i, j int) bool {
+		if c.findings[i].Line != c.findings[j].Line {
+			return c.findings[i].Line < c.findings[j].Line
+		}
+		return c.findings[i].Position.BeginCol < c.findings[j].Position.BeginCol
+	})
+	return c.findings
+}
+
+func newFinding(pos *Position, rule, severity, message string) Finding {
+	f := Finding{
+		Position: pos,
+		Rule:     rule,
+		Severity: severity,
+		Message:  message,
+	}
+	if pos != nil {
+		f.File = pos.StringModule()
+		f.Line = pos.BeginRow
+		f.Column = pos.BeginCol
+	}
+	return f
+}
+
+type checker struct {
+	ns       EnvType            // environment the code runs on
+	expander *Expander          // of the macros of ns and of the ones defined by the checked code
+	globals  map[string]MalType // symbols defined by the checked code (with their parameters if functions)
+	findings []Finding
+	module   *string
+}
+
+type binding struct {
+	sym  Symbol
+	used bool
+}
+
+type scope struct {
+	names map[string]*binding
+	outer *scope
+}
+
+func newScope(outer *scope) *scope {
+	return &scope{names: map[string]*binding{}, outer: outer}
+}
+
+func (s *scope) lookup(name string) *binding {
+	for ; s != nil; s = s.outer {
+		if b, ok := s.names[name]; ok {
+			return b
+		}
+	}
+	return nil
+}
+
+func (c *checker) report(form MalType, rule, severity, format string, args ...any) {
+	pos := lisperror.GetPosition(form)
+	if pos == nil || pos.Module == nil {
+		// generated by a macro: nothing to point to on the checked code
+		return
+	}
+	if c.module == nil {
+		c.module = pos.Module
+	}
+	if *pos.Module != *c.module {
+		// points to the macro definition, not to the checked code
+		return
+	}
+	c.findings = append(c.findings, newFinding(pos, rule, severity, fmt.Sprintf(format, args...)))
+}
+
+// collectDefinitions records the symbols defined anywhere on the checked code, as
+// functions might be referenced before they are defined.
+func (c *checker) collectDefinitions(form MalType) {
+	lst, ok := form.(List)
+	if !ok {
+		if seq, err := GetSlice(form); err == nil {
+			for _, item := range seq {
+				c.collectDefinitions(item)
+			}
+		}
+		return
+	}
+	if len(lst.Val) >= 2 {
+		if name, ok := lst.Val[1].(Symbol); ok {
+			switch headName(lst) {
+			case "def", "defmacro":
+				// (def name docstring? attr-map? value)
+				c.globals[name.Val] = nil
+				if value := lst.Val[len(lst.Val)-1]; len(lst.Val) >= 3 && headName(value) == "fn" && len(value.(List).Val) >= 2 {
+					c.globals[name.Val] = value.(List).Val[1]
+				}
+			}
+		}
+	}
+	if c.module == nil && lst.Cursor != nil && lst.Cursor.Module != nil {
+		c.module = lst.Cursor.Module
+	}
+	for _, item := range lst.Val {
+		c.collectDefinitions(item)
+	}
+}
+
+func headName(form MalType) string {
+	if lst, ok := form.(List); ok && len(lst.Val) > 0 {
+		if sym, ok := lst.Val[0].(Symbol); ok {
+			return sym.Val
+		}
+	}
+	return ""
+}
+
+func (c *checker) walk(form MalType, sc *scope, tail bool) {
+	switch form := form.(type) {
+	case Symbol:
+		c.resolve(form, sc)
+	case List:
+		c.walkList(form, sc, tail)
+	case Vector:
+		for _, item := range form.Val {
+			c.walk(item, sc, false)
+		}
+	case HashMap:
+		for _, item := range form.Val {
+			c.walk(item, sc, false)
+		}
+	}
+}
+
+func (c *checker) walkBody(body []MalType, sc *scope, tail bool) {
+	for i, form := range body {
+		c.walk(form, sc, tail && i == len(body)-1)
+	}
+}
+
+func (c *checker) resolve(sym Symbol, sc *scope) {
+	if b := sc.lookup(sym.Val); b != nil {
+		b.used = true
+		return
+	}
+	if _, ok := c.globals[sym.Val]; ok {
+		return
+	}
+	if c.ns.Find(sym) != nil {
+		return
+	}
+	c.report(sym, RuleUndefinedSymbol, SeverityError, "symbol '%s' not found", sym.Val)
+}
+
+// bind adds a symbol to the scope, reporting if it shadows a library function
+func (c *checker) bind(form MalType, sc *scope) {
+	sym, ok := form.(Symbol)
+	if !ok || sym.Val == "&" {
+		return
+	}
+	c.checkShadowing(sym)
+	sc.names[sym.Val] = &binding{sym: sym}
+}
+
+func (c *checker) checkShadowing(sym Symbol) {
+	if c.ns.Find(sym) == nil {
+		return
+	}
+	v, err := c.ns.Get(sym)
+	if err != nil {
+		return
+	}
+	switch v.(type) {
+	case Func, MalFunc:
+		c.report(sym, RuleShadowing, SeverityWarning, "'%s' shadows a library function", sym.Val)
+	}
+}
+
+func (c *checker) reportUnused(sc *scope) {
+	for _, b := range sc.names {
+		if !b.used && len(b.sym.Val) > 0 && b.sym.Val[0] != '_' {
+			c.report(b.sym, RuleUnusedBinding, SeverityWarning, "binding '%s' is never used", b.sym.Val)
+		}
+	}
+}
+
+func (c *checker) walkList(lst List, sc *scope, tail bool) {
+	if len(lst.Val) == 0 {
+		return
+	}
+	head, isSymbol := lst.Val[0].(Symbol)
+	if isSymbol && sc.lookup(head.Val) == nil {
+		switch head.Val {
+		case "def":
+			if len(lst.Val) < 3 || len(lst.Val) > 5 {
+				// (def name docstring? attr-map? value)
+				c.report(lst, RuleSyntax, SeverityError, "def requires a symbol and a value")
+			}
+			if len(lst.Val) >= 2 {
+				if sym, ok := lst.Val[1].(Symbol); ok {
+					c.checkShadowing(sym)
+				} else {
+					c.report(lst, RuleSyntax, SeverityError, "cannot use '%T' as identifier", lst.Val[1])
+				}
+			}
+			c.walkBody(lst.Val[2:], sc, false)
+			return
+		case "let":
+			c.walkLet(lst, sc, tail)
+			return
+		case "fn":
+			c.walkFn(lst, sc)
+			return
+		case "quote", "macroexpand":
+			return
+		case ".":
+			// the method or field name is not evaluated
+			c.walkBody(lst.Val[min(2, len(lst.Val)):], sc, false)
+			return
+		case "quasiquote", "quasiquoteexpand":
+			if len(lst.Val) >= 2 {
+				c.walkQuasiquote(lst.Val[1], sc)
+			}
+			return
+		case "defmacro":
+			c.walkBody(lst.Val[2:], sc, false)
+			return
+		case "try":
+			c.walkTry(lst, sc, tail)
+			return
+		case "do":
+			c.walkBody(lst.Val[1:], sc, tail)
+			return
+		case "if":
+			if len(lst.Val) < 3 || len(lst.Val) > 4 {
+				c.report(lst, RuleSyntax, SeverityError, "if requires a condition and one or two branches")
+			}
+			if len(lst.Val) >= 2 {
+				c.walk(lst.Val[1], sc, false)
+			}
+			for _, branch := range lst.Val[min(2, len(lst.Val)):] {
+				c.walk(branch, sc, tail)
+			}
+			return
+		case "recur":
+			if tail {
+				c.report(lst, RuleRecur, SeverityError, "recur is not supported, call the function by its name (tail calls are optimised)")
+			} else {
+				c.report(lst, RuleRecur, SeverityError, "recur is not supported and it is not on tail position")
+			}
+			c.walkBody(lst.Val[1:], sc, false)
+			return
+		}
+		if c.expander.IsMacro(head) {
+			// its expansion failed (and it was reported)
+			return
+		}
+		c.checkArity(head, len(lst.Val)-1)
+	}
+	for _, item := range lst.Val {
+		c.walk(item, sc, false)
+	}
+}
+
+func (c *checker) checkArity(head Symbol, n int) {
+	var params MalType
+	if p, ok := c.globals[head.Val]; ok {
+		params = p
+	} else if c.ns.Find(head) != nil {
+		v, err := c.ns.Get(head)
+		if err != nil {
+			return
+		}
+		switch fn := v.(type) {
+		case Func:
+			if fn.Signature != nil && !fn.Signature.AcceptsArgs(n) {
+				c.report(head, RuleArity, SeverityError, "'%s' called with %d arguments (%s)", head.Val, n, expectedArgs(fn.Signature.MinArgs, fn.Signature.MaxArgs))
+			}
+			return
+		case MalFunc:
+			params = fn.Params
+		}
+	}
+	if params == nil {
+		return
+	}
+	minArgs, maxArgs, ok := paramsArity(params)
+	if ok && (n < minArgs || (maxArgs != UnlimitedArgs && n > maxArgs)) {
+		c.report(head, RuleArity, SeverityError, "'%s' called with %d arguments (%s)", head.Val, n, expectedArgs(minArgs, maxArgs))
+	}
+}
+
+func paramsArity(params MalType) (int, int, bool) {
+	seq, err := GetSlice(params)
+	if err != nil {
+		return 0, 0, false
+	}
+	for i, param := range seq {
+		if sym, ok := param.(Symbol); ok && sym.Val == "&" {
+			return i, UnlimitedArgs, true
+		}
+	}
+	return len(seq), len(seq), true
+}
+
+func expectedArgs(minArgs, maxArgs int) string {
+	switch {
+	case maxArgs == UnlimitedArgs:
+		return fmt.Sprintf("expected at least %d", minArgs)
+	case minArgs == maxArgs:
+		return fmt.Sprintf("expected %d", minArgs)
+	default:
+		return fmt.Sprintf("expected %d…%d", minArgs, maxArgs)
+	}
+}
+
+func (c *checker) walkLet(lst List, sc *scope, tail bool) {
+	if len(lst.Val) < 2 {
+		c.report(lst, RuleSyntax, SeverityError, "let requires a binding vector")
+		return
+	}
+	bindings, err := GetSlice(lst.Val[1])
+	if err != nil {
+		c.report(lst, RuleSyntax, SeverityError, "let requires a binding vector")
+		return
+	}
+	if len(bindings)%2 != 0 {
+		c.report(lst.Val[1], RuleSyntax, SeverityError, "let: odd elements on binding vector")
+	}
+	letScope := newScope(sc)
+	for i := 0; i+1 < len(bindings); i += 2 {
+		c.walk(bindings[i+1], letScope, false)
+		if _, ok := bindings[i].(Symbol); !ok {
+			c.report(lst.Val[1], RuleSyntax, SeverityError, "non-symbol bind value")
+			continue
+		}
+		c.bind(bindings[i], letScope)
+	}
+	c.walkBody(lst.Val[2:], letScope, tail)
+	c.reportUnused(letScope)
+}
+
+func (c *checker) walkFn(lst List, sc *scope) {
+	if len(lst.Val) < 2 {
+		c.report(lst, RuleSyntax, SeverityError, "fn requires a parameter vector")
+		return
+	}
+	fnScope := newScope(sc)
+	params, err := GetSlice(lst.Val[1])
+	if err != nil {
+		c.report(lst, RuleSyntax, SeverityError, "fn requires a parameter vector")
+		return
+	}
+	for _, param := range params {
+		c.bind(param, fnScope)
+	}
+	c.walkBody(lst.Val[2:], fnScope, true)
+}
+
+func (c *checker) walkQuasiquote(form MalType, sc *scope) {
+	switch form := form.(type) {
+	case List:
+		switch headName(form) {
+		case "unquote", "splice-unquote":
+			c.walkBody(form.Val[1:], sc, false)
+			return
+		}
+		for _, item := range form.Val {
+			c.walkQuasiquote(item, sc)
+		}
+	case Vector:
+		for _, item := range form.Val {
+			c.walkQuasiquote(item, sc)
+		}
+	}
+}
+
+func (c *checker) walkTry(lst List, sc *scope, tail bool) {
+	body := lst.Val[1:]
+	var catchClause, finallyClause List
+	if len(body) > 0 && headName(body[len(body)-1]) == "finally" {
+		finallyClause = body[len(body)-1].(List)
+		body = body[:len(body)-1]
+	}
+	if len(body) > 0 && headName(body[len(body)-1]) == "catch" {
+		catchClause = body[len(body)-1].(List)
+		body = body[:len(body)-1]
+	}
+	tryBody := make([]MalType, 0, len(body))
+	for _, form := range body {
+		switch headName(form) {
+		case "catch":
+			c.report(form, RuleUnreachable, SeverityError, "catch clause must be the last form of try (or precede finally)")
+		case "finally":
+			c.report(form, RuleUnreachable, SeverityError, "finally clause must be the last form of try")
+		default:
+			tryBody = append(tryBody, form)
+		}
+	}
+	body = tryBody
+	if catchClause.Val != nil && !mayFail(body) {
+		c.report(catchClause, RuleUnreachable, SeverityWarning, "catch clause is unreachable: try body cannot fail")
+	}
+
+	c.walkBody(body, sc, false)
+	if catchClause.Val != nil {
+		if sym, ok := catchClause.Val[min(1, len(catchClause.Val)-1)].(Symbol); !ok || len(catchClause.Val) < 3 {
+			c.report(catchClause, RuleSyntax, SeverityError, "catch requires a symbol and a body")
+		} else {
+			catchScope := newScope(sc)
+			c.bind(sym, catchScope)
+			catchScope.names[sym.Val].used = true
+			c.walkBody(catchClause.Val[2:], catchScope, tail)
+		}
+	}
+	if finallyClause.Val != nil {
+		c.walkBody(finallyClause.Val[1:], sc, false)
+	}
+}
+
+// mayFail returns false if all forms are literals (that cannot fail when evaluated)
+func mayFail(forms []MalType) bool {
+	for _, form := range forms {
+		switch form := form.(type) {
+		case nil, bool, int, float32, float64, string:
+		case List:
+			if len(form.Val) > 0 && headName(form) != "quote" {
+				return true
+			}
+		case Vector:
+			if mayFail(form.Val) {
+				return true
+			}
+		default:
+			return true
+		}
+	}
+	return false
+}
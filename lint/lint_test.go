@@ -0,0 +1,188 @@
+package lint
+
+import (
+	"context"
+	"encoding/json"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jig/lisp"
+	"github.com/jig/lisp/env"
+	"github.com/jig/lisp/lib/core/nscore"
+	"github.com/jig/lisp/types"
+)
+
+func newEnv(t *testing.T) types.EnvType {
+	ns := env.NewEnv()
+	if err := nscore.Load(ns); err != nil {
+		t.Fatal(err)
+	}
+	return ns
+}
+
+func check(t *testing.T, source string) []Finding {
+	findings, err := CheckSource(source, types.NewCursorFile(t.Name()), newEnv(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return findings
+}
+
+func rules(findings []Finding) string {
+	result := []string{}
+	for _, f := range findings {
+		result = append(result, f.Rule)
+	}
+	return strings.Join(result, " ")
+}
+
+func TestCheck(t *testing.T) {
+	for _, testCase := range []struct {
+		name   string
+		source string
+		rules  string
+	}{
+		{"correct", `(do (defn f [a & more] (cons a more)) (f 1 2 3))`, ""},
+		{"undefined", `(do (def a 1) (+ a b))`, RuleUndefinedSymbol},
+		{"forward-reference", `(do (defn f [] (g)) (defn g [] 1))`, ""},
+		{"unused", `(let [a 1 _b 2 c 3] c)`, RuleUnusedBinding},
+		{"shadowing-let", `(let [count 1] count)`, RuleShadowing},
+		{"shadowing-def", `(def first 1)`, RuleShadowing},
+		{"arity-go", `(nth [1 2])`, RuleArity},
+		{"arity-go-variadic", `(str 1 2 3)`, ""},
+		{"arity-lisp", `(do (defn f [a b] a) (f 1))`, RuleArity},
+		{"arity-lisp-variadic", `(do (defn f [a & b] a) (f))`, RuleArity},
+		{"arity-typed", `(do (defn f ^int [^int a ^{:schema [:vector :int]} b] (+ a (count b))) (f 1))`, RuleArity},
+		{"def-doc", `(defn f "doc" {:added 1} [a] a)`, ""},
+		{"typed", `(do (defn f ^int [^int a ^{:schema [:vector :int]} b] (+ a (count b))) (f 1 [2]))`, ""},
+		{"misplaced-catch", `(try (catch e 1) 2)`, RuleUnreachable},
+		{"unreachable-catch", `(try 1 "a" (catch e e))`, RuleUnreachable},
+		{"reachable-catch", `(try (throw 1) (catch e e) (finally nil))`, ""},
+		{"recur", `(defn f [n] (if (> n 0) (recur (- n 1)) n))`, RuleRecur},
+		{"macro", `(cond (= 1 1) x)`, RuleUndefinedSymbol},
+		{"local-macro", `(do (defmacro unless (fn [c a b] (list 'if c b a))) (unless true y 1))`, RuleUndefinedSymbol},
+		{"quasiquote", "(do (def a 1) `(b ~a ~@c))", RuleUndefinedSymbol},
+		{"quasiquote-macro", "`(b ~(cond true c))", RuleUndefinedSymbol},
+		{"macro-side-effect", `(do (defmacro m (fn [] (println "expanded") 1)) (m))`, RuleMacroExpansion},
+		{"self-expanding-macro", `(do (defmacro m (fn [] '(m))) (m))`, RuleMacroExpansion},
+		{"syntax", `(let [a 1)`, RuleSyntax},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			findings := check(t, testCase.source)
+			if got := rules(findings); got != testCase.rules {
+				t.Fatalf("expected %q got %q (%v)", testCase.rules, got, findings)
+			}
+		})
+	}
+}
+
+func TestFindingPosition(t *testing.T) {
+	findings := check(t, "(do\n  (def a 1)\n  (+ a b))")
+	if len(findings) != 1 {
+		t.Fatalf("unexpected findings %v", findings)
+	}
+	f := findings[0]
+	if f.File != t.Name() || f.Line != 3 || f.Severity != SeverityError {
+		t.Fatalf("unexpected finding %+v", f)
+	}
+	b, err := json.Marshal(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"file":"TestFindingPosition","line":3,"column":8,"end_line":3,"end_column":9,"rule":"undefined-symbol","severity":"error","message":"symbol 'b' not found"}`
+	if string(b) != expected {
+		t.Fatalf("unexpected JSON %s", b)
+	}
+}
+
+func TestFindingSpan(t *testing.T) {
+	// forms spanning several lines, on the first line of a file
+	dir := t.TempDir()
+	fileName := filepath.Join(dir, "f.lisp")
+	if err := os.WriteFile(fileName, []byte("(def\n  a)"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	findings, err := CheckFile(fileName, newEnv(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(findings) != 1 {
+		t.Fatalf("unexpected findings %v", findings)
+	}
+	if f := findings[0]; f.Line != 1 || f.Column != 1 || f.EndLine != 2 || f.EndColumn != 5 {
+		t.Fatalf("unexpected span %d:%d…%d:%d", f.Line, f.Column, f.EndLine, f.EndColumn)
+	}
+}
+
+func TestExpansionDeadline(t *testing.T) {
+	ast, err := lisp.READ(`(do (defmacro spin (fn [] (let [f (fn [g] (g g))] (f f)))) (spin))`, types.NewCursorFile(t.Name()), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	findings := CheckContext(ctx, ast, newEnv(t))
+	if rules(findings) != RuleMacroExpansion || !strings.Contains(findings[0].Message, "timeout") {
+		t.Fatalf("unexpected findings %v", findings)
+	}
+}
+
+func TestCheckDoesNotModifyEnv(t *testing.T) {
+	ns := newEnv(t)
+	if _, err := CheckSource(`(do (defmacro m (fn [] 1)) (def x (m)))`, types.NewCursorFile(t.Name()), ns); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"m", "x"} {
+		if ns.Find(types.Symbol{Val: name}) != nil {
+			t.Fatalf("%s must not be defined on the environment", name)
+		}
+	}
+}
+
+// repoFiles returns the Lisp files of the repository
+func repoFiles(t *testing.T) []string {
+	var files []string
+	err := filepath.WalkDir("..", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && strings.HasPrefix(d.Name(), ".") && path != ".." {
+			return filepath.SkipDir
+		}
+		if !d.IsDir() && strings.HasSuffix(path, ".lisp") {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+func TestCheckRepoFiles(t *testing.T) {
+	// must not panic on any of them (e.g. on the placeholders of their preambles)
+	for _, fileName := range repoFiles(t) {
+		if _, err := CheckFile(fileName, newEnv(t)); err != nil {
+			t.Errorf("%s: %s", fileName, err)
+		}
+	}
+}
+
+func TestCheckPlaceholders(t *testing.T) {
+	findings, err := CheckFile("../helloworld_placeholder.lisp", newEnv(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(findings) != 0 {
+		t.Fatalf("unexpected findings %v", findings)
+	}
+	// placeholders not on the preamble are read as nil
+	if findings := check(t, ";; $A 1\n(str $A $B)"); len(findings) != 0 {
+		t.Fatalf("unexpected findings %v", findings)
+	}
+}
@@ -16,9 +16,10 @@ type StackFrame struct {
 
 // Errors/Exceptions
 type LispError struct {
-	err    MalType
-	cursor *Position
-	Stack  []*StackFrame // Stack trace of positions where error propagated
+	err        MalType
+	cursor     *Position
+	Stack      []*StackFrame // Stack trace of positions where error propagated
+	Suppressed []error       // Errors raised while cleaning up (e.g. on finally) after this one
 }
 
 func (e LispError) Unwrap() error {
@@ -80,6 +81,10 @@ func (e LispError) Error() string {
 		}
 	}
 
+	for _, suppressed := range e.Suppressed {
+		msg += fmt.Sprintf("\n  suppressed: %s", suppressed)
+	}
+
 	return msg
 }
 
@@ -166,10 +171,19 @@ func (e LispError) AddStackFrame(pos *Position, functionName string) LispError {
 	return e
 }
 
+// AddSuppressed attaches an error raised while cleaning up after e (e.g. on a finally clause).
+// e remains the primary error.
+func (e LispError) AddSuppressed(err error) LispError {
+	if err != nil {
+		e.Suppressed = append(e.Suppressed[:len(e.Suppressed):len(e.Suppressed)], err)
+	}
+	return e
+}
+
 func (e LispError) MarshalHashMap() (MalType, error) {
 	hm := HashMap{
-		Val: map[string]MalType{
-			"ʞtype": fmt.Sprintf("%T", e),
+		Val: map[MalType]MalType{
+			NewKeyword("type"): fmt.Sprintf("%T", e),
 		},
 	}
 
@@ -179,13 +193,13 @@ func (e LispError) MarshalHashMap() (MalType, error) {
 		if err != nil {
 			return nil, err
 		}
-		hm.Val["ʞerr"] = pHm
+		hm.Val[NewKeyword("err")] = pHm
 	default:
-		hm.Val["ʞerr"] = printer.Pr_str(ee, true)
+		hm.Val[NewKeyword("err")] = printer.Pr_str(ee, true)
 	}
 
 	if e.cursor != nil {
-		hm.Val["ʞpos"] = e.cursor.String()
+		hm.Val[NewKeyword("pos")] = e.cursor.String()
 	}
 
 	return hm, nil
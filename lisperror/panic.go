@@ -0,0 +1,77 @@
+package lisperror
+
+import (
+	"fmt"
+	"io"
+	"runtime/debug"
+
+	. "github.com/jig/lisp/types"
+)
+
+// CaptureGoStack enables capturing the Go stack trace of the panics recovered
+// while executing Go functions (see [NewPanicError]). It is disabled by default
+// as capturing the stack is expensive.
+var CaptureGoStack = false
+
+// PanicError is the error produced when a panic is recovered on a Go function
+// called from Lisp (e.g. on a library function wrapped by the call package).
+type PanicError struct {
+	Function   string    // Lisp facing name of the function (e.g. github.com/jig/lisp/lib/core[nth])
+	GoFunction string    // Go name of the function as reported by the runtime
+	Value      any       // value passed to panic
+	GoStack    []byte    // Go stack trace at the panic (nil unless CaptureGoStack is enabled)
+	Position   *Position // Lisp position of the call (nil if unknown)
+}
+
+// NewPanicError creates a PanicError from a recovered value. It must be called from the
+// deferred function that recovered the panic to capture the right stack.
+func NewPanicError(function, goFunction string, value any) *PanicError {
+	e := &PanicError{
+		Function:   function,
+		GoFunction: goFunction,
+		Value:      value,
+	}
+	if CaptureGoStack {
+		e.GoStack = debug.Stack()
+	}
+	return e
+}
+
+func (e *PanicError) Error() string {
+	if e.Function == "" {
+		return fmt.Sprint(e.Value)
+	}
+	return fmt.Sprintf("%s: %v", e.Function, e.Value)
+}
+
+func (e *PanicError) Unwrap() error {
+	if err, ok := e.Value.(error); ok {
+		return err
+	}
+	return nil
+}
+
+func (e *PanicError) GetPosition() *Position {
+	return e.Position
+}
+
+// Format prints the Go function, the Lisp position and the Go stack with the %+v verb.
+func (e *PanicError) Format(s fmt.State, verb rune) {
+	switch {
+	case verb == 'v' && s.Flag('+'):
+		_, _ = io.WriteString(s, e.Error())
+		if e.GoFunction != "" {
+			_, _ = fmt.Fprintf(s, "\ngo function: %s", e.GoFunction)
+		}
+		if e.Position != nil {
+			_, _ = fmt.Fprintf(s, "\nlisp position: %s", e.Position)
+		}
+		if e.GoStack != nil {
+			_, _ = fmt.Fprintf(s, "\ngo stack:\n%s", e.GoStack)
+		}
+	case verb == 'q':
+		_, _ = fmt.Fprintf(s, "%q", e.Error())
+	default:
+		_, _ = io.WriteString(s, e.Error())
+	}
+}
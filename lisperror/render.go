@@ -0,0 +1,262 @@
+package lisperror
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/jig/lisp/printer"
+	. "github.com/jig/lisp/types"
+)
+
+// Sources gives access to the source code of a module. Module names are the ones
+// found on [Position].Module.
+type Sources interface {
+	Source(module string) (string, bool)
+}
+
+// SourceMap is a [Sources] implementation for in memory sources (e.g. REPL input
+// or code embedded on Go packages).
+type SourceMap map[string]string
+
+func (sm SourceMap) Source(module string) (string, bool) {
+	source, ok := sm[module]
+	return source, ok
+}
+
+// FileSources is a [Sources] implementation that reads modules from the file system,
+// taking the module name as the file path (as load-file does). Modules not found on
+// the file system are looked up on Fallback (if not nil).
+type FileSources struct {
+	Fallback Sources
+}
+
+func (fs FileSources) Source(module string) (string, bool) {
+	if fs.Fallback != nil {
+		if source, ok := fs.Fallback.Source(module); ok {
+			return source, true
+		}
+	}
+	b, err := os.ReadFile(module)
+	if err != nil {
+		return "", false
+	}
+	return string(b), true
+}
+
+// RenderOptions configures [Render].
+type RenderOptions struct {
+	Sources Sources // where to look for the source code of the modules (might be nil)
+	Color   bool    // use ANSI colors (for terminals, not for logs)
+	Context int     // lines of source code shown before the offending line
+	Debug   bool    // show Go details (e.g. the Go stack of recovered panics)
+}
+
+const (
+	colorReset  = "\033[0m"
+	colorError  = "\033[1;31m"
+	colorCaret  = "\033[31m"
+	colorGutter = "\033[34m"
+	colorNote   = "\033[36m"
+)
+
+// Render formats an error for humans: the message, an excerpt of the offending
+// source code with the form underlined, macro expansion notes and the Lisp stack.
+//
+// Errors that are not a [LispError] are rendered with their message only.
+func Render(err error, opts RenderOptions) string {
+	r := renderer{opts: opts}
+	var lispErr LispError
+	if !errors.As(err, &lispErr) {
+		r.header(err.Error())
+		return r.String()
+	}
+
+	r.header(errorMessage(lispErr))
+	r.excerpt(lispErr.cursor)
+
+	for _, frame := range lispErr.Stack {
+		if macro, ok := strings.CutPrefix(frame.FunctionName, "macro:"); ok {
+			r.note(fmt.Sprintf("in expansion of macro `%s` at %s", macro, frame.Position))
+		}
+	}
+	for _, suppressed := range lispErr.Suppressed {
+		r.note(fmt.Sprintf("while cleaning up: %s", suppressed))
+	}
+
+	if len(lispErr.Stack) > 0 {
+		r.line(r.paint(colorNote, "stack:"))
+		for _, frame := range lispErr.Stack {
+			if frame.Position == nil {
+				continue
+			}
+			if frame.FunctionName != "" {
+				r.line(fmt.Sprintf("  at %s (%s)", frame.FunctionName, frame.Position))
+			} else {
+				r.line(fmt.Sprintf("  at %s", frame.Position))
+			}
+		}
+	}
+
+	var panicErr *PanicError
+	if r.opts.Debug && errors.As(err, &panicErr) {
+		if panicErr.GoFunction != "" {
+			r.line(r.paint(colorNote, "go function:") + " " + panicErr.GoFunction)
+		}
+		if panicErr.GoStack != nil {
+			r.line(r.paint(colorNote, "go stack:"))
+			r.line(strings.TrimSuffix(string(panicErr.GoStack), "\n"))
+		}
+	}
+	return r.String()
+}
+
+func errorMessage(e LispError) string {
+	switch value := e.err.(type) {
+	case error:
+		return value.Error()
+	case string:
+		return value
+	default:
+		return printer.Pr_str(value, true)
+	}
+}
+
+type renderer struct {
+	opts RenderOptions
+	sb   strings.Builder
+}
+
+func (r *renderer) String() string {
+	return strings.TrimSuffix(r.sb.String(), "\n")
+}
+
+func (r *renderer) paint(color, s string) string {
+	if !r.opts.Color {
+		return s
+	}
+	return color + s + colorReset
+}
+
+func (r *renderer) line(s string) {
+	r.sb.WriteString(s)
+	r.sb.WriteByte('\n')
+}
+
+func (r *renderer) header(msg string) {
+	r.line(r.paint(colorError, "error:") + " " + msg)
+}
+
+func (r *renderer) note(msg string) {
+	r.line(r.paint(colorNote, "note:") + " " + msg)
+}
+
+func (r *renderer) excerpt(pos *Position) {
+	if pos == nil {
+		return
+	}
+	r.line(" --> " + pos.String())
+	if r.opts.Sources == nil || pos.Module == nil || pos.BeginRow <= 0 {
+		return
+	}
+	source, ok := r.opts.Sources.Source(*pos.Module)
+	if !ok {
+		return
+	}
+	lines := strings.Split(source, "\n")
+	if pos.BeginRow > len(lines) {
+		return
+	}
+
+	from := max(pos.BeginRow-r.opts.Context, 1)
+	width := len(strconv.Itoa(pos.BeginRow))
+	gutter := func(n string) string {
+		return r.paint(colorGutter, fmt.Sprintf("%*s |", width, n))
+	}
+	r.line(gutter(""))
+	for row := from; row <= pos.BeginRow; row++ {
+		r.line(gutter(strconv.Itoa(row)) + " " + strings.TrimRight(lines[row-1], "\r"))
+	}
+	line := []rune(strings.TrimRight(lines[pos.BeginRow-1], "\r"))
+	begin, end := formSpan(line, pos.BeginCol)
+	if begin >= 0 {
+		padding := strings.Map(func(c rune) rune {
+			if c == '\t' {
+				return '\t'
+			}
+			return ' '
+		}, string(line[:begin]))
+		r.line(gutter("") + " " + padding + r.paint(colorCaret, "^"+strings.Repeat("~", end-begin)))
+	}
+	if pos.Row > pos.BeginRow {
+		r.line(gutter("") + " " + r.paint(colorNote, fmt.Sprintf("(form continues until line %d)", pos.Row)))
+	}
+}
+
+// formSpan returns the first and last rune index of the form starting on line.
+// Reader positions point to the column right after the first token of the form,
+// so the token is looked up backwards and, if it opens a collection, the closing
+// bracket is looked up forwards (up to the end of the line).
+func formSpan(line []rune, colAfterToken int) (int, int) {
+	last := colAfterToken - 2
+	if last < 0 || last >= len(line) {
+		return -1, -1
+	}
+	switch line[last] {
+	case '(', '[', '{', '«':
+		begin := last
+		if line[last] == '{' && last > 0 && line[last-1] == '#' {
+			begin--
+		}
+		return begin, closingBracket(line, last)
+	case '"':
+		begin := last - 1
+		for begin > 0 && (line[begin] != '"' || line[begin-1] == '\\') {
+			begin--
+		}
+		return max(begin, 0), last
+	}
+	begin := last
+	for begin > 0 && !delimiter(line[begin-1]) {
+		begin--
+	}
+	return begin, last
+}
+
+func closingBracket(line []rune, open int) int {
+	depth := 0
+	inString := false
+	for i := open; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case inString:
+			if c == '\\' {
+				i++
+			} else if c == '"' {
+				inString = false
+			}
+		case c == '"':
+			inString = true
+		case c == ';':
+			return len(line) - 1
+		case c == '(' || c == '[' || c == '{' || c == '«':
+			depth++
+		case c == ')' || c == ']' || c == '}' || c == '»':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return len([]rune(strings.TrimRight(string(line), " \t"))) - 1
+}
+
+func delimiter(c rune) bool {
+	switch c {
+	case ' ', '\t', '(', ')', '[', ']', '{', '}', '«', '»', '\'', '`', '~', '@', '^', ',':
+		return true
+	}
+	return false
+}
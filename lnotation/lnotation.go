@@ -35,24 +35,49 @@ func V[T any](args []T) Vector {
 	return Vector{Val: result}
 }
 
-// M converts Go map to lisp HashMap
+// HM converts Go map to lisp HashMap keyed by strings (see HMK for keywords)
 func HM(arg map[string]interface{}) HashMap {
-	result := map[string]MalType{}
+	return hashMap(arg, func(k string) MalType { return k })
+}
+
+// HMK converts Go map to lisp HashMap keyed by the keywords of its keys (named without
+// the colon, as K), also on nested maps
+func HMK(arg map[string]interface{}) HashMap {
+	return hashMap(arg, func(k string) MalType { return NewKeyword(k) })
+}
+
+func hashMap(arg map[string]interface{}, key func(string) MalType) HashMap {
+	result := map[MalType]MalType{}
 	for k, v := range arg {
 		switch v := v.(type) {
 		case map[string]interface{}:
-			result[k] = HM(v)
+			result[key(k)] = hashMap(v, key)
 		default:
-			result[k] = v
+			result[key(k)] = v
 		}
 	}
 	return HashMap{Val: result}
 }
 
+// SET returns a lisp set of its arguments (see SETK for keywords)
 func SET(args []string) Set {
-	result := map[string]struct{}{}
+	result := map[MalType]struct{}{}
 	for _, k := range args {
 		result[k] = struct{}{}
 	}
 	return Set{Val: result}
 }
+
+// SETK returns a lisp set of the keywords of its arguments (named without the colon, as K)
+func SETK(args []string) Set {
+	result := map[MalType]struct{}{}
+	for _, k := range args {
+		result[NewKeyword(k)] = struct{}{}
+	}
+	return Set{Val: result}
+}
+
+// K returns the keyword of name (without the colon)
+func K(name string) Keyword {
+	return NewKeyword(name)
+}
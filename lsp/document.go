@@ -0,0 +1,363 @@
+package lsp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/jig/lisp/lint"
+	"github.com/jig/lisp/lisperror"
+	"github.com/jig/lisp/reader"
+	. "github.com/jig/lisp/types"
+)
+
+// document is an open text document and the result of its analysis
+type document struct {
+	uri          string
+	path         string
+	text         string
+	lines        []string
+	cst          *reader.Node // nil if the document cannot be read
+	placeholders *HashMap     // values of the preamble placeholders
+	ast          List         // (do forms...) of the forms that could be read
+	defs         []definition // definitions found on the document
+	diagnostics  []diagnostic // of the reader (see lint for the ones of the linter)
+}
+
+// definition is a def, defn or defmacro form of a document
+type definition struct {
+	name      string
+	form      string // def, defn, defmacro...
+	detail    string // parameters of functions and macros
+	kind      int    // symbol kind
+	rng       textRange
+	nameRange textRange
+}
+
+// definitionForms are the forms that define symbols, with the symbol kind they define
+var definitionForms = map[string]int{
+	"def":      symbolVariable,
+	"defn":     symbolFunction,
+	"defmacro": symbolFunction,
+}
+
+func newDocument(uri, text string, ns EnvType) *document {
+	d := &document{
+		uri:          uri,
+		path:         uriToPath(uri),
+		text:         text,
+		lines:        strings.Split(text, "\n"),
+		placeholders: &HashMap{Val: map[MalType]MalType{}},
+		ast:          List{Val: []MalType{Symbol{Val: "do"}}},
+		diagnostics:  []diagnostic{},
+	}
+	d.read(constructors{ns})
+	return d
+}
+
+// read reads the document and collects its definitions. «constructors» are read
+// from ns, which does not run them (see constructors).
+func (d *document) read(ns EnvType) {
+	cst, err := reader.ReadCST(d.text, NewCursorFile(d.path))
+	if err != nil {
+		d.addError(err, nil, "syntax")
+		return
+	}
+	d.cst = cst
+	placeholders, err := cst.Placeholders(ns)
+	if err != nil {
+		d.addError(err, nil, "preamble")
+		return
+	}
+	d.placeholders = placeholders
+	d.checkPlaceholders(cst)
+
+	for _, form := range cst.Forms() {
+		ast, err := form.AST(placeholders, ns)
+		if err != nil {
+			// e.g. unknown «constructors»
+			d.addError(err, form, "syntax")
+			continue
+		}
+		d.ast.Val = append(d.ast.Val, ast)
+	}
+	d.collectDefinitions(cst.Forms())
+}
+
+// lint returns the diagnostics of the document: the ones of the reader and the
+// findings of the linter, which expands macros until ctx is done
+func (d *document) lint(ctx context.Context, ns EnvType) []diagnostic {
+	diagnostics := append([]diagnostic{}, d.diagnostics...)
+	for _, finding := range lint.CheckContext(ctx, d.ast, ns) {
+		severity := severityWarning
+		if finding.Severity == lint.SeverityError {
+			severity = severityError
+		}
+		diagnostics = append(diagnostics, diagnostic{
+			Range:    d.positionRange(finding.Position),
+			Severity: severity,
+			Code:     finding.Rule,
+			Source:   "lisp",
+			Message:  finding.Message,
+		})
+	}
+	return diagnostics
+}
+
+// constructors is the environment documents are read from: «name args...» values
+// are read as constructorCall values if ns has a new-name function, which is not
+// called, as documents are analyzed while they are edited
+type constructors struct {
+	EnvType
+}
+
+func (c constructors) Get(key Symbol) (MalType, error) {
+	constructor, err := c.EnvType.Get(key)
+	if err != nil || !strings.HasPrefix(key.Val, "new-") || !Callable_Q(constructor) {
+		return constructor, err
+	}
+	name := strings.TrimPrefix(key.Val, "new-")
+	return Func{Fn: func(_ context.Context, args []MalType) (MalType, error) {
+		return constructorCall{name: name, args: args}, nil
+	}}, nil
+}
+
+func (c constructors) GetNT(key Symbol) (MalType, error) {
+	return c.Get(key)
+}
+
+// constructorCall is a «name args...» value that has not been constructed
+type constructorCall struct {
+	name string
+	args []MalType
+}
+
+func (c constructorCall) LispPrint(pr_str func(MalType, bool) string) string {
+	var sb strings.Builder
+	sb.WriteString("«" + c.name)
+	for _, arg := range c.args {
+		sb.WriteString(" " + pr_str(arg, true))
+	}
+	sb.WriteString("»")
+	return sb.String()
+}
+
+// addError adds a diagnostic for err, located on its position or on form if it has none
+func (d *document) addError(err error, form *reader.Node, code string) {
+	rng := textRange{}
+	var lispErr lisperror.LispError
+	pos := lisperror.GetPosition(err)
+	if errors.As(err, &lispErr) {
+		pos = lispErr.Position()
+	}
+	switch {
+	case pos != nil && pos.BeginRow > 0:
+		rng = d.positionRange(pos)
+	case form != nil:
+		rng = d.spanRange(form.Span)
+	}
+	message := err.Error()
+	if errors.As(err, &lispErr) {
+		message = fmt.Sprint(lispErr.ErrorValue())
+	}
+	d.diagnostics = append(d.diagnostics, diagnostic{
+		Range:    rng,
+		Severity: severityError,
+		Code:     code,
+		Source:   "lisp",
+		Message:  message,
+	})
+}
+
+// checkPlaceholders reports placeholders not defined on the preamble
+func (d *document) checkPlaceholders(n *reader.Node) {
+	if n.Kind == reader.NodeAtom && strings.HasPrefix(n.Text, "$") {
+		if _, ok := d.placeholders.Val[n.Text]; !ok {
+			d.diagnostics = append(d.diagnostics, diagnostic{
+				Range:    d.spanRange(n.Span),
+				Severity: severityWarning,
+				Code:     "placeholder",
+				Source:   "lisp",
+				Message:  fmt.Sprintf("placeholder %s is not defined on the preamble (it will be read as nil)", n.Text),
+			})
+		}
+	}
+	for _, child := range n.Children {
+		d.checkPlaceholders(child)
+	}
+}
+
+func (d *document) collectDefinitions(forms []*reader.Node) {
+	for _, form := range forms {
+		if form.Kind != reader.NodeList {
+			continue
+		}
+		items := form.Forms()
+		if len(items) < 2 || items[0].Kind != reader.NodeAtom {
+			continue
+		}
+		if items[0].Text == "do" {
+			d.collectDefinitions(items[1:])
+			continue
+		}
+		kind, ok := definitionForms[items[0].Text]
+		if !ok || items[1].Kind != reader.NodeAtom {
+			continue
+		}
+		def := definition{
+			name:      items[1].Text,
+			form:      items[0].Text,
+			kind:      kind,
+			rng:       d.spanRange(form.Span),
+			nameRange: d.spanRange(items[1].Span),
+		}
+		switch {
+		case len(items) > 2 && items[2].Kind == reader.NodeVector:
+			def.detail = items[2].String()
+		case len(items) > 2 && items[2].Kind == reader.NodeList:
+			// (def f (fn [params] ...))
+			value := items[2].Forms()
+			if len(value) > 1 && value[0].Kind == reader.NodeAtom && value[0].Text == "fn" {
+				def.kind = symbolFunction
+				def.detail = value[1].String()
+			}
+		}
+		d.defs = append(d.defs, def)
+	}
+}
+
+func (d *document) definition(name string) *definition {
+	for i := range d.defs {
+		if d.defs[i].name == name {
+			return &d.defs[i]
+		}
+	}
+	return nil
+}
+
+// positionRange returns the range of the form a reader position points to. Reader positions
+// point to the column right after the first token of the form.
+func (d *document) positionRange(pos *Position) textRange {
+	if pos == nil || pos.BeginRow <= 0 {
+		return textRange{}
+	}
+	if d.cst != nil {
+		if n := d.cst.FormAt(pos.BeginRow, pos.BeginCol); n != nil {
+			return d.spanRange(n.Span)
+		}
+	}
+	line := pos.BeginRow - 1
+	return textRange{
+		Start: d.position(line, pos.BeginCol-2),
+		End:   d.position(line, pos.BeginCol-1),
+	}
+}
+
+func (d *document) spanRange(span reader.Span) textRange {
+	return textRange{
+		Start: d.position(span.StartRow-1, span.StartCol-1),
+		End:   d.position(span.EndRow-1, span.EndCol-1),
+	}
+}
+
+// position returns the LSP position of a line and a column (both starting at 0). The reader
+// counts columns in characters, LSP counts them in UTF-16 code units.
+func (d *document) position(line, col int) position {
+	col = max(col, 0)
+	if line < 0 || line >= len(d.lines) {
+		return position{Line: line, Character: col}
+	}
+	character := 0
+	for i, r := range []rune(d.lines[line]) {
+		if i == col {
+			break
+		}
+		character += utf16.RuneLen(r)
+	}
+	return position{Line: line, Character: character + max(col-utf8.RuneCountInString(d.lines[line]), 0)}
+}
+
+// column returns the column in characters of an LSP position on line
+func column(line []rune, character int) int {
+	units := 0
+	for i, r := range line {
+		if units >= character {
+			return i
+		}
+		units += utf16.RuneLen(r)
+	}
+	return len(line)
+}
+
+// delimiters end symbols when looking for the word under the cursor
+const delimiters = " \t\r\n()[]{}«»'`~@^\",;"
+
+// wordAt returns the symbol at (or right before) a position, its range and the character
+// preceding it (e.g. '«' for constructors or '(' for function calls)
+func (d *document) wordAt(pos position) (string, textRange, rune) {
+	if pos.Line < 0 || pos.Line >= len(d.lines) {
+		return "", textRange{}, 0
+	}
+	line := []rune(d.lines[pos.Line])
+	end := column(line, pos.Character)
+	begin := end
+	for begin > 0 && !strings.ContainsRune(delimiters, line[begin-1]) {
+		begin--
+	}
+	for end < len(line) && !strings.ContainsRune(delimiters, line[end]) {
+		end++
+	}
+	var before rune
+	if begin > 0 {
+		before = line[begin-1]
+	}
+	return string(line[begin:end]), textRange{
+		Start: d.position(pos.Line, begin),
+		End:   d.position(pos.Line, end),
+	}, before
+}
+
+// prefixAt returns the part of the symbol before the cursor (for completion)
+func (d *document) prefixAt(pos position) (string, rune) {
+	if pos.Line < 0 || pos.Line >= len(d.lines) {
+		return "", 0
+	}
+	line := []rune(d.lines[pos.Line])
+	end := column(line, pos.Character)
+	begin := end
+	for begin > 0 && !strings.ContainsRune(delimiters, line[begin-1]) {
+		begin--
+	}
+	var before rune
+	if begin > 0 {
+		before = line[begin-1]
+	}
+	return string(line[begin:end]), before
+}
+
+// keywords returns the keywords used on the document
+func (d *document) keywords() []string {
+	set := map[string]bool{}
+	var walk func(n *reader.Node)
+	walk = func(n *reader.Node) {
+		if n.Kind == reader.NodeAtom && strings.HasPrefix(n.Text, ":") {
+			set[n.Text] = true
+		}
+		for _, child := range n.Children {
+			walk(child)
+		}
+	}
+	if d.cst != nil {
+		walk(d.cst)
+	}
+	keywords := make([]string, 0, len(set))
+	for k := range set {
+		keywords = append(keywords, k)
+	}
+	sort.Strings(keywords)
+	return keywords
+}
@@ -0,0 +1,522 @@
+// Package lsp implements a Language Server Protocol server for Lisp files.
+//
+// The server speaks JSON-RPC over a stream (stdio for editors) and provides:
+//   - diagnostics from the reader (including unknown «constructors» and preamble
+//     errors) and from the linter;
+//   - completion of the symbols of the environment, of the document definitions,
+//     of «constructors» and of preamble placeholders;
+//   - hover with the signature of Go functions (as registered on _PACKAGES_) and of
+//     Lisp functions and macros;
+//   - go to definition of the symbols defined on the document or loaded from files;
+//   - document symbols (def, defn and defmacro forms).
+//
+// Documents are synchronized in full on every change. They are read on every change,
+// but they are linted once they have not changed for AnalysisDelay, and for at most
+// AnalysisTimeout. «constructors» of the documents are never run.
+package lsp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jig/lisp/printer"
+	. "github.com/jig/lisp/types"
+)
+
+const (
+	// AnalysisDelay is the time a document must be left unchanged to be linted
+	AnalysisDelay = 300 * time.Millisecond
+	// AnalysisTimeout bounds the time spent linting a document (expanding its macros)
+	AnalysisTimeout = 2 * time.Second
+)
+
+// Server is a Language Server Protocol server
+type Server struct {
+	ns       EnvType
+	mu       sync.Mutex // guards docs, analyses and writes to w
+	docs     map[string]*document
+	analyses map[string]*analysis // pending or running, by document URI
+	w        io.Writer
+	shutdown bool
+}
+
+// analysis is the linting of a version of a document
+type analysis struct {
+	timer  *time.Timer
+	cancel context.CancelFunc
+}
+
+// NewServer creates a server for Lisp code that runs on ns (the environment with the
+// libraries the code is expected to use). ns is not modified.
+func NewServer(ns EnvType) *Server {
+	return &Server{
+		ns:       ns,
+		docs:     map[string]*document{},
+		analyses: map[string]*analysis{},
+	}
+}
+
+// errExit is returned by the exit notification handler to stop serving
+var errExit = errors.New("exit")
+
+// Serve reads requests from r and writes responses and notifications to w until the
+// client sends the exit notification or r is closed.
+func (s *Server) Serve(r io.Reader, w io.Writer) error {
+	s.w = w
+	defer s.cancelAnalyses()
+	br := bufio.NewReader(r)
+	for {
+		msg, err := readMessage(br)
+		if err == io.EOF {
+			return nil
+		}
+		var rpcErr *responseError
+		if errors.As(err, &rpcErr) {
+			if err := s.write(errorResponse{JSONRPC: "2.0", Error: rpcErr}); err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		s.mu.Lock()
+		result, err := s.handle(msg)
+		s.mu.Unlock()
+		if err == errExit {
+			return nil
+		}
+		if msg.ID == nil {
+			// notifications have no response
+			continue
+		}
+		if err != nil {
+			if !errors.As(err, &rpcErr) {
+				rpcErr = &responseError{Code: codeInternalError, Message: err.Error()}
+			}
+			err = s.write(errorResponse{JSONRPC: "2.0", ID: msg.ID, Error: rpcErr})
+		} else {
+			err = s.write(response{JSONRPC: "2.0", ID: msg.ID, Result: result})
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Server) write(msg any) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return writeMessage(s.w, msg)
+}
+
+// notify writes a notification (s.mu must be held)
+func (s *Server) notify(method string, params any) error {
+	return writeMessage(s.w, notification{JSONRPC: "2.0", Method: method, Params: params})
+}
+
+func (s *Server) handle(msg *message) (any, error) {
+	switch msg.Method {
+	case "initialize":
+		return map[string]any{
+			"capabilities": map[string]any{
+				"textDocumentSync": 1, // full
+				"completionProvider": map[string]any{
+					"triggerCharacters": []string{"(", "«", "$", ":"},
+				},
+				"hoverProvider":          true,
+				"definitionProvider":     true,
+				"documentSymbolProvider": true,
+			},
+			"serverInfo": map[string]any{"name": "lisp"},
+		}, nil
+	case "initialized", "$/cancelRequest", "$/setTrace", "workspace/didChangeConfiguration":
+		return nil, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	case "exit":
+		return nil, errExit
+	case "textDocument/didOpen":
+		var params didOpenParams
+		if err := unmarshalParams(msg, &params); err != nil {
+			return nil, err
+		}
+		return nil, s.open(params.TextDocument.URI, params.TextDocument.Text, 0)
+	case "textDocument/didChange":
+		var params didChangeParams
+		if err := unmarshalParams(msg, &params); err != nil {
+			return nil, err
+		}
+		if len(params.ContentChanges) == 0 {
+			return nil, nil
+		}
+		return nil, s.open(params.TextDocument.URI, params.ContentChanges[len(params.ContentChanges)-1].Text, AnalysisDelay)
+	case "textDocument/didSave":
+		return nil, nil
+	case "textDocument/didClose":
+		var params didCloseParams
+		if err := unmarshalParams(msg, &params); err != nil {
+			return nil, err
+		}
+		delete(s.docs, params.TextDocument.URI)
+		s.cancelAnalysis(params.TextDocument.URI)
+		return nil, s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{URI: params.TextDocument.URI, Diagnostics: []diagnostic{}})
+	case "textDocument/completion":
+		var params textDocumentPositionParams
+		if err := unmarshalParams(msg, &params); err != nil {
+			return nil, err
+		}
+		return s.completion(params)
+	case "textDocument/hover":
+		var params textDocumentPositionParams
+		if err := unmarshalParams(msg, &params); err != nil {
+			return nil, err
+		}
+		return s.hover(params)
+	case "textDocument/definition":
+		var params textDocumentPositionParams
+		if err := unmarshalParams(msg, &params); err != nil {
+			return nil, err
+		}
+		return s.definition(params)
+	case "textDocument/documentSymbol":
+		var params documentSymbolParams
+		if err := unmarshalParams(msg, &params); err != nil {
+			return nil, err
+		}
+		return s.documentSymbols(params)
+	default:
+		if msg.ID == nil {
+			// unknown notifications are ignored
+			return nil, nil
+		}
+		return nil, &responseError{Code: codeMethodNotFound, Message: "method not found: " + msg.Method}
+	}
+}
+
+func unmarshalParams(msg *message, params any) error {
+	if err := json.Unmarshal(msg.Params, params); err != nil {
+		return &responseError{Code: codeInvalidParams, Message: err.Error()}
+	}
+	return nil
+}
+
+// open reads a new version of a document and lints it after delay (s.mu must be
+// held), canceling the analysis of the previous version
+func (s *Server) open(uri, text string, delay time.Duration) error {
+	doc := newDocument(uri, text, s.ns)
+	s.docs[uri] = doc
+	s.cancelAnalysis(uri)
+	if delay == 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), AnalysisTimeout)
+		defer cancel()
+		return s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{URI: uri, Diagnostics: doc.lint(ctx, s.ns)})
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), delay+AnalysisTimeout)
+	a := &analysis{cancel: cancel}
+	a.timer = time.AfterFunc(delay, func() {
+		defer cancel()
+		diagnostics := doc.lint(ctx, s.ns)
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.docs[uri] != doc || ctx.Err() == context.Canceled {
+			// changed (or closed) while it was linted
+			return
+		}
+		delete(s.analyses, uri)
+		_ = s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{URI: uri, Diagnostics: diagnostics})
+	})
+	s.analyses[uri] = a
+	return nil
+}
+
+// cancelAnalysis stops the analysis of a document, pending or running (s.mu must be held)
+func (s *Server) cancelAnalysis(uri string) {
+	if a, ok := s.analyses[uri]; ok {
+		a.timer.Stop()
+		a.cancel()
+		delete(s.analyses, uri)
+	}
+}
+
+func (s *Server) cancelAnalyses() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for uri := range s.analyses {
+		s.cancelAnalysis(uri)
+	}
+}
+
+func (s *Server) document(uri string) (*document, error) {
+	doc, ok := s.docs[uri]
+	if !ok {
+		return nil, &responseError{Code: codeInvalidParams, Message: "document not open: " + uri}
+	}
+	return doc, nil
+}
+
+// specialForms are the forms handled by EVAL (they are not on the environment)
+var specialForms = []string{
+	"def", "defmacro", "do", "fn", "if", "let", "macroexpand", "quasiquote",
+	"quasiquoteexpand", "quote", "try", "catch", "finally",
+}
+
+func (s *Server) completion(params textDocumentPositionParams) ([]completionItem, error) {
+	doc, err := s.document(params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	prefix, before := doc.prefixAt(params.Position)
+	items := []completionItem{}
+	seen := map[string]bool{}
+	add := func(label string, kind int, detail string) {
+		if seen[label] || !strings.HasPrefix(label, prefix) {
+			return
+		}
+		seen[label] = true
+		items = append(items, completionItem{Label: label, Kind: kind, Detail: detail})
+	}
+
+	switch {
+	case before == '«':
+		// «name args...» calls the constructor new-name
+		for _, suffix := range s.ns.Symbols(nil, "new-"+prefix) {
+			name := prefix + string(suffix)
+			add(name, completionFunction, s.detail("new-"+name))
+		}
+	case strings.HasPrefix(prefix, "$"):
+		for name, value := range doc.placeholders.Val {
+			add(name.(string), completionConstant, printer.Pr_str(value, true))
+		}
+	case strings.HasPrefix(prefix, ":"):
+		for _, keyword := range doc.keywords() {
+			if keyword != prefix {
+				add(keyword, completionKeyword, "")
+			}
+		}
+	default:
+		for _, def := range doc.defs {
+			kind := completionVariable
+			if def.kind == symbolFunction {
+				kind = completionFunction
+			}
+			add(def.name, kind, def.detail)
+		}
+		for _, name := range specialForms {
+			add(name, completionKeyword, "special form")
+		}
+		for _, suffix := range s.ns.Symbols(nil, prefix) {
+			name := prefix + string(suffix)
+			if strings.HasPrefix(name, "_") {
+				// internal symbols (e.g. _PACKAGES_)
+				continue
+			}
+			kind := completionVariable
+			value, err := s.ns.Get(Symbol{Val: name})
+			if err == nil && (Q[Func](value) || Q[MalFunc](value)) {
+				kind = completionFunction
+			}
+			add(name, kind, s.detail(name))
+		}
+	}
+	sort.SliceStable(items, func(i, j int) bool { return items[i].Label < items[j].Label })
+	return items, nil
+}
+
+// detail returns the signature of a symbol of the environment (empty if unknown)
+func (s *Server) detail(name string) string {
+	value, err := s.ns.Get(Symbol{Val: name})
+	if err != nil {
+		return ""
+	}
+	switch value := value.(type) {
+	case Func:
+		if value.Signature != nil {
+			return lispSignature(value.Signature)
+		}
+	case MalFunc:
+		return fmt.Sprintf("(%s %s)", name, strings.Trim(printer.Pr_str(value.Params, true), "()"))
+	}
+	return ""
+}
+
+func (s *Server) hover(params textDocumentPositionParams) (*hover, error) {
+	doc, err := s.document(params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	word, rng, before := doc.wordAt(params.Position)
+	if word == "" {
+		return nil, nil
+	}
+	var text string
+	switch {
+	case strings.HasPrefix(word, "$"):
+		value, ok := doc.placeholders.Val[word]
+		if !ok {
+			return nil, nil
+		}
+		text = fmt.Sprintf("placeholder `%s` = `%s`", word, printer.Pr_str(value, true))
+	case before == '«':
+		text = s.describe("new-" + word)
+	default:
+		if def := doc.definition(word); def != nil {
+			text = fmt.Sprintf("```lisp\n(%s %s %s)\n```\ndefined on this document", def.form, def.name, def.detail)
+		} else {
+			text = s.describe(word)
+		}
+	}
+	if text == "" {
+		return nil, nil
+	}
+	return &hover{Contents: markupContent{Kind: "markdown", Value: text}, Range: &rng}, nil
+}
+
+// describe returns the markdown description of a symbol of the environment
+func (s *Server) describe(name string) string {
+	value, err := s.ns.Get(Symbol{Val: name})
+	if err != nil {
+		return ""
+	}
+	if doc := Doc(value); doc != "" {
+		return s.describeValue(name, value) + "\n\n" + doc
+	}
+	return s.describeValue(name, value)
+}
+
+// describeValue returns the markdown signature of a symbol of the environment
+func (s *Server) describeValue(name string, value MalType) string {
+	switch value := value.(type) {
+	case Func:
+		if value.Signature == nil {
+			return fmt.Sprintf("```lisp\n(%s ...)\n```", name)
+		}
+		sig := value.Signature
+		return fmt.Sprintf("```lisp\n%s\n```\nGo: `%s` (package `%s`)\n\n```go\n%s\n```",
+			lispSignature(sig), sig.GoName, s.packageOf(name, sig.Package), sig.GoType())
+	case MalFunc:
+		kind := "function"
+		if value.IsMacro {
+			kind = "macro"
+		}
+		return fmt.Sprintf("```lisp\n%s\n```\nLisp %s", s.detail(name), kind)
+	default:
+		return fmt.Sprintf("```lisp\n%s\n```", printer.Pr_str(value, true))
+	}
+}
+
+// packageOf looks up the package of a function on _PACKAGES_
+func (s *Server) packageOf(name, fallback string) string {
+	packages, err := s.ns.Get(Symbol{Val: "_PACKAGES_"})
+	if err != nil {
+		return fallback
+	}
+	hm, ok := packages.(HashMap)
+	if !ok {
+		return fallback
+	}
+	if set, ok := hm.Val[fallback].(Set); ok {
+		if _, ok := set.Val[name]; ok {
+			return fallback
+		}
+	}
+	for pkg, set := range hm.Val {
+		if set, ok := set.(Set); ok {
+			if _, ok := set.Val[name]; ok {
+				return pkg.(string)
+			}
+		}
+	}
+	return fallback
+}
+
+// lispSignature formats the Go parameter types as a Lisp call, e.g. (nth seq int)
+func lispSignature(sig *Signature) string {
+	args := []string{sig.Name}
+	for i, in := range sig.In {
+		if sig.Variadic && i == len(sig.In)-1 {
+			args = append(args, "&", typeName(in.Elem())+"...")
+			continue
+		}
+		args = append(args, typeName(in))
+	}
+	return "(" + strings.Join(args, " ") + ")"
+}
+
+func typeName(t reflect.Type) string {
+	if t.Kind() == reflect.Interface && t.NumMethod() == 0 {
+		return "any"
+	}
+	return t.String()
+}
+
+func (s *Server) definition(params textDocumentPositionParams) ([]location, error) {
+	doc, err := s.document(params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	word, _, before := doc.wordAt(params.Position)
+	if word == "" {
+		return []location{}, nil
+	}
+	if before == '«' {
+		word = "new-" + word
+	}
+	if def := doc.definition(word); def != nil {
+		return []location{{URI: doc.uri, Range: def.nameRange}}, nil
+	}
+
+	// symbols loaded from files keep the position of their definition
+	value, err := s.ns.Get(Symbol{Val: word})
+	if err != nil {
+		return []location{}, nil
+	}
+	var pos *Position
+	switch value := value.(type) {
+	case MalFunc:
+		pos = value.Cursor
+	case Func:
+		pos = value.Cursor
+	}
+	if pos == nil || pos.Module == nil || pos.BeginRow <= 0 {
+		return []location{}, nil
+	}
+	if _, err := os.Stat(*pos.Module); err != nil {
+		return []location{}, nil
+	}
+	line := pos.BeginRow - 1
+	return []location{{
+		URI:   pathToURI(*pos.Module),
+		Range: textRange{Start: position{Line: line}, End: position{Line: line}},
+	}}, nil
+}
+
+func (s *Server) documentSymbols(params documentSymbolParams) ([]documentSymbol, error) {
+	doc, err := s.document(params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	symbols := []documentSymbol{}
+	for _, def := range doc.defs {
+		symbols = append(symbols, documentSymbol{
+			Name:           def.name,
+			Detail:         def.detail,
+			Kind:           def.kind,
+			Range:          def.rng,
+			SelectionRange: def.nameRange,
+		})
+	}
+	return symbols, nil
+}
@@ -0,0 +1,321 @@
+package lsp_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jig/lisp/env"
+	"github.com/jig/lisp/lib/call"
+	"github.com/jig/lisp/lib/core/nscore"
+	"github.com/jig/lisp/lsp"
+)
+
+type Point struct {
+	X, Y int
+}
+
+func distance(p Point, q Point) (int, error) {
+	return max(p.X-q.X, q.X-p.X) + max(p.Y-q.Y, q.Y-p.Y), nil
+}
+
+// constructed counts the calls of new_point
+var constructed atomic.Int32
+
+func new_point(x, y int) (Point, error) {
+	constructed.Add(1)
+	return Point{X: x, Y: y}, nil
+}
+
+// client drives a server through pipes
+type client struct {
+	t   *testing.T
+	w   io.Writer
+	r   *bufio.Reader
+	id  int
+	err chan error
+}
+
+func newClient(t *testing.T) *client {
+	ns := env.NewEnv()
+	if err := nscore.Load(ns); err != nil {
+		t.Fatal(err)
+	}
+	call.Call(ns, new_point)
+	call.Call(ns, distance)
+
+	clientR, serverW := io.Pipe()
+	serverR, clientW := io.Pipe()
+	c := &client{t: t, w: clientW, r: bufio.NewReader(clientR), err: make(chan error, 1)}
+	go func() {
+		c.err <- lsp.NewServer(ns).Serve(serverR, serverW)
+		serverW.Close()
+	}()
+	return c
+}
+
+func (c *client) send(method string, id *int, params any) {
+	msg := map[string]any{"jsonrpc": "2.0", "method": method, "params": params}
+	if id != nil {
+		msg["id"] = *id
+	}
+	body, err := json.Marshal(msg)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	if _, err := fmt.Fprintf(c.w, "Content-Length: %d\r\n\r\n%s", len(body), body); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+func (c *client) receive() map[string]json.RawMessage {
+	length := 0
+	for {
+		line, err := c.r.ReadString('\n')
+		if err != nil {
+			c.t.Fatal(err)
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			break
+		}
+		if value, ok := strings.CutPrefix(line, "Content-Length: "); ok {
+			length, _ = strconv.Atoi(value)
+		}
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(c.r, body); err != nil {
+		c.t.Fatal(err)
+	}
+	var msg map[string]json.RawMessage
+	if err := json.Unmarshal(body, &msg); err != nil {
+		c.t.Fatal(err)
+	}
+	return msg
+}
+
+// request sends a request and decodes its result on result
+func (c *client) request(method string, params any, result any) {
+	c.id++
+	id := c.id
+	c.send(method, &id, params)
+	msg := c.receive()
+	if e, ok := msg["error"]; ok {
+		c.t.Fatalf("%s: %s", method, e)
+	}
+	if err := json.Unmarshal(msg["result"], result); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+type diagnostic struct {
+	Range struct {
+		Start struct{ Line, Character int }
+		End   struct{ Line, Character int }
+	}
+	Severity int
+	Code     string
+	Message  string
+}
+
+// open opens a document and returns its diagnostics
+func (c *client) open(uri, text string) []diagnostic {
+	c.send("textDocument/didOpen", nil, map[string]any{
+		"textDocument": map[string]any{"uri": uri, "languageId": "lisp", "version": 1, "text": text},
+	})
+	return c.diagnostics(uri)
+}
+
+// change changes the text of a document
+func (c *client) change(uri, text string) {
+	c.send("textDocument/didChange", nil, map[string]any{
+		"textDocument":   map[string]any{"uri": uri, "version": 2},
+		"contentChanges": []map[string]any{{"text": text}},
+	})
+}
+
+// diagnostics receives the diagnostics of a document
+func (c *client) diagnostics(uri string) []diagnostic {
+	msg := c.receive()
+	var params struct {
+		URI         string
+		Diagnostics []diagnostic
+	}
+	if err := json.Unmarshal(msg["params"], &params); err != nil {
+		c.t.Fatal(err)
+	}
+	if params.URI != uri {
+		c.t.Fatalf("diagnostics for %s instead of %s", params.URI, uri)
+	}
+	return params.Diagnostics
+}
+
+func at(uri string, line, character int) map[string]any {
+	return map[string]any{
+		"textDocument": map[string]any{"uri": uri},
+		"position":     map[string]any{"line": line, "character": character},
+	}
+}
+
+const source = `;; $ORIGIN «point 0 0»
+
+(defn far? [p limit]
+  (> (distance p $ORIGIN) limit))
+
+(def home «point 3 4»)
+(prn (far? home 5) undefined-thing $UNKNOWN)
+`
+
+func TestServer(t *testing.T) {
+	c := newClient(t)
+	const uri = "file:///tmp/config.lisp"
+
+	var initResult struct {
+		Capabilities map[string]any
+	}
+	c.request("initialize", map[string]any{"capabilities": map[string]any{}}, &initResult)
+	if initResult.Capabilities["hoverProvider"] != true {
+		t.Fatalf("unexpected capabilities %v", initResult.Capabilities)
+	}
+	c.send("initialized", nil, map[string]any{})
+
+	t.Run("diagnostics", func(t *testing.T) {
+		diagnostics := c.open(uri, source)
+		codes := []string{}
+		for _, d := range diagnostics {
+			codes = append(codes, fmt.Sprintf("%s@%d:%d-%d", d.Code, d.Range.Start.Line, d.Range.Start.Character, d.Range.End.Character))
+		}
+		expected := []string{"placeholder@6:35-43", "undefined-symbol@6:19-34"}
+		if strings.Join(codes, " ") != strings.Join(expected, " ") {
+			t.Fatalf("got %v, expected %v", codes, expected)
+		}
+	})
+
+	t.Run("utf-16 positions", func(t *testing.T) {
+		// 😀 takes two UTF-16 code units
+		diagnostics := c.open("file:///tmp/emoji.lisp", "(str \"😀\" $X)")
+		if len(diagnostics) != 1 || diagnostics[0].Range.Start.Character != 10 || diagnostics[0].Range.End.Character != 12 {
+			t.Fatalf("unexpected diagnostics %v", diagnostics)
+		}
+	})
+
+	t.Run("constructor errors", func(t *testing.T) {
+		diagnostics := c.open("file:///tmp/bad.lisp", "(def a «nothing 1»)\n(def b (")
+		if len(diagnostics) != 1 || diagnostics[0].Code != "syntax" {
+			t.Fatalf("unexpected diagnostics %v", diagnostics)
+		}
+		diagnostics = c.open("file:///tmp/bad.lisp", "(def a «nothing 1»)")
+		if len(diagnostics) != 1 || !strings.Contains(diagnostics[0].Message, "new-nothing") {
+			t.Fatalf("unexpected diagnostics %v", diagnostics)
+		}
+	})
+
+	t.Run("completion", func(t *testing.T) {
+		var items []struct{ Label, Detail string }
+		c.request("textDocument/completion", at(uri, 3, 10), &items) // (> (dist|
+		if len(items) != 1 || items[0].Label != "distance" || items[0].Detail != "(distance lsp_test.Point lsp_test.Point)" {
+			t.Fatalf("unexpected completion %v", items)
+		}
+		c.request("textDocument/completion", at(uri, 5, 12), &items) // «p|
+		if len(items) != 1 || items[0].Label != "point" {
+			t.Fatalf("unexpected constructor completion %v", items)
+		}
+		c.request("textDocument/completion", at(uri, 3, 21), &items) // $O|
+		if len(items) != 1 || items[0].Label != "$ORIGIN" {
+			t.Fatalf("unexpected placeholder completion %v", items)
+		}
+		c.request("textDocument/completion", at(uri, 6, 9), &items) // (prn (far|
+		if len(items) != 1 || items[0].Label != "far?" || items[0].Detail != "[p limit]" {
+			t.Fatalf("unexpected definition completion %v", items)
+		}
+	})
+
+	t.Run("hover", func(t *testing.T) {
+		var h struct {
+			Contents struct{ Value string }
+		}
+		c.request("textDocument/hover", at(uri, 3, 7), &h)
+		for _, expected := range []string{"(distance lsp_test.Point lsp_test.Point)", "func(lsp_test.Point, lsp_test.Point) (int, error)", "package `github.com/jig/lisp/lsp_test`"} {
+			if !strings.Contains(h.Contents.Value, expected) {
+				t.Fatalf("%q not found on hover %q", expected, h.Contents.Value)
+			}
+		}
+		c.request("textDocument/hover", at(uri, 5, 12), &h)
+		if !strings.Contains(h.Contents.Value, "(new-point int int)") {
+			t.Fatalf("unexpected constructor hover %q", h.Contents.Value)
+		}
+		c.request("textDocument/hover", at(uri, 6, 8), &h)
+		if !strings.Contains(h.Contents.Value, "(defn far? [p limit])") {
+			t.Fatalf("unexpected definition hover %q", h.Contents.Value)
+		}
+	})
+
+	t.Run("definition", func(t *testing.T) {
+		var locations []struct {
+			URI   string
+			Range struct {
+				Start struct{ Line, Character int }
+			}
+		}
+		c.request("textDocument/definition", at(uri, 6, 12), &locations) // home
+		if len(locations) != 1 || locations[0].URI != uri || locations[0].Range.Start.Line != 5 || locations[0].Range.Start.Character != 5 {
+			t.Fatalf("unexpected definition %v", locations)
+		}
+	})
+
+	t.Run("document symbols", func(t *testing.T) {
+		var symbols []struct {
+			Name string
+			Kind int
+		}
+		c.request("textDocument/documentSymbol", map[string]any{"textDocument": map[string]any{"uri": uri}}, &symbols)
+		if fmt.Sprint(symbols) != "[{far? 12} {home 13}]" {
+			t.Fatalf("unexpected symbols %v", symbols)
+		}
+	})
+
+	var result any
+	c.request("shutdown", nil, &result)
+	c.send("exit", nil, nil)
+	if err := <-c.err; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAnalysis(t *testing.T) {
+	c := newClient(t)
+	const uri = "file:///tmp/edited.lisp"
+
+	constructed.Store(0)
+	if diagnostics := c.open(uri, "(def home «point 3 4»)"); len(diagnostics) != 0 {
+		t.Fatalf("unexpected diagnostics %v", diagnostics)
+	}
+	// changes are linted once the document is left unchanged
+	start := time.Now()
+	c.change(uri, "(defmacro m (fn [] '(m)))")
+	c.change(uri, "(defmacro m (fn [] '(m)))\n(m)")
+	c.change(uri, "(defmacro m (fn [] '(m)))\n(m)\n(def home «point 3 4»)")
+	diagnostics := c.diagnostics(uri)
+	if time.Since(start) < lsp.AnalysisDelay {
+		t.Fatalf("document linted before %s", lsp.AnalysisDelay)
+	}
+	if len(diagnostics) != 1 || diagnostics[0].Code != "macro-expansion" || diagnostics[0].Range.Start.Line != 1 {
+		t.Fatalf("unexpected diagnostics %v", diagnostics)
+	}
+	if n := constructed.Load(); n != 0 {
+		t.Fatalf("«point» constructed %d times", n)
+	}
+
+	var result any
+	c.request("shutdown", nil, &result)
+	c.send("exit", nil, nil)
+	if err := <-c.err; err != nil {
+		t.Fatal(err)
+	}
+}
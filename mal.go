@@ -33,6 +33,7 @@ import (
 	"errors"
 	"fmt"
 	"regexp"
+	"slices"
 	"strings"
 	"time"
 
@@ -52,6 +53,16 @@ const preamblePrefix = ";; $"
 // Set this to true before evaluation if you need DEBUG-EVAL functionality.
 var DebugEvalEnabled = false
 
+// TryCleanupReserve is the time reserved to the catch and finally clauses of a try
+// form when the context carries a deadline. The try body is evaluated with a deadline
+// brought forward by this amount, so cleanup code still has time to run when the body
+// times out. The reserve never exceeds half of the remaining time.
+//
+// Lisp code might override it by defining *try-cleanup-ms* (in milliseconds).
+var TryCleanupReserve = 100 * time.Millisecond
+
+const tryCleanupSymbol = "*try-cleanup-ms*"
+
 // READ reads Lisp source code and generates an AST that might be evaled by [EVAL] or printed by [PRINT].
 //
 // cursor and environment might be passed nil and READ will provide correct values for you.
@@ -99,7 +110,7 @@ func READ(sourceCode string, cursor *Position, ns EnvType) (MalType, error) {
 // READWithPreamble is used to read code (actually decode) on transmission. Use [AddPreamble]
 // when calling from Go code.
 func READWithPreamble(str string, cursor *Position, ns EnvType) (MalType, error) {
-	placeholderMap := &HashMap{Val: map[string]MalType{}}
+	placeholderMap := &HashMap{Val: map[MalType]MalType{}}
 	i := 0
 	for ; ; i++ {
 		var line string
@@ -219,10 +230,17 @@ func macroexpand(ctx context.Context, ast MalType, env EnvType) (MalType, error)
 			return nil, e
 		}
 		fn := mac.(MalFunc)
+		cursor := ast.(List).Cursor
 		ast, e = Apply(ctx, fn, slc[1:])
 		if e != nil {
 			return nil, e
 		}
+		// the expansion is located where the macro was called (e.g. so functions
+		// defined with defn know where they were defined)
+		if expansion, ok := ast.(List); ok && expansion.Cursor == nil {
+			expansion.Cursor = cursor
+			ast = expansion
+		}
 	}
 	return ast, nil
 }
@@ -260,7 +278,7 @@ func eval_ast(ctx context.Context, ast MalType, env EnvType) (MalType, error) {
 		return Vector{Val: lst, Cursor: origVec.Cursor}, nil
 	} else if Q[HashMap](ast) {
 		m := ast.(HashMap)
-		new_hm := HashMap{Val: map[string]MalType{}, Cursor: m.Cursor}
+		new_hm := HashMap{Val: map[MalType]MalType{}, Cursor: m.Cursor, Record: m.Record}
 		for k, v := range m.Val {
 			kv, e2 := EVAL(ctx, v, env)
 			if e2 != nil {
@@ -301,6 +319,58 @@ func extractFunctionName(ast MalType, isMacro bool) string {
 	}
 }
 
+// defParts splits (def name [docstring] [meta-map] value) (and defmacro) into the form
+// of the value and its metadata (nil if none). Forms that do not follow that shape are
+// evaluated as (def name value) always did.
+func defParts(ctx context.Context, ast List, env EnvType) (MalType, *HashMap, error) {
+	if len(ast.Val) < 3 {
+		return nil, nil, nil
+	}
+	forms := ast.Val[2 : len(ast.Val)-1]
+	if len(forms) == 0 || len(forms) > 2 {
+		return ast.Val[2], nil, nil
+	}
+	var attrs MalType
+	meta := HashMap{Val: map[MalType]MalType{}}
+	for i, form := range forms {
+		switch {
+		case i == 0 && String_Q(form):
+			meta.Val[NewKeyword("doc")] = form
+		case i == len(forms)-1 && Q[HashMap](form):
+			attrs = form
+		default:
+			return ast.Val[2], nil, nil
+		}
+	}
+	if attrs != nil {
+		attrs, err := EVAL(ctx, attrs, env)
+		if err != nil {
+			return nil, nil, err
+		}
+		for k, v := range attrs.(HashMap).Val {
+			meta.Val[k] = v
+		}
+	}
+	return ast.Val[len(ast.Val)-1], &meta, nil
+}
+
+// defined adds the metadata of a definition to its value. Lisp functions without a
+// position are located on the definition (e.g. those defined with defn).
+func defined(value MalType, meta *HashMap, ast List) (MalType, error) {
+	if meta != nil {
+		withMeta, err := VaryMeta(value, *meta)
+		if err != nil {
+			return nil, lisperror.NewLispError(fmt.Errorf("%s: cannot attach metadata to %T", first(ast), value), ast)
+		}
+		value = withMeta
+	}
+	if fn, ok := value.(MalFunc); ok && fn.Cursor == nil {
+		fn.Cursor = ast.Cursor
+		value = fn
+	}
+	return value, nil
+}
+
 func do(ctx context.Context, ast MalType, from, to int, env EnvType) (MalType, error) {
 	if ast == nil {
 		return nil, nil
@@ -338,6 +408,20 @@ func EVAL(ctx context.Context, ast MalType, env EnvType) (res MalType, e error)
 		}
 	}()
 
+	// checks of the results of the functions called on tail position, run once on the
+	// result of the last one (a function calling itself is checked once)
+	var returns []pendingReturn
+	defer func() {
+		for i := len(returns) - 1; i >= 0 && e == nil; i-- {
+			if res, e = returns[i].Check(ctx, res); e != nil {
+				e = lisperror.NewLispError(e, returns[i].call)
+			}
+		}
+	}()
+
+	dbg := debugging(ctx)
+	tailCall := false // a Lisp function was already applied on this EVAL (by TCO)
+	entryCtx := ctx   // function contexts derive from it so tail calls do not nest them
 	for {
 		if ctx != nil {
 			select {
@@ -375,6 +459,12 @@ func EVAL(ctx context.Context, ast MalType, env EnvType) (res MalType, e error)
 			return eval_ast(ctx, ast, env)
 		}
 
+		if dbg != nil {
+			if e := dbg.debugger.Eval(ctx, ast, env, dbg.frame); e != nil {
+				return nil, lisperror.NewLispError(e, ast)
+			}
+		}
+
 		// apply list
 		ast, e = macroexpand(ctx, ast, env)
 		if e != nil {
@@ -410,12 +500,20 @@ func EVAL(ctx context.Context, ast MalType, env EnvType) (res MalType, e error)
 		}
 		switch a0sym {
 		case "def":
-			res, e := EVAL(ctx, a2, env)
+			valueForm, meta, e := defParts(ctx, ast.(List), env)
+			if e != nil {
+				return nil, e
+			}
+			res, e := EVAL(ctx, valueForm, env)
 			if e != nil {
 				return nil, e
 			}
 			switch a1 := a1.(type) {
 			case Symbol:
+				res, e = defined(res, meta, ast.(List))
+				if e != nil {
+					return nil, e
+				}
 				return env.Set(a1, res), nil
 			default:
 				return nil, lisperror.NewLispError(fmt.Errorf("cannot use '%T' as identifier", a1), ast)
@@ -452,103 +550,30 @@ func EVAL(ctx context.Context, ast MalType, env EnvType) (res MalType, e error)
 		case "quasiquote": // `
 			ast = quasiquote(a1)
 		case "defmacro":
-			fn, e := EVAL(ctx, a2, env)
+			valueForm, meta, e := defParts(ctx, ast.(List), env)
+			if e != nil {
+				return nil, e
+			}
+			fn, e := EVAL(ctx, valueForm, env)
 			if e != nil {
 				return nil, e
 			}
 			switch fn := fn.(type) {
 			case MalFunc:
-				return env.Set(a1.(Symbol), fn.SetMacro()), nil
+				macro, e := defined(fn.SetMacro(), meta, ast.(List))
+				if e != nil {
+					return nil, e
+				}
+				return env.Set(a1.(Symbol), macro), nil
 			default:
 				return nil, lisperror.NewLispError(fmt.Errorf("defmacro: second argument must be a function (was of type %T)", fn), ast)
 			}
 		case "macroexpand":
 			return macroexpand(ctx, a1, env)
 		case "try":
-			lst := ast.(List).Val
-			var last MalType
-			var prelast MalType
-			switch len(lst) {
-			case 1:
-				return nil, nil
-			case 2:
-				last = lst[1]
-				prelast = nil
-			case 3:
-				last = lst[2]
-				prelast = lst[1]
-			default:
-				last = lst[len(lst)-1]
-				prelast = lst[len(lst)-2]
-			}
-			var tryDo, catchDo, finallyDo MalType // Lists
-			var catchBind MalType                 // Symbol
-
-			switch first(last) {
-			case "catch":
-				finallyDo = nil
-				catchBind = last.(List).Val[1]
-				catchDo = List{Val: last.(List).Val[2:]}
-				tryDo = List{Val: lst[1 : len(lst)-1]}
-				if len(catchDo.(List).Val) == 0 {
-					return nil, lisperror.NewLispError(errors.New("catch must have 2 arguments at least"), ast)
-				}
-			case "finally":
-				finallyDo = List{Val: last.(List).Val[1:]}
-				switch first(prelast) {
-				case "catch":
-					catchBind = prelast.(List).Val[1]
-					catchDo = List{Val: prelast.(List).Val[2:]}
-					tryDo = List{Val: lst[1 : len(lst)-2]}
-				default:
-					catchBind = nil
-					catchDo = nil
-					tryDo = List{Val: lst[1 : len(lst)-1]}
-				}
-			default:
-				finallyDo = nil
-				catchBind = nil
-				catchDo = nil
-				tryDo = List{Val: lst[1:]}
-			}
-			exp, e := func() (res MalType, err error) {
-				defer malRecover(&err)
-				if dl, ok := ctx.Deadline(); ok {
-					// give 80% of the time to the try, and the remaining 20% to the catch + finally
-					timeout := (time.Until(dl) / 10) * 8
-					ctx, cancel := context.WithTimeout(ctx, timeout)
-					defer cancel()
-					return do(ctx, tryDo, 0, 0, env)
-				}
-				return do(ctx, tryDo, 0, 0, env)
-			}()
-
-			defer func() { _, _ = do(ctx, finallyDo, 0, 0, env) }()
-
-			if e == nil {
-				return exp, nil
-			} else {
-				if catchDo != nil {
-					var caughtError MalType
-					if er, ok := e.(interface{ ErrorValue() MalType }); ok {
-						caughtError = er.ErrorValue()
-					} else {
-						caughtError = e.Error()
-					}
-					binds := NewList(nil, catchBind)
-					new_env, err := NewSubordinateEnvWithBinds(env, binds, NewList(nil, caughtError))
-					if err != nil {
-						return nil, err
-					}
-					ast, err = do(ctx, catchDo, 0, 0, new_env)
-					if err != nil {
-						return nil, err
-					}
-					env = new_env
-					continue
-				}
-				return nil, e
-			}
+			return evalTry(ctx, ast.(List), env)
+		case ".": // (.Method obj args...) and (.-Field obj) are read as (. Method obj args...)
+			return evalInterop(ctx, ast.(List), env)
 		case "do":
 			var err error
 			ast, err = do(ctx, ast, 1, -1, env)
@@ -589,6 +614,15 @@ func EVAL(ctx context.Context, ast MalType, env EnvType) (res MalType, e error)
 			f := el.(List).Val[0]
 			if Q[MalFunc](f) {
 				fn := f.(MalFunc)
+				call := lisperror.GetPosition(ast)
+				if fn.Args != nil {
+					if e := fn.Args.Check(ctx, el.(List).Val[1:]); e != nil {
+						return nil, lisperror.NewLispError(e, ast)
+					}
+				}
+				if fn.Returns != nil && !slices.ContainsFunc(returns, func(p pendingReturn) bool { return p.ReturnCheck == fn.Returns }) {
+					returns = append(returns, pendingReturn{fn.Returns, ast})
+				}
 				ast = fn.Exp
 				env, e = NewSubordinateEnvWithBinds(fn.Env, fn.Params, List{Val: el.(List).Val[1:]})
 				if e != nil {
@@ -602,13 +636,32 @@ func EVAL(ctx context.Context, ast MalType, env EnvType) (res MalType, e error)
 						return nil, lisperror.NewLispError(e, ast)
 					}
 				}
+				if dbg != nil {
+					if tailCall {
+						ctx = dbg.tailCall(entryCtx, a0sym, call, env)
+					} else {
+						ctx = dbg.call(entryCtx, a0sym, call, env)
+						tailCall = true
+					}
+					dbg = debugging(ctx)
+				}
 			} else {
-				fn, ok := f.(Func)
-				if !ok {
+				var result MalType
+				var err error
+				switch fn := f.(type) {
+				case Func:
+					result, err = fn.Fn(ctx, el.(List).Val[1:])
+				case Callable:
+					// keywords, hash maps, sets and vectors
+					result, err = fn.Call(ctx, el.(List).Val[1:])
+				default:
 					return nil, lisperror.NewLispError(fmt.Errorf("attempt to call non-function (was of type %T)", f), el)
 				}
-				result, err := fn.Fn(ctx, el.(List).Val[1:])
 				if err != nil {
+					var panicErr *lisperror.PanicError
+					if errors.As(err, &panicErr) && panicErr.Position == nil {
+						panicErr.Position = lisperror.GetPosition(ast)
+					}
 					return nil, lisperror.NewLispError(err, ast)
 				}
 				return result, nil
@@ -617,17 +670,184 @@ func EVAL(ctx context.Context, ast MalType, env EnvType) (res MalType, e error)
 	} // TCO loop
 }
 
+// pendingReturn is the check of the result of a function called on tail position
+type pendingReturn struct {
+	*ReturnCheck
+	call MalType
+}
+
+// evalInterop calls a method or reads a field of a Go value through the [Interop] of env
+func evalInterop(ctx context.Context, ast List, env EnvType) (MalType, error) {
+	if len(ast.Val) < 3 {
+		return nil, lisperror.NewLispError(errors.New("expected (.Method obj args...) or (.-Field obj)"), ast)
+	}
+	member, ok := ast.Val[1].(Symbol)
+	if !ok {
+		return nil, lisperror.NewLispError(fmt.Errorf("expected method or field name (was of type %T)", ast.Val[1]), ast)
+	}
+	registry, err := env.Get(Symbol{Val: InteropSymbol})
+	if err != nil {
+		return nil, lisperror.NewLispError(fmt.Errorf("%s: no Go types are registered for interop", member.Val), ast)
+	}
+	interop, ok := registry.(Interop)
+	if !ok {
+		return nil, lisperror.NewLispError(fmt.Errorf("%s is not an interop registry (was of type %T)", InteropSymbol, registry), ast)
+	}
+	values := make([]MalType, len(ast.Val)-2)
+	for i, form := range ast.Val[2:] {
+		if values[i], err = EVAL(ctx, form, env); err != nil {
+			return nil, err
+		}
+	}
+	result, err := interop.Invoke(ctx, member.Val, values[0], values[1:])
+	if err != nil {
+		return nil, lisperror.NewLispError(err, ast)
+	}
+	return result, nil
+}
+
 func first(list MalType) string {
-	if list != nil && Q[List](list) && Q[Symbol](list.(List).Val[0]) {
-		return list.(List).Val[0].(Symbol).Val
+	if lst, ok := list.(List); ok && len(lst.Val) > 0 && Q[Symbol](lst.Val[0]) {
+		return lst.Val[0].(Symbol).Val
 	}
 	return ""
 }
 
+// evalTry evaluates a (try body... (catch e handler...) (finally cleanup...)) form.
+//
+// try is not evaluated on the TCO loop, so finally is executed right after the body
+// (or the catch handler) ends and nested try forms run their finally clauses inner first.
+// Errors raised by finally are returned if the body (or catch) succeeded, or attached
+// as suppressed errors to the primary error otherwise.
+func evalTry(ctx context.Context, ast List, env EnvType) (MalType, error) {
+	lst := ast.Val
+	if len(lst) == 1 {
+		return nil, nil
+	}
+	last := lst[len(lst)-1]
+	var prelast MalType
+	if len(lst) > 2 {
+		prelast = lst[len(lst)-2]
+	}
+
+	var tryDo, catchDo, finallyDo MalType // Lists
+	var catchBind MalType                 // Symbol
+	switch first(last) {
+	case "catch":
+		if len(last.(List).Val) < 3 {
+			return nil, lisperror.NewLispError(errors.New("catch must have 2 arguments at least"), ast)
+		}
+		catchBind = last.(List).Val[1]
+		catchDo = List{Val: last.(List).Val[2:]}
+		tryDo = List{Val: lst[1 : len(lst)-1]}
+	case "finally":
+		finallyDo = List{Val: last.(List).Val[1:]}
+		switch first(prelast) {
+		case "catch":
+			if len(prelast.(List).Val) < 3 {
+				return nil, lisperror.NewLispError(errors.New("catch must have 2 arguments at least"), ast)
+			}
+			catchBind = prelast.(List).Val[1]
+			catchDo = List{Val: prelast.(List).Val[2:]}
+			tryDo = List{Val: lst[1 : len(lst)-2]}
+		default:
+			tryDo = List{Val: lst[1 : len(lst)-1]}
+		}
+	default:
+		tryDo = List{Val: lst[1:]}
+	}
+
+	deadline, hasDeadline := ctx.Deadline()
+	var reserve time.Duration
+	if hasDeadline {
+		reserve = tryCleanupReserve(env, time.Until(deadline))
+	}
+
+	res, err := func() (res MalType, err error) {
+		defer malRecover(&err)
+		if hasDeadline {
+			ctx, cancel := context.WithDeadline(ctx, deadline.Add(-reserve))
+			defer cancel()
+			return do(ctx, tryDo, 0, 0, env)
+		}
+		return do(ctx, tryDo, 0, 0, env)
+	}()
+
+	if err != nil && catchDo != nil {
+		var caughtError MalType
+		if er, ok := err.(interface{ ErrorValue() MalType }); ok {
+			caughtError = er.ErrorValue()
+		} else {
+			caughtError = err.Error()
+		}
+		if p, ok := caughtError.(*lisperror.PanicError); ok {
+			if _, ok := p.Value.(error); !ok {
+				// values passed to panic (e.g. (panic 3)) are caught as they are, as thrown ones
+				caughtError = p.Value
+			}
+		}
+		catchEnv, e := NewSubordinateEnvWithBinds(env, NewList(nil, catchBind), NewList(nil, caughtError))
+		if e != nil {
+			return nil, e
+		}
+		res, err = func() (res MalType, err error) {
+			defer malRecover(&err)
+			if hasDeadline && finallyDo != nil {
+				// catch and finally share the reserve
+				ctx, cancel := context.WithDeadline(ctx, deadline.Add(-reserve/2))
+				defer cancel()
+				return do(ctx, catchDo, 0, 0, catchEnv)
+			}
+			return do(ctx, catchDo, 0, 0, catchEnv)
+		}()
+	}
+
+	if finallyDo == nil {
+		return res, err
+	}
+	_, finallyErr := func() (res MalType, err error) {
+		defer malRecover(&err)
+		return do(ctx, finallyDo, 0, 0, env)
+	}()
+	switch {
+	case finallyErr == nil:
+		return res, err
+	case err == nil:
+		return nil, finallyErr
+	default:
+		return nil, lisperror.NewLispError(err, ast).AddSuppressed(finallyErr)
+	}
+}
+
+// tryCleanupReserve returns the time reserved to catch and finally clauses
+// given the remaining time until the deadline.
+func tryCleanupReserve(env EnvType, remaining time.Duration) time.Duration {
+	reserve := TryCleanupReserve
+	sym := Symbol{Val: tryCleanupSymbol}
+	if env.Find(sym) != nil {
+		if v, err := env.Get(sym); err == nil {
+			if ms, ok := v.(int); ok && ms >= 0 {
+				reserve = time.Duration(ms) * time.Millisecond
+			}
+		}
+	}
+	if reserve > remaining/2 {
+		reserve = remaining / 2
+	}
+	return reserve
+}
+
 func malRecover(err *error) {
 	rerr := recover()
 	if rerr != nil {
-		*err = rerr.(error)
+		switch rerr := rerr.(type) {
+		case lisperror.LispError:
+			*err = rerr
+		case error:
+			*err = lisperror.NewPanicError("", "", rerr)
+		default:
+			*err = lisperror.NewLispError(rerr, nil)
+		}
 	}
 }
 
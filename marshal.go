@@ -0,0 +1,205 @@
+package lisp
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/jig/lisp/bind"
+	"github.com/jig/lisp/printer"
+	. "github.com/jig/lisp/types"
+)
+
+// Marshaler is implemented by types that convert themselves to Lisp values
+type Marshaler = bind.Marshaler
+
+// Marshal returns the Lisp source code of v, that [READ] and [Unmarshal] read back.
+// Go values are converted as package bind does: structs are hash maps keyed by
+// keywords as set by the lisp struct tags (e.g. `lisp:"port,omitempty"`). Hash map keys
+// and set items are sorted so the output is stable. Floating point numbers are read as
+// float32 by [READ], so they might lose precision on the way back, and values out of
+// the float32 range are an error.
+func Marshal(v any) ([]byte, error) {
+	return marshal(v, "", false)
+}
+
+// MarshalIndent is like [Marshal] but writes each entry of hash maps and each item of
+// sequences holding collections on its own line, aligned as `lisp fmt` does. Each new
+// line starts with prefix.
+func MarshalIndent(v any, prefix string) ([]byte, error) {
+	return marshal(v, prefix, true)
+}
+
+func marshal(v any, prefix string, indent bool) ([]byte, error) {
+	ast, err := bind.ToLisp(v)
+	if err != nil {
+		return nil, err
+	}
+	e := encodeState{prefix: prefix, indent: indent}
+	if err := e.value(ast, 0); err != nil {
+		return nil, err
+	}
+	return []byte(e.sb.String()), nil
+}
+
+// Encoder writes Lisp values to an output stream
+type Encoder struct {
+	w      io.Writer
+	prefix string
+	indent bool
+}
+
+// NewEncoder returns an encoder that writes to w
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w}
+}
+
+// SetIndent makes the encoder write values as [MarshalIndent] does
+func (enc *Encoder) SetIndent(prefix string) {
+	enc.prefix = prefix
+	enc.indent = true
+}
+
+// Encode writes the Lisp source code of v followed by a new line
+func (enc *Encoder) Encode(v any) error {
+	b, err := marshal(v, enc.prefix, enc.indent)
+	if err != nil {
+		return err
+	}
+	_, err = enc.w.Write(append(b, '\n'))
+	return err
+}
+
+// maxLineItems is the length up to which a collection without nested collections is
+// written on a single line by MarshalIndent
+const maxLineItems = 60
+
+type encodeState struct {
+	sb     strings.Builder
+	prefix string
+	indent bool
+}
+
+// value writes v, being col the column where it starts
+func (e *encodeState) value(v MalType, col int) error {
+	switch v := v.(type) {
+	case nil:
+		e.sb.WriteString("nil")
+	case bool:
+		e.sb.WriteString(strconv.FormatBool(v))
+	case int:
+		e.sb.WriteString(strconv.Itoa(v))
+	case float32:
+		return e.float(float64(v), 32)
+	case float64:
+		return e.float(v, 64)
+	case string:
+		e.sb.WriteString(printer.Pr_str(v, true))
+	case Keyword:
+		e.sb.WriteString(v.String())
+	case Symbol:
+		e.sb.WriteString(v.Val)
+	case []byte:
+		items := make([]MalType, len(v))
+		for i, b := range v {
+			items[i] = int(b)
+		}
+		return e.collection("[", "]", items, false, col)
+	case List:
+		return e.collection("(", ")", v.Val, false, col)
+	case Vector:
+		return e.collection("[", "]", v.Val, false, col)
+	case HashMap:
+		keys := SortedKeys(v.Val)
+		open, close := "{", "}"
+		if v.Record != nil {
+			keys = make([]MalType, len(v.Record.Fields))
+			for i, field := range v.Record.Fields {
+				keys[i] = field
+			}
+			open, close = "«"+v.Record.Name+" {", "}»"
+		}
+		items := make([]MalType, 0, 2*len(keys))
+		for _, key := range keys {
+			items = append(items, key, v.Val[key])
+		}
+		return e.collection(open, close, items, true, col)
+	case Set:
+		return e.collection("#{", "}", SortedKeys(v.Val), false, col)
+	default:
+		return fmt.Errorf("lisp: unsupported value of type %T", v)
+	}
+	return nil
+}
+
+func (e *encodeState) float(f float64, bitSize int) error {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return fmt.Errorf("lisp: unsupported float value %v", f)
+	}
+	if math.Abs(f) > math.MaxFloat32 {
+		// the reader reads floats as float32
+		return fmt.Errorf("lisp: float value %v out of the float32 range", f)
+	}
+	s := strconv.FormatFloat(f, 'g', -1, bitSize)
+	if !strings.ContainsAny(s, ".e") {
+		// keep it a float when read back
+		s += ".0"
+	}
+	e.sb.WriteString(s)
+	return nil
+}
+
+// collection writes the items of a collection (pairs of keys and values if pairs is set)
+func (e *encodeState) collection(open, close string, items []MalType, pairs bool, col int) error {
+	if !e.indent || fitsLine(items) {
+		e.sb.WriteString(open)
+		for i, item := range items {
+			if i > 0 {
+				e.sb.WriteString(" ")
+			}
+			if err := e.value(item, 0); err != nil {
+				return err
+			}
+		}
+		e.sb.WriteString(close)
+		return nil
+	}
+
+	e.sb.WriteString(open)
+	itemCol := col + len([]rune(open))
+	step := 1
+	if pairs {
+		step = 2
+	}
+	for i := 0; i < len(items); i += step {
+		if i > 0 {
+			e.sb.WriteString("\n" + e.prefix + strings.Repeat(" ", itemCol))
+		}
+		valueCol := itemCol
+		if pairs {
+			key := printer.Pr_str(items[i], true)
+			e.sb.WriteString(key + " ")
+			valueCol += len([]rune(key)) + 1
+		}
+		if err := e.value(items[i+step-1], valueCol); err != nil {
+			return err
+		}
+	}
+	e.sb.WriteString(close)
+	return nil
+}
+
+// fitsLine reports whether a collection is written on a single line by MarshalIndent
+func fitsLine(items []MalType) bool {
+	length := 0
+	for _, item := range items {
+		switch item.(type) {
+		case List, Vector, HashMap, Set, []byte:
+			return false
+		}
+		length += len(printer.Pr_str(item, true)) + 1
+	}
+	return length <= maxLineItems
+}
@@ -0,0 +1,180 @@
+package lisp
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jig/lisp/types"
+)
+
+type endpoint struct {
+	Host string `lisp:"host"`
+	Port int    `lisp:"port,omitempty"`
+}
+
+type document struct {
+	Title    string            `lisp:"title"`
+	Quote    string            `lisp:"quote"`
+	JSON     string            `lisp:"json"`
+	Ratio    float64           `lisp:"ratio"`
+	Whole    float64           `lisp:"whole"`
+	Enabled  bool              `lisp:"enabled"`
+	Tags     []string          `lisp:"tags"`
+	Data     []byte            `lisp:"data"`
+	Labels   map[string]string `lisp:"labels"`
+	Primary  *endpoint         `lisp:"primary"`
+	Backup   *endpoint         `lisp:"backup"`
+	Replicas []endpoint        `lisp:"replicas"`
+	Created  time.Time         `lisp:"created"`
+	Level    level             `lisp:"level"`
+}
+
+// level is written as a symbol
+type level int
+
+func (l level) MarshalLisp() (types.MalType, error) {
+	return types.Symbol{Val: [...]string{"low", "high"}[l]}, nil
+}
+
+func (l *level) UnmarshalLisp(v types.MalType) error {
+	symbol, ok := v.(types.Symbol)
+	if !ok {
+		return fmt.Errorf("expected symbol, got %T", v)
+	}
+	switch symbol.Val {
+	case "low":
+		*l = 0
+	case "high":
+		*l = 1
+	default:
+		return fmt.Errorf("unknown level %s", symbol.Val)
+	}
+	return nil
+}
+
+func newDocument() document {
+	return document{
+		Title:    "line 1\nline 2",
+		Quote:    `say "hi" \ bye`,
+		JSON:     `{"a": 1}`,
+		Ratio:    0.1,
+		Whole:    2,
+		Enabled:  true,
+		Tags:     []string{"a", "b"},
+		Data:     []byte("hi"),
+		Labels:   map[string]string{"env": "prod", "zone": "eu"},
+		Primary:  &endpoint{Host: "example.com", Port: 80},
+		Replicas: []endpoint{{Host: "r1"}, {Host: "r2", Port: 81}},
+		Created:  time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		Level:    1,
+	}
+}
+
+func TestMarshalRoundTrip(t *testing.T) {
+	doc := newDocument()
+	indented := func(v any) ([]byte, error) {
+		return MarshalIndent(v, "")
+	}
+	for _, marshal := range []func(any) ([]byte, error){Marshal, indented} {
+		b, err := marshal(doc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ast, err := READ(string(b), nil, nil)
+		if err != nil {
+			t.Fatalf("%s: %s", b, err)
+		}
+		// the value read back is written identically
+		again, err := marshal(ast)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(again, b) {
+			t.Fatalf("got %s, expected %s", again, b)
+		}
+		var back document
+		if err := Unmarshal(ast, &back); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(back, doc) {
+			t.Fatalf("got %+v, expected %+v", back, doc)
+		}
+	}
+}
+
+func TestMarshal(t *testing.T) {
+	b, err := Marshal(endpoint{Host: "example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != `{:host "example.com"}` {
+		t.Fatalf("unexpected %s", b)
+	}
+	point := types.NewRecordType("Point", types.NewKeyword("x"), types.NewKeyword("y"))
+	record, err := point.New(types.HashMap{Val: map[types.MalType]types.MalType{types.NewKeyword("y"): 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err = Marshal(record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != `«Point {:x nil :y 2}»` {
+		t.Fatalf("unexpected %s", b)
+	}
+	for _, v := range []any{math.NaN(), 1e300, func() {}, map[int]int{1: 1}} {
+		if _, err := Marshal(v); err == nil {
+			t.Errorf("%T: expected an error", v)
+		}
+	}
+}
+
+func TestEncoder(t *testing.T) {
+	var buf bytes.Buffer
+	encoder := NewEncoder(&buf)
+	encoder.SetIndent(";; ")
+	if err := encoder.Encode(map[string]any{
+		"servers": []endpoint{{Host: "a", Port: 1}, {Host: "b"}},
+		"name":    "main",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	expected := strings.Join([]string{
+		`{"name" "main"`,
+		`;;  "servers" [{:host "a" :port 1}`,
+		`;;             {:host "b"}]}`,
+		``,
+	}, "\n")
+	if buf.String() != expected {
+		t.Fatalf("got\n%s\nexpected\n%s", buf.String(), expected)
+	}
+}
+
+func ExampleMarshalIndent() {
+	type Server struct {
+		Host string   `lisp:"host"`
+		Port int      `lisp:"port,omitempty"`
+		Tags []string `lisp:"tags,omitempty"`
+	}
+	type Config struct {
+		Sessions int      `lisp:"sessions"`
+		Servers  []Server `lisp:"servers"`
+	}
+	b, _ := MarshalIndent(Config{
+		Sessions: 10,
+		Servers:  []Server{{Host: "localhost", Port: 8080, Tags: []string{"main"}}, {Host: "example.com"}},
+	}, "")
+	fmt.Println(string(b))
+
+	// Output:
+	// {:servers [{:host "localhost"
+	//             :port 8080
+	//             :tags ["main"]}
+	//            {:host "example.com"}]
+	//  :sessions 10}
+}
@@ -22,9 +22,9 @@ type LispMarshalExample struct {
 
 func (lec LispMarshalExample) MarshalHashMap() (types.MalType, error) {
 	return types.HashMap{
-		Val: map[string]types.MalType{
-			"ʞa": lec.Val.A,
-			"ʞb": lec.Val.B,
+		Val: map[types.MalType]types.MalType{
+			types.NewKeyword("a"): lec.Val.A,
+			types.NewKeyword("b"): lec.Val.B,
 		},
 	}, nil
 }
@@ -40,8 +40,8 @@ func new_marshalexample() (types.MalType, error) {
 func (lec LispMarshalExampleFactory) FromHashMap(_hm types.MalType) (types.MalType, error) {
 	hm := _hm.(types.HashMap)
 	ex := MarshalExample{
-		A: hm.Val["ʞa"].(int),
-		B: hm.Val["ʞb"].(string),
+		A: hm.Val[types.NewKeyword("a")].(int),
+		B: hm.Val[types.NewKeyword("b")].(string),
 	}
 	return LispMarshalExample{ex}, nil
 }
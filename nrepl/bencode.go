@@ -0,0 +1,153 @@
+package nrepl
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+)
+
+// bencode values are decoded as int64, string, []any and map[string]any
+
+// decode reads a bencoded value from r
+func decode(r *bufio.Reader) (any, error) {
+	c, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case c == 'i':
+		s, err := r.ReadString('e')
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		n, err := strconv.ParseInt(s[:len(s)-1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("bencode: invalid integer %q", s[:len(s)-1])
+		}
+		return n, nil
+	case c == 'l':
+		list := []any{}
+		for {
+			if end, err := atEnd(r); err != nil {
+				return nil, err
+			} else if end {
+				return list, nil
+			}
+			item, err := decode(r)
+			if err != nil {
+				return nil, unexpectedEOF(err)
+			}
+			list = append(list, item)
+		}
+	case c == 'd':
+		dict := map[string]any{}
+		for {
+			if end, err := atEnd(r); err != nil {
+				return nil, err
+			} else if end {
+				return dict, nil
+			}
+			key, err := decode(r)
+			if err != nil {
+				return nil, unexpectedEOF(err)
+			}
+			k, ok := key.(string)
+			if !ok {
+				return nil, fmt.Errorf("bencode: dictionary key must be a string, got %T", key)
+			}
+			value, err := decode(r)
+			if err != nil {
+				return nil, unexpectedEOF(err)
+			}
+			dict[k] = value
+		}
+	case c >= '0' && c <= '9':
+		s, err := r.ReadString(':')
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		length, err := strconv.Atoi(string(c) + s[:len(s)-1])
+		if err != nil || length < 0 {
+			return nil, fmt.Errorf("bencode: invalid string length %q", string(c)+s[:len(s)-1])
+		}
+		buf := make([]byte, length)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		return string(buf), nil
+	default:
+		return nil, fmt.Errorf("bencode: unexpected character %q", c)
+	}
+}
+
+// atEnd consumes the 'e' that terminates lists and dictionaries
+func atEnd(r *bufio.Reader) (bool, error) {
+	c, err := r.ReadByte()
+	if err != nil {
+		return false, unexpectedEOF(err)
+	}
+	if c == 'e' {
+		return true, nil
+	}
+	return false, r.UnreadByte()
+}
+
+func unexpectedEOF(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// encode appends the bencoding of v to buf. Dictionary keys are sorted as required by
+// the specification.
+func encode(buf []byte, v any) ([]byte, error) {
+	switch v := v.(type) {
+	case int:
+		return fmt.Appendf(buf, "i%de", v), nil
+	case int64:
+		return fmt.Appendf(buf, "i%de", v), nil
+	case bool:
+		if v {
+			return append(buf, "i1e"...), nil
+		}
+		return append(buf, "i0e"...), nil
+	case string:
+		return fmt.Appendf(buf, "%d:%s", len(v), v), nil
+	case []string:
+		buf = append(buf, 'l')
+		for _, item := range v {
+			buf = fmt.Appendf(buf, "%d:%s", len(item), item)
+		}
+		return append(buf, 'e'), nil
+	case []any:
+		buf = append(buf, 'l')
+		for _, item := range v {
+			var err error
+			if buf, err = encode(buf, item); err != nil {
+				return nil, err
+			}
+		}
+		return append(buf, 'e'), nil
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		buf = append(buf, 'd')
+		for _, k := range keys {
+			buf = fmt.Appendf(buf, "%d:%s", len(k), k)
+			var err error
+			if buf, err = encode(buf, v[k]); err != nil {
+				return nil, err
+			}
+		}
+		return append(buf, 'e'), nil
+	default:
+		return nil, fmt.Errorf("bencode: unsupported type %T", v)
+	}
+}
@@ -0,0 +1,460 @@
+// Package nrepl implements a network REPL server compatible with nREPL clients
+// (CIDER, Calva, rep, Conjure...), so a REPL can be attached to a running process
+// that embeds the interpreter.
+//
+// Messages are bencoded dictionaries exchanged over a stream (TCP or a unix socket).
+// The supported operations are:
+//   - clone, close and ls-sessions to manage sessions;
+//   - describe to list the supported operations;
+//   - eval and load-file to evaluate code (a value message is sent per top level form);
+//   - interrupt to cancel the evaluations of a session (through their context);
+//   - completions (and its legacy name complete) to complete symbols of the environment.
+//
+// The output of the evaluations (prn, println...) is sent to the client as out and err
+// messages of the session that requested the evaluation.
+//
+// All sessions evaluate on the environment given to [NewServer].
+package nrepl
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jig/lisp"
+	"github.com/jig/lisp/lisperror"
+	"github.com/jig/lisp/reader"
+	. "github.com/jig/lisp/types"
+)
+
+// Server is an nREPL server
+type Server struct {
+	// Context is the parent context of the evaluations (context.Background if nil).
+	// Cancelling it interrupts all running evaluations.
+	Context context.Context
+	// Timeout limits the duration of each evaluation (0 for no timeout)
+	Timeout time.Duration
+
+	ns        EnvType
+	mu        sync.Mutex
+	sessions  map[string]*session
+	listeners map[net.Listener]struct{}
+	conns     map[net.Conn]struct{}
+	closed    bool
+	wg        sync.WaitGroup
+}
+
+// session serializes the evaluations of a client and tracks them to be interrupted
+type session struct {
+	id      string
+	eval    sync.Mutex
+	mu      sync.Mutex
+	running map[string]context.CancelFunc // by message id
+}
+
+// NewServer creates a server that evaluates code on ns
+func NewServer(ns EnvType) *Server {
+	return &Server{
+		ns:        ns,
+		sessions:  map[string]*session{},
+		listeners: map[net.Listener]struct{}{},
+		conns:     map[net.Conn]struct{}{},
+	}
+}
+
+// ErrServerClosed is returned by Serve and ListenAndServe after Close
+var ErrServerClosed = errors.New("nrepl: server closed")
+
+// ListenAndServe listens on network ("tcp" or "unix") address and serves clients until
+// the server is closed
+func (s *Server) ListenAndServe(network, address string) error {
+	l, err := net.Listen(network, address)
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+// Serve accepts connections on l and serves each one on its own goroutine until the
+// server is closed. l is closed on return.
+func (s *Server) Serve(l net.Listener) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		l.Close()
+		return ErrServerClosed
+	}
+	s.listeners[l] = struct{}{}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.listeners, l)
+		s.mu.Unlock()
+		l.Close()
+	}()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+			if closed {
+				return ErrServerClosed
+			}
+			return err
+		}
+		s.mu.Lock()
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+		go func() {
+			s.ServeConn(conn)
+			s.mu.Lock()
+			delete(s.conns, conn)
+			s.mu.Unlock()
+		}()
+	}
+}
+
+// Close stops listening, closes the connections, interrupts the running evaluations
+// and waits for them to finish
+func (s *Server) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	for l := range s.listeners {
+		l.Close()
+	}
+	for conn := range s.conns {
+		conn.Close()
+	}
+	for _, sess := range s.sessions {
+		sess.interrupt("")
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+	return nil
+}
+
+// ServeConn serves a single client on rw until it is closed
+func (s *Server) ServeConn(rw io.ReadWriteCloser) error {
+	defer rw.Close()
+	t := &transport{w: rw}
+	r := bufio.NewReader(rw)
+	for {
+		v, err := decode(r)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		msg, ok := v.(map[string]any)
+		if !ok {
+			return fmt.Errorf("nrepl: message must be a dictionary, got %T", v)
+		}
+		s.handle(t, msg)
+	}
+}
+
+// transport writes the responses of a connection (which are written from several
+// goroutines)
+type transport struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (t *transport) send(msg map[string]any) {
+	buf, err := encode(nil, msg)
+	if err != nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.w.Write(buf)
+}
+
+// reply sends a response to req (with its id and session) with the given fields
+func (t *transport) reply(req map[string]any, fields map[string]any) {
+	if id, ok := req["id"].(string); ok {
+		fields["id"] = id
+	}
+	if session, ok := req["session"].(string); ok {
+		if _, ok := fields["session"]; !ok {
+			fields["session"] = session
+		}
+	}
+	t.send(fields)
+}
+
+func done(status ...string) []any {
+	result := make([]any, 0, len(status)+1)
+	for _, s := range status {
+		result = append(result, s)
+	}
+	return append(result, "done")
+}
+
+func str(msg map[string]any, key string) string {
+	s, _ := msg[key].(string)
+	return s
+}
+
+// ops are the supported operations (as reported by describe)
+var ops = []string{"clone", "close", "completions", "complete", "describe", "eval", "interrupt", "load-file", "ls-sessions"}
+
+func (s *Server) handle(t *transport, req map[string]any) {
+	op := str(req, "op")
+	switch op {
+	case "clone":
+		id := uuid.NewString()
+		s.mu.Lock()
+		s.sessions[id] = &session{id: id, running: map[string]context.CancelFunc{}}
+		s.mu.Unlock()
+		t.reply(req, map[string]any{"new-session": id, "status": done()})
+	case "close":
+		sess, ok := s.session(req)
+		if !ok {
+			t.reply(req, map[string]any{"status": done("error", "unknown-session")})
+			return
+		}
+		sess.interrupt("")
+		s.mu.Lock()
+		delete(s.sessions, sess.id)
+		s.mu.Unlock()
+		t.reply(req, map[string]any{"status": done("session-closed")})
+	case "ls-sessions":
+		s.mu.Lock()
+		ids := make([]any, 0, len(s.sessions))
+		for id := range s.sessions {
+			ids = append(ids, id)
+		}
+		s.mu.Unlock()
+		sort.Slice(ids, func(i, j int) bool { return ids[i].(string) < ids[j].(string) })
+		t.reply(req, map[string]any{"sessions": ids, "status": done()})
+	case "describe":
+		supported := map[string]any{}
+		for _, op := range ops {
+			supported[op] = map[string]any{}
+		}
+		t.reply(req, map[string]any{
+			"ops":      supported,
+			"versions": map[string]any{"lisp": map[string]any{"version-string": "jig/lisp"}},
+			"status":   done(),
+		})
+	case "eval":
+		s.evaluate(t, req, str(req, "code"), str(req, "file"))
+	case "load-file":
+		file := str(req, "file-path")
+		if file == "" {
+			file = str(req, "file-name")
+		}
+		s.evaluate(t, req, str(req, "file"), file)
+	case "interrupt":
+		sess, ok := s.session(req)
+		if !ok {
+			t.reply(req, map[string]any{"status": done("error", "unknown-session")})
+			return
+		}
+		if sess.interrupt(str(req, "interrupt-id")) {
+			t.reply(req, map[string]any{"status": done()})
+		} else {
+			t.reply(req, map[string]any{"status": done("session-idle")})
+		}
+	case "completions", "complete":
+		prefix := str(req, "prefix")
+		if prefix == "" {
+			prefix = str(req, "symbol")
+		}
+		t.reply(req, map[string]any{"completions": s.completions(prefix), "status": done()})
+	default:
+		t.reply(req, map[string]any{"op": op, "status": done("error", "unknown-op")})
+	}
+}
+
+// session returns the session of req (which must have been created with clone)
+func (s *Server) session(req map[string]any) (*session, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess, ok := s.sessions[str(req, "session")]
+	return sess, ok
+}
+
+// interrupt cancels the evaluation with the given message id (all the evaluations of
+// the session if id is empty). It returns false if there was nothing to interrupt.
+func (sess *session) interrupt(id string) bool {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	interrupted := false
+	for msgID, cancel := range sess.running {
+		if id == "" || id == msgID {
+			cancel()
+			interrupted = true
+		}
+	}
+	return interrupted
+}
+
+// evaluate evaluates code on a goroutine, so the connection can still receive
+// interrupts. Evaluations of the same session run one after the other.
+func (s *Server) evaluate(t *transport, req map[string]any, code, file string) {
+	ephemeral := ""
+	if _, ok := req["session"]; !ok {
+		// sessionless evaluations get a session of their own (so they can be interrupted)
+		ephemeral = uuid.NewString()
+		req["session"] = ephemeral
+		s.mu.Lock()
+		s.sessions[ephemeral] = &session{id: ephemeral, running: map[string]context.CancelFunc{}}
+		s.mu.Unlock()
+	}
+	sess, ok := s.session(req)
+	if !ok {
+		t.reply(req, map[string]any{"status": done("error", "unknown-session")})
+		return
+	}
+
+	parent := s.Context
+	if parent == nil {
+		parent = context.Background()
+	}
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if s.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(parent, s.Timeout)
+	} else {
+		ctx, cancel = context.WithCancel(parent)
+	}
+	msgID := str(req, "id")
+	sess.mu.Lock()
+	sess.running[msgID] = cancel
+	sess.mu.Unlock()
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer func() {
+			sess.mu.Lock()
+			delete(sess.running, msgID)
+			sess.mu.Unlock()
+			cancel()
+			if ephemeral != "" {
+				s.mu.Lock()
+				delete(s.sessions, ephemeral)
+				s.mu.Unlock()
+			}
+		}()
+		sess.eval.Lock()
+		defer sess.eval.Unlock()
+
+		ctx = WithOutput(ctx, &output{t: t, req: req, key: "out"}, &output{t: t, req: req, key: "err"})
+		if err := s.eval(ctx, t, req, code, file); err != nil {
+			if errors.Is(ctx.Err(), context.Canceled) && (parent.Err() == nil) {
+				t.reply(req, map[string]any{"status": done("interrupted")})
+				return
+			}
+			if file == "" {
+				file = "<nrepl>"
+			}
+			rendered := lisperror.Render(err, lisperror.RenderOptions{Sources: lisperror.FileSources{Fallback: lisperror.SourceMap{file: code}}})
+			t.reply(req, map[string]any{"err": rendered + "\n"})
+			t.reply(req, map[string]any{"ex": errorClass(err), "status": done("eval-error")})
+			return
+		}
+		t.reply(req, map[string]any{"status": done()})
+	}()
+}
+
+// eval evaluates each top level form of code and sends its value
+func (s *Server) eval(ctx context.Context, t *transport, req map[string]any, code, file string) error {
+	if file == "" {
+		file = "<nrepl>"
+	}
+	cst, err := reader.ReadCST(code, NewCursorFile(file))
+	if err != nil {
+		return err
+	}
+	placeholders, err := cst.Placeholders(s.ns)
+	if err != nil {
+		return err
+	}
+	for _, form := range cst.Forms() {
+		ast, err := form.AST(placeholders, s.ns)
+		if err != nil {
+			return err
+		}
+		result, err := lisp.EVAL(ctx, ast, s.ns)
+		if err != nil {
+			return err
+		}
+		t.reply(req, map[string]any{"value": lisp.PRINT(result), "ns": "user"})
+	}
+	return nil
+}
+
+// errorClass names the kind of error for the ex field of eval errors
+func errorClass(err error) string {
+	var lispErr lisperror.LispError
+	if errors.As(err, &lispErr) {
+		return "lisp-error"
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return "timeout"
+	}
+	return "go-error"
+}
+
+// output sends what is written to it as out or err messages of a request
+type output struct {
+	t   *transport
+	req map[string]any
+	key string
+}
+
+func (o *output) Write(p []byte) (int, error) {
+	o.t.reply(o.req, map[string]any{o.key: string(p)})
+	return len(p), nil
+}
+
+// completions returns the symbols of the environment starting with prefix
+func (s *Server) completions(prefix string) []any {
+	names := []string{}
+	for _, suffix := range s.ns.Symbols(nil, prefix) {
+		name := prefix + string(suffix)
+		if strings.HasPrefix(name, "_") {
+			// internal symbols (e.g. _PACKAGES_)
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	result := make([]any, 0, len(names))
+	for _, name := range names {
+		result = append(result, map[string]any{"candidate": name, "type": s.kind(name)})
+	}
+	return result
+}
+
+func (s *Server) kind(name string) string {
+	value, err := s.ns.Get(Symbol{Val: name})
+	if err != nil {
+		return "var"
+	}
+	switch v := value.(type) {
+	case MalFunc:
+		if v.IsMacro {
+			return "macro"
+		}
+		return "function"
+	case Func:
+		return "function"
+	default:
+		return "var"
+	}
+}
@@ -0,0 +1,249 @@
+package nrepl
+
+import (
+	"bufio"
+	"net"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jig/lisp/env"
+	"github.com/jig/lisp/lib/core/nscore"
+)
+
+func TestBencode(t *testing.T) {
+	values := []any{
+		int64(-42),
+		"",
+		"hello: world",
+		[]any{int64(1), "a", []any{}},
+		map[string]any{"op": "eval", "code": "(+ 1 2)", "nested": map[string]any{"list": []any{"x"}}},
+	}
+	for _, v := range values {
+		buf, err := encode(nil, v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := decode(bufio.NewReader(strings.NewReader(string(buf))))
+		if err != nil {
+			t.Fatalf("%s: %s", buf, err)
+		}
+		if !reflect.DeepEqual(got, v) {
+			t.Fatalf("got %#v, expected %#v", got, v)
+		}
+	}
+
+	buf, _ := encode(nil, map[string]any{"b": 1, "a": []string{"x"}})
+	if string(buf) != "d1:al1:xe1:bi1ee" {
+		t.Fatalf("unexpected encoding %s", buf)
+	}
+
+	for _, invalid := range []string{"i12", "l1:a", "5:abc", "d1:ai1e", "di1ei2ee", "x"} {
+		if _, err := decode(bufio.NewReader(strings.NewReader(invalid))); err == nil {
+			t.Fatalf("expected error decoding %q", invalid)
+		}
+	}
+}
+
+// client talks to a server over a connection
+type client struct {
+	t    *testing.T
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func newServer(t *testing.T, network, address string) (*Server, *client) {
+	ns := env.NewEnv()
+	if err := nscore.Load(ns); err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen(network, address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewServer(ns)
+	go s.Serve(l)
+	t.Cleanup(func() { s.Close() })
+
+	conn, err := net.Dial(network, l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, &client{t: t, conn: conn, r: bufio.NewReader(conn)}
+}
+
+func (c *client) send(msg map[string]any) {
+	c.t.Helper()
+	buf, err := encode(nil, msg)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	if _, err := c.conn.Write(buf); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+func (c *client) receive() map[string]any {
+	c.t.Helper()
+	c.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	v, err := decode(c.r)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	return v.(map[string]any)
+}
+
+// until receives the responses to id until the one with status done
+func (c *client) until(id string) []map[string]any {
+	c.t.Helper()
+	responses := []map[string]any{}
+	for {
+		msg := c.receive()
+		if msg["id"] != id {
+			c.t.Fatalf("unexpected response %v", msg)
+		}
+		responses = append(responses, msg)
+		if status, ok := msg["status"].([]any); ok && status[len(status)-1] == "done" {
+			return responses
+		}
+	}
+}
+
+func (c *client) clone() string {
+	c.t.Helper()
+	c.send(map[string]any{"op": "clone", "id": "clone"})
+	session, _ := c.until("clone")[0]["new-session"].(string)
+	if session == "" {
+		c.t.Fatal("no session")
+	}
+	return session
+}
+
+// summary joins the fields of the responses that show what the evaluation did
+func summary(responses []map[string]any) string {
+	parts := []string{}
+	for _, msg := range responses {
+		for _, key := range []string{"out", "value", "ex"} {
+			if v, ok := msg[key]; ok {
+				parts = append(parts, key+"="+strings.TrimSpace(v.(string)))
+			}
+		}
+		if status, ok := msg["status"].([]any); ok {
+			for _, s := range status {
+				parts = append(parts, "status="+s.(string))
+			}
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+func testEval(t *testing.T, network, address string) {
+	_, c := newServer(t, network, address)
+	session := c.clone()
+
+	c.send(map[string]any{"op": "eval", "id": "1", "session": session, "code": `(def x 40) (println "hello") (+ x 2)`})
+	responses := c.until("1")
+	const expected = "value=40 out=hello value=nil value=42 status=done"
+	if got := summary(responses); got != expected {
+		t.Fatalf("got %q, expected %q", got, expected)
+	}
+	for _, msg := range responses {
+		if msg["session"] != session {
+			t.Fatalf("response without session %v", msg)
+		}
+	}
+
+	c.send(map[string]any{"op": "eval", "id": "2", "session": session, "code": "(+ 1 undefined-symbol)"})
+	responses = c.until("2")
+	if got := summary(responses); got != "ex=lisp-error status=eval-error status=done" {
+		t.Fatalf("unexpected error responses %q", got)
+	}
+	if err, _ := responses[0]["err"].(string); !strings.Contains(err, "undefined-symbol") {
+		t.Fatalf("unexpected err %q", err)
+	}
+}
+
+func TestEvalTCP(t *testing.T) {
+	testEval(t, "tcp", "127.0.0.1:0")
+}
+
+func TestEvalUnixSocket(t *testing.T) {
+	testEval(t, "unix", filepath.Join(t.TempDir(), "nrepl.sock"))
+}
+
+func TestCompletionsAndDescribe(t *testing.T) {
+	_, c := newServer(t, "tcp", "127.0.0.1:0")
+	c.send(map[string]any{"op": "completions", "id": "1", "prefix": "str"})
+	completions := c.until("1")[0]["completions"].([]any)
+	found := false
+	for _, completion := range completions {
+		completion := completion.(map[string]any)
+		if !strings.HasPrefix(completion["candidate"].(string), "str") {
+			t.Fatalf("unexpected candidate %v", completion)
+		}
+		if completion["candidate"] == "str" {
+			found = completion["type"] == "function"
+		}
+	}
+	if !found {
+		t.Fatalf("str not found as a function on %v", completions)
+	}
+
+	c.send(map[string]any{"op": "describe", "id": "2"})
+	ops := c.until("2")[0]["ops"].(map[string]any)
+	for _, op := range []string{"eval", "interrupt", "completions"} {
+		if _, ok := ops[op]; !ok {
+			t.Fatalf("%s not described on %v", op, ops)
+		}
+	}
+
+	c.send(map[string]any{"op": "unknown", "id": "3"})
+	if got := summary(c.until("3")); got != "status=error status=unknown-op status=done" {
+		t.Fatalf("unexpected response %q", got)
+	}
+}
+
+func TestInterrupt(t *testing.T) {
+	_, c := newServer(t, "tcp", "127.0.0.1:0")
+	session := c.clone()
+
+	c.send(map[string]any{"op": "eval", "id": "1", "session": session, "code": `(println "sleeping") (sleep 10000)`})
+	if msg := c.receive(); msg["out"] != "sleeping\n" {
+		t.Fatalf("unexpected response %v", msg)
+	}
+	start := time.Now()
+	c.send(map[string]any{"op": "interrupt", "id": "2", "session": session, "interrupt-id": "1"})
+
+	// the responses of both messages might arrive in any order
+	responses := map[string][]map[string]any{}
+	got := map[string]string{}
+	for len(got) < 2 {
+		msg := c.receive()
+		id := msg["id"].(string)
+		responses[id] = append(responses[id], msg)
+		if _, ok := msg["status"]; ok {
+			got[id] = summary(responses[id])
+		}
+	}
+	if got["1"] != "value=nil status=interrupted status=done" {
+		t.Fatalf("unexpected eval response %q", got["1"])
+	}
+	if got["2"] != "status=done" {
+		t.Fatalf("unexpected interrupt response %q", got["2"])
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("interrupt took %s", elapsed)
+	}
+
+	// the session is still usable
+	c.send(map[string]any{"op": "eval", "id": "3", "session": session, "code": "(+ 1 2)"})
+	if got := summary(c.until("3")); got != "value=3 status=done" {
+		t.Fatalf("unexpected response %q", got)
+	}
+	c.send(map[string]any{"op": "interrupt", "id": "4", "session": session})
+	if got := summary(c.until("4")); got != "status=session-idle status=done" {
+		t.Fatalf("unexpected response %q", got)
+	}
+}
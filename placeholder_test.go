@@ -37,7 +37,7 @@ func TestPlaceholders(t *testing.T) {
 		str,
 		nil,
 		&HashMap{
-			Val: map[string]MalType{
+			Val: map[MalType]MalType{
 				"$0":      "hello",
 				"$1":      "{\"key\": \"value\"}",
 				"$NUMBER": 44,
@@ -653,10 +653,10 @@ func TestHashMapMarshalers(t *testing.T) {
 		if err != nil {
 			t.Fatal(err)
 		}
-		if goStruct.(HashMap).Val["ʞa"] != 1984 {
+		if goStruct.(HashMap).Val[NewKeyword("a")] != 1984 {
 			t.Fatal("no 1984")
 		}
-		if goStruct.(HashMap).Val["ʞb"] != "I am B" {
+		if goStruct.(HashMap).Val[NewKeyword("b")] != "I am B" {
 			t.Fatal("no B")
 		}
 	}
@@ -30,6 +30,8 @@ func Pr_str(obj types.MalType, print_readably bool) string {
 	// 	return tobj.LispPrint(Pr_str)
 	case types.List:
 		return Pr_list(tobj.Val, print_readably, "(", ")", " ")
+	case types.LazySeq:
+		return Pr_list(tobj.All(), print_readably, "(", ")", " ")
 	case types.Vector:
 		return Pr_list(tobj.Val, print_readably, "[", "]", " ")
 	case marshaler.HashMap:
@@ -47,9 +49,7 @@ func Pr_str(obj types.MalType, print_readably bool) string {
 		}
 		return "#{" + strings.Join(str_list, " ") + "}"
 	case string:
-		if strings.HasPrefix(tobj, "\u029e") {
-			return ":" + tobj[2:]
-		} else if print_readably {
+		if print_readably {
 			if strings.HasPrefix(tobj, `{"`) && strings.HasSuffix(tobj, `}`) {
 				return `¬` + strings.Replace(tobj, `¬`, `¬¬`, -1) + `¬`
 			} else {
@@ -62,6 +62,8 @@ func Pr_str(obj types.MalType, print_readably bool) string {
 		} else {
 			return tobj
 		}
+	case types.Keyword:
+		return tobj.String()
 	case types.Symbol:
 		return tobj.Val
 	case nil:
@@ -92,6 +94,14 @@ func Pr_str(obj types.MalType, print_readably bool) string {
 }
 
 func hashMapToString(tobj types.HashMap, print_readably bool) string {
+	if tobj.Record != nil {
+		// fields in declaration order, read back as records by the reader
+		str_list := make([]string, 0, len(tobj.Val)*2)
+		for _, field := range tobj.Record.Fields {
+			str_list = append(str_list, Pr_str(field, print_readably), Pr_str(tobj.Val[field], print_readably))
+		}
+		return "«" + tobj.Record.Name + " {" + strings.Join(str_list, " ") + "}»"
+	}
 	str_list := make([]string, 0, len(tobj.Val)*2)
 	for k, v := range tobj.Val {
 		str_list = append(str_list, Pr_str(k, print_readably))
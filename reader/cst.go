@@ -0,0 +1,428 @@
+package reader
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/jig/scanner"
+
+	"github.com/jig/lisp/lisperror"
+	"github.com/jig/lisp/printer"
+	. "github.com/jig/lisp/types"
+)
+
+// NodeKind is the kind of a concrete syntax tree [Node]
+type NodeKind int
+
+const (
+	NodeFile       NodeKind = iota // whole source code, children are top level forms and trivia
+	NodeWhitespace                 // spaces, tabs and new lines (trivia)
+	NodeComment                    // ; comments (trivia)
+	NodePreamble                   // ;; $NAME value placeholder lines and the ;; $MODULE header (trivia)
+	NodeAtom                       // symbols, numbers, strings, keywords, placeholders...
+	NodeList                       // ( ... )
+	NodeVector                     // [ ... ]
+	NodeHashMap                    // { ... }
+	NodeSet                        // #{ ... }
+	NodeExternal                   // « ... » Go constructors
+	NodePrefixed                   // ' ` ~ ~@ @ and ^ followed by its form(s)
+)
+
+// Span locates a node on the source code. Offsets are in bytes (End is exclusive), rows
+// and columns start at 1 and columns count characters (EndCol is exclusive).
+type Span struct {
+	Start, End       int
+	StartRow, EndRow int
+	StartCol, EndCol int
+}
+
+// Node is a node of the concrete syntax tree read by [ReadCST]. Unlike the AST read by
+// [Read_str] it keeps comments, whitespace and the preamble, so the source code is
+// rebuilt exactly by [Node.String].
+type Node struct {
+	Kind     NodeKind
+	Text     string  // text of leaves, opening delimiter of collections or prefix of prefixed forms
+	Close    string  // closing delimiter of collections
+	Children []*Node // forms and trivia of files, collections and prefixed forms
+	Span     Span
+
+	tokens []Token // tokens of the node as Read_str would see them (nil for trivia)
+}
+
+// IsTrivia returns true for nodes without meaning for the evaluator (whitespace, comments and preamble)
+func (n *Node) IsTrivia() bool {
+	switch n.Kind {
+	case NodeWhitespace, NodeComment, NodePreamble:
+		return true
+	default:
+		return false
+	}
+}
+
+// Forms returns the children that are not trivia
+func (n *Node) Forms() []*Node {
+	forms := make([]*Node, 0, len(n.Children))
+	for _, child := range n.Children {
+		if !child.IsTrivia() {
+			forms = append(forms, child)
+		}
+	}
+	return forms
+}
+
+// FormAt returns the form whose first token ends on row and (exclusive) column col, the
+// form a position of the AST read by [Read_str] points to (nil if none)
+func (n *Node) FormAt(row, col int) *Node {
+	if !n.IsTrivia() && n.Kind != NodeFile &&
+		n.Span.StartRow == row && n.Span.StartCol+utf8.RuneCountInString(n.Text) == col {
+		return n
+	}
+	for _, child := range n.Children {
+		if child.Span.StartRow > row {
+			break
+		}
+		if found := child.FormAt(row, col); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// String returns the source code of the node
+func (n *Node) String() string {
+	var sb strings.Builder
+	n.write(&sb)
+	return sb.String()
+}
+
+func (n *Node) write(sb *strings.Builder) {
+	sb.WriteString(n.Text)
+	for _, child := range n.Children {
+		child.write(sb)
+	}
+	sb.WriteString(n.Close)
+}
+
+// AST converts a form (or a file with a single form) to the AST that [Read_str] would read.
+// placeholderValues and ns are used as on [Read_str] (and might be nil).
+func (n *Node) AST(placeholderValues *HashMap, ns EnvType) (MalType, error) {
+	if n.Kind == NodeFile {
+		forms := n.Forms()
+		if len(forms) != 1 {
+			return nil, lisperror.NewLispError(errors.New("source code must contain a single form to be converted to AST"), nil)
+		}
+		return forms[0].AST(placeholderValues, ns)
+	}
+	if n.IsTrivia() {
+		return nil, errors.New("trivia nodes do not have AST")
+	}
+	if n.tokens == nil {
+		// built by FromAST: there are no tokens to reuse
+		return Read_str(n.String(), nil, placeholderValues, ns)
+	}
+	var tokens []Token
+	n.collectTokens(&tokens)
+	rdr := tokenReader{tokens: tokens}
+	return read_form(&rdr, placeholderValues, ns)
+}
+
+func (n *Node) collectTokens(tokens *[]Token) {
+	if n.IsTrivia() {
+		return
+	}
+	if len(n.tokens) > 0 {
+		*tokens = append(*tokens, n.tokens[0])
+	}
+	for _, child := range n.Children {
+		child.collectTokens(tokens)
+	}
+	if len(n.tokens) > 1 {
+		*tokens = append(*tokens, n.tokens[1])
+	}
+}
+
+var (
+	preambleRE = regexp.MustCompile(`^;; \$[\-\d\w]+\s(.+)`)
+	preambleKV = regexp.MustCompile(`^;; (\$[\-\d\w]+)\s(.+)`)
+)
+
+// Placeholders reads the values of the preamble of a file (see lisp.READWithPreamble)
+func (n *Node) Placeholders(ns EnvType) (*HashMap, error) {
+	placeholderMap := &HashMap{Val: map[MalType]MalType{}}
+	for _, child := range n.Children {
+		if child.Kind != NodePreamble {
+			continue
+		}
+		kv := preambleKV.FindStringSubmatch(child.Text)
+		if kv == nil || kv[1] == "$MODULE" {
+			continue
+		}
+		value, err := Read_str(kv[2], NewAnonymousCursorHere(child.Span.StartRow, 1), nil, ns)
+		if err != nil {
+			return nil, err
+		}
+		placeholderMap.Val[kv[1]] = value
+	}
+	return placeholderMap, nil
+}
+
+// ReadCST reads Lisp source code keeping comments, whitespace and preamble lines.
+// cursor might be nil, and it is used as on [Read_str] to name the module. Spans
+// refer to the source code as is, while the positions of the AST (see [Node.AST])
+// are the same ones [Read_str] would set.
+func ReadCST(source string, cursor *Position) (*Node, error) {
+	if cursor == nil {
+		cursor = NewAnonymousCursorHere(1, 1)
+	}
+	if cursor.Module == nil {
+		if matches := moduleNamePrefixRE.FindStringSubmatch(source); matches != nil {
+			cursor = NewCursorFile(matches[1])
+		}
+	}
+	leaves, err := scanCST(source, cursor)
+	if err != nil {
+		return nil, err
+	}
+	p := cstParser{leaves: leaves}
+	file := &Node{Kind: NodeFile}
+	for p.pos < len(p.leaves) {
+		node, err := p.parse()
+		if err != nil {
+			return nil, err
+		}
+		file.Children = append(file.Children, node)
+	}
+
+	// comments on top of the file (before any form) might be preamble lines
+	for _, child := range file.Children {
+		if !child.IsTrivia() {
+			break
+		}
+		if child.Kind == NodeComment && (preambleRE.MatchString(child.Text) || moduleNamePrefixRE.MatchString(child.Text)) {
+			child.Kind = NodePreamble
+		}
+	}
+	file.Span = Span{Start: 0, End: len(source), StartRow: 1, StartCol: 1}
+	if len(leaves) > 0 {
+		last := leaves[len(leaves)-1].Span
+		file.Span.EndRow, file.Span.EndCol = last.EndRow, last.EndCol
+	}
+	return file, nil
+}
+
+// scanCST returns the leaves of the tree (tokens and trivia) in source code order
+func scanCST(source string, cursor *Position) ([]*Node, error) {
+	var s scanner.Scanner
+	s.Init(strings.NewReader(source))
+	s.Mode = scanner.LispTokens &^ scanner.SkipComments
+	if cursor.Module != nil {
+		s.Filename = *cursor.Module
+	}
+
+	leaves := []*Node{}
+	prevEnd := scanner.Position{Offset: 0, Line: 1, Column: 1}
+	for tok := s.Scan(); tok != scanner.EOF; tok = s.Scan() {
+		if s.ErrorCount != 0 {
+			return nil, lisperror.NewLispError(errors.New("invalid token "+s.TokenText()), &Position{
+				Module:   cursor.Module,
+				BeginRow: s.Pos().Line,
+				BeginCol: s.Pos().Column - 1,
+				Row:      s.Pos().Line,
+				Col:      s.Pos().Column - 1,
+			})
+		}
+		start, end := s.Position, s.Pos()
+		if start.Offset > prevEnd.Offset {
+			leaves = append(leaves, &Node{
+				Kind: NodeWhitespace,
+				Text: source[prevEnd.Offset:start.Offset],
+				Span: Span{
+					Start: prevEnd.Offset, End: start.Offset,
+					StartRow: prevEnd.Line, StartCol: prevEnd.Column,
+					EndRow: start.Line, EndCol: start.Column,
+				},
+			})
+		}
+		leaf := &Node{
+			Kind: NodeAtom,
+			Text: s.TokenText(),
+			Span: Span{
+				Start: start.Offset, End: end.Offset,
+				StartRow: start.Line, StartCol: start.Column,
+				EndRow: end.Line, EndCol: end.Column,
+			},
+		}
+		if tok == scanner.Comment {
+			leaf.Kind = NodeComment
+		} else {
+			leaf.tokens = []Token{newToken(&s, tok, cursor, 0)}
+		}
+		leaves = append(leaves, leaf)
+		prevEnd = end
+	}
+	if prevEnd.Offset < len(source) {
+		leaves = append(leaves, &Node{
+			Kind: NodeWhitespace,
+			Text: source[prevEnd.Offset:],
+			Span: Span{
+				Start: prevEnd.Offset, End: len(source),
+				StartRow: prevEnd.Line, StartCol: prevEnd.Column,
+				EndRow: s.Pos().Line, EndCol: s.Pos().Column,
+			},
+		})
+	}
+	return leaves, nil
+}
+
+var closingDelimiters = map[string]string{
+	"(":  ")",
+	"[":  "]",
+	"{":  "}",
+	"#{": "}",
+	"«":  "»",
+}
+
+var collectionKinds = map[string]NodeKind{
+	"(":  NodeList,
+	"[":  NodeVector,
+	"{":  NodeHashMap,
+	"#{": NodeSet,
+	"«":  NodeExternal,
+}
+
+type cstParser struct {
+	leaves []*Node
+	pos    int
+}
+
+func (p *cstParser) errorAt(msg string, leaf *Node) error {
+	var token MalType
+	if leaf != nil && len(leaf.tokens) > 0 {
+		token = leaf.tokens[0]
+	}
+	return lisperror.NewLispError(errors.New(msg), token)
+}
+
+// parse returns the next node: a trivia leaf or a complete form
+func (p *cstParser) parse() (*Node, error) {
+	leaf := p.leaves[p.pos]
+	p.pos++
+	if leaf.IsTrivia() {
+		return leaf, nil
+	}
+	switch leaf.Text {
+	case ")", "]", "}", "»":
+		return nil, p.errorAt("unexpected '"+leaf.Text+"'", leaf)
+	case "(", "[", "{", "#{", "«":
+		return p.parseCollection(leaf)
+	case "'", "`", "~", "~@", "@":
+		return p.parsePrefixed(leaf, 1)
+	case "^":
+		return p.parsePrefixed(leaf, 2)
+	default:
+		return leaf, nil
+	}
+}
+
+func (p *cstParser) parseCollection(open *Node) (*Node, error) {
+	closing := closingDelimiters[open.Text]
+	node := &Node{
+		Kind:   collectionKinds[open.Text],
+		Text:   open.Text,
+		Close:  closing,
+		Span:   open.Span,
+		tokens: open.tokens,
+	}
+	for {
+		if p.pos >= len(p.leaves) {
+			return nil, p.errorAt("expected '"+closing+"', got EOF", open)
+		}
+		leaf := p.leaves[p.pos]
+		if leaf.Kind == NodeAtom && leaf.Text == closing {
+			p.pos++
+			node.tokens = append(node.tokens, leaf.tokens...)
+			node.Span.End, node.Span.EndRow, node.Span.EndCol = leaf.Span.End, leaf.Span.EndRow, leaf.Span.EndCol
+			return node, nil
+		}
+		child, err := p.parse()
+		if err != nil {
+			return nil, err
+		}
+		node.Children = append(node.Children, child)
+	}
+}
+
+func (p *cstParser) parsePrefixed(prefix *Node, forms int) (*Node, error) {
+	node := &Node{
+		Kind:   NodePrefixed,
+		Text:   prefix.Text,
+		Span:   prefix.Span,
+		tokens: prefix.tokens,
+	}
+	for forms > 0 {
+		if p.pos >= len(p.leaves) {
+			return nil, p.errorAt("read_form underflow", prefix)
+		}
+		child, err := p.parse()
+		if err != nil {
+			return nil, err
+		}
+		node.Children = append(node.Children, child)
+		node.Span.End, node.Span.EndRow, node.Span.EndCol = child.Span.End, child.Span.EndRow, child.Span.EndCol
+		if !child.IsTrivia() {
+			forms--
+		}
+	}
+	return node, nil
+}
+
+// FromAST converts an AST to a concrete syntax tree with a single space between forms.
+// Hash map entries and set items are sorted to get a stable output. Spans are not set
+// and [Node.AST] reads the printed source code back.
+func FromAST(ast MalType) *Node {
+	switch ast := ast.(type) {
+	case List:
+		if len(ast.Val) == 2 {
+			if sym, ok := ast.Val[0].(Symbol); ok {
+				if prefix, ok := quotePrefixes[sym.Val]; ok {
+					return &Node{Kind: NodePrefixed, Text: prefix, Children: []*Node{FromAST(ast.Val[1])}}
+				}
+			}
+		}
+		return collectionFromAST(NodeList, "(", ")", ast.Val)
+	case Vector:
+		return collectionFromAST(NodeVector, "[", "]", ast.Val)
+	case HashMap:
+		keys := SortedKeys(ast.Val)
+		items := make([]MalType, 0, 2*len(keys))
+		for _, k := range keys {
+			items = append(items, k, ast.Val[k])
+		}
+		return collectionFromAST(NodeHashMap, "{", "}", items)
+	case Set:
+		return collectionFromAST(NodeSet, "#{", "}", SortedKeys(ast.Val))
+	default:
+		return &Node{Kind: NodeAtom, Text: printer.Pr_str(ast, true)}
+	}
+}
+
+var quotePrefixes = map[string]string{
+	"quote":          "'",
+	"quasiquote":     "`",
+	"unquote":        "~",
+	"splice-unquote": "~@",
+	"deref":          "@",
+}
+
+func collectionFromAST(kind NodeKind, open, close string, items []MalType) *Node {
+	node := &Node{Kind: kind, Text: open, Close: close}
+	for i, item := range items {
+		if i > 0 {
+			node.Children = append(node.Children, &Node{Kind: NodeWhitespace, Text: " "})
+		}
+		node.Children = append(node.Children, FromAST(item))
+	}
+	return node
+}
@@ -0,0 +1,144 @@
+package reader_test
+
+import (
+	"testing"
+
+	"github.com/jig/lisp/env"
+	"github.com/jig/lisp/lib/call"
+	"github.com/jig/lisp/lib/core/nscore"
+	"github.com/jig/lisp/printer"
+	"github.com/jig/lisp/reader"
+	"github.com/jig/lisp/types"
+)
+
+const cstSource = `;; $NAME "world"
+;; $N 3
+
+; greets someone
+(defn greet [name] ; inline comment
+  (str "hello, " name))   
+
+^{:a 1} [1 2.5 :kw "s" 'q ` + "`(a ~b ~@c)" + ` @at #{:b :a} {:x $N}]
+(greet $NAME) ;; trailing
+«example 33 "hi"»
+`
+
+func TestCSTRoundTrip(t *testing.T) {
+	ns := env.NewEnv()
+	if err := nscore.Load(ns); err != nil {
+		t.Fatal(err)
+	}
+	call.Call(ns, new_example)
+
+	cst, err := reader.ReadCST(cstSource, types.NewCursorFile(t.Name()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cst.String() != cstSource {
+		t.Fatalf("round trip mismatch:\n%q\n%q", cst.String(), cstSource)
+	}
+
+	preamble := 0
+	for _, child := range cst.Children {
+		if child.Kind == reader.NodePreamble {
+			preamble++
+		}
+	}
+	if preamble != 2 {
+		t.Fatalf("expected 2 preamble lines, got %d", preamble)
+	}
+	placeholders, err := cst.Placeholders(ns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if placeholders.Val["$N"] != 3 || placeholders.Val["$NAME"] != "world" {
+		t.Fatalf("unexpected placeholders %v", placeholders.Val)
+	}
+
+	forms := cst.Forms()
+	if len(forms) != 4 {
+		t.Fatalf("expected 4 forms, got %d", len(forms))
+	}
+	if forms[0].Kind != reader.NodeList || forms[0].Span.StartRow != 5 || forms[0].Span.EndRow != 6 {
+		t.Fatalf("unexpected first form %+v", forms[0].Span)
+	}
+	if forms[3].Kind != reader.NodeExternal {
+		t.Fatalf("expected external form, got %v", forms[3].Kind)
+	}
+
+	// every form must convert to the AST Read_str returns
+	for _, form := range forms {
+		ast, err := form.AST(placeholders, ns)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected, err := reader.Read_str(cstSource[form.Span.Start:form.Span.End], nil, placeholders, ns)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !types.Equal_Q(ast, expected) {
+			t.Fatalf("AST mismatch: %s != %s", printer.Pr_str(ast, true), printer.Pr_str(expected, true))
+		}
+	}
+	ast, err := forms[0].AST(placeholders, ns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pos := ast.(types.List).Cursor; pos.BeginRow != 5 || *pos.Module != t.Name() {
+		t.Fatalf("unexpected position %v", pos)
+	}
+}
+
+func TestCSTFromAST(t *testing.T) {
+	ast, err := reader.Read_str(`(let [a 'x] {:b #{"z" "y"} :a [1 "s"]})`, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cst := reader.FromAST(ast)
+	const expected = `(let [a 'x] {:a [1 "s"] :b #{"y" "z"}})`
+	if cst.String() != expected {
+		t.Fatalf("got %s, expected %s", cst.String(), expected)
+	}
+	ast2, err := cst.AST(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !types.Equal_Q(ast, ast2) {
+		t.Fatalf("%s != %s", printer.Pr_str(ast, true), printer.Pr_str(ast2, true))
+	}
+}
+
+func TestCSTErrors(t *testing.T) {
+	for _, source := range []string{"(a b", "a)", "[1 2}", "'"} {
+		if _, err := reader.ReadCST(source, nil); err == nil {
+			t.Errorf("%q: expected error", source)
+		}
+	}
+}
+
+func TestModuleRows(t *testing.T) {
+	// the header of embedded modules is their first line
+	cst, err := reader.ReadCST(";; $MODULE header-x\n\n(a)", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ast, err := cst.Forms()[0].AST(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pos := ast.(types.List).Cursor; *pos.Module != "header-x" || pos.BeginRow != 3 {
+		t.Fatalf("unexpected position %s", pos)
+	}
+
+	// load-file wraps files on a do form that is not part of them
+	ast, err = reader.Read_module("f.lisp", "(a)\n(b)", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pos := ast.(types.List).Val[1].(types.List).Cursor; *pos.Module != "f.lisp" || pos.BeginRow != 1 || pos.BeginCol != 2 {
+		t.Fatalf("unexpected position %s", pos)
+	}
+	if pos := ast.(types.List).Val[2].(types.List).Cursor; pos.BeginRow != 2 || pos.BeginCol != 2 {
+		t.Fatalf("unexpected position %s", pos)
+	}
+}
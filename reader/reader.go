@@ -41,7 +41,8 @@ func (tr *tokenReader) peek() *Token {
 	return &tr.tokens[tr.position]
 }
 
-func tokenize(sourceCode string, cursor *Position) ([]Token, error) {
+// tokenize returns the tokens of sourceCode, adding rowOffset to their rows
+func tokenize(sourceCode string, cursor *Position, rowOffset int) ([]Token, error) {
 	result := make([]Token, 0, 1)
 
 	var s scanner.Scanner
@@ -54,28 +55,35 @@ func tokenize(sourceCode string, cursor *Position) ([]Token, error) {
 		if s.ErrorCount != 0 {
 			return nil, lisperror.NewLispError(fmt.Errorf("invalid token %s", s.TokenText()), &Position{
 				Module:   cursor.Module,
-				BeginRow: s.Pos().Line,
+				BeginRow: s.Pos().Line + rowOffset,
 				BeginCol: s.Pos().Column - 1,
-				Row:      s.Pos().Line,
+				Row:      s.Pos().Line + rowOffset,
 				Col:      s.Pos().Column - 1,
 			})
 		}
-		tokenString := s.TokenText()
-		result = append(result, Token{
-			Value: tokenString,
-			Type:  tok,
-			Cursor: Position{
-				Module:   cursor.Module,
-				BeginRow: s.Pos().Line,
-				BeginCol: s.Pos().Column,
-				Row:      s.Pos().Line,
-				Col:      s.Pos().Column + s.Pos().Offset,
-			},
-		})
+		result = append(result, newToken(&s, tok, cursor, rowOffset))
 	}
 	return result, nil
 }
 
+// newToken returns the token just scanned by s
+func newToken(s *scanner.Scanner, tok rune, cursor *Position, rowOffset int) Token {
+	return Token{
+		Value: s.TokenText(),
+		Type:  tok,
+		Cursor: Position{
+			Module:   cursor.Module,
+			BeginRow: s.Pos().Line + rowOffset,
+			BeginCol: s.Pos().Column,
+			Row:      s.Pos().Line + rowOffset,
+			Col:      s.Pos().Column + s.Pos().Offset,
+		},
+	}
+}
+
+// unescape replaces the escape sequences of strings
+var unescape = strings.NewReplacer(`\\`, `\`, `\"`, `"`, `\n`, "\n")
+
 func read_atom(rdr *tokenReader) (MalType, error) {
 	tokenStruct := rdr.next()
 	if tokenStruct == nil {
@@ -91,13 +99,7 @@ func read_atom(rdr *tokenReader) (MalType, error) {
 		return int(i), nil
 	case scanner.String:
 		str := (*token)[1 : len(*token)-1]
-		return strings.Replace(
-			strings.Replace(
-				strings.Replace(
-					strings.Replace(str, `\\`, "\u029e", -1),
-					`\"`, `"`, -1),
-				`\n`, "\n", -1),
-			"\u029e", "\\", -1), nil
+		return unescape.Replace(str), nil
 	case scanner.RawString:
 		if *token == "¬" {
 			return nil, lisperror.NewLispError(errors.New("expected '¬', got EOF"), tokenStruct.GetPosition())
@@ -171,20 +173,19 @@ func read_external(rdr *tokenReader, placeholderValues *HashMap, ns EnvType) (Ma
 	args := lst.(List).Val
 	// cursor := lst.(List).Cursor
 	symbol := Symbol{Val: "new-" + args[0].(Symbol).Val}
+	if ns == nil {
+		return nil, fmt.Errorf("%s: no environment to read «%s» values", symbol.Val, args[0].(Symbol).Val)
+	}
 	constructor, err := ns.Get(symbol)
 	if err != nil {
 		return nil, err
 	}
 
-	fnConstructor, ok := constructor.(Func)
-	if !ok {
+	// Go constructors and Lisp ones (e.g. of records)
+	if !Callable_Q(constructor) {
 		return nil, fmt.Errorf("attempt to call non-function (was of type %T)", constructor)
 	}
-	typedValue, err := fnConstructor.Fn(context.Background(), args[1:])
-	if err != nil {
-		return nil, err
-	}
-	return typedValue, nil
+	return Apply(context.Background(), constructor, args[1:])
 }
 
 func read_vector(rdr *tokenReader, placeholderValues *HashMap, ns EnvType) (MalType, error) {
@@ -326,7 +327,19 @@ func Read_str(str string, cursor *Position, placeholderValues *HashMap, ns ...En
 			cursor = NewCursorFile(matches[1])
 		}
 	}
-	tokens, err := tokenize(str, cursor)
+	return read_str(str, cursor, 0, placeholderValues, ns...)
+}
+
+// Read_module reads the source code of the file (or module) name as load-file does:
+// wrapped on a (do ...) form, so all its forms are read. Positions refer to the rows and
+// columns of source, as the wrapping is not part of it.
+func Read_module(name, source string, placeholderValues *HashMap, ns ...EnvType) (MalType, error) {
+	// the (do is on a row of its own, before the first row of source
+	return read_str("(do\n"+source+"\n)", NewCursorFile(name), -1, placeholderValues, ns...)
+}
+
+func read_str(str string, cursor *Position, rowOffset int, placeholderValues *HashMap, ns ...EnvType) (MalType, error) {
+	tokens, err := tokenize(str, cursor, rowOffset)
 	if err != nil {
 		return nil, err
 	}
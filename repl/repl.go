@@ -51,6 +51,9 @@ func Execute(ctx context.Context, repl_env types.EnvType) error {
 		lines = append(lines, line)
 		completeLine := strings.Join(lines, "\n")
 
+		if code, ok := command(completeLine); ok {
+			completeLine = code
+		}
 		out, err := lisp.REPL(ctx, repl_env, completeLine, types.NewCursorFile("REPL"))
 		if err != nil {
 			if err.Error() == "<empty line>" {
@@ -62,14 +65,11 @@ func Execute(ctx context.Context, repl_env types.EnvType) error {
 			}
 			lines = []string{}
 			l.SetPrompt("\033[32m»\033[0m ")
-			switch err := err.(type) {
-			case interface{ ErrorValue() types.MalType }:
-				fmt.Printf("\033[31mLisp Error:\033[0m %s\n", lisp.PRINT(err.ErrorValue()))
-				continue
-			default:
-				fmt.Printf("Error: %s\n", err)
-				continue
-			}
+			fmt.Println(lisperror.Render(err, lisperror.RenderOptions{
+				Sources: lisperror.FileSources{Fallback: lisperror.SourceMap{"REPL": completeLine}},
+				Color:   true,
+			}))
+			continue
 		}
 		lines = []string{}
 		l.SetPrompt("\033[32m»\033[0m ")
@@ -77,6 +77,17 @@ func Execute(ctx context.Context, repl_env types.EnvType) error {
 	}
 }
 
+// command translates the REPL commands into Lisp code:
+//
+//	:doc name    prints the documentation of name, as (doc name)
+func command(line string) (string, bool) {
+	name, ok := strings.CutPrefix(line, ":doc ")
+	if !ok || strings.TrimSpace(name) == "" {
+		return "", false
+	}
+	return "(doc " + strings.TrimSpace(name) + ")", true
+}
+
 func multiLine(err error) bool {
 	if lerr, ok := err.(lisperror.LispError); ok {
 		switch typedLispError := lerr.ErrorValue().(type) {
@@ -32,3 +32,19 @@ func TestMultilineFail(t *testing.T) {
 		}
 	}
 }
+
+func TestCommand(t *testing.T) {
+	for line, expected := range map[string]string{
+		":doc map":       "(doc map)",
+		":doc  defn  ":   "(doc defn)",
+		":doc":           "",
+		":docs":          "",
+		"(doc map)":      "",
+		`(str ":doc a")`: "",
+	} {
+		code, ok := command(line)
+		if code != expected || ok != (expected != "") {
+			t.Fatalf("%q: got %q (%v), expected %q", line, code, ok, expected)
+		}
+	}
+}
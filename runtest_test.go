@@ -15,6 +15,8 @@ import (
 	"github.com/jig/lisp/lib/concurrent"
 	"github.com/jig/lisp/lib/core"
 	"github.com/jig/lisp/lib/coreextented"
+	"github.com/jig/lisp/lib/dispatch"
+	"github.com/jig/lisp/lib/schema"
 	"github.com/jig/lisp/lib/system"
 	"github.com/jig/lisp/types"
 )
@@ -120,6 +122,8 @@ func newEnv(fileName string) types.EnvType {
 	core.LoadInput(newenv)
 	concurrent.Load(newenv)
 	system.Load(newenv)
+	dispatch.Load(newenv)
+	schema.Load(newenv)
 	newenv.Set(types.Symbol{Val: "eval"}, types.Func{Fn: func(ctx context.Context, a []types.MalType) (types.MalType, error) {
 		return EVAL(ctx, a[0], newenv)
 	}})
@@ -140,6 +144,12 @@ func newEnv(fileName string) types.EnvType {
 	if _, err := REPL(ctx, newenv, concurrent.HeaderConcurrent(), types.NewCursorFile(fileName)); err != nil {
 		return nil
 	}
+	if _, err := REPL(ctx, newenv, dispatch.HeaderDispatch(), types.NewCursorFile(fileName)); err != nil {
+		return nil
+	}
+	if _, err := REPL(ctx, newenv, schema.HeaderSchema(), types.NewCursorFile(fileName)); err != nil {
+		return nil
+	}
 
 	return newenv
 }
@@ -90,3 +90,51 @@ func TestTimeoutOnTryCatch(t *testing.T) {
 		t.Fatalf("unexpected result %s", res)
 	}
 }
+
+func TestTimeoutOnNestedTryFinally(t *testing.T) {
+	ns := newEnv(t.Name())
+	ast, err := READ(`(do
+		(def log (atom []))
+		(try
+			(try
+				(try
+					(sleep 10000)
+					(finally (swap! log conj :inner)))
+				(finally (swap! log conj :middle)))
+			(catch e (swap! log conj :caught))
+			(finally (swap! log conj :outer)))
+		@log)`, types.NewCursorFile(t.Name()), ns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	res, err := EVAL(ctx, ast, ns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if PRINT(res) != "[:inner :middle :caught :outer]" {
+		t.Fatalf("unexpected result %s", PRINT(res))
+	}
+}
+
+func TestTryCleanupReserveFromLisp(t *testing.T) {
+	ns := newEnv(t.Name())
+	ast, err := READ(`(do
+		(def *try-cleanup-ms* 300)
+		(try
+			(sleep 10000)
+			(catch e (do (sleep 200) "slow cleanup"))))`, types.NewCursorFile(t.Name()), ns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 1000*time.Millisecond)
+	defer cancel()
+	res, err := EVAL(ctx, ast, ns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res != "slow cleanup" {
+		t.Fatalf("unexpected result %s", res)
+	}
+}
@@ -3,10 +3,12 @@ package lisp
 import (
 	"context"
 	_ "embed"
+	"strings"
 	"testing"
 
 	"github.com/jig/lisp/env"
 	"github.com/jig/lisp/lib/core"
+	"github.com/jig/lisp/lisperror"
 )
 
 //go:embed trycatchfinally_test.lisp
@@ -29,3 +31,22 @@ func TestTryCatchFinally(t *testing.T) {
 		t.Fatal(res)
 	}
 }
+
+func TestFinallyErrorIsSuppressed(t *testing.T) {
+	repl_env := env.NewEnv()
+	core.Load(repl_env)
+	_, err := REPL(context.Background(), repl_env, `(try (throw "primary") (finally (throw "cleanup")))`, nil)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	lispErr, ok := err.(lisperror.LispError)
+	if !ok {
+		t.Fatalf("unexpected error type %T", err)
+	}
+	if lispErr.ErrorValue() != "primary" {
+		t.Fatalf("unexpected primary error %v", lispErr.ErrorValue())
+	}
+	if len(lispErr.Suppressed) != 1 || !strings.Contains(lispErr.Suppressed[0].Error(), "cleanup") {
+		t.Fatalf("unexpected suppressed errors %v", lispErr.Suppressed)
+	}
+}
@@ -0,0 +1,68 @@
+package typecheck
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/jig/lisp"
+	"github.com/jig/lisp/lib/call"
+	"github.com/jig/lisp/types"
+)
+
+// EnableAssertions makes the functions defined by defn on env from now on assert the
+// type hints of their parameters and of their result on every call, failing with the
+// mismatch (e.g. "argument 1 (n) of 'f' is string, expected int")
+func EnableAssertions(env types.EnvType) error {
+	call.CallOverrideFN(env, "_assert-args", assertArgs)
+	call.CallOverrideFN(env, "_assert-return", assertReturn)
+	_, err := lisp.REPL(context.Background(), env, "(def *assert-types* true)", nil)
+	return err
+}
+
+// assertArgs returns fn asserting the hints on the arguments of its calls. params are
+// the parameters of fn; hints are those of the parameters but &.
+func assertArgs(fn types.MalFunc, name string, hints, params types.Vector) types.MalFunc {
+	names := slices.DeleteFunc(slices.Clone(params.Val), isAmpersand)
+	variadic := slices.IndexFunc(params.Val, isAmpersand)
+	fn.Args = &types.ArgsCheck{Check: func(ctx context.Context, args []types.MalType) error {
+		if variadic >= 0 && variadic <= len(args) {
+			// the rest of the arguments are bound as a list
+			args = append(slices.Clone(args[:variadic]), types.List{Val: args[variadic:]})
+		}
+		for i, hint := range hints.Val {
+			if hint == nil || i >= len(args) {
+				continue
+			}
+			if err := Assert(ctx, hint, args[i]); err != nil {
+				return fmt.Errorf("argument %d (%s) of '%s' %s", i+1, paramName(names[i]), name, mismatch(err))
+			}
+		}
+		return nil
+	}}
+	return fn
+}
+
+func isAmpersand(param types.MalType) bool {
+	sym, ok := param.(types.Symbol)
+	return ok && sym.Val == "&"
+}
+
+// assertReturn returns fn asserting the hint on the results of its calls
+func assertReturn(fn types.MalFunc, name string, hint types.MalType) types.MalFunc {
+	fn.Returns = &types.ReturnCheck{Check: func(ctx context.Context, v types.MalType) (types.MalType, error) {
+		if err := Assert(ctx, hint, v); err != nil {
+			return nil, fmt.Errorf("result of '%s' %s", name, mismatch(err))
+		}
+		return v, nil
+	}}
+	return fn
+}
+
+// mismatch returns the description of the error of Assert, after a value
+func mismatch(err error) string {
+	if err, ok := err.(kindError); ok {
+		return fmt.Sprintf("is %s, expected %s", err.actual, err.expected)
+	}
+	return "does not conform to its schema: " + err.Error()
+}
@@ -0,0 +1,406 @@
+package typecheck
+
+import (
+	"context"
+	"encoding"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/jig/lisp/bind"
+	"github.com/jig/lisp/lib/call"
+	"github.com/jig/lisp/lib/schema"
+	"github.com/jig/lisp/printer"
+	"github.com/jig/lisp/types"
+)
+
+// Kind is a set of kinds of Lisp values: the type of an expression is the set of the
+// kinds of the values it may evaluate to
+type Kind uint32
+
+const (
+	Nil Kind = 1 << iota
+	Bool
+	Int
+	Double
+	String
+	Keyword
+	Symbol
+	List
+	Vector
+	LazySeq
+	Map
+	Set
+	Fn
+	Other // Go values of other types (e.g. atoms)
+
+	Number = Int | Double
+	Seq    = List | Vector | LazySeq
+	Any    = Other<<1 - 1
+)
+
+// hints are the kinds named by type hints (e.g. ^int)
+var hints = map[string]Kind{
+	"any":     Any,
+	"nil":     Nil,
+	"boolean": Bool,
+	"int":     Int,
+	"double":  Double,
+	"number":  Number,
+	"string":  String,
+	"keyword": Keyword,
+	"symbol":  Symbol,
+	"list":    List,
+	"vector":  Vector,
+	"seq":     Seq,
+	"map":     Map,
+	"set":     Set,
+	"fn":      Fn,
+}
+
+// kindNames are the names of the kinds (and of the unions named by hints) on messages
+var kindNames = []struct {
+	kind Kind
+	name string
+}{
+	{Number, "number"}, {Seq, "seq"},
+	{Nil, "nil"}, {Bool, "boolean"}, {Int, "int"}, {Double, "double"}, {String, "string"},
+	{Keyword, "keyword"}, {Symbol, "symbol"}, {List, "list"}, {Vector, "vector"},
+	{LazySeq, "lazy-seq"}, {Map, "map"}, {Set, "set"}, {Fn, "fn"}, {Other, "go-value"},
+}
+
+func (k Kind) String() string {
+	if k == Any {
+		return "any"
+	}
+	names := []string{}
+	for _, kn := range kindNames {
+		if k&kn.kind == kn.kind && kn.kind != 0 {
+			names = append(names, kn.name)
+			k &^= kn.kind
+		}
+	}
+	return strings.Join(names, "|")
+}
+
+// Accepts reports whether a value of kinds k may be used where kinds expected are
+// required: only values that cannot be of the expected kinds are rejected
+func (k Kind) Accepts(actual Kind) bool {
+	return actual == 0 || k&actual != 0
+}
+
+// KindOf returns the kind of a value
+func KindOf(v types.MalType) Kind {
+	switch v.(type) {
+	case nil:
+		return Nil
+	case bool:
+		return Bool
+	case int:
+		return Int
+	case float32, float64:
+		return Double
+	case string:
+		return String
+	case types.Keyword:
+		return Keyword
+	case types.Symbol:
+		return Symbol
+	case types.List:
+		return List
+	case types.Vector:
+		return Vector
+	case types.LazySeq:
+		return LazySeq
+	case types.HashMap:
+		return Map
+	case types.Set:
+		return Set
+	case types.Func, types.MalFunc:
+		return Fn
+	default:
+		if types.Callable_Q(v) {
+			return Fn | Other
+		}
+		return Other
+	}
+}
+
+// ParseHint returns the kinds of a type hint: a name (e.g. int, as a symbol or a
+// keyword) or a map of a :schema (e.g. {:schema [:vector :int]})
+func ParseHint(hint types.MalType) (Kind, error) {
+	switch h := hint.(type) {
+	case nil:
+		return Any, nil
+	case types.Symbol:
+		if k, ok := hints[h.Val]; ok {
+			return k, nil
+		}
+	case types.Keyword:
+		if k, ok := hints[h.Name()]; ok {
+			return k, nil
+		}
+	case types.HashMap:
+		if form, ok := h.Val[types.NewKeyword("schema")]; ok {
+			return schemaKind(form), nil
+		}
+	}
+	return 0, fmt.Errorf("invalid type hint %s", printer.Pr_str(hint, true))
+}
+
+// schemaKind returns the kinds of the values of a schema (see package schema), from its
+// form as written, so schemas referring to other definitions are not evaluated
+func schemaKind(form types.MalType) Kind {
+	var kind string
+	var args []types.MalType
+	switch f := form.(type) {
+	case types.Keyword:
+		kind = f.Name()
+	case types.Vector:
+		if len(f.Val) == 0 {
+			return Any
+		}
+		k, ok := f.Val[0].(types.Keyword)
+		if !ok {
+			return Any
+		}
+		kind, args = k.Name(), f.Val[1:]
+		if len(args) > 0 && types.Q[types.HashMap](args[0]) && kind != "enum" {
+			args = args[1:]
+		}
+	case *schema.Schema:
+		return schemaKind(f.Form())
+	default:
+		return Any
+	}
+	switch kind {
+	case "re":
+		return String
+	case "map", "map-of":
+		return Map
+	case "vector", "tuple":
+		return Vector
+	case "sequential":
+		return Seq
+	case "maybe":
+		if len(args) == 1 {
+			return Nil | schemaKind(args[0])
+		}
+	case "and":
+		if len(args) > 0 {
+			return schemaKind(args[0])
+		}
+	case "or":
+		var k Kind
+		for _, arg := range args {
+			k |= schemaKind(arg)
+		}
+		return k
+	case "enum":
+		var k Kind
+		for _, arg := range args {
+			k |= KindOf(arg)
+		}
+		return k
+	default:
+		if k, ok := hints[kind]; ok {
+			return k
+		}
+	}
+	return Any
+}
+
+// Assert checks a value against a type hint, returning an error describing the
+// mismatch. Hints of schemas are validated by the schema.
+func Assert(ctx context.Context, hint, v types.MalType) error {
+	if hm, ok := hint.(types.HashMap); ok {
+		if form, ok := hm.Val[types.NewKeyword("schema")]; ok {
+			s, err := schema.Parse(form)
+			if err != nil {
+				return err
+			}
+			return s.Validate(ctx, v)
+		}
+	}
+	k, err := ParseHint(hint)
+	if err != nil {
+		return err
+	}
+	if !k.Accepts(KindOf(v)) {
+		return kindError{expected: k, actual: KindOf(v)}
+	}
+	return nil
+}
+
+type kindError struct {
+	expected, actual Kind
+}
+
+func (e kindError) Error() string {
+	return fmt.Sprintf("expected %s, got %s", e.expected, e.actual)
+}
+
+var (
+	optionsType         = reflect.TypeFor[call.Options]()
+	durationType        = reflect.TypeFor[time.Duration]()
+	unmarshalerType     = reflect.TypeFor[bind.Unmarshaler]()
+	textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+	errorType           = reflect.TypeFor[error]()
+	lispTypes           = map[reflect.Type]Kind{
+		reflect.TypeFor[types.Keyword](): Keyword,
+		reflect.TypeFor[types.Symbol]():  Symbol,
+		reflect.TypeFor[types.List]():    List,
+		reflect.TypeFor[types.Vector]():  Vector,
+		reflect.TypeFor[types.LazySeq](): LazySeq,
+		reflect.TypeFor[types.HashMap](): Map,
+		reflect.TypeFor[types.Set]():     Set,
+		reflect.TypeFor[types.Func]():    Fn,
+		reflect.TypeFor[types.MalFunc](): Fn,
+	}
+)
+
+// Signature is the type of a function: the kinds of its parameters and of its result
+type Signature struct {
+	Params   []Kind
+	Variadic bool // the last of Params is the kind of the rest of the arguments
+	Options  bool // arguments after Params are keyword options
+	Return   Kind
+}
+
+// Param returns the kinds of the argument i (starting at 0), Any if unknown
+func (s *Signature) Param(i int) Kind {
+	switch {
+	case i < len(s.Params) && !(s.Variadic && i == len(s.Params)-1):
+		return s.Params[i]
+	case s.Variadic && len(s.Params) > 0:
+		return s.Params[len(s.Params)-1]
+	default:
+		return Any
+	}
+}
+
+// FromGo returns the signature of a Go function registered with lib/call, from the
+// types of its parameters and results: the values the arguments are converted from
+// (e.g. ints and doubles for float64 parameters) and the values it returns
+func FromGo(sig *types.Signature) *Signature {
+	s := &Signature{Variadic: sig.Variadic, Return: Nil}
+	in := sig.In
+	if len(in) > 0 && isOptions(in[len(in)-1]) {
+		s.Options = true
+		in = in[:len(in)-1]
+	}
+	for i, t := range in {
+		if s.Variadic && i == len(in)-1 {
+			t = t.Elem()
+		}
+		s.Params = append(s.Params, accepted(t))
+	}
+	out := sig.Out
+	if len(out) > 0 && out[len(out)-1] == errorType {
+		out = out[:len(out)-1]
+	}
+	switch len(out) {
+	case 0:
+	case 1:
+		s.Return = returned(out[0])
+	default:
+		s.Return = Vector
+	}
+	return s
+}
+
+func isOptions(t reflect.Type) bool {
+	if t.Kind() != reflect.Struct {
+		return false
+	}
+	for i := range t.NumField() {
+		if f := t.Field(i); f.Anonymous && f.Type == optionsType {
+			return true
+		}
+	}
+	return false
+}
+
+// accepted returns the kinds of the values converted to a parameter of type t
+func accepted(t reflect.Type) Kind {
+	if k, ok := lispTypes[t]; ok {
+		return k
+	}
+	switch {
+	case t == durationType:
+		return String
+	case t.Kind() == reflect.Interface,
+		t.Implements(unmarshalerType), reflect.PointerTo(t).Implements(unmarshalerType),
+		t.Implements(textUnmarshalerType), reflect.PointerTo(t).Implements(textUnmarshalerType):
+		return Any
+	case isSequence(t):
+		return Seq | Nil
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return Bool
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return Int
+	case reflect.Float32, reflect.Float64:
+		return Number
+	case reflect.String:
+		return String | Keyword
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return String | Other | Nil
+		}
+		return Seq | Nil
+	case reflect.Array:
+		return Seq
+	case reflect.Map:
+		return Map | Nil
+	case reflect.Struct:
+		return Map | Other
+	case reflect.Pointer:
+		return Nil | Other | accepted(t.Elem())
+	default:
+		return Any
+	}
+}
+
+// returned returns the kinds of the results of type t
+func returned(t reflect.Type) Kind {
+	if k, ok := lispTypes[t]; ok {
+		return k
+	}
+	if isSequence(t) {
+		return LazySeq | Nil
+	}
+	switch t.Kind() {
+	case reflect.Interface:
+		return Any
+	case reflect.Bool:
+		return Bool
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return Int
+	case reflect.Float32, reflect.Float64:
+		return Double
+	case reflect.String:
+		return String
+	case reflect.Pointer, reflect.Slice, reflect.Map, reflect.Func, reflect.Chan:
+		return Other | Nil
+	default:
+		return Other
+	}
+}
+
+// isSequence reports whether lib/call converts values of type t from and to sequences:
+// receive channels and iterators
+func isSequence(t reflect.Type) bool {
+	if t.Kind() == reflect.Chan {
+		return t.ChanDir()&reflect.RecvDir != 0
+	}
+	if t.Kind() != reflect.Func || t.NumIn() != 1 || t.NumOut() != 0 {
+		return false
+	}
+	yield := t.In(0)
+	return yield.Kind() == reflect.Func && yield.NumIn() == 1 && yield.NumOut() == 1 && yield.Out(0).Kind() == reflect.Bool
+}
@@ -0,0 +1,650 @@
+// Package typecheck checks the type hints of Lisp code without running it.
+//
+// Functions defined by defn declare the types of their parameters and of their result
+// with optional hints: (defn f ^int [^int n ^{:schema [:vector :int]} xs] ...). The
+// checker infers the type of every expression, as the set of the kinds of the values
+// it may evaluate to (see Kind), from literals, hints, the Go signatures of library
+// functions (as reflected by lib/call) and the bodies of unannotated functions, and
+// reports calls whose arguments cannot be of the types of the parameters and functions
+// whose results cannot be of their declared types. Types that cannot be inferred are
+// any, so unannotated code is checked as far as its types are known. Macros are
+// expanded as lint does, evaluating the ones defined by the checked code on a sandbox
+// with no side effects and under a deadline (see [lint.Expander]).
+//
+// At run time, hints are asserted on every call of the functions defined once
+// EnableAssertions is called.
+package typecheck
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/jig/lisp/env"
+	"github.com/jig/lisp/lint"
+	"github.com/jig/lisp/lisperror"
+	"github.com/jig/lisp/types"
+)
+
+// RuleType is the rule of the findings of the checker (see [lint.Finding])
+const RuleType = "type"
+
+// CheckFile checks a Lisp source file. The file is read the same way load-file reads it
+// (see [lint.CheckFileWith]).
+func CheckFile(fileName string, ns types.EnvType) ([]lint.Finding, error) {
+	return lint.CheckFileWith(fileName, ns, Check)
+}
+
+// CheckSource reads and checks Lisp source code (see [lint.CheckSourceWith]). Read
+// errors are reported as findings.
+func CheckSource(source string, cursor *types.Position, ns types.EnvType) ([]lint.Finding, error) {
+	return lint.CheckSourceWith(source, cursor, ns, Check)
+}
+
+// Check checks an AST. ns is the environment the code is expected to run on, it is
+// used to resolve library functions and macros but it is not modified. Macros are
+// expanded for at most lint.ExpansionTimeout.
+func Check(ast types.MalType, ns types.EnvType) []lint.Finding {
+	ctx, cancel := context.WithTimeout(context.Background(), lint.ExpansionTimeout)
+	defer cancel()
+	return CheckContext(ctx, ast, ns)
+}
+
+// CheckContext is like Check, expanding macros until ctx is done
+func CheckContext(ctx context.Context, ast types.MalType, ns types.EnvType) []lint.Finding {
+	if ns == nil {
+		ns = env.NewEnv()
+	}
+	c := &checker{
+		ctx:      ctx,
+		ns:       ns,
+		expander: lint.NewExpander(ctx, ns),
+		globals:  map[string]*definition{},
+		library:  map[string]*inference{},
+	}
+	// expansion failures are reported by lint
+	ast = c.expander.Expand(ast, func(types.List, error) {})
+	c.collectDefinitions(ast)
+	c.infer(ast, nil)
+	sort.SliceStable(c.findings, func(i, j int) bool {
+		if c.findings[i].Line != c.findings[j].Line {
+			return c.findings[i].Line < c.findings[j].Line
+		}
+		return c.findings[i].Position.BeginCol < c.findings[j].Position.BeginCol
+	})
+	return c.findings
+}
+
+func newFinding(pos *types.Position, rule, message string) lint.Finding {
+	f := lint.Finding{
+		Position: pos,
+		Rule:     rule,
+		Severity: lint.SeverityError,
+		Message:  message,
+	}
+	if pos != nil {
+		f.File = pos.StringModule()
+		f.Line = pos.BeginRow
+		f.Column = pos.BeginCol
+	}
+	return f
+}
+
+type checker struct {
+	ctx      context.Context
+	ns       types.EnvType  // environment the code runs on
+	expander *lint.Expander // of the macros of ns and of the ones defined by the checked code
+	globals  map[string]*definition
+	library  map[string]*inference // results of the Lisp functions of ns, by name
+	findings []lint.Finding
+	module   *string
+	quiet    int // >0 while inferring the result of a function, checked on its definition
+}
+
+// definition is a symbol defined by the checked code
+type definition struct {
+	name  types.Symbol
+	value types.MalType // value form
+	fn    *function     // nil if the value is not a fn form
+	inference
+}
+
+// function is a fn form and its declared type
+type function struct {
+	params   []types.MalType // symbols, without &
+	body     []types.MalType
+	sig      Signature
+	declared bool // the result is declared (else it is inferred from the body)
+}
+
+// inference is the state of the inference of the type of a value or of a result
+type inference struct {
+	state int // notInferred, inferring or inferred
+	kind  Kind
+}
+
+const (
+	notInferred = iota
+	inferring
+	inferred
+)
+
+type scope struct {
+	names map[string]Kind
+	outer *scope
+}
+
+func newScope(outer *scope) *scope {
+	return &scope{names: map[string]Kind{}, outer: outer}
+}
+
+func (s *scope) lookup(name string) (Kind, bool) {
+	for ; s != nil; s = s.outer {
+		if k, ok := s.names[name]; ok {
+			return k, true
+		}
+	}
+	return 0, false
+}
+
+func (c *checker) report(form types.MalType, format string, args ...any) {
+	if c.quiet > 0 {
+		return
+	}
+	pos := lisperror.GetPosition(form)
+	if pos == nil || pos.Module == nil {
+		// generated by a macro: nothing to point to on the checked code
+		return
+	}
+	if c.module == nil {
+		c.module = pos.Module
+	}
+	if *pos.Module != *c.module {
+		// points to the macro definition, not to the checked code
+		return
+	}
+	c.findings = append(c.findings, newFinding(pos, RuleType, fmt.Sprintf(format, args...)))
+}
+
+func headName(form types.MalType) string {
+	if lst, ok := form.(types.List); ok && len(lst.Val) > 0 {
+		if sym, ok := lst.Val[0].(types.Symbol); ok {
+			return sym.Val
+		}
+	}
+	return ""
+}
+
+// collectDefinitions records the symbols defined anywhere on the checked code, as
+// functions might be referenced before they are defined
+func (c *checker) collectDefinitions(form types.MalType) {
+	lst, ok := form.(types.List)
+	if !ok {
+		if v, ok := form.(types.Vector); ok {
+			for _, item := range v.Val {
+				c.collectDefinitions(item)
+			}
+		}
+		return
+	}
+	switch headName(lst) {
+	case "quote", "quasiquote", "defmacro":
+		return
+	case "def":
+		if len(lst.Val) >= 3 {
+			if name, ok := lst.Val[1].(types.Symbol); ok {
+				c.globals[name.Val] = c.definition(name, lst.Val[2:len(lst.Val)-1], lst.Val[len(lst.Val)-1])
+			}
+		}
+	}
+	for _, item := range lst.Val {
+		c.collectDefinitions(item)
+	}
+}
+
+// definition returns the definition of name with the attributes (docstring and
+// attr-map, with the hints set by defn) of def
+func (c *checker) definition(name types.Symbol, attrs []types.MalType, value types.MalType) *definition {
+	d := &definition{name: name, value: value}
+	if headName(value) != "fn" || len(value.(types.List).Val) < 2 {
+		return d
+	}
+	params, err := types.GetSlice(value.(types.List).Val[1])
+	if err != nil {
+		return d
+	}
+	fn := &function{body: value.(types.List).Val[2:]}
+	for _, param := range params {
+		if sym, ok := param.(types.Symbol); ok && sym.Val == "&" {
+			continue
+		}
+		fn.params = append(fn.params, param)
+	}
+	fn.sig.Return = Any
+	var paramHints types.MalType
+	for _, attr := range attrs {
+		hm, ok := attr.(types.HashMap)
+		if !ok {
+			continue
+		}
+		paramHints = hm.Val[types.NewKeyword("param-types")]
+		if hint, ok := hm.Val[types.NewKeyword("return-type")]; ok {
+			k, err := ParseHint(unquote(hint))
+			if err != nil {
+				c.report(name, "%s of the result of '%s'", err, name.Val)
+				k = Any
+			}
+			fn.sig.Return = k
+			fn.declared = true
+		}
+	}
+	hints, _ := types.GetSlice(paramHints)
+	for i := range fn.params {
+		k := Any
+		if i < len(hints) {
+			var err error
+			if k, err = ParseHint(unquote(hints[i])); err != nil {
+				c.report(name, "%s of parameter '%s' of '%s'", err, paramName(fn.params[i]), name.Val)
+				k = Any
+			}
+		}
+		fn.sig.Params = append(fn.sig.Params, k)
+	}
+	d.fn = fn
+	return d
+}
+
+// unquote returns the hint of the form set by defn: symbols are quoted
+func unquote(form types.MalType) types.MalType {
+	if headName(form) == "quote" && len(form.(types.List).Val) == 2 {
+		return form.(types.List).Val[1]
+	}
+	return form
+}
+
+func paramName(param types.MalType) string {
+	if sym, ok := param.(types.Symbol); ok {
+		return sym.Val
+	}
+	return fmt.Sprint(param)
+}
+
+// infer returns the type of a form, reporting the type errors found on it
+func (c *checker) infer(form types.MalType, sc *scope) Kind {
+	switch f := form.(type) {
+	case types.Symbol:
+		return c.resolve(f, sc)
+	case types.List:
+		return c.inferList(f, sc)
+	case types.Vector:
+		for _, item := range f.Val {
+			c.infer(item, sc)
+		}
+		return Vector
+	case types.HashMap:
+		for _, item := range f.Val {
+			c.infer(item, sc)
+		}
+		return Map
+	default:
+		return KindOf(form)
+	}
+}
+
+func (c *checker) inferBody(body []types.MalType, sc *scope) Kind {
+	k := Nil
+	for _, form := range body {
+		k = c.infer(form, sc)
+	}
+	return k
+}
+
+func (c *checker) resolve(sym types.Symbol, sc *scope) Kind {
+	if k, ok := sc.lookup(sym.Val); ok {
+		return k
+	}
+	if d, ok := c.globals[sym.Val]; ok {
+		if d.fn != nil {
+			return Fn
+		}
+		return c.valueKind(d)
+	}
+	if c.ns.Find(sym) != nil {
+		if v, err := c.ns.Get(sym); err == nil {
+			return KindOf(v)
+		}
+	}
+	return Any
+}
+
+// valueKind returns the type of the value of a definition that is not a function
+func (c *checker) valueKind(d *definition) Kind {
+	switch d.state {
+	case inferring:
+		return Any
+	case inferred:
+		return d.kind
+	}
+	d.state = inferring
+	c.quiet++
+	d.kind = c.infer(d.value, nil)
+	c.quiet--
+	d.state = inferred
+	return d.kind
+}
+
+func (c *checker) inferList(lst types.List, sc *scope) Kind {
+	if len(lst.Val) == 0 {
+		return List
+	}
+	head, isSymbol := lst.Val[0].(types.Symbol)
+	if !isSymbol {
+		for _, item := range lst.Val {
+			c.infer(item, sc)
+		}
+		return Any
+	}
+	if _, local := sc.lookup(head.Val); !local {
+		if c.expander.IsMacro(head) {
+			// its expansion failed
+			return Any
+		}
+		switch head.Val {
+		case "def":
+			return c.inferDef(lst, sc)
+		case "let":
+			return c.inferLet(lst, sc)
+		case "fn":
+			c.inferFn(lst, sc)
+			return Fn
+		case "do":
+			return c.inferBody(lst.Val[1:], sc)
+		case "if":
+			if len(lst.Val) < 3 {
+				return Any
+			}
+			c.infer(lst.Val[1], sc)
+			k := c.infer(lst.Val[2], sc)
+			if len(lst.Val) > 3 {
+				return k | c.infer(lst.Val[3], sc)
+			}
+			return k | Nil
+		case "quote":
+			if len(lst.Val) == 2 {
+				return KindOf(lst.Val[1])
+			}
+			return Any
+		case "quasiquote":
+			if len(lst.Val) == 2 {
+				c.inferQuasiquote(lst.Val[1], sc)
+				return KindOf(lst.Val[1])
+			}
+			return Any
+		case "try":
+			return c.inferTry(lst, sc)
+		case "defmacro":
+			return Fn
+		case "macroexpand", "quasiquoteexpand":
+			return Any
+		case ".":
+			for _, item := range lst.Val[min(2, len(lst.Val)):] {
+				c.infer(item, sc)
+			}
+			return Any
+		}
+	}
+	return c.inferCall(head, lst, sc)
+}
+
+func (c *checker) inferDef(lst types.List, sc *scope) Kind {
+	if len(lst.Val) < 3 {
+		return Any
+	}
+	for _, attr := range lst.Val[2 : len(lst.Val)-1] {
+		c.infer(attr, sc)
+	}
+	value := lst.Val[len(lst.Val)-1]
+	if name, ok := lst.Val[1].(types.Symbol); ok && sc == nil {
+		if d, ok := c.globals[name.Val]; ok && d.fn != nil && d.value.(types.List).Cursor == lisperror.GetPosition(value) {
+			c.checkFunction(d)
+			return Fn
+		}
+	}
+	return c.infer(value, sc)
+}
+
+// checkFunction checks the body of a function defined by the checked code against the
+// types of its parameters and of its result
+func (c *checker) checkFunction(d *definition) {
+	fnScope := newScope(nil)
+	for i, param := range d.fn.params {
+		fnScope.names[paramName(param)] = d.fn.sig.Params[i]
+	}
+	k := c.inferBody(d.fn.body, fnScope)
+	if d.fn.declared && !d.fn.sig.Return.Accepts(k) {
+		c.report(d.name, "'%s' returns %s, declared %s", d.name.Val, k, d.fn.sig.Return)
+	}
+	if d.state != inferring {
+		d.state, d.kind = inferred, k
+	}
+}
+
+func (c *checker) inferLet(lst types.List, sc *scope) Kind {
+	if len(lst.Val) < 2 {
+		return Any
+	}
+	bindings, err := types.GetSlice(lst.Val[1])
+	if err != nil {
+		return Any
+	}
+	letScope := newScope(sc)
+	for i := 0; i+1 < len(bindings); i += 2 {
+		k := c.infer(bindings[i+1], letScope)
+		if sym, ok := bindings[i].(types.Symbol); ok {
+			letScope.names[sym.Val] = k
+		}
+	}
+	return c.inferBody(lst.Val[2:], letScope)
+}
+
+func (c *checker) inferFn(lst types.List, sc *scope) Kind {
+	if len(lst.Val) < 2 {
+		return Any
+	}
+	fnScope := newScope(sc)
+	params, err := types.GetSlice(lst.Val[1])
+	if err != nil {
+		return Any
+	}
+	for _, param := range params {
+		if sym, ok := param.(types.Symbol); ok {
+			fnScope.names[sym.Val] = Any
+		}
+	}
+	return c.inferBody(lst.Val[2:], fnScope)
+}
+
+func (c *checker) inferQuasiquote(form types.MalType, sc *scope) {
+	switch form := form.(type) {
+	case types.List:
+		switch headName(form) {
+		case "unquote", "splice-unquote":
+			c.inferBody(form.Val[1:], sc)
+			return
+		}
+		for _, item := range form.Val {
+			c.inferQuasiquote(item, sc)
+		}
+	case types.Vector:
+		for _, item := range form.Val {
+			c.inferQuasiquote(item, sc)
+		}
+	}
+}
+
+func (c *checker) inferTry(lst types.List, sc *scope) Kind {
+	body, k := Nil, Kind(0) // kinds of the body and of the catch clause
+	for _, form := range lst.Val[1:] {
+		switch headName(form) {
+		case "catch":
+			clause := form.(types.List)
+			catchScope := newScope(sc)
+			if len(clause.Val) >= 2 {
+				if sym, ok := clause.Val[1].(types.Symbol); ok {
+					catchScope.names[sym.Val] = Any
+				}
+			}
+			k |= c.inferBody(clause.Val[min(2, len(clause.Val)):], catchScope)
+		case "finally":
+			c.inferBody(form.(types.List).Val[1:], sc)
+		default:
+			body = c.infer(form, sc)
+		}
+	}
+	return body | k
+}
+
+func (c *checker) inferCall(head types.Symbol, lst types.List, sc *scope) Kind {
+	sig := c.signature(head, sc)
+	for i, arg := range lst.Val[1:] {
+		k := c.infer(arg, sc)
+		if sig == nil {
+			continue
+		}
+		if expected := sig.Param(i); !expected.Accepts(k) {
+			at := arg
+			if lisperror.GetPosition(arg) == nil {
+				at = lst
+			}
+			c.report(at, "argument %d of '%s' is %s, expected %s", i+1, head.Val, k, expected)
+		}
+	}
+	if sig == nil {
+		return Any
+	}
+	return sig.Return
+}
+
+// signature returns the type of the function called by name (nil if unknown)
+func (c *checker) signature(head types.Symbol, sc *scope) *Signature {
+	if _, local := sc.lookup(head.Val); local {
+		return nil
+	}
+	if d, ok := c.globals[head.Val]; ok {
+		if d.fn == nil {
+			return nil
+		}
+		sig := d.fn.sig
+		if !d.fn.declared {
+			sig.Return = c.result(d)
+		}
+		return &sig
+	}
+	if c.ns.Find(head) == nil {
+		return nil
+	}
+	v, err := c.ns.Get(head)
+	if err != nil {
+		return nil
+	}
+	switch fn := v.(type) {
+	case types.Func:
+		if fn.Signature == nil {
+			return nil
+		}
+		return FromGo(fn.Signature)
+	case types.MalFunc:
+		if fn.GetMacro() {
+			return nil
+		}
+		return c.librarySignature(head.Val, fn)
+	}
+	return nil
+}
+
+// result returns the type of the result of a function defined by the checked code
+// without a declared one, inferred from its body
+func (c *checker) result(d *definition) Kind {
+	switch d.state {
+	case inferring:
+		return Any
+	case inferred:
+		return d.kind
+	}
+	d.state = inferring
+	c.quiet++
+	fnScope := newScope(nil)
+	for i, param := range d.fn.params {
+		fnScope.names[paramName(param)] = d.fn.sig.Params[i]
+	}
+	d.kind = c.inferBody(d.fn.body, fnScope)
+	c.quiet--
+	d.state = inferred
+	return d.kind
+}
+
+// librarySignature returns the type of a Lisp function of the environment: the one
+// declared by its hints (see defn), its result inferred from its body if not declared
+func (c *checker) librarySignature(name string, fn types.MalFunc) *Signature {
+	params, err := types.GetSlice(fn.Params)
+	if err != nil {
+		return nil
+	}
+	sig := &Signature{Return: Any}
+	var paramHints, returnHint types.MalType
+	declared := false
+	if meta, ok := fn.Meta.(types.HashMap); ok {
+		paramHints = meta.Val[types.NewKeyword("param-types")]
+		returnHint, declared = meta.Val[types.NewKeyword("return-type")]
+	}
+	hints, _ := types.GetSlice(paramHints)
+	fnScope := newScope(nil)
+	i := 0
+	for _, param := range params {
+		sym, ok := param.(types.Symbol)
+		if !ok || sym.Val == "&" {
+			continue
+		}
+		k := Any
+		if i < len(hints) {
+			if hk, err := ParseHint(hints[i]); err == nil {
+				k = hk
+			}
+		}
+		sig.Params = append(sig.Params, k)
+		fnScope.names[sym.Val] = k
+		i++
+	}
+	if declared {
+		if k, err := ParseHint(returnHint); err == nil {
+			sig.Return = k
+		}
+		return sig
+	}
+
+	inf, ok := c.library[name]
+	if !ok {
+		inf = &inference{}
+		c.library[name] = inf
+	}
+	switch inf.state {
+	case inferring:
+		return sig
+	case inferred:
+		sig.Return = inf.kind
+		return sig
+	}
+	inf.state = inferring
+	lib := &checker{
+		ctx:      c.ctx,
+		ns:       fn.Env,
+		expander: lint.NewExpander(c.ctx, fn.Env),
+		globals:  map[string]*definition{},
+		library:  c.library,
+		quiet:    1,
+	}
+	inf.kind = lib.infer(lib.expander.Expand(fn.Exp, func(types.List, error) {}), fnScope)
+	inf.state = inferred
+	sig.Return = inf.kind
+	return sig
+}
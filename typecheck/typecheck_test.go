@@ -0,0 +1,166 @@
+package typecheck
+
+import (
+	"context"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jig/lisp"
+	"github.com/jig/lisp/env"
+	"github.com/jig/lisp/lib/core/nscore"
+	"github.com/jig/lisp/lint"
+	"github.com/jig/lisp/types"
+)
+
+func newEnv(t *testing.T) types.EnvType {
+	ns := env.NewEnv()
+	if err := nscore.Load(ns); err != nil {
+		t.Fatal(err)
+	}
+	return ns
+}
+
+func check(t *testing.T, source string) []lint.Finding {
+	findings, err := CheckSource(source, types.NewCursorFile(t.Name()), newEnv(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return findings
+}
+
+func messages(findings []lint.Finding) string {
+	result := []string{}
+	for _, f := range findings {
+		result = append(result, f.Message)
+	}
+	return strings.Join(result, "; ")
+}
+
+func TestCheck(t *testing.T) {
+	for _, testCase := range []struct {
+		name     string
+		source   string
+		messages string
+	}{
+		{"correct", `(do (defn f ^int [^int a ^{:schema [:vector :int]} b] (+ a (count b))) (f 1 [2]))`, ""},
+		{"unannotated", `(do (defn f [a] (g a)) (f "a"))`, ""},
+		{"go-argument", `(+ "a" 1)`, "argument 1 of '+' is string, expected int"},
+		{"go-result", `(+ (str 1) 1)`, "argument 1 of '+' is string, expected int"},
+		{"hinted-argument", `(do (defn f [^int a] a) (f "a"))`, "argument 1 of 'f' is string, expected int"},
+		{"schema-argument", `(do (defn f [^{:schema [:vector :int]} a] a) (f {:a 1}))`, "argument 1 of 'f' is map, expected vector"},
+		{"keyword-hint", `(do (defn f [^:string a] a) (f 1))`, "argument 1 of 'f' is int, expected string"},
+		{"union", `(do (defn f [^number a] a) (f (if true 1 2.5)))`, ""},
+		{"maybe", `(do (defn f [^int a] a) (f (if true 1)))`, ""},
+		{"declared-result", `(defn f ^string [^int a] (+ a 1))`, "'f' returns int, declared string"},
+		{"inferred-result", `(do (defn f [a] (str a)) (+ (f 1) 1))`, "argument 1 of '+' is string, expected int"},
+		{"forward-reference", `(do (defn g [] (+ (f 1) 1)) (defn f [a] (str a)))`, "argument 1 of '+' is string, expected int"},
+		{"recursion", `(defn f ^int [^int n] (if (< n 1) 0 (+ n (f (- n 1)))))`, ""},
+		{"global", `(do (def port "80") (+ port 1))`, "argument 1 of '+' is string, expected int"},
+		{"let", `(let [a "a" b (count a)] (+ a b))`, "argument 1 of '+' is string, expected int"},
+		{"local-shadows", `(do (defn f [^int a] a) (let [f str] (f "a")))`, ""},
+		{"library", `(+ (inc 1) (first [1]))`, ""},
+		{"macro", `(do (defn f [^int a] a) (when true (f "a")))`, "argument 1 of 'f' is string, expected int"},
+		{"invalid-hint", `(defn f [^integer a] a)`, "invalid type hint integer of parameter 'a' of 'f'"},
+		{"local-macro", `(do (defmacro twice (fn [x] (list '+ x x))) (defn f [^int a] a) (f (twice 1)) (f (str (twice 1))))`, "argument 1 of 'f' is string, expected int"},
+		{"self-expanding-macro", `(do (defmacro m (fn [] '(m))) (m))`, ""},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			findings := check(t, testCase.source)
+			if got := messages(findings); got != testCase.messages {
+				t.Fatalf("expected %q got %q", testCase.messages, got)
+			}
+			for _, f := range findings {
+				if f.Rule != RuleType || f.File != t.Name() {
+					t.Fatalf("unexpected finding %v", f)
+				}
+			}
+		})
+	}
+}
+
+func TestAssertions(t *testing.T) {
+	ns := newEnv(t)
+	if err := EnableAssertions(ns); err != nil {
+		t.Fatal(err)
+	}
+	_, err := lisp.REPL(context.Background(), ns, `(defn f ^int [^int a ^{:schema [:vector :int]} b & more] (if (= a 0) "zero" (+ a (count b))))`, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// tail calls are still optimised when the result is asserted
+	_, err = lisp.REPL(context.Background(), ns, `(defn g [^int a & ^{:schema [:sequential :int]} more] a)`, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = lisp.REPL(context.Background(), ns, `(defn count-down ^int [^int n ^int acc] (if (< n 0) "negative" (if (= n 0) acc (count-down (- n 1) (+ acc 1)))))`, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, testCase := range []struct {
+		call string
+		err  string
+	}{
+		{`(f 1 [2] "x")`, ""},
+		{`(f "1" [2])`, "argument 1 (a) of 'f' is string, expected int"},
+		{`(f 1 ["2"])`, "argument 2 (b) of 'f' does not conform to its schema: 1:6: [0]: expected int, got string"},
+		{`(f 0 [])`, "result of 'f' is string, expected int"},
+		{`(count-down 100000 0)`, ""},
+		{`(count-down -1 0)`, "result of 'count-down' is string, expected int"},
+		{`(g 1 2 3)`, ""},
+		{`(g 1)`, ""},
+		{`(g 1 :a)`, "argument 2 (more) of 'g' does not conform to its schema"},
+		{`(map (fn [x] (f x [])) ["1"])`, "argument 1 (a) of 'f' is string, expected int"},
+	} {
+		_, err := lisp.REPL(context.Background(), ns, testCase.call, nil)
+		switch {
+		case testCase.err == "" && err != nil:
+			t.Errorf("%s: unexpected error %s", testCase.call, err)
+		case testCase.err != "" && (err == nil || !strings.Contains(err.Error(), testCase.err)):
+			t.Errorf("%s: expected error %q, got %v", testCase.call, testCase.err, err)
+		}
+	}
+}
+
+func TestAssertionPosition(t *testing.T) {
+	ns := newEnv(t)
+	if err := EnableAssertions(ns); err != nil {
+		t.Fatal(err)
+	}
+	// as other runtime errors, the mismatches point to the calls
+	_, err := lisp.REPL(context.Background(), ns, "(do\n  (defn add ^int [^int a ^int b] (+ a b))\n  (add 1 \"x\"))", types.NewCursorFile("t.lisp"))
+	if err == nil || !strings.HasPrefix(err.Error(), "t.lisp:3:") {
+		t.Fatalf("expected an error on the call, got %v", err)
+	}
+}
+
+// repoFiles returns the Lisp files of the repository
+func repoFiles(t *testing.T) []string {
+	var files []string
+	err := filepath.WalkDir("..", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && strings.HasPrefix(d.Name(), ".") && path != ".." {
+			return filepath.SkipDir
+		}
+		if !d.IsDir() && strings.HasSuffix(path, ".lisp") {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+func TestCheckRepoFiles(t *testing.T) {
+	// must not panic on any of them (e.g. on the placeholders of their preambles)
+	for _, fileName := range repoFiles(t) {
+		if _, err := CheckFile(fileName, newEnv(t)); err != nil {
+			t.Errorf("%s: %s", fileName, err)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package types
+
+import (
+	"context"
+	"fmt"
+)
+
+// Callable is implemented by the values that are called as functions without being
+// functions: keywords, hash maps and sets look up their argument and vectors their
+// index, with an optional default value as second argument
+//
+//	(:port cfg)        (cfg :port)        (:port cfg 8080)
+//	(#{:a :b} x)       ([10 20 30] 1)
+type Callable interface {
+	Call(ctx context.Context, args []MalType) (MalType, error)
+}
+
+// Callable_Q reports whether obj can be called as a function: functions and [Callable] values
+func Callable_Q(obj MalType) bool {
+	switch obj.(type) {
+	case MalFunc, Func, func([]MalType) (MalType, error), Callable:
+		return true
+	default:
+		return false
+	}
+}
+
+// lookupArgs returns the argument and the default value of a call to a Callable
+func lookupArgs(self MalType, args []MalType) (MalType, MalType, error) {
+	switch len(args) {
+	case 1:
+		return args[0], nil, nil
+	case 2:
+		return args[0], args[1], nil
+	default:
+		return nil, nil, fmt.Errorf("wrong number of arguments (%d) passed to %s (expected 1 or 2)", len(args), describe(self))
+	}
+}
+
+func describe(self MalType) string {
+	switch self := self.(type) {
+	case Keyword:
+		return "keyword " + self.String()
+	case HashMap:
+		return "hash-map"
+	case Set:
+		return "set"
+	case Vector:
+		return "vector"
+	default:
+		return fmt.Sprintf("%T", self)
+	}
+}
+
+// Call looks up the keyword on a hash map or a set (nil or the default if not found
+// or if the argument is neither)
+func (k Keyword) Call(ctx context.Context, args []MalType) (MalType, error) {
+	coll, def, err := lookupArgs(k, args)
+	if err != nil {
+		return nil, err
+	}
+	switch coll := coll.(type) {
+	case HashMap:
+		return coll.Call(ctx, append([]MalType{k}, args[1:]...))
+	case Set:
+		return coll.Call(ctx, append([]MalType{k}, args[1:]...))
+	default:
+		return def, nil
+	}
+}
+
+// Call returns the value of the key (nil or the default if not found or if the key
+// cannot be a map key)
+func (hm HashMap) Call(ctx context.Context, args []MalType) (MalType, error) {
+	key, def, err := lookupArgs(hm, args)
+	if err != nil {
+		return nil, err
+	}
+	if !HashKey_Q(key) {
+		return def, nil
+	}
+	if value, ok := hm.Val[key]; ok {
+		return value, nil
+	}
+	return def, nil
+}
+
+// Call returns the item if it belongs to the set (nil or the default if not or if the
+// item cannot be a set item)
+func (s Set) Call(ctx context.Context, args []MalType) (MalType, error) {
+	item, def, err := lookupArgs(s, args)
+	if err != nil {
+		return nil, err
+	}
+	if !HashKey_Q(item) {
+		return def, nil
+	}
+	if _, ok := s.Val[item]; ok {
+		return item, nil
+	}
+	return def, nil
+}
+
+// Call returns the item at the index; an index out of bounds is an error unless a
+// default is given
+func (v Vector) Call(ctx context.Context, args []MalType) (MalType, error) {
+	index, def, err := lookupArgs(v, args)
+	if err != nil {
+		return nil, err
+	}
+	i, ok := index.(int)
+	if !ok {
+		return nil, fmt.Errorf("vector called with an index that is not an int (%T)", index)
+	}
+	if i < 0 || i >= len(v.Val) {
+		if len(args) == 2 {
+			return def, nil
+		}
+		return nil, fmt.Errorf("vector index %d out of bounds (count %d)", i, len(v.Val))
+	}
+	return v.Val[i], nil
+}
@@ -0,0 +1,152 @@
+package types
+
+import (
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Keyword is a Lisp keyword (:name). Keywords are interned: keywords with the same name
+// are equal (==), so they compare as fast as pointers and are hash map keys and set
+// items as strings are.
+type Keyword struct {
+	name *string // nil on the keyword of empty name
+}
+
+var keywords sync.Map // name → Keyword
+
+// NewKeyword returns the keyword of name (without the colon)
+func NewKeyword(name string) Keyword {
+	if name == "" {
+		return Keyword{}
+	}
+	if k, ok := keywords.Load(name); ok {
+		return k.(Keyword)
+	}
+	k, _ := keywords.LoadOrStore(name, Keyword{name: &name})
+	return k.(Keyword)
+}
+
+// Name returns the name of the keyword (without the colon)
+func (k Keyword) Name() string {
+	if k.name == nil {
+		return ""
+	}
+	return *k.name
+}
+
+// String returns the keyword as written on Lisp (e.g. :name)
+func (k Keyword) String() string {
+	return ":" + k.Name()
+}
+
+// MarshalText encodes keywords by their names (e.g. on JSON)
+func (k Keyword) MarshalText() ([]byte, error) {
+	return []byte(k.Name()), nil
+}
+
+func Keyword_Q(obj MalType) bool {
+	return Q[Keyword](obj)
+}
+
+func String_Q(obj MalType) bool {
+	return Q[string](obj)
+}
+
+// HashKey_Q reports whether obj can be a hash map key or a set item
+func HashKey_Q(obj MalType) bool {
+	return Q[string](obj) || Q[Keyword](obj)
+}
+
+// KeyName returns the name of a hash map key: the string or the name of the keyword
+func KeyName(key MalType) string {
+	switch key := key.(type) {
+	case Keyword:
+		return key.Name()
+	case string:
+		return key
+	default:
+		return ""
+	}
+}
+
+// SortedKeys returns the keys of a hash map or the items of a set sorted: strings first,
+// then keywords, each sorted by name
+func SortedKeys[V any](m map[MalType]V) []MalType {
+	keys := make([]MalType, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	slices.SortFunc(keys, func(a, b MalType) int {
+		if ka, kb := Keyword_Q(a), Keyword_Q(b); ka != kb {
+			if ka {
+				return 1
+			}
+			return -1
+		}
+		return strings.Compare(KeyName(a), KeyName(b))
+	})
+	return keys
+}
+
+// Compatibility with the encoding of keywords of previous versions, as strings prefixed
+// with LegacyKeywordPrefix. Embedders building Lisp values with that encoding convert
+// them with FromLegacy or LegacyHashMap.
+
+// LegacyKeywordPrefix prefixes the names of keywords encoded as strings
+//
+// Deprecated: use NewKeyword and Keyword.
+const LegacyKeywordPrefix = "ʞ"
+
+// FromLegacy returns v with its strings prefixed with LegacyKeywordPrefix converted to
+// keywords, including the items of lists, vectors and sets and the keys and values of
+// hash maps
+func FromLegacy(v MalType) MalType {
+	switch v := v.(type) {
+	case string:
+		if name, ok := strings.CutPrefix(v, LegacyKeywordPrefix); ok {
+			return NewKeyword(name)
+		}
+		return v
+	case List:
+		v.Val = fromLegacySlice(v.Val)
+		return v
+	case Vector:
+		v.Val = fromLegacySlice(v.Val)
+		return v
+	case HashMap:
+		m := make(map[MalType]MalType, len(v.Val))
+		for key, value := range v.Val {
+			m[FromLegacy(key)] = FromLegacy(value)
+		}
+		v.Val = m
+		return v
+	case Set:
+		m := make(map[MalType]struct{}, len(v.Val))
+		for item := range v.Val {
+			m[FromLegacy(item)] = struct{}{}
+		}
+		v.Val = m
+		return v
+	default:
+		return v
+	}
+}
+
+func fromLegacySlice(slc []MalType) []MalType {
+	result := make([]MalType, len(slc))
+	for i, item := range slc {
+		result[i] = FromLegacy(item)
+	}
+	return result
+}
+
+// LegacyHashMap returns the hash map of the string keyed map m (as the Val of hash maps
+// of previous versions), converted with FromLegacy
+func LegacyHashMap(m map[string]MalType) HashMap {
+	hm := HashMap{Val: make(map[MalType]MalType, len(m))}
+	for key, value := range m {
+		hm.Val[FromLegacy(key)] = FromLegacy(value)
+	}
+	return hm
+}
@@ -0,0 +1,87 @@
+package types
+
+import "sync"
+
+// LazySeq is a sequence whose elements are produced on demand (e.g. received from a Go
+// channel or pulled from a Go iterator). Realized elements are kept, so a LazySeq can be
+// walked many times and by many goroutines. The zero LazySeq is empty.
+type LazySeq struct {
+	cell *lazyCell
+}
+
+// lazyCell is a node of a LazySeq, realized once by calling next
+type lazyCell struct {
+	once  sync.Once
+	next  func() (MalType, bool)
+	first MalType
+	rest  *lazyCell // nil if the sequence ends here
+}
+
+// NewLazySeq returns a sequence of the values returned by next until it returns false.
+// next is called at most once per element and never concurrently.
+func NewLazySeq(next func() (MalType, bool)) LazySeq {
+	return LazySeq{cell: &lazyCell{next: next}}
+}
+
+func (c *lazyCell) realize() *lazyCell {
+	c.once.Do(func() {
+		if v, ok := c.next(); ok {
+			c.first = v
+			c.rest = &lazyCell{next: c.next}
+		}
+		c.next = nil
+	})
+	return c
+}
+
+// First returns the first element of the sequence, false if it is empty
+func (s LazySeq) First() (MalType, bool) {
+	if s.cell == nil || s.cell.realize().rest == nil {
+		return nil, false
+	}
+	return s.cell.first, true
+}
+
+// Rest returns the sequence without its first element
+func (s LazySeq) Rest() LazySeq {
+	if s.cell == nil {
+		return s
+	}
+	return LazySeq{cell: s.cell.realize().rest}
+}
+
+// Empty reports whether the sequence has no elements (realizing the first one)
+func (s LazySeq) Empty() bool {
+	_, ok := s.First()
+	return !ok
+}
+
+// Take returns the first n elements of the sequence (less if it is shorter)
+func (s LazySeq) Take(n int) []MalType {
+	elems := []MalType{}
+	for ; len(elems) < n; s = s.Rest() {
+		v, ok := s.First()
+		if !ok {
+			break
+		}
+		elems = append(elems, v)
+	}
+	return elems
+}
+
+// All realizes the whole sequence and returns its elements. It does not return if the
+// sequence is infinite.
+func (s LazySeq) All() []MalType {
+	elems := []MalType{}
+	for ; ; s = s.Rest() {
+		v, ok := s.First()
+		if !ok {
+			return elems
+		}
+		elems = append(elems, v)
+	}
+}
+
+func (s LazySeq) Type() string {
+	return "lazy-seq"
+}
@@ -0,0 +1,95 @@
+package types
+
+import "errors"
+
+// Metadata is implemented by the values defined out of this package that hold metadata
+// (e.g. multimethods)
+type Metadata interface {
+	Meta() MalType
+	WithMeta(meta MalType) MalType
+}
+
+// MetaOf returns the metadata of obj (nil if none)
+func MetaOf(obj MalType) (MalType, error) {
+	switch obj := obj.(type) {
+	case List:
+		return obj.Meta, nil
+	case Vector:
+		return obj.Meta, nil
+	case HashMap:
+		return obj.Meta, nil
+	case Set:
+		return obj.Meta, nil
+	case Func:
+		return obj.Meta, nil
+	case MalFunc:
+		return obj.Meta, nil
+	case Metadata:
+		return obj.Meta(), nil
+	default:
+		return nil, errors.New("meta not supported on type")
+	}
+}
+
+// WithMeta returns a copy of obj with meta as its metadata (keeping its position and,
+// on records, its record type)
+func WithMeta(obj, meta MalType) (MalType, error) {
+	switch obj := obj.(type) {
+	case List:
+		obj.Meta = meta
+		return obj, nil
+	case Vector:
+		obj.Meta = meta
+		return obj, nil
+	case HashMap:
+		obj.Meta = meta
+		return obj, nil
+	case Set:
+		obj.Meta = meta
+		return obj, nil
+	case Func:
+		obj.Meta = meta
+		return obj, nil
+	case MalFunc:
+		obj.Meta = meta
+		return obj, nil
+	case Metadata:
+		return obj.WithMeta(meta), nil
+	default:
+		return nil, errors.New("with-meta not supported on type")
+	}
+}
+
+// VaryMeta returns a copy of obj with the entries of meta added to its metadata (which
+// is replaced if it is not a map)
+func VaryMeta(obj MalType, meta HashMap) (MalType, error) {
+	current, err := MetaOf(obj)
+	if err != nil {
+		return nil, err
+	}
+	merged := HashMap{Val: map[MalType]MalType{}}
+	if current, ok := current.(HashMap); ok {
+		for k, v := range current.Val {
+			merged.Val[k] = v
+		}
+	}
+	for k, v := range meta.Val {
+		merged.Val[k] = v
+	}
+	return WithMeta(obj, merged)
+}
+
+// Doc returns the docstring of obj: the :doc metadata of Lisp values or the
+// documentation of Go functions registered with lib/call ("" if none)
+func Doc(obj MalType) string {
+	if fn, ok := obj.(Func); ok && fn.Signature != nil && fn.Signature.Doc != "" {
+		return fn.Signature.Doc
+	}
+	meta, _ := MetaOf(obj)
+	if meta, ok := meta.(HashMap); ok {
+		if doc, ok := meta.Val[NewKeyword("doc")].(string); ok {
+			return doc
+		}
+	}
+	return ""
+}
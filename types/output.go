@@ -0,0 +1,47 @@
+package types
+
+import (
+	"context"
+	"io"
+	"os"
+)
+
+type outputKey struct{}
+
+type output struct {
+	stdout, stderr io.Writer
+}
+
+// WithOutput returns a context that redirects the output of the Lisp code evaluated with
+// it (prn, println...) to stdout and stderr. A nil writer keeps the one of ctx.
+func WithOutput(ctx context.Context, stdout, stderr io.Writer) context.Context {
+	if stdout == nil {
+		stdout = Stdout(ctx)
+	}
+	if stderr == nil {
+		stderr = Stderr(ctx)
+	}
+	return context.WithValue(ctx, outputKey{}, output{stdout: stdout, stderr: stderr})
+}
+
+// Stdout returns the standard output of the Lisp code evaluated with ctx (os.Stdout
+// unless redirected with WithOutput)
+func Stdout(ctx context.Context) io.Writer {
+	if ctx != nil {
+		if out, ok := ctx.Value(outputKey{}).(output); ok {
+			return out.stdout
+		}
+	}
+	return os.Stdout
+}
+
+// Stderr returns the standard error of the Lisp code evaluated with ctx (os.Stderr
+// unless redirected with WithOutput)
+func Stderr(ctx context.Context) io.Writer {
+	if ctx != nil {
+		if out, ok := ctx.Value(outputKey{}).(output); ok {
+			return out.stderr
+		}
+	}
+	return os.Stderr
+}
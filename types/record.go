@@ -0,0 +1,57 @@
+package types
+
+import (
+	"fmt"
+	"slices"
+)
+
+// RecordType is a type of records defined with defrecord: hash maps (with the Record of
+// their type set) of declared fields (keywords), all present, that report the name of
+// their type through type?
+type RecordType struct {
+	Name   string
+	Fields []Keyword
+}
+
+// NewRecordType returns the record type name of the fields
+func NewRecordType(name string, fields ...Keyword) *RecordType {
+	return &RecordType{Name: name, Fields: fields}
+}
+
+func (rt *RecordType) Type() string {
+	return "record-type"
+}
+
+func (rt *RecordType) LispPrint(_ func(MalType, bool) string) string {
+	return "«record-type " + rt.Name + "»"
+}
+
+// HasField reports whether key is a field of the record type
+func (rt *RecordType) HasField(key MalType) bool {
+	k, ok := key.(Keyword)
+	return ok && slices.Contains(rt.Fields, k)
+}
+
+// New returns the record of the fields of m (nil the missing ones). Keys that are not
+// fields are errors.
+func (rt *RecordType) New(m HashMap) (HashMap, error) {
+	record := HashMap{Val: make(map[MalType]MalType, len(rt.Fields)), Record: rt}
+	for _, field := range rt.Fields {
+		record.Val[field] = nil
+	}
+	for _, key := range SortedKeys(m.Val) {
+		if !rt.HasField(key) {
+			return HashMap{}, rt.FieldError(key)
+		}
+		record.Val[key] = m.Val[key]
+	}
+	return record, nil
+}
+
+// FieldError returns the error of a key that is not a field of the record type
+func (rt *RecordType) FieldError(key MalType) error {
+	if k, ok := key.(Keyword); ok {
+		return fmt.Errorf("record %s has no field %s", rt.Name, k)
+	}
+	return fmt.Errorf("record %s has no field %q", rt.Name, key)
+}
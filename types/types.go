@@ -62,27 +62,69 @@ type Symbol struct {
 	Cursor *Position
 }
 
-// Keywords
-func NewKeyword(s string) string {
-	return "\u029e" + s
-}
-
-func Keyword_Q(obj MalType) bool {
-	return Q[string](obj) && strings.HasPrefix(obj.(string), "\u029e")
-}
-
-func String_Q(obj MalType) bool {
-	return Q[string](obj) && !strings.HasPrefix(obj.(string), "\u029e")
-}
-
 type ExternalCall func(context.Context, []MalType) (MalType, error)
 
 // Functions
 type Func struct {
 	// Fn     func(context.Context, []MalType) (MalType, error)
-	Fn     ExternalCall
-	Meta   MalType
-	Cursor *Position
+	Fn        ExternalCall
+	Meta      MalType
+	Cursor    *Position
+	Signature *Signature // nil if not registered with lib/call
+}
+
+// InteropSymbol is the symbol of the [Interop] used by EVAL to evaluate
+// (.Method obj args...) and (.-Field obj)
+const InteropSymbol = "_INTEROP_"
+
+// Interop calls methods and reads fields of Go values (see call.Register). member is
+// the name of the method, or the name of the field prefixed with "-".
+type Interop interface {
+	Invoke(ctx context.Context, member string, obj MalType, args []MalType) (MalType, error)
+}
+
+// UnlimitedArgs is the Signature.MaxArgs of functions without a maximum argument count
+const UnlimitedArgs = -1
+
+// Signature describes a Go function registered with lib/call, as seen from Lisp.
+// The context.Context parameter, if any, is not included.
+type Signature struct {
+	Name     string         // Lisp name
+	Package  string         // Go package name (as registered on _PACKAGES_)
+	GoName   string         // Go name as reported by the runtime
+	MinArgs  int            // minimum argument count
+	MaxArgs  int            // maximum argument count (UnlimitedArgs if variadic without maximum)
+	In       []reflect.Type // parameter types (the last one is a slice if Variadic)
+	Out      []reflect.Type // result types
+	Variadic bool
+	Arglist  Vector // parameter names derived from In (e.g. [s n & xs])
+	Doc      string // docstring (see lib/call.Doc)
+}
+
+// GoType formats the signature as a Go function type (e.g. "func(int, int) (int, error)")
+func (s *Signature) GoType() string {
+	in := make([]string, len(s.In))
+	for i, t := range s.In {
+		if s.Variadic && i == len(s.In)-1 {
+			in[i] = "..." + t.Elem().String()
+		} else {
+			in[i] = t.String()
+		}
+	}
+	out := make([]string, len(s.Out))
+	for i, t := range s.Out {
+		out[i] = t.String()
+	}
+	results := strings.Join(out, ", ")
+	if len(out) > 1 {
+		results = "(" + results + ")"
+	}
+	return fmt.Sprintf("func(%s) %s", strings.Join(in, ", "), results)
+}
+
+// AcceptsArgs returns true if n arguments are within the argument count bounds
+func (s *Signature) AcceptsArgs(n int) bool {
+	return n >= s.MinArgs && (s.MaxArgs == UnlimitedArgs || n <= s.MaxArgs)
 }
 
 type MalFunc struct {
@@ -94,6 +136,22 @@ type MalFunc struct {
 	GenEnv  func(EnvType, MalType, MalType) (EnvType, error)
 	Meta    MalType
 	Cursor  *Position
+	Returns *ReturnCheck // checks the results of the calls (nil if none)
+	Args    *ArgsCheck   // checks the arguments of the calls (nil if none)
+}
+
+// ArgsCheck checks the arguments of the calls of a MalFunc (e.g. asserting the type
+// hints of defn) before they are bound. Its errors are reported at the call, as the
+// ones of ReturnCheck.
+type ArgsCheck struct {
+	Check func(ctx context.Context, args []MalType) error
+}
+
+// ReturnCheck checks the results of the calls of a MalFunc (e.g. asserting the type
+// hint of defn), returning the result or an error. It is run when the call returns, so
+// tail calls of the function are still optimised.
+type ReturnCheck struct {
+	Check func(ctx context.Context, result MalType) (MalType, error)
 }
 
 func (f MalFunc) SetMacro() MalType {
@@ -110,6 +168,11 @@ func (f MalFunc) GetMacro() bool {
 func Apply(ctx context.Context, f_mt MalType, a []MalType) (MalType, error) {
 	switch f := f_mt.(type) {
 	case MalFunc:
+		if f.Args != nil {
+			if e := f.Args.Check(ctx, a); e != nil {
+				return nil, e
+			}
+		}
 		env, e := f.GenEnv(f.Env, f.Params, List{
 			Val:    a,
 			Cursor: f.Cursor,
@@ -117,11 +180,17 @@ func Apply(ctx context.Context, f_mt MalType, a []MalType) (MalType, error) {
 		if e != nil {
 			return nil, e
 		}
-		return f.Eval(ctx, f.Exp, env)
+		result, e := f.Eval(ctx, f.Exp, env)
+		if e != nil || f.Returns == nil {
+			return result, e
+		}
+		return f.Returns.Check(ctx, result)
 	case Func:
 		return f.Fn(ctx, a)
 	case func([]MalType) (MalType, error):
 		return f(a)
+	case Callable:
+		return f.Call(ctx, a)
 	default:
 		return nil, fmt.Errorf("invalid function to Apply (%T)", f)
 	}
@@ -151,6 +220,8 @@ func GetSlice(seq MalType) ([]MalType, error) {
 		return seq.Val, nil
 	case Vector:
 		return seq.Val, nil
+	case LazySeq:
+		return seq.All(), nil
 	default:
 		return nil, errors.New("GetSlice called on non-sequence")
 	}
@@ -158,9 +229,10 @@ func GetSlice(seq MalType) ([]MalType, error) {
 
 // Hash Maps
 type HashMap struct {
-	Val    map[string]MalType
+	Val    map[MalType]MalType // keys are strings or keywords
 	Meta   MalType
 	Cursor *Position
+	Record *RecordType // type of the records (nil on other hash maps)
 }
 
 func NewHashMap(cursor *Position, seq MalType) (MalType, error) {
@@ -171,20 +243,19 @@ func NewHashMap(cursor *Position, seq MalType) (MalType, error) {
 	if len(lst)%2 == 1 {
 		return nil, errors.New("odd number of arguments to NewHashMap")
 	}
-	m := map[string]MalType{}
+	m := map[MalType]MalType{}
 	for i := 0; i < len(lst); i += 2 {
-		str, ok := lst[i].(string)
-		if !ok {
-			return nil, fmt.Errorf("expected hash-map key string (found %T)", lst[i])
+		if !HashKey_Q(lst[i]) {
+			return nil, fmt.Errorf("expected hash-map key string or keyword (found %T)", lst[i])
 		}
-		m[str] = lst[i+1]
+		m[lst[i]] = lst[i+1]
 	}
 	return HashMap{Val: m, Cursor: cursor}, nil
 }
 
 // Sets
 type Set struct {
-	Val    map[string]struct{}
+	Val    map[MalType]struct{} // items are strings or keywords
 	Meta   MalType
 	Cursor *Position
 }
@@ -199,13 +270,12 @@ func NewSet(seq MalType) (Set, error) {
 		return Set{}, e
 	}
 
-	m := map[string]struct{}{}
+	m := map[MalType]struct{}{}
 	for _, item := range lst {
-		sItem, ok := item.(string)
-		if !ok {
+		if !HashKey_Q(item) {
 			return Set{}, errors.New("set items must be strings or keywords")
 		}
-		m[sItem] = struct{}{}
+		m[item] = struct{}{}
 	}
 	return Set{Val: m}, nil
 }
@@ -225,7 +295,8 @@ func Sequential_Q(seq MalType) bool {
 		return false
 	}
 	return (reflect.TypeOf(seq).Name() == "List") ||
-		(reflect.TypeOf(seq).Name() == "Vector")
+		(reflect.TypeOf(seq).Name() == "Vector") ||
+		(reflect.TypeOf(seq).Name() == "LazySeq")
 }
 
 func Equal_Q(a, b MalType) bool {
@@ -240,7 +311,7 @@ func Equal_Q(a, b MalType) bool {
 	switch a.(type) {
 	case Symbol:
 		return a.(Symbol).Val == b.(Symbol).Val
-	case List:
+	case List, LazySeq:
 		as, _ := GetSlice(a)
 		bs, _ := GetSlice(b)
 		if len(as) != len(bs) {
@@ -265,6 +336,9 @@ func Equal_Q(a, b MalType) bool {
 		}
 		return true
 	case HashMap:
+		if a.(HashMap).Record != b.(HashMap).Record {
+			return false
+		}
 		am := a.(HashMap).Val
 		bm := b.(HashMap).Val
 		if len(am) != len(bm) {
@@ -293,8 +367,19 @@ func Equal_Q(a, b MalType) bool {
 	}
 }
 
+// MarshalJSON encodes hash maps as JSON objects named by the keys (keywords by their
+// names). A string and a keyword of the same name are an error, as they would be the
+// same name.
 func (hm HashMap) MarshalJSON() ([]byte, error) {
-	return json.Marshal(hm.Val)
+	m := make(map[string]MalType, len(hm.Val))
+	for key, value := range hm.Val {
+		name := KeyName(key)
+		if _, ok := m[name]; ok {
+			return nil, fmt.Errorf("keys %q and :%s are both encoded as the JSON name %q", name, name, name)
+		}
+		m[name] = value
+	}
+	return json.Marshal(m)
 }
 
 func (v Vector) MarshalJSON() ([]byte, error) {
@@ -325,6 +410,8 @@ func ConvertFrom(from MalType) ([]MalType, MalType, error) {
 		return from.Val, from.Meta, nil
 	case Vector:
 		return from.Val, from.Meta, nil
+	case LazySeq:
+		return from.All(), nil, nil
 	default:
 		return nil, nil, fmt.Errorf("cannot convert from type %T", from)
 	}
@@ -333,9 +420,9 @@ func ConvertFrom(from MalType) ([]MalType, MalType, error) {
 func ConvertTo(from []MalType, _to MalType, meta MalType) (MalType, error) {
 	switch _to.(type) {
 	case Set:
-		to := Set{Val: map[string]struct{}{}}
+		to := Set{Val: map[MalType]struct{}{}}
 		for _, k := range from {
-			to.Val[k.(string)] = struct{}{}
+			to.Val[k] = struct{}{}
 		}
 		return to, nil
 	case List:
@@ -368,3 +455,45 @@ type Placeholder struct {
 type Typed interface {
 	Type() string
 }
+
+// TypeName returns the name of the type of obj as (type? obj) does: the Lisp name of the
+// built-in types (e.g. "hash-map") and Type() of Typed values
+func TypeName(obj MalType) string {
+	switch obj := obj.(type) {
+	case nil:
+		return "nil"
+	case List:
+		return "list"
+	case HashMap:
+		if obj.Record != nil {
+			return obj.Record.Name
+		}
+		return "hash-map"
+	case Vector:
+		return "vector"
+	case Set:
+		return "set"
+	case int:
+		return "integer"
+	case bool:
+		return "boolean"
+	case Symbol:
+		return "symbol"
+	case string:
+		return "string"
+	case Keyword:
+		return "keyword"
+	case MalFunc:
+		return "function"
+	case interface{ ErrorValue() MalType }:
+		return "error"
+	case Typed:
+		return obj.Type()
+	case error:
+		return "go-error"
+	case Func:
+		return "go-function"
+	default:
+		return fmt.Sprintf("unsupported(%T)", obj)
+	}
+}
@@ -0,0 +1,95 @@
+package lisp
+
+import (
+	"io"
+
+	"github.com/jig/lisp/bind"
+	"github.com/jig/lisp/reader"
+	. "github.com/jig/lisp/types"
+)
+
+// Unmarshaler is implemented by types that convert themselves from Lisp values
+type Unmarshaler = bind.Unmarshaler
+
+// Unmarshal stores the Lisp value data (e.g. a configuration file read by [READ]) in the
+// value pointed by v, in the spirit of encoding/json. Structs are read from hash maps
+// keyed by keywords as set by the lisp struct tags (see package bind for the conversion
+// rules and the default tag option). Keys without a matching field are ignored.
+//
+// Errors are *bind.Error and include the path of the value and the position of the
+// collection that contains it:
+//
+//	config.lisp:12:5: .server.port: expected int, got string
+func Unmarshal(data MalType, v any) error {
+	return bind.FromLisp(data, v, bind.Lenient)
+}
+
+// Decoder reads Lisp values from an input stream and stores them in Go values
+type Decoder struct {
+	r            io.Reader
+	module       *string
+	mode         bind.Mode
+	forms        []*reader.Node
+	placeholders *HashMap
+	err          error
+	read         bool
+}
+
+// NewDecoder returns a decoder that reads from r. If r has a Name method (as *os.File)
+// the name is used as the module of the positions of the errors.
+func NewDecoder(r io.Reader) *Decoder {
+	d := &Decoder{r: r}
+	if named, ok := r.(interface{ Name() string }); ok {
+		module := named.Name()
+		d.module = &module
+	}
+	return d
+}
+
+// DisallowUnknownFields makes Decode fail on hash map keys without a matching struct field
+func (d *Decoder) DisallowUnknownFields() {
+	d.mode = bind.Strict
+}
+
+// Decode reads the next Lisp form of the input and stores it in the value pointed by v
+// as [Unmarshal] does. Forms are not evaluated. Placeholders of the preamble of the input
+// are filled (see [READWithPreamble]). It returns io.EOF when there are no more forms.
+func (d *Decoder) Decode(v any) error {
+	if !d.read {
+		d.read = true
+		d.err = d.readAll()
+	}
+	if d.err != nil {
+		return d.err
+	}
+	if len(d.forms) == 0 {
+		return io.EOF
+	}
+	form := d.forms[0]
+	d.forms = d.forms[1:]
+	data, err := form.AST(d.placeholders, nil)
+	if err != nil {
+		return err
+	}
+	return bind.FromLisp(data, v, d.mode)
+}
+
+func (d *Decoder) readAll() error {
+	source, err := io.ReadAll(d.r)
+	if err != nil {
+		return err
+	}
+	var cursor *Position
+	if d.module != nil {
+		cursor = NewCursorFile(*d.module)
+	}
+	cst, err := reader.ReadCST(string(source), cursor)
+	if err != nil {
+		return err
+	}
+	if d.placeholders, err = cst.Placeholders(nil); err != nil {
+		return err
+	}
+	d.forms = cst.Forms()
+	return nil
+}
@@ -0,0 +1,136 @@
+package lisp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jig/lisp/bind"
+	"github.com/jig/lisp/types"
+)
+
+type serverConfig struct {
+	Host    string        `lisp:"host,default=\"localhost\""`
+	Port    int           `lisp:"port,default=8080"`
+	Timeout time.Duration `lisp:"timeout,default=\"5s\""`
+}
+
+type config struct {
+	Name   string       `lisp:"name"`
+	Server serverConfig `lisp:"server"`
+	Admins []string     `lisp:"admins,omitempty"`
+}
+
+// UnmarshalLisp reads a duration from a string (e.g. "5s") or from seconds
+func (d *duration) UnmarshalLisp(v types.MalType) error {
+	switch v := v.(type) {
+	case int:
+		*d = duration(time.Duration(v) * time.Second)
+	case string:
+		parsed, err := time.ParseDuration(v)
+		if err != nil {
+			return err
+		}
+		*d = duration(parsed)
+	default:
+		return fmt.Errorf("expected duration, got %T", v)
+	}
+	return nil
+}
+
+type duration time.Duration
+
+func TestUnmarshal(t *testing.T) {
+	ast, err := READ(`{:name "main" :server {:port 9090} :other 1}`, types.NewCursorFile("config.lisp"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cfg config
+	if err := Unmarshal(ast, &cfg); err != nil {
+		t.Fatal(err)
+	}
+	expected := serverConfig{Host: "localhost", Port: 9090, Timeout: 5 * time.Second}
+	if cfg.Name != "main" || cfg.Server != expected {
+		t.Fatalf("unexpected %+v", cfg)
+	}
+}
+
+func TestUnmarshalHook(t *testing.T) {
+	var timeouts map[string]duration
+	ast, err := READ(`{:read 3 :write "1m"}`, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Unmarshal(ast, &timeouts); err != nil {
+		t.Fatal(err)
+	}
+	if timeouts["read"] != duration(3*time.Second) || timeouts["write"] != duration(time.Minute) {
+		t.Fatalf("unexpected %v", timeouts)
+	}
+
+	ast, err = READ(`{:read :never}`, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = Unmarshal(ast, &timeouts)
+	if err == nil || !strings.Contains(err.Error(), `["read"]: expected duration, got types.Keyword`) {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestDecoder(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "config.lisp")
+	source := `;; $PORT 7070
+{:name "a"
+ :server {:port $PORT}}
+{:name "b"
+ :server {:host "example.com"
+          :port "80"}}
+`
+	if err := os.WriteFile(fileName, []byte(source), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	decoder := NewDecoder(f)
+	var cfg config
+	if err := decoder.Decode(&cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Name != "a" || cfg.Server.Port != 7070 || cfg.Server.Host != "localhost" {
+		t.Fatalf("unexpected %+v", cfg)
+	}
+
+	err = decoder.Decode(&cfg)
+	var bindErr *bind.Error
+	if !errors.As(err, &bindErr) {
+		t.Fatalf("expected a *bind.Error, got %v", err)
+	}
+	expected := fileName + ":5:10: .server.port: expected int, got string"
+	if err.Error() != expected {
+		t.Fatalf("got %q, expected %q", err, expected)
+	}
+
+	if err := decoder.Decode(&cfg); err != io.EOF {
+		t.Fatalf("expected io.EOF, got %v", err)
+	}
+}
+
+func TestDecoderDisallowUnknownFields(t *testing.T) {
+	decoder := NewDecoder(strings.NewReader(`{:name "a" :nmae "b"}`))
+	decoder.DisallowUnknownFields()
+	var cfg config
+	err := decoder.Decode(&cfg)
+	if err == nil || err.Error() != "1:1: unknown key :nmae" {
+		t.Fatalf("unexpected error %v", err)
+	}
+}